@@ -1,10 +1,18 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
 	"time"
 
+	"github.com/tristanpenman/go-cast/internal/client"
 	"github.com/tristanpenman/go-cast/internal/discovery"
 )
 
@@ -12,19 +20,202 @@ var log = slog.Default()
 
 func main() {
 	timeout := flag.Duration("timeout", 10*time.Second, "how long to search for Cast devices")
+	watch := flag.Bool("watch", false, "keep watching for devices being added, updated and removed, until interrupted")
+	iface := flag.String("iface", "", "network interface name or local address to search on (optional)")
+	ipv6 := flag.Bool("ipv6", false, "also search over IPv6, and report IPv6 addresses")
+	members := flag.Bool("members", false, "connect to each speaker group found, and print its members")
+	enrich := flag.Bool("enrich", false, "connect to each device found, and query it for its details")
+	deviceAuth := flag.Bool("device-auth", true, "authenticate devices when enriching them")
+	storePath := flag.String("store", defaultStorePath(), "file in which known devices are kept, or empty to not keep them")
+	addHost := flag.String("add-host", "", "add a device that discovery does not find, as host[:port] (optional)")
+	favorite := flag.String("favorite", "", "name or ID of a known device to mark as a favorite (optional)")
+	forget := flag.String("forget", "", "name or ID of a known device to forget (optional)")
 	flag.Parse()
 
-	devices, err := discovery.Discover(*timeout)
+	store, err := openStore(*storePath, *addHost, *favorite, *forget)
+	if err != nil {
+		log.Error("error updating known devices", "err", err)
+		return
+	}
+
+	options := discovery.Options{Interface: *iface, IPv6: *ipv6}
+	var enrichOptions *discovery.EnrichOptions
+	if *enrich {
+		enrichOptions = &discovery.EnrichOptions{DeviceAuth: *deviceAuth, Timeout: 5 * time.Second}
+	}
+	if *watch {
+		watchDevices(options, store, enrichOptions, *members)
+		return
+	}
+
+	devices, groups, err := discovery.Discover(*timeout, options)
 	if err != nil {
 		log.Error("error performing mDNS lookup", "err", err)
 		return
 	}
+	if store != nil {
+		devices = store.Merge(devices)
+	}
+	if enrichOptions != nil {
+		devices = discovery.Enrich(devices, *enrichOptions)
+	}
+	remember(store, devices...)
 	for _, device := range devices {
-		log.Info("found device",
-			"id", device.ID,
-			"name", device.Name,
-			"model", device.Model,
-			"host", device.Host,
-			"port", device.Port)
+		log.Info("found device", deviceAttrs(device)...)
+	}
+	for _, group := range groups {
+		log.Info("found group", groupAttrs(group)...)
+		if *members {
+			logMembers(group)
+		}
+	}
+}
+
+// defaultStorePath returns the shared store of known devices, or an empty path
+// if there is no user config dir to keep it in.
+func defaultStorePath() string {
+	path, err := discovery.DefaultStorePath()
+	if err != nil {
+		return ""
+	}
+	return path
+}
+
+// openStore opens the store of known devices, and applies the changes that
+// were asked for. It returns nil if no store is kept.
+func openStore(path, addHost, favorite, forget string) (*discovery.Store, error) {
+	if path == "" {
+		if addHost != "" || favorite != "" || forget != "" {
+			return nil, errors.New("known devices are not kept without --store")
+		}
+		return nil, nil
+	}
+
+	store, err := discovery.OpenStore(path)
+	if err != nil {
+		return nil, err
+	}
+	if addHost != "" {
+		host, port := addHost, 8009
+		if splitHost, splitPort, err := net.SplitHostPort(addHost); err == nil {
+			host = splitHost
+			if port, err = strconv.Atoi(splitPort); err != nil {
+				return nil, fmt.Errorf("invalid port in %q", addHost)
+			}
+		}
+		if _, err := store.AddManual(host, port); err != nil {
+			return nil, err
+		}
+	}
+	if favorite != "" {
+		known, err := store.Find(favorite)
+		if err != nil {
+			return nil, err
+		}
+		if err := store.SetFavorite(known.Key(), true); err != nil {
+			return nil, err
+		}
+	}
+	if forget != "" {
+		known, err := store.Find(forget)
+		if err != nil {
+			return nil, err
+		}
+		if err := store.Forget(known.Key()); err != nil {
+			return nil, err
+		}
+	}
+	return store, nil
+}
+
+// remember records the devices that were found on the network, by discovery
+// or by connecting to them.
+func remember(store *discovery.Store, devices ...discovery.Device) {
+	if store == nil {
+		return
+	}
+	var seen []discovery.Device
+	for _, device := range devices {
+		if device.ConnectionState == "discovered" || device.ConnectionState == "reachable" {
+			seen = append(seen, device)
+		}
+	}
+	if err := store.Remember(seen...); err != nil {
+		log.Warn("error saving known devices", "err", err)
+	}
+}
+
+func watchDevices(options discovery.Options, store *discovery.Store, enrichOptions *discovery.EnrichOptions, members bool) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	events, err := discovery.Watch(ctx, options)
+	if err != nil {
+		log.Error("error watching for devices", "err", err)
+		return
+	}
+	for event := range events {
+		if event.Group == nil {
+			device := event.Device
+			if event.Type != discovery.Removed {
+				if enrichOptions != nil {
+					device = discovery.Enrich([]discovery.Device{device}, *enrichOptions)[0]
+				}
+				remember(store, device)
+			}
+			log.Info("device "+event.Type.String(), deviceAttrs(device)...)
+			continue
+		}
+		log.Info("group "+event.Type.String(), groupAttrs(*event.Group)...)
+		if members && event.Type != discovery.Removed {
+			logMembers(*event.Group)
+		}
+	}
+}
+
+// logMembers reads a group's members from its leader.
+func logMembers(group discovery.Group) {
+	members, err := client.GroupMembers(group.Host, group.Port, 5*time.Second)
+	if err != nil {
+		log.Error("error reading group members", "group", group.Name, "err", err)
+		return
+	}
+	for _, member := range members {
+		log.Info("group member", "group", group.Name, "id", member.DeviceID, "name", member.Name)
+	}
+}
+
+func deviceAttrs(device discovery.Device) []any {
+	attrs := []any{
+		"id", device.ID,
+		"name", device.Name,
+		"model", device.Model,
+		"host", device.Host,
+		"port", device.Port,
+		"capabilities", device.Capabilities.String(),
+		"status", device.StatusText,
+		"groupLeader", device.GroupLeader,
+		"favorite", device.Favorite,
+		"state", device.ConnectionState,
+	}
+	if device.ConnectionState == "reachable" {
+		attrs = append(attrs,
+			"firmware", device.Firmware,
+			"udn", device.UDN,
+			"connectLatency", device.ConnectLatency,
+			"authLatency", device.AuthLatency,
+		)
+	}
+	return attrs
+}
+
+func groupAttrs(group discovery.Group) []any {
+	return []any{
+		"id", group.ID,
+		"name", group.Name,
+		"host", group.Host,
+		"port", group.Port,
+		"leader", group.LeaderID,
+		"status", group.StatusText,
 	}
 }
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/common"
+	"github.com/tristanpenman/go-cast/internal/latency"
+)
+
+var log = common.NewLogger("main")
+
+// parseSize parses a frame size such as "1280x720".
+func parseSize(value string) (int, int, error) {
+	widthValue, heightValue, ok := strings.Cut(value, "x")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid size %q", value)
+	}
+
+	width, err := strconv.Atoi(widthValue)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid size %q: %w", value, err)
+	}
+	height, err := strconv.Atoi(heightValue)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid size %q: %w", value, err)
+	}
+
+	return width, height, nil
+}
+
+func main() {
+	var bitrate = flag.Int("bitrate", 2000000, "test pattern bitrate, in bits per second")
+	var duration = flag.Duration("duration", 10*time.Second, "how long to stream the test pattern for")
+	var frameRate = flag.Int("frame-rate", 30, "test pattern frame rate")
+	var size = flag.String("size", "1280x720", "test pattern size")
+	var targetDelay = flag.Duration("target-delay", 400*time.Millisecond, "playout delay requested from the receiver")
+
+	flag.Parse()
+
+	width, height, err := parseSize(*size)
+	if err != nil {
+		log.Error("invalid size", "err", err)
+		os.Exit(2)
+	}
+
+	report, err := latency.Measure(latency.Config{
+		Bitrate:     *bitrate,
+		Duration:    *duration,
+		FrameRate:   *frameRate,
+		Height:      height,
+		TargetDelay: *targetDelay,
+		Width:       width,
+	}, common.NewLogger("stream"))
+	if err != nil {
+		log.Error("failed to measure latency", "err", err)
+		os.Exit(1)
+	}
+
+	if err := report.Write(os.Stdout); err != nil {
+		log.Error("failed to write report", "err", err)
+		os.Exit(1)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"path"
 	"runtime"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -25,7 +26,11 @@ import (
 
 	// internal
 	"github.com/tristanpenman/go-cast/internal/common"
+	"github.com/tristanpenman/go-cast/internal/media"
+	"github.com/tristanpenman/go-cast/internal/metrics"
+	"github.com/tristanpenman/go-cast/internal/preview"
 	"github.com/tristanpenman/go-cast/internal/server"
+	"github.com/tristanpenman/go-cast/internal/session"
 )
 
 var log = common.NewLogger("main")
@@ -108,32 +113,31 @@ func loadImage(filePath string) (image.Image, error) {
 	return decoded, err
 }
 
-func runHeadless(images <-chan *image.RGBA, shutdown <-chan struct{}) {
+func newSnapshotSink(format media.SnapshotFormat) (*media.AsyncSink, error) {
+	sink, err := media.NewSnapshotSink("tmp", format, log)
+	if err != nil {
+		return nil, err
+	}
+
+	return media.NewAsyncSink(sink), nil
+}
+
+func runHeadless(stats *media.StatsSink, shutdown <-chan struct{}) {
 	log.Info("running in headless mode")
 
 	ticker := time.NewTicker(time.Second)
 	defer ticker.Stop()
 
-	var totalFrames uint64
-	var framesSinceLast uint64
-	var bytesSinceLast uint64
+	var last media.FrameStats
 	lastLogTime := time.Now()
 
 	for {
 		select {
 		case <-shutdown:
 			return
-		case img, ok := <-images:
-			if !ok {
-				log.Info("image channel closed", "frames", totalFrames)
-				return
-			}
-
-			totalFrames++
-			framesSinceLast++
-			bytesSinceLast += uint64(len(img.Pix))
 		case now := <-ticker.C:
-			if framesSinceLast == 0 {
+			current := stats.Snapshot()
+			if current.Frames == last.Frames {
 				lastLogTime = now
 				continue
 			}
@@ -143,23 +147,22 @@ func runHeadless(images <-chan *image.RGBA, shutdown <-chan struct{}) {
 				continue
 			}
 
-			fps := float64(framesSinceLast) / elapsed
-			bandwidthMbps := (float64(bytesSinceLast) * 8) / elapsed / 1000000
+			fps := float64(current.Frames-last.Frames) / elapsed
+			bandwidthMbps := (float64(current.Bytes-last.Bytes) * 8) / elapsed / 1000000
 
 			log.Info("video stats",
-				"frames", totalFrames,
+				"frames", current.Frames,
 				"fps", fps,
 				"bandwidthMbps", bandwidthMbps,
 			)
 
-			framesSinceLast = 0
-			bytesSinceLast = 0
+			last = current
 			lastLogTime = now
 		}
 	}
 }
 
-func runWindowed(images <-chan *image.RGBA, assetsDir string, shutdown <-chan struct{}) {
+func runWindowed(frames *media.Mailbox, assetsDir string, shutdown <-chan struct{}) {
 	err := glfw.Init()
 	if err != nil {
 		panic(err)
@@ -246,11 +249,15 @@ func runWindowed(images <-chan *image.RGBA, assetsDir string, shutdown <-chan st
 		gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, 0)
 	}
 
+	// wake the event loop when a frame arrives
 	go func() {
-		for i := range images {
-			img = i
-			log.Info("received image")
-			glfw.PostEmptyEvent()
+		for {
+			select {
+			case <-shutdown:
+				return
+			case <-frames.Ready():
+				glfw.PostEmptyEvent()
+			}
 		}
 	}()
 
@@ -261,6 +268,14 @@ func runWindowed(images <-chan *image.RGBA, assetsDir string, shutdown <-chan st
 		default:
 		}
 
+		if frame, ok := frames.Take(); ok {
+			bounds := frame.Image.Bounds()
+			if img.Rect.Size() != bounds.Size() {
+				img = image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+			}
+			draw.Draw(img, img.Bounds(), frame.Image, bounds.Min, draw.Src)
+		}
+
 		w, h := window.GetSize()
 
 		gl.BindTexture(gl.TEXTURE_2D, texture)
@@ -304,17 +319,27 @@ func main() {
 	var certServiceSalt = flag.String("cert-service-salt", "", "salt for generating cert service hash")
 
 	// general options
+	var allowRename = flag.Bool("allow-rename", false, "let anyone who can reach --http-addr rename the receiver, as Chromecasts do")
 	var assetsDir = flag.String("assets-dir", "assets", "path to assets directory (fonts and backdrop)")
+	var captureDir = flag.String("capture", "", "directory to capture raw session packets to, for replay (optional)")
 	var clientPrefix = flag.String("client-prefix", "", "optional client prefix, to limit connections")
 	var deviceID = flag.String("device-id", "", "stable receiver UUID; overrides config.json")
 	var deviceModel = flag.String("device-model", "go-cast", "device model")
+	var dialAddr = flag.String("dial-addr", "", "address to serve DIAL on, and answer SSDP searches for, e.g. :8008 (optional)")
 	var enableMdns = flag.Bool("enable-mdns", false, "advertise service using mDNS")
 	var fixNewlines = flag.Bool("fix-newlines", false, "fix newline characters in manifest file")
 	var friendlyName = flag.String("friendly-name", "GoCast Receiver", "friendly name")
 	var headless = flag.Bool("headless", false, "run without UI and log stats")
-	var iface = flag.String("iface", "", "network interface name or local address to listen on (optional)")
-	var jpegOutput = flag.Bool("jpeg-output", false, "write each frame to tmp/{frameNum}.jpeg")
+	var httpAddr = flag.String("http-addr", "", "address to serve the preview and status page on, e.g. :8080 (optional)")
+	var iface = flag.String("iface", "", "comma-separated network interface names or local addresses to listen on (default: all)")
+	var jpegOutput = flag.Bool("jpeg-output", false, "write frames to tmp/{frameNum}.jpg")
+	var pngOutput = flag.Bool("png-output", false, "write frames to tmp/{frameNum}.png")
+	var metricsEnabled = flag.Bool("metrics", false, "serve Prometheus metrics at /metrics on --http-addr")
 	var port = flag.Int("port", 8009, "port to listen on")
+	var record = flag.String("record", "", "directory to record mirroring sessions to (optional)")
+	var recordFormat = flag.String("record-format", "webm", "recording container format (ivf or webm)")
+	var sessionPorts = flag.String("session-ports", "", "UDP port or port range for mirroring sessions, e.g. 50000-50099 (default: ephemeral)")
+	var y4mOutput = flag.String("y4m-output", "", "write frames as YUV4MPEG2 to a file, FIFO or - for stdout; files are split when the resolution changes, while FIFOs and stdout scale frames to the first resolution (optional)")
 
 	flag.Parse()
 
@@ -324,6 +349,8 @@ func main() {
 	}
 
 	log.Info("args",
+		"allow-rename", *allowRename,
+		"capture", *captureDir,
 		"cert-manifest", *certManifest,
 		"cert-manifest-dir", *certManifestDir,
 		"cert-service", *certService,
@@ -331,13 +358,21 @@ func main() {
 		"client-prefix", *clientPrefix,
 		"device-id", *deviceID,
 		"device-model", *deviceModel,
+		"dial-addr", *dialAddr,
 		"enable-mdns", *enableMdns,
 		"fix-newlines", *fixNewlines,
 		"friendly-name", *friendlyName,
 		"headless", *headless,
+		"http-addr", *httpAddr,
 		"iface", *iface,
 		"jpeg-output", *jpegOutput,
+		"metrics", *metricsEnabled,
+		"png-output", *pngOutput,
 		"port", *port,
+		"record", *record,
+		"record-format", *recordFormat,
+		"session-ports", *sessionPorts,
+		"y4m-output", *y4mOutput,
 	)
 
 	manifest := resolveManifest(*certManifest, *certManifestDir, *certService, *certServiceSalt, *fixNewlines)
@@ -352,11 +387,84 @@ func main() {
 		return
 	}
 
-	images := make(chan *image.RGBA)
+	format, err := session.ParseRecordingFormat(*recordFormat)
+	if err != nil {
+		log.Error("invalid recording format", "err", err)
+		return
+	}
+
+	ports, err := session.ParsePortRange(*sessionPorts)
+	if err != nil {
+		log.Error("invalid session ports", "err", err)
+		return
+	}
+
+	if *metricsEnabled && *httpAddr == "" {
+		log.Error("--metrics requires --http-addr")
+		return
+	}
+
+	capture := session.CaptureConfig{Dir: *captureDir}
+	recording := session.RecordingConfig{Dir: *record, Format: format}
+
+	// the window or headless stats loop always receives frames, and snapshots
+	// are written from their own goroutines so they cannot stall decoding
+	var frames media.FanOut
+	var mailbox *media.Mailbox
+	var stats *media.StatsSink
+	if *headless {
+		stats = &media.StatsSink{}
+		frames = append(frames, stats)
+	} else {
+		mailbox = media.NewMailbox()
+		frames = append(frames, mailbox)
+	}
+
+	if *jpegOutput {
+		snapshots, err := newSnapshotSink(media.JPEGSnapshot)
+		if err != nil {
+			log.Error("failed to create snapshot sink", "err", err)
+			return
+		}
+		defer snapshots.Close()
+		frames = append(frames, snapshots)
+	}
+	if *pngOutput {
+		snapshots, err := newSnapshotSink(media.PNGSnapshot)
+		if err != nil {
+			log.Error("failed to create snapshot sink", "err", err)
+			return
+		}
+		defer snapshots.Close()
+		frames = append(frames, snapshots)
+	}
+	if *y4mOutput != "" {
+		y4m, err := media.CreateY4MSink(*y4mOutput, log)
+		if err != nil {
+			log.Error("failed to create y4m output", "err", err)
+			return
+		}
+		defer func() {
+			if err := y4m.Close(); err != nil {
+				log.Warn("failed to close y4m output", "err", err)
+			}
+		}()
+
+		async := media.NewAsyncSink(y4m)
+		defer async.Close()
+		frames = append(frames, async)
+	}
+
+	var previewFrames *preview.FrameCache
+	if *httpAddr != "" {
+		previewFrames = preview.NewFrameCache()
+		frames = append(frames, previewFrames)
+	}
+
 	udn := id
-	device := server.NewDevice(images, *deviceModel, *friendlyName, id, *jpegOutput, udn)
+	device := server.NewDevice(capture, *deviceModel, frames, *friendlyName, id, recording, ports, udn)
 
-	castServer, err := server.NewServer(device, manifest, clientPrefix, iface, *port)
+	castServer, err := server.NewServer(device, manifest, clientPrefix, strings.Split(*iface, ","), *port)
 	if err != nil {
 		log.Error("failed to start server", "err", err)
 		return
@@ -367,9 +475,43 @@ func main() {
 		}
 	}()
 
+	if previewFrames != nil {
+		previewServer, err := preview.NewServer(*httpAddr, previewFrames, device, common.NewLogger("preview"))
+		if err != nil {
+			log.Error("failed to start http preview", "err", err)
+			return
+		}
+		defer func() {
+			if err := previewServer.Close(); err != nil {
+				log.Warn("failed to stop http preview", "err", err)
+			}
+		}()
+
+		// renames are not authenticated, so they are only accepted on request
+		if *allowRename {
+			previewServer.Handle("/setup/set_eureka_info", server.NewSetupHandler(device))
+		}
+		if *metricsEnabled {
+			previewServer.Handle("/metrics", metrics.NewHandler(device, castServer))
+		}
+	}
+
+	if *dialAddr != "" {
+		dialServer, err := server.NewDIALServer(device, *dialAddr, castServer.InterfaceNames())
+		if err != nil {
+			log.Error("failed to start dial server", "err", err)
+			return
+		}
+		defer func() {
+			if err := dialServer.Close(); err != nil {
+				log.Warn("failed to stop dial server", "err", err)
+			}
+		}()
+	}
+
 	var advertisement *server.Advertisement
 	if *enableMdns {
-		advertisement, err = server.NewAdvertisement(device, *port, castServer.InterfaceNames())
+		advertisement, err = server.NewAdvertisement(device, *port, castServer.InterfaceNames(), castServer.ListenIPs())
 		if err != nil {
 			log.Error("failed to advertise receiver", "err", err)
 		} else {
@@ -381,9 +523,9 @@ func main() {
 	defer stopSignals()
 
 	if *headless {
-		runHeadless(images, shutdown.Done())
+		runHeadless(stats, shutdown.Done())
 		return
 	}
 
-	runWindowed(images, *assetsDir, shutdown.Done())
+	runWindowed(mailbox, *assetsDir, shutdown.Done())
 }
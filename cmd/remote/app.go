@@ -2,30 +2,43 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"sort"
+	"strconv"
 	"sync"
 	"time"
 
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+
 	"github.com/tristanpenman/go-cast/internal/client"
+	"github.com/tristanpenman/go-cast/internal/common"
 	"github.com/tristanpenman/go-cast/internal/discovery"
 )
 
+var log = common.NewLogger("remote")
+
 const receiverTimeout = 10 * time.Second
 const youtubeLaunchTimeout = 30 * time.Second
 const youtubeAppID = "233637DE"
 const youtubeAndroidTVAppID = "2C6A6E3D"
 
+// Events pushed to the frontend while devices are watched.
+const devicesEvent = "devices"
+const discoveryErrorEvent = "discovery-error"
+
 type knownApplication struct {
-	ID   string
-	Name string
+	ID    string
+	Name  string
+	Video bool
 }
 
 var knownApplications = []knownApplication{
-	{ID: youtubeAppID, Name: "YouTube"},
-	{ID: youtubeAndroidTVAppID, Name: "YouTube (Android)"},
-	{ID: "0F5096E8", Name: "Chrome mirroring"},
-	{ID: "674A0243", Name: "Android mirroring"},
+	{ID: youtubeAppID, Name: "YouTube", Video: true},
+	{ID: youtubeAndroidTVAppID, Name: "YouTube (Android)", Video: true},
+	{ID: "0F5096E8", Name: "Chrome mirroring", Video: true},
+	{ID: "674A0243", Name: "Android mirroring", Video: true},
 }
 
 // DeviceApp is the frontend view of an application supported by a receiver.
@@ -36,22 +49,222 @@ type DeviceApp struct {
 	Running    bool   `json:"running"`
 }
 
+// Discovered is the frontend view of the receivers on the network.
+type Discovered struct {
+	Devices []discovery.Device `json:"devices"`
+	Groups  []discovery.Group  `json:"groups"`
+}
+
 // App contains the backend methods exposed to the Wails frontend.
 type App struct {
-	discover func(time.Duration) ([]discovery.Device, error)
+	discover func(time.Duration, discovery.Options) ([]discovery.Device, []discovery.Group, error)
+	emit     func(ctx context.Context, eventName string, optionalData ...interface{})
+	enrich   func([]discovery.Device, discovery.EnrichOptions) []discovery.Device
+	options  discovery.Options
+	store    *discovery.Store
+	watch    func(context.Context, discovery.Options) (<-chan discovery.Event, error)
 
 	mu         sync.Mutex
 	connection *client.ServerConnection
 	sender     *client.Sender
+
+	// watchedMu guards what the watch has found, which is merged with known
+	// devices whenever either changes
+	watchedMu sync.Mutex
+	ctx       context.Context
+	devices   map[string]discovery.Device
+	groups    map[string]discovery.Group
 }
 
 func NewApp() *App {
-	return &App{discover: discovery.Discover}
+	app := &App{
+		discover: discovery.Discover,
+		emit:     runtime.EventsEmit,
+		enrich:   discovery.Enrich,
+		options:  discovery.Options{IPv6: true},
+		watch:    discovery.Watch,
+	}
+
+	// the remote works without known devices, if they cannot be kept
+	path, err := discovery.DefaultStorePath()
+	if err != nil {
+		log.Warn("not remembering known devices", "err", err)
+		return app
+	}
+	if app.store, err = discovery.OpenStore(path); err != nil {
+		log.Warn("not remembering known devices", "path", path, "err", err)
+	}
+	return app
+}
+
+// startup starts watching for receivers, so that the frontend's device list
+// follows devices as they appear, change and disappear.
+func (a *App) startup(ctx context.Context) {
+	a.watchedMu.Lock()
+	a.ctx = ctx
+	a.watchedMu.Unlock()
+
+	events, err := a.watch(ctx, a.options)
+	if err != nil {
+		a.emit(ctx, discoveryErrorEvent, err.Error())
+		return
+	}
+
+	go a.watchDevices(ctx, events)
+}
+
+// watchDevices pushes the complete, sorted lists of devices and groups to the
+// frontend after each change, until the watch ends. Devices are remembered
+// as they are found.
+func (a *App) watchDevices(ctx context.Context, events <-chan discovery.Event) {
+	for event := range events {
+		a.watchedMu.Lock()
+		if a.devices == nil {
+			a.devices = make(map[string]discovery.Device)
+			a.groups = make(map[string]discovery.Group)
+		}
+		switch {
+		case event.Type == discovery.Removed:
+			delete(a.devices, event.Instance)
+			delete(a.groups, event.Instance)
+		case event.Group != nil:
+			a.groups[event.Instance] = *event.Group
+		default:
+			a.devices[event.Instance] = event.Device
+			a.remember(event.Device)
+		}
+		discovered := a.watchedLocked()
+		a.watchedMu.Unlock()
+
+		a.emit(ctx, devicesEvent, discovered)
+	}
+}
+
+// watchedLocked returns what the watch has found, merged with known devices.
+func (a *App) watchedLocked() Discovered {
+	discovered := Discovered{
+		Devices: make([]discovery.Device, 0, len(a.devices)),
+		Groups:  make([]discovery.Group, 0, len(a.groups)),
+	}
+	for _, device := range a.devices {
+		discovered.Devices = append(discovered.Devices, device)
+	}
+	for _, group := range a.groups {
+		discovered.Groups = append(discovered.Groups, group)
+	}
+	discovered.Devices = a.merge(discovered.Devices)
+	discovery.SortGroups(discovered.Groups)
+	return discovered
+}
+
+// publish pushes the watched devices to the frontend after known devices have
+// changed, and returns them.
+func (a *App) publish() Discovered {
+	a.watchedMu.Lock()
+	discovered := a.watchedLocked()
+	ctx := a.ctx
+	a.watchedMu.Unlock()
+
+	if ctx != nil {
+		a.emit(ctx, devicesEvent, discovered)
+	}
+	return discovered
+}
+
+func (a *App) merge(devices []discovery.Device) []discovery.Device {
+	if a.store == nil {
+		discovery.SortDevices(devices)
+		return devices
+	}
+	return a.store.Merge(devices)
+}
+
+func (a *App) remember(devices ...discovery.Device) {
+	if a.store == nil {
+		return
+	}
+	_ = a.store.Remember(devices...)
+}
+
+// DiscoverDevices performs a bounded mDNS scan for Google Cast receivers and
+// speaker groups. Known devices that were not found are included.
+func (a *App) DiscoverDevices() (Discovered, error) {
+	devices, groups, err := a.discover(5*time.Second, a.options)
+	if err != nil {
+		return Discovered{}, err
+	}
+	a.remember(devices...)
+	return Discovered{Devices: a.merge(devices), Groups: groups}, nil
+}
+
+// AddDevice remembers a device that discovery does not find, given as
+// host[:port]. The device is connected to, to identify it.
+func (a *App) AddDevice(address string) (Discovered, error) {
+	if a.store == nil {
+		return Discovered{}, errors.New("known devices cannot be saved")
+	}
+
+	host, port := address, 8009
+	if splitHost, splitPort, err := net.SplitHostPort(address); err == nil {
+		host = splitHost
+		if port, err = strconv.Atoi(splitPort); err != nil {
+			return Discovered{}, fmt.Errorf("invalid port in %q", address)
+		}
+	}
+	known, err := a.store.AddManual(host, port)
+	if err != nil {
+		return Discovered{}, err
+	}
+
+	device := discovery.Device{Name: known.Name, Host: known.Host, Port: known.Port}
+	device = a.enrich([]discovery.Device{device}, discovery.EnrichOptions{DeviceAuth: true, Timeout: receiverTimeout})[0]
+	if device.ConnectionState == "reachable" {
+		a.remember(device)
+	}
+	return a.publish(), nil
 }
 
-// DiscoverDevices performs a bounded mDNS scan for Google Cast receivers.
-func (a *App) DiscoverDevices() ([]discovery.Device, error) {
-	return a.discover(5 * time.Second)
+// SetFavorite marks or unmarks a known device as a favorite.
+func (a *App) SetFavorite(device discovery.Device, favorite bool) (Discovered, error) {
+	if a.store == nil {
+		return Discovered{}, errors.New("known devices cannot be saved")
+	}
+	if err := a.store.SetFavorite(knownKey(device), favorite); err != nil {
+		return Discovered{}, err
+	}
+	return a.publish(), nil
+}
+
+// ForgetDevice removes a known device.
+func (a *App) ForgetDevice(device discovery.Device) (Discovered, error) {
+	if a.store == nil {
+		return Discovered{}, errors.New("known devices cannot be saved")
+	}
+	if err := a.store.Forget(knownKey(device)); err != nil {
+		return Discovered{}, err
+	}
+	return a.publish(), nil
+}
+
+func knownKey(device discovery.Device) string {
+	return discovery.KnownDevice{ID: device.ID, Host: device.Host, Port: device.Port}.Key()
+}
+
+// connect connects to a receiver. A known device that cannot be reached is
+// looked for by ID, in case it has moved to a new address.
+func (a *App) connect(device discovery.Device) (*client.ServerConnection, error) {
+	castClient, err := client.NewClient(device.Host, uint(device.Port), true, nil)
+	if err == nil || device.ID == "" || a.store == nil || errors.Is(err, client.ErrDeviceAuth) {
+		return castClient, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	relocated, resolveErr := a.store.Relocate(ctx, device.ID, a.options)
+	if resolveErr != nil {
+		return nil, err
+	}
+	return client.NewClient(relocated.Host, uint(relocated.Port), true, nil)
 }
 
 // SelectDevice connects to a receiver and returns its available applications.
@@ -64,7 +277,7 @@ func (a *App) SelectDevice(device discovery.Device) ([]DeviceApp, error) {
 	}
 
 	a.closeLocked()
-	castClient, err := client.NewClient(device.Host, uint(device.Port), true, nil)
+	castClient, err := a.connect(device)
 	if err != nil {
 		return nil, err
 	}
@@ -90,7 +303,28 @@ func (a *App) SelectDevice(device discovery.Device) ([]DeviceApp, error) {
 		return nil, fmt.Errorf("query receiver status: %w", err)
 	}
 
-	return deviceApps(sender.Availability(), sender.Status()), nil
+	apps := deviceApps(sender.Availability(), sender.Status())
+	if audioOnly(device) {
+		apps = withoutVideoApps(apps)
+	}
+	return apps, nil
+}
+
+// GroupMembers returns the members of the selected speaker group, as reported
+// by its leader.
+func (a *App) GroupMembers() ([]client.MultizoneDevice, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.sender == nil {
+		return nil, fmt.Errorf("no device selected")
+	}
+	a.sender.RequestMultizoneStatus()
+	members, err := a.sender.WaitForMultizoneMembers(receiverTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("query group members: %w", err)
+	}
+	return members, nil
 }
 
 // LaunchApp launches an available application on the selected receiver.
@@ -254,6 +488,30 @@ func deviceApps(availability map[string]string, status *client.ReceiverStatus) [
 	return apps
 }
 
+// audioOnly reports whether a device advertises audio output without video
+// output, as speakers and speaker groups do. Devices that advertise no
+// capabilities are assumed to have video.
+func audioOnly(device discovery.Device) bool {
+	return device.Capabilities.Has(discovery.AudioOut) && !device.Capabilities.Has(discovery.VideoOut)
+}
+
+// withoutVideoApps hides known video apps that are not running, since they
+// cannot be used on a speaker.
+func withoutVideoApps(apps []DeviceApp) []DeviceApp {
+	video := make(map[string]bool, len(knownApplications))
+	for _, known := range knownApplications {
+		video[known.ID] = known.Video
+	}
+
+	filtered := make([]DeviceApp, 0, len(apps))
+	for _, app := range apps {
+		if !video[app.ID] || app.Running {
+			filtered = append(filtered, app)
+		}
+	}
+	return filtered
+}
+
 func preferredLaunchAppID(appID string, availability map[string]string) string {
 	if availability[appID] == "APP_AVAILABLE" {
 		return appID
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -10,32 +12,203 @@ import (
 )
 
 func TestDiscoverDevices(t *testing.T) {
-	want := []discovery.Device{{ID: "one", Name: "Living Room"}}
-	app := &App{discover: func(timeout time.Duration) ([]discovery.Device, error) {
+	devices := []discovery.Device{{ID: "one", Name: "Living Room", GroupLeader: true}}
+	groups := []discovery.Group{{ID: "group", Name: "Downstairs", LeaderID: "one"}}
+	app := &App{discover: func(timeout time.Duration, _ discovery.Options) ([]discovery.Device, []discovery.Group, error) {
 		if timeout != 5*time.Second {
 			t.Fatalf("unexpected timeout: %s", timeout)
 		}
-		return want, nil
+		return devices, groups, nil
 	}}
 
 	got, err := app.DiscoverDevices()
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(got) != 1 || got[0].ID != want[0].ID {
-		t.Fatalf("unexpected devices: %+v", got)
+	if len(got.Devices) != 1 || got.Devices[0].ID != "one" {
+		t.Fatalf("unexpected devices: %+v", got.Devices)
+	}
+	if len(got.Groups) != 1 || got.Groups[0].LeaderID != "one" {
+		t.Fatalf("unexpected groups: %+v", got.Groups)
 	}
 }
 
 func TestDiscoverDevicesReturnsDiscoveryError(t *testing.T) {
-	app := &App{discover: func(time.Duration) ([]discovery.Device, error) {
-		return nil, errors.New("network unavailable")
+	app := &App{discover: func(time.Duration, discovery.Options) ([]discovery.Device, []discovery.Group, error) {
+		return nil, nil, errors.New("network unavailable")
 	}}
 	if _, err := app.DiscoverDevices(); err == nil {
 		t.Fatal("expected an error")
 	}
 }
 
+func TestStartupPushesWatchedDevices(t *testing.T) {
+	events := make(chan discovery.Event, 6)
+	events <- discovery.Event{Device: discovery.Device{Name: "Office"}, Instance: "office", Type: discovery.Added}
+	events <- discovery.Event{Device: discovery.Device{Name: "Kitchen"}, Instance: "kitchen", Type: discovery.Added}
+	events <- discovery.Event{Device: discovery.Device{Name: "Bedroom"}, Instance: "office", Type: discovery.Updated}
+	events <- discovery.Event{Device: discovery.Device{Name: "Kitchen"}, Instance: "kitchen", Type: discovery.Removed}
+	events <- discovery.Event{Group: &discovery.Group{Name: "Downstairs"}, Instance: "group", Type: discovery.Added}
+	events <- discovery.Event{Instance: "group", Type: discovery.Removed}
+	close(events)
+
+	pushed := make(chan Discovered, 6)
+	app := &App{
+		emit: func(_ context.Context, eventName string, optionalData ...interface{}) {
+			if eventName != devicesEvent {
+				t.Errorf("unexpected event %q", eventName)
+				return
+			}
+			pushed <- optionalData[0].(Discovered)
+		},
+		watch: func(context.Context, discovery.Options) (<-chan discovery.Event, error) {
+			return events, nil
+		},
+	}
+	app.startup(context.Background())
+
+	var names [][]string
+	for i := 0; i < 6; i++ {
+		var list []string
+		discovered := <-pushed
+		for _, device := range discovered.Devices {
+			list = append(list, device.Name)
+		}
+		for _, group := range discovered.Groups {
+			list = append(list, "group:"+group.Name)
+		}
+		names = append(names, list)
+	}
+
+	want := [][]string{
+		{"Office"}, {"Kitchen", "Office"}, {"Bedroom", "Kitchen"}, {"Bedroom"},
+		{"Bedroom", "group:Downstairs"}, {"Bedroom"},
+	}
+	for i := range want {
+		if len(names[i]) != len(want[i]) {
+			t.Fatalf("pushed %v, want %v", names, want)
+		}
+		for j := range want[i] {
+			if names[i][j] != want[i][j] {
+				t.Fatalf("pushed %v, want %v", names, want)
+			}
+		}
+	}
+}
+
+func testStore(t *testing.T) *discovery.Store {
+	t.Helper()
+
+	store, err := discovery.OpenStore(filepath.Join(t.TempDir(), "devices.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestDiscoverDevicesIncludesKnownDevices(t *testing.T) {
+	store := testStore(t)
+	if err := store.Remember(discovery.Device{ID: "kitchen", Name: "Kitchen", Host: "192.0.2.30", Port: 8009}); err != nil {
+		t.Fatal(err)
+	}
+	app := &App{
+		discover: func(time.Duration, discovery.Options) ([]discovery.Device, []discovery.Group, error) {
+			return []discovery.Device{{ID: "office", Name: "Office", Host: "192.0.2.20", Port: 8009, ConnectionState: "discovered"}}, nil, nil
+		},
+		store: store,
+	}
+
+	got, err := app.DiscoverDevices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Devices) != 2 || got.Devices[0].Name != "Kitchen" || got.Devices[0].ConnectionState != "remembered" {
+		t.Fatalf("unexpected devices: %+v", got.Devices)
+	}
+	if _, err := store.Find("office"); err != nil {
+		t.Fatalf("discovered device was not remembered: %v", err)
+	}
+}
+
+func TestAddDeviceIdentifiesAndPublishesManualHost(t *testing.T) {
+	var pushed []Discovered
+	app := &App{
+		emit: func(_ context.Context, eventName string, optionalData ...interface{}) {
+			pushed = append(pushed, optionalData[0].(Discovered))
+		},
+		enrich: func(devices []discovery.Device, options discovery.EnrichOptions) []discovery.Device {
+			if devices[0].Host != "192.0.2.40" || devices[0].Port != 8010 {
+				t.Errorf("enriched %+v", devices[0])
+			}
+			device := devices[0]
+			device.ID, device.Name, device.ConnectionState = "garage", "Garage", "reachable"
+			return []discovery.Device{device}
+		},
+		store: testStore(t),
+		watch: func(context.Context, discovery.Options) (<-chan discovery.Event, error) {
+			events := make(chan discovery.Event)
+			close(events)
+			return events, nil
+		},
+	}
+	app.startup(context.Background())
+
+	if _, err := app.AddDevice("192.0.2.40:not-a-port"); err == nil {
+		t.Fatal("expected an error for an invalid port")
+	}
+	got, err := app.AddDevice("192.0.2.40:8010")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Devices) != 1 || got.Devices[0].ID != "garage" || got.Devices[0].Name != "Garage" {
+		t.Fatalf("unexpected devices: %+v", got.Devices)
+	}
+	if len(pushed) != 1 {
+		t.Fatalf("pushed %d updates, want 1", len(pushed))
+	}
+
+	got, err = app.SetFavorite(got.Devices[0], true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Devices[0].Favorite {
+		t.Fatalf("device was not marked as a favorite: %+v", got.Devices[0])
+	}
+
+	got, err = app.ForgetDevice(got.Devices[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Devices) != 0 {
+		t.Fatalf("device was not forgotten: %+v", got.Devices)
+	}
+}
+
+func TestGroupMembersRequiresSelectedDevice(t *testing.T) {
+	if _, err := (&App{}).GroupMembers(); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestStartupReportsWatchError(t *testing.T) {
+	var reported []interface{}
+	app := &App{
+		emit: func(_ context.Context, eventName string, optionalData ...interface{}) {
+			if eventName == discoveryErrorEvent {
+				reported = optionalData
+			}
+		},
+		watch: func(context.Context, discovery.Options) (<-chan discovery.Event, error) {
+			return nil, errors.New("no multicast")
+		},
+	}
+	app.startup(context.Background())
+
+	if len(reported) != 1 || reported[0] != "no multicast" {
+		t.Fatalf("reported %v, want the watch error", reported)
+	}
+}
+
 func TestDeviceAppsMergesAvailabilityAndRunningStatus(t *testing.T) {
 	availability := map[string]string{
 		"233637DE": "APP_AVAILABLE",
@@ -108,6 +281,24 @@ func TestDeviceAppsListsBothYouTubeReceivers(t *testing.T) {
 	}
 }
 
+func TestWithoutVideoAppsHidesVideoAppsOnSpeakers(t *testing.T) {
+	speaker := discovery.Device{Capabilities: discovery.AudioOut | discovery.MultizoneGroup}
+	tv := discovery.Device{Capabilities: discovery.VideoOut | discovery.AudioOut}
+	if !audioOnly(speaker) || audioOnly(tv) || audioOnly(discovery.Device{}) {
+		t.Fatal("unexpected audio-only classification")
+	}
+
+	apps := []DeviceApp{
+		{ID: youtubeAppID, Name: "YouTube"},
+		{ID: "0F5096E8", Name: "Chrome mirroring", Running: true},
+		{ID: "CUSTOM", Name: "Music", Running: true},
+	}
+	got := withoutVideoApps(apps)
+	if len(got) != 2 || got[0].ID != "0F5096E8" || got[1].ID != "CUSTOM" {
+		t.Fatalf("unexpected apps %+v", got)
+	}
+}
+
 func TestPlayYouTubeValidatesURLBeforeConnecting(t *testing.T) {
 	app := &App{}
 	if _, err := app.PlayYouTube(youtubeAppID, "https://example.com/video"); err == nil {
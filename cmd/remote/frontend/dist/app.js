@@ -1,17 +1,22 @@
 const deviceListView = document.querySelector("#device-list-view");
 const deviceControlView = document.querySelector("#device-control-view");
 const devices = document.querySelector("#devices");
+const groupsSection = document.querySelector("#groups-section");
+const groupsElement = document.querySelector("#groups");
 const status = document.querySelector("#status");
 const refresh = document.querySelector("#refresh");
+const addDeviceForm = document.querySelector("#add-device-form");
 const back = document.querySelector("#back");
 const selectedDeviceName = document.querySelector("#selected-device-name");
 const selectedDeviceDetails = document.querySelector("#selected-device-details");
+const groupMembers = document.querySelector("#group-members");
 const controlStatus = document.querySelector("#control-status");
 const appsElement = document.querySelector("#apps");
 const appCount = document.querySelector("#app-count");
 const appControls = document.querySelector("#app-controls");
 
 let renderedDevices = [];
+let renderedGroups = [];
 let renderedApps = [];
 let selectedAppID = null;
 
@@ -21,6 +26,17 @@ function escapeHTML(value) {
   return node.innerHTML;
 }
 
+// Cast devices serve the icon named in their advertisement over HTTP, on the
+// setup port. The glyph is shown if there is no icon, or it fails to load.
+function deviceIcon(device) {
+  if (!device.iconPath || !device.host) {
+    return "◧";
+  }
+  const host = device.host.includes(":") ? `[${device.host}]` : device.host;
+  const url = `http://${host}:8008${device.iconPath}`;
+  return `<img src="${escapeHTML(url)}" alt="" onerror="this.replaceWith('◧')">`;
+}
+
 function renderDevices(found) {
   renderedDevices = found;
 
@@ -35,16 +51,41 @@ function renderDevices(found) {
 
   devices.innerHTML = found.map((device, index) => `
     <button class="device" type="button" data-device-index="${index}">
-      <span class="device-icon" aria-hidden="true">◧</span>
+      <span class="device-icon" aria-hidden="true">${deviceIcon(device)}</span>
       <span class="device-copy">
         <strong>${escapeHTML(device.name)}</strong>
         <span>${escapeHTML(device.model || "Google Cast device")}</span>
+        ${device.busy && device.statusText ? `<span class="now-playing">Now playing: ${escapeHTML(device.statusText)}</span>` : ""}
         <code>${escapeHTML(device.host)}:${device.port}</code>
       </span>
-      <span class="state">${escapeHTML(device.connectionState)}</span>
+      ${device.groupLeader ? `<span class="state leader">Group leader</span>` : ""}
+      <span class="state ${escapeHTML(device.connectionState)}">${escapeHTML(device.connectionState)}</span>
+      <span class="favorite${device.favorite ? " active" : ""}" role="button" title="${device.favorite ? "Remove from favorites" : "Add to favorites"}" data-favorite-index="${index}">${device.favorite ? "★" : "☆"}</span>
+      ${device.connectionState === "remembered" ? `<span class="forget" role="button" title="Forget this device" data-forget-index="${index}">×</span>` : ""}
     </button>`).join("");
 }
 
+// Groups are advertised from the address of their leader, which is named if
+// it has been found.
+function renderGroups(found) {
+  renderedGroups = found;
+  groupsSection.hidden = !found.length;
+
+  groupsElement.innerHTML = found.map((group, index) => {
+    const leader = renderedDevices.find((device) => device.id === group.leaderId);
+    return `
+    <button class="device" type="button" data-group-index="${index}">
+      <span class="device-icon" aria-hidden="true">◫</span>
+      <span class="device-copy">
+        <strong>${escapeHTML(group.name)}</strong>
+        <span>${leader ? `Led by ${escapeHTML(leader.name)}` : "Speaker group"}</span>
+        ${group.busy && group.statusText ? `<span class="now-playing">Now playing: ${escapeHTML(group.statusText)}</span>` : ""}
+        <code>${escapeHTML(group.host)}:${group.port}</code>
+      </span>
+    </button>`;
+  }).join("");
+}
+
 function renderApps(found) {
   renderedApps = found || [];
   appCount.textContent = `${renderedApps.length} available`;
@@ -114,10 +155,11 @@ function showDeviceList() {
   deviceListView.hidden = false;
 }
 
-async function openDevice(device) {
+async function openDevice(device, isGroup) {
   selectedAppID = null;
   selectedDeviceName.textContent = device.name;
-  selectedDeviceDetails.textContent = `${device.model || "Google Cast device"} · ${device.host}:${device.port}`;
+  selectedDeviceDetails.textContent = `${isGroup ? "Speaker group" : device.model || "Google Cast device"} · ${device.host}:${device.port}`;
+  groupMembers.hidden = true;
   deviceListView.hidden = true;
   deviceControlView.hidden = false;
   controlStatus.className = "status scanning";
@@ -139,6 +181,24 @@ async function openDevice(device) {
   } catch (error) {
     controlStatus.className = "status error";
     controlStatus.textContent = `Unable to load device: ${error}`;
+    return;
+  }
+
+  if (isGroup) {
+    showGroupMembers();
+  }
+}
+
+async function showGroupMembers() {
+  groupMembers.hidden = false;
+  groupMembers.textContent = "Reading group members…";
+  try {
+    const members = await window.go.main.App.GroupMembers();
+    groupMembers.textContent = members?.length
+      ? `Members: ${members.map((member) => member.name).join(", ")}`
+      : "This group has no members.";
+  } catch (error) {
+    groupMembers.textContent = `Unable to read group members: ${error}`;
   }
 }
 
@@ -190,12 +250,10 @@ async function scan() {
   status.textContent = "Looking for Chromecast devices…";
 
   try {
-    const found = await window.go.main.App.DiscoverDevices();
-    renderDevices(found || []);
-    status.className = "status";
-    status.textContent = `${found?.length || 0} device${found?.length === 1 ? "" : "s"} found`;
+    showDevices(await window.go.main.App.DiscoverDevices());
   } catch (error) {
     devices.innerHTML = "";
+    renderGroups([]);
     status.className = "status error";
     status.textContent = `Discovery failed: ${error}`;
   } finally {
@@ -204,14 +262,48 @@ async function scan() {
   }
 }
 
+// Known devices are changed in the backend, which returns the updated list.
+async function updateKnownDevices(update, failure) {
+  try {
+    showDevices(await update());
+  } catch (error) {
+    status.className = "status error";
+    status.textContent = `${failure}: ${error}`;
+  }
+}
+
 devices.addEventListener("click", (event) => {
+  const favoriteElement = event.target.closest("[data-favorite-index]");
+  if (favoriteElement) {
+    const device = renderedDevices[Number(favoriteElement.dataset.favoriteIndex)];
+    updateKnownDevices(() => window.go.main.App.SetFavorite(device, !device.favorite), "Unable to update favorites");
+    return;
+  }
+  const forgetElement = event.target.closest("[data-forget-index]");
+  if (forgetElement) {
+    const device = renderedDevices[Number(forgetElement.dataset.forgetIndex)];
+    updateKnownDevices(() => window.go.main.App.ForgetDevice(device), "Unable to forget device");
+    return;
+  }
+
   const deviceElement = event.target.closest("[data-device-index]");
   if (!deviceElement) {
     return;
   }
   const device = renderedDevices[Number(deviceElement.dataset.deviceIndex)];
   if (device) {
-    openDevice(device);
+    openDevice(device, false);
+  }
+});
+
+groupsElement.addEventListener("click", (event) => {
+  const groupElement = event.target.closest("[data-group-index]");
+  if (!groupElement) {
+    return;
+  }
+  const group = renderedGroups[Number(groupElement.dataset.groupIndex)];
+  if (group) {
+    openDevice(group, true);
   }
 });
 
@@ -238,6 +330,45 @@ appControls.addEventListener("submit", (event) => {
   }
 });
 
+function showDevices(found) {
+  const foundDevices = found?.devices || [];
+  const foundGroups = found?.groups || [];
+  renderDevices(foundDevices);
+  renderGroups(foundGroups);
+  const remembered = foundDevices.filter((device) => device.connectionState === "remembered").length;
+  const live = foundDevices.length - remembered;
+  status.className = "status";
+  status.textContent = `${live} device${live === 1 ? "" : "s"} found`;
+  if (remembered) {
+    status.textContent += `, ${remembered} remembered`;
+  }
+  if (foundGroups.length) {
+    status.textContent += `, and ${foundGroups.length} group${foundGroups.length === 1 ? "" : "s"}`;
+  }
+}
+
+addDeviceForm.addEventListener("submit", async (event) => {
+  event.preventDefault();
+  const address = new FormData(addDeviceForm).get("address").trim();
+  const button = addDeviceForm.querySelector("button[type=submit]");
+  button.disabled = true;
+  status.className = "status scanning";
+  status.textContent = `Adding ${address}…`;
+  await updateKnownDevices(() => window.go.main.App.AddDevice(address), "Unable to add device");
+  button.disabled = false;
+  addDeviceForm.reset();
+});
+
 back.addEventListener("click", showDeviceList);
 refresh.addEventListener("click", scan);
+
+// The backend keeps watching for devices after the initial scan, and pushes
+// the whole list of devices and groups whenever one appears, changes or
+// disappears.
+window.runtime?.EventsOn("devices", showDevices);
+window.runtime?.EventsOn("discovery-error", (error) => {
+  status.className = "status error";
+  status.textContent = `Live discovery unavailable: ${error}`;
+});
+
 scan();
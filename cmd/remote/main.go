@@ -23,6 +23,7 @@ func main() {
 		},
 		Bind:       []interface{}{app},
 		OnShutdown: app.shutdown,
+		OnStartup:  app.startup,
 	})
 	if err != nil {
 		fmt.Println("Error:", err)
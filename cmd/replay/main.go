@@ -0,0 +1,115 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"time"
+
+	// third-party
+	"github.com/google/uuid"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/common"
+	"github.com/tristanpenman/go-cast/internal/media"
+	"github.com/tristanpenman/go-cast/internal/session"
+)
+
+const chromeMirroringAppId = "0F5096E8"
+
+var log = common.NewLogger("main")
+
+// headlessDevice discards the messages that the session would send back to
+// the sender.
+type headlessDevice struct{}
+
+func (device *headlessDevice) SendUTF8(namespace string, payloadUtf8 *string, sourceID string, destinationID string) {
+	log.Debug("discarding message", "namespace", namespace, "payload", *payloadUtf8)
+}
+
+func newSnapshotSink(format media.SnapshotFormat) (*media.AsyncSink, error) {
+	sink, err := media.NewSnapshotSink("tmp", format, log)
+	if err != nil {
+		return nil, err
+	}
+
+	return media.NewAsyncSink(sink), nil
+}
+
+// run replays a capture and returns the process's exit code. It is separate
+// from main so that deferred sinks are closed before the process exits.
+func run() int {
+	var capturePath = flag.String("capture", "", "path to a session capture (.gcap) file")
+	var jpegOutput = flag.Bool("jpeg-output", false, "write frames to tmp/{frameNum}.jpg")
+	var pngOutput = flag.Bool("png-output", false, "write frames to tmp/{frameNum}.png")
+	var record = flag.String("record", "", "directory to record the replayed session to (optional)")
+	var recordFormat = flag.String("record-format", "webm", "recording container format (ivf or webm)")
+	var speed = flag.Float64("speed", 1, "replay speed, where 0 replays as quickly as possible")
+	var timeout = flag.Duration("timeout", 10*time.Second, "how long to wait for frames to be played out")
+
+	flag.Parse()
+
+	if *capturePath == "" {
+		flag.PrintDefaults()
+		return 2
+	}
+
+	format, err := session.ParseRecordingFormat(*recordFormat)
+	if err != nil {
+		log.Error("invalid recording format", "err", err)
+		return 2
+	}
+
+	capture, err := session.LoadCapture(*capturePath)
+	if err != nil {
+		log.Error("failed to load capture", "err", err)
+		return 1
+	}
+	if err := capture.Validate(); err != nil {
+		log.Error("capture cannot be replayed", "err", err)
+		return 1
+	}
+
+	stats := &media.StatsSink{}
+	frames := media.FanOut{stats}
+	if *jpegOutput {
+		snapshots, err := newSnapshotSink(media.JPEGSnapshot)
+		if err != nil {
+			log.Error("failed to create snapshot sink", "err", err)
+			return 1
+		}
+		defer snapshots.Close()
+		frames = append(frames, snapshots)
+	}
+	if *pngOutput {
+		snapshots, err := newSnapshotSink(media.PNGSnapshot)
+		if err != nil {
+			log.Error("failed to create snapshot sink", "err", err)
+			return 1
+		}
+		defer snapshots.Close()
+		frames = append(frames, snapshots)
+	}
+
+	recording := session.RecordingConfig{Dir: *record, Format: format}
+	listen := session.ListenConfig{Host: "127.0.0.1"}
+	replaySession, err := session.NewSession(chromeMirroringAppId, session.CaptureConfig{}, 0, &headlessDevice{}, "Replay", frames, listen, recording, uuid.New().String(), "pid-replay")
+	if err != nil {
+		log.Error("failed to create session", "err", err)
+		return 1
+	}
+
+	replaySession.Start()
+	err = session.Replay(capture, replaySession, session.ReplayOptions{Speed: *speed, Timeout: *timeout})
+	replaySession.Stop()
+	if err != nil {
+		log.Error("replay failed", "err", err)
+		return 1
+	}
+
+	log.Info("replay complete", "records", len(capture.Records), "frames", stats.Snapshot().Frames)
+	return 0
+}
+
+func main() {
+	os.Exit(run())
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/client"
+	"github.com/tristanpenman/go-cast/internal/common"
+	"github.com/tristanpenman/go-cast/internal/discovery"
+	"github.com/tristanpenman/go-cast/internal/sender"
+)
+
+const chromeMirroringAppID = "0F5096E8"
+
+var log = common.NewLogger("main")
+
+// parseSize parses a frame size such as "1280x720".
+func parseSize(value string) (int, int, error) {
+	widthValue, heightValue, ok := strings.Cut(value, "x")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid size %q", value)
+	}
+
+	width, err := strconv.Atoi(widthValue)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid size %q: %w", value, err)
+	}
+	height, err := strconv.Atoi(heightValue)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid size %q: %w", value, err)
+	}
+
+	return width, height, nil
+}
+
+func openSource(file string, size string, frameRate int, bitrate int, duration time.Duration) (sender.Source, error) {
+	if file != "" {
+		return sender.OpenSource(file)
+	}
+
+	width, height, err := parseSize(size)
+	if err != nil {
+		return nil, err
+	}
+
+	return sender.NewPattern(width, height, frameRate, bitrate, duration)
+}
+
+// stopApp stops the mirroring app, if the receiver still reports it running.
+func stopApp(castSender *client.Sender, appID string, timeout time.Duration) {
+	status := castSender.Status()
+	if status == nil {
+		return
+	}
+
+	for _, app := range status.Applications {
+		if app.MatchesAppID(appID) && app.SessionID != "" {
+			castSender.StopApp(app.SessionID)
+			if err := castSender.WaitForAppStopped(appID, timeout); err != nil {
+				log.Warn("failed to stop app", "err", err)
+			}
+			return
+		}
+	}
+}
+
+// streamTo negotiates a stream with a running mirroring app, and streams the
+// source to it until the source ends or the sender is interrupted.
+func streamTo(castSender *client.Sender, hostname string, source sender.Source, targetDelay time.Duration, timeout time.Duration, transportID string) error {
+	config, err := sender.Negotiate(castSender, transportID, targetDelay, timeout)
+	if err != nil {
+		return err
+	}
+
+	stream, err := sender.NewStream(config, hostname, common.NewLogger("stream"))
+	if err != nil {
+		return err
+	}
+
+	interrupted, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignals()
+	go func() {
+		<-interrupted.Done()
+		_ = stream.Close()
+	}()
+
+	log.Info("streaming", "port", config.UDPPort, "ssrc", config.SSRC)
+	err = stream.Run(source)
+	_ = stream.Close()
+
+	stats := stream.Stats()
+	log.Info("stream complete",
+		"frames", stats.FramesSent,
+		"acked", stats.FramesAcked,
+		"packets", stats.PacketsSent,
+		"retransmissions", stats.Retransmissions,
+		"keyframesRequested", stats.KeyframesRequested)
+
+	if interrupted.Err() != nil {
+		return nil
+	}
+
+	return err
+}
+
+// connectKnown connects to a device that the discovery app or the remote has
+// seen before, given its name or ID. A device that is no longer at the address
+// that it was last seen at is looked for by ID.
+func connectKnown(query string, deviceAuth bool, timeout time.Duration) (*client.ServerConnection, string, error) {
+	path, err := discovery.DefaultStorePath()
+	if err != nil {
+		return nil, "", err
+	}
+	store, err := discovery.OpenStore(path)
+	if err != nil {
+		return nil, "", err
+	}
+	known, err := store.Find(query)
+	if err != nil {
+		return nil, "", err
+	}
+
+	castClient, err := client.NewClient(known.Host, uint(known.Port), deviceAuth, nil)
+	if err == nil || known.ID == "" || errors.Is(err, client.ErrDeviceAuth) {
+		return castClient, known.Host, err
+	}
+
+	log.Info("device is not at its last known address, looking for it", "id", known.ID, "err", err)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	device, resolveErr := store.Relocate(ctx, known.ID, discovery.Options{})
+	if resolveErr != nil {
+		return nil, "", errors.Join(err, resolveErr)
+	}
+	castClient, err = client.NewClient(device.Host, uint(device.Port), deviceAuth, nil)
+	return castClient, device.Host, err
+}
+
+func main() {
+	var appID = flag.String("app-id", chromeMirroringAppID, "mirroring app to launch on the receiver")
+	var bitrate = flag.Int("bitrate", 2000000, "test pattern bitrate, in bits per second")
+	var device = flag.String("device", "", "name or ID of a previously discovered device, instead of --hostname")
+	var deviceAuth = flag.Bool("device-auth", true, "verify the receiver's device certificate")
+	var duration = flag.Duration("duration", 0, "how long to stream the test pattern for (default: until interrupted)")
+	var file = flag.String("file", "", "IVF or WebM file containing VP8 video to stream (default: test pattern)")
+	var frameRate = flag.Int("frame-rate", 30, "test pattern frame rate")
+	var hostname = flag.String("hostname", "", "receiver hostname or address")
+	var port = flag.Int("port", 8009, "receiver port")
+	var size = flag.String("size", "1280x720", "test pattern size")
+	var targetDelay = flag.Duration("target-delay", 400*time.Millisecond, "playout delay requested from the receiver")
+	var timeout = flag.Duration("timeout", 10*time.Second, "how long to wait for the receiver to respond")
+
+	flag.Parse()
+
+	if (*hostname == "" && *device == "") || *port < 1 || *port > 65535 {
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	source, err := openSource(*file, *size, *frameRate, *bitrate, *duration)
+	if err != nil {
+		log.Error("failed to open source", "err", err)
+		os.Exit(1)
+	}
+	defer func() {
+		_ = source.Close()
+	}()
+
+	var castClient *client.ServerConnection
+	if *device != "" {
+		castClient, *hostname, err = connectKnown(*device, *deviceAuth, *timeout)
+	} else {
+		castClient, err = client.NewClient(*hostname, uint(*port), *deviceAuth, nil)
+	}
+	if err != nil {
+		log.Error("failed to connect to receiver", "err", err)
+		os.Exit(1)
+	}
+	defer func() {
+		_ = castClient.Close()
+	}()
+
+	castSender := client.NewSender(castClient, nil)
+	castSender.Connect()
+	castSender.LaunchApp(*appID)
+	transportID, err := castSender.WaitForApp(*appID, *timeout)
+	if err != nil {
+		log.Error("failed to launch app", "err", err)
+		os.Exit(1)
+	}
+	castSender.ConnectTransport(transportID)
+
+	err = streamTo(castSender, *hostname, source, *targetDelay, *timeout, transportID)
+	stopApp(castSender, *appID, *timeout)
+	if err != nil {
+		log.Error("stream failed", "err", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,172 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/channel"
+	"github.com/tristanpenman/go-cast/internal/common"
+)
+
+// DeviceInfo is a receiver's description of itself, as reported on the
+// discovery namespace in answer to GET_DEVICE_INFO.
+type DeviceInfo struct {
+	Capabilities int    `json:"deviceCapabilities"`
+	DeviceID     string `json:"deviceId"`
+	IconURL      string `json:"deviceIconUrl"`
+	Model        string `json:"deviceModel"`
+	Name         string `json:"friendlyName"`
+}
+
+// EurekaInfo is the part of a receiver's setup information, as reported on the
+// setup namespace in answer to eureka_info, that identifies it.
+type EurekaInfo struct {
+	Firmware string
+	Name     string
+	UDN      string
+	Version  int
+}
+
+type deviceInfoMessage struct {
+	requestMessage
+	DeviceInfo
+}
+
+// setupRequest is sent on the setup namespace, which uses snake case.
+type setupRequest struct {
+	Data      setupRequestData `json:"data"`
+	RequestID int              `json:"request_id"`
+	Type      string           `json:"type"`
+}
+
+type setupRequestData struct {
+	Params string `json:"params"`
+}
+
+// eurekaInfoMessage accepts both the nested layout that is returned when
+// params are requested, and the older flat layout.
+type eurekaInfoMessage struct {
+	Type string `json:"type"`
+	Data struct {
+		BuildInfo struct {
+			CastBuildRevision string `json:"cast_build_revision"`
+		} `json:"build_info"`
+		BuildVersion      string `json:"build_version"`
+		CastBuildRevision string `json:"cast_build_revision"`
+		DeviceInfo        struct {
+			SSDPUDN string `json:"ssdp_udn"`
+		} `json:"device_info"`
+		Name    string `json:"name"`
+		SSDPUDN string `json:"ssdp_udn"`
+		Version int    `json:"version"`
+	} `json:"data"`
+}
+
+func (m eurekaInfoMessage) info() EurekaInfo {
+	return EurekaInfo{
+		Firmware: firstNonEmpty(m.Data.BuildInfo.CastBuildRevision, m.Data.CastBuildRevision, m.Data.BuildVersion),
+		Name:     m.Data.Name,
+		UDN:      firstNonEmpty(m.Data.DeviceInfo.SSDPUDN, m.Data.SSDPUDN),
+		Version:  m.Data.Version,
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// RequestDeviceInfo sends GET_DEVICE_INFO on the discovery namespace.
+func (s *Sender) RequestDeviceInfo() {
+	request := requestMessage{RequestID: s.nextRequestID(), Type: "GET_DEVICE_INFO"}
+	payloadBytes, _ := json.Marshal(request)
+	s.client.SendMessage(newUTF8CastMessage(common.DiscoveryNamespace, s.senderID, s.receiverID, string(payloadBytes)))
+}
+
+// RequestEurekaInfo sends eureka_info on the setup namespace, asking for the
+// build and device information.
+func (s *Sender) RequestEurekaInfo() {
+	request := setupRequest{
+		Data:      setupRequestData{Params: "build_info,device_info,name"},
+		RequestID: s.nextRequestID(),
+		Type:      "eureka_info",
+	}
+	payloadBytes, _ := json.Marshal(request)
+	s.client.SendMessage(newUTF8CastMessage(common.SetupNamespace, s.senderID, s.receiverID, string(payloadBytes)))
+}
+
+// WaitForDeviceInfo blocks until the receiver has answered GET_DEVICE_INFO.
+func (s *Sender) WaitForDeviceInfo(timeout time.Duration) (DeviceInfo, error) {
+	deadline := time.Now().Add(timeout)
+	timer := s.broadcastAtTimeout(timeout)
+	defer timer.Stop()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.deviceInfo == nil {
+		if err := s.waitErrorLocked(deadline, "device info"); err != nil {
+			return DeviceInfo{}, err
+		}
+		s.cond.Wait()
+	}
+	return *s.deviceInfo, nil
+}
+
+// WaitForEurekaInfo blocks until the receiver has answered eureka_info.
+func (s *Sender) WaitForEurekaInfo(timeout time.Duration) (EurekaInfo, error) {
+	deadline := time.Now().Add(timeout)
+	timer := s.broadcastAtTimeout(timeout)
+	defer timer.Stop()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.eurekaInfo == nil {
+		if err := s.waitErrorLocked(deadline, "eureka info"); err != nil {
+			return EurekaInfo{}, err
+		}
+		s.cond.Wait()
+	}
+	return *s.eurekaInfo, nil
+}
+
+func (s *Sender) handleDiscoveryMessage(castMessage *channel.CastMessage) {
+	if castMessage.PayloadUtf8 == nil {
+		return
+	}
+	var message deviceInfoMessage
+	if err := json.Unmarshal([]byte(*castMessage.PayloadUtf8), &message); err != nil {
+		s.log.Warn("failed to parse discovery message", "err", err)
+		return
+	}
+	if message.messageType() != "DEVICE_INFO" {
+		return
+	}
+	s.mu.Lock()
+	s.deviceInfo = &message.DeviceInfo
+	s.cond.Broadcast()
+	s.mu.Unlock()
+}
+
+func (s *Sender) handleSetupMessage(castMessage *channel.CastMessage) {
+	if castMessage.PayloadUtf8 == nil {
+		return
+	}
+	var message eurekaInfoMessage
+	if err := json.Unmarshal([]byte(*castMessage.PayloadUtf8), &message); err != nil {
+		s.log.Warn("failed to parse setup message", "err", err)
+		return
+	}
+	if message.Type != "eureka_info" {
+		return
+	}
+	info := message.info()
+	s.mu.Lock()
+	s.eurekaInfo = &info
+	s.cond.Broadcast()
+	s.mu.Unlock()
+}
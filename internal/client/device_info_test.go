@@ -0,0 +1,62 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tristanpenman/go-cast/internal/channel"
+	"github.com/tristanpenman/go-cast/internal/common"
+)
+
+func namespaceMessage(namespace, payload string) *channel.CastMessage {
+	return &channel.CastMessage{Namespace: &namespace, PayloadUtf8: &payload}
+}
+
+func TestHandleDeviceInfo(t *testing.T) {
+	sender := testSender()
+	sender.handleDiscoveryMessage(namespaceMessage(common.DiscoveryNamespace,
+		`{"requestId":1,"type":"DEVICE_INFO","controlNotifications":1,"deviceCapabilities":4101,"deviceIconUrl":"/setup/icon.png","deviceId":"5f1e1c2a","deviceModel":"Chromecast Ultra","friendlyName":"Living Room TV"}`))
+
+	info, err := sender.WaitForDeviceInfo(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := DeviceInfo{Capabilities: 4101, DeviceID: "5f1e1c2a", IconURL: "/setup/icon.png", Model: "Chromecast Ultra", Name: "Living Room TV"}
+	if info != want {
+		t.Fatalf("got %+v, want %+v", info, want)
+	}
+}
+
+func TestHandleEurekaInfo(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    EurekaInfo
+	}{
+		{
+			name:    "nested",
+			payload: `{"request_id":2,"type":"eureka_info","response_code":200,"data":{"build_info":{"build_type":2,"cast_build_revision":"1.56.500000","system_build_number":"500000"},"device_info":{"ssdp_udn":"5f1e1c2a-9b3d-4e6f-8a7b-6c5d4e3f2a1b"},"name":"Living Room TV","version":12}}`,
+			want:    EurekaInfo{Firmware: "1.56.500000", Name: "Living Room TV", UDN: "5f1e1c2a-9b3d-4e6f-8a7b-6c5d4e3f2a1b", Version: 12},
+		},
+		{
+			name:    "flat",
+			payload: `{"request_id":2,"type":"eureka_info","response_code":200,"data":{"build_version":"1.36.159268","name":"Kitchen","ssdp_udn":"0b8c2e74-d1a9-4f53-a6e2-c71f9d0b3e48","version":8}}`,
+			want:    EurekaInfo{Firmware: "1.36.159268", Name: "Kitchen", UDN: "0b8c2e74-d1a9-4f53-a6e2-c71f9d0b3e48", Version: 8},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sender := testSender()
+			sender.handleSetupMessage(namespaceMessage(common.SetupNamespace, test.payload))
+
+			info, err := sender.WaitForEurekaInfo(time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info != test.want {
+				t.Fatalf("got %+v, want %+v", info, test.want)
+			}
+		})
+	}
+}
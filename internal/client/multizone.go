@@ -0,0 +1,168 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/channel"
+	"github.com/tristanpenman/go-cast/internal/common"
+)
+
+// MultizoneDevice is a member of a speaker group, as reported on the
+// multizone namespace by the group's leader.
+type MultizoneDevice struct {
+	Capabilities int    `json:"capabilities"`
+	DeviceID     string `json:"deviceId"`
+	Name         string `json:"name"`
+}
+
+type multizoneStatusMessage struct {
+	requestMessage
+	Status struct {
+		Devices []MultizoneDevice `json:"devices"`
+	} `json:"status"`
+}
+
+type multizoneDeviceMessage struct {
+	requestMessage
+	Device MultizoneDevice `json:"device"`
+}
+
+type multizoneRemovedMessage struct {
+	requestMessage
+	DeviceID string `json:"deviceId"`
+}
+
+// RequestMultizoneStatus asks the receiver for the members of the speaker
+// group that it is connected as. Receivers that do not lead a group do not
+// answer.
+func (s *Sender) RequestMultizoneStatus() {
+	request := requestMessage{RequestID: s.nextRequestID(), Type: "GET_STATUS"}
+	payloadBytes, _ := json.Marshal(request)
+	s.client.SendMessage(newUTF8CastMessage(common.MultizoneNamespace, s.senderID, s.receiverID, string(payloadBytes)))
+}
+
+// MultizoneMembers returns the most recently reported group members, or nil
+// if no MULTIZONE_STATUS has been received.
+func (s *Sender) MultizoneMembers() []MultizoneDevice {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.membersLocked()
+}
+
+// WaitForMultizoneMembers blocks until a MULTIZONE_STATUS has been received,
+// and returns the group members.
+func (s *Sender) WaitForMultizoneMembers(timeout time.Duration) ([]MultizoneDevice, error) {
+	deadline := time.Now().Add(timeout)
+	timer := s.broadcastAtTimeout(timeout)
+	defer timer.Stop()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.members == nil {
+		if err := s.waitErrorLocked(deadline, "multizone status"); err != nil {
+			return nil, err
+		}
+		s.cond.Wait()
+	}
+	return s.membersLocked(), nil
+}
+
+func (s *Sender) membersLocked() []MultizoneDevice {
+	if s.members == nil {
+		return nil
+	}
+	return append([]MultizoneDevice{}, s.members...)
+}
+
+// GroupMembers connects to the Cast endpoint of a speaker group, and returns
+// its members.
+func GroupMembers(host string, port int, timeout time.Duration) ([]MultizoneDevice, error) {
+	connection, err := NewClient(host, uint(port), false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("read group members: %w", err)
+	}
+	defer connection.Close()
+
+	sender := NewSender(connection, nil)
+	sender.Connect()
+	sender.RequestMultizoneStatus()
+	members, err := sender.WaitForMultizoneMembers(timeout)
+	if err != nil {
+		return nil, fmt.Errorf("read group members: %w", err)
+	}
+	return members, nil
+}
+
+func (s *Sender) handleMultizoneMessage(castMessage *channel.CastMessage) {
+	if castMessage.PayloadUtf8 == nil {
+		return
+	}
+
+	payload := []byte(*castMessage.PayloadUtf8)
+
+	var envelope requestMessage
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		s.log.Warn("failed to parse multizone payload", "err", err)
+		return
+	}
+
+	switch envelope.messageType() {
+	case "MULTIZONE_STATUS":
+		var msg multizoneStatusMessage
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			s.log.Warn("failed to parse multizone status", "err", err)
+			return
+		}
+		s.mu.Lock()
+		s.members = append([]MultizoneDevice{}, msg.Status.Devices...)
+		s.cond.Broadcast()
+		s.mu.Unlock()
+	case "DEVICE_ADDED", "DEVICE_UPDATED":
+		var msg multizoneDeviceMessage
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			s.log.Warn("failed to parse multizone device", "err", err)
+			return
+		}
+		s.mu.Lock()
+		s.updateMemberLocked(msg.Device)
+		s.cond.Broadcast()
+		s.mu.Unlock()
+	case "DEVICE_REMOVED":
+		var msg multizoneRemovedMessage
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			s.log.Warn("failed to parse multizone device", "err", err)
+			return
+		}
+		s.mu.Lock()
+		s.removeMemberLocked(msg.DeviceID)
+		s.cond.Broadcast()
+		s.mu.Unlock()
+	}
+}
+
+// updateMemberLocked ignores changes that arrive before MULTIZONE_STATUS, since
+// the rest of the group is not known yet.
+func (s *Sender) updateMemberLocked(device MultizoneDevice) {
+	if s.members == nil {
+		return
+	}
+	for i, member := range s.members {
+		if member.DeviceID == device.DeviceID {
+			s.members[i] = device
+			return
+		}
+	}
+	s.members = append(s.members, device)
+}
+
+func (s *Sender) removeMemberLocked(deviceID string) {
+	for i, member := range s.members {
+		if member.DeviceID == deviceID {
+			s.members = append(s.members[:i], s.members[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tristanpenman/go-cast/internal/channel"
+	"github.com/tristanpenman/go-cast/internal/common"
+)
+
+func multizoneMessage(payload string) *channel.CastMessage {
+	namespace := common.MultizoneNamespace
+	return &channel.CastMessage{Namespace: &namespace, PayloadUtf8: &payload}
+}
+
+func TestHandleMultizoneMessages(t *testing.T) {
+	sender := testSender()
+
+	// changes before the first status are not enough to know the group
+	sender.handleMultizoneMessage(multizoneMessage(`{"type":"DEVICE_ADDED","device":{"deviceId":"early","name":"Early"}}`))
+	if members := sender.MultizoneMembers(); members != nil {
+		t.Fatalf("unexpected members before status: %+v", members)
+	}
+
+	for _, payload := range []string{
+		`{"requestId":1,"type":"MULTIZONE_STATUS","status":{"devices":[{"capabilities":196612,"deviceId":"kitchen","name":"Kitchen speaker"},{"capabilities":196612,"deviceId":"lounge","name":"Lounge"}],"isMultichannel":false}}`,
+		`{"type":"DEVICE_UPDATED","device":{"capabilities":196612,"deviceId":"kitchen","name":"Kitchen"}}`,
+		`{"type":"DEVICE_ADDED","device":{"capabilities":196612,"deviceId":"study","name":"Study"}}`,
+		`{"type":"DEVICE_REMOVED","deviceId":"lounge"}`,
+	} {
+		sender.handleMultizoneMessage(multizoneMessage(payload))
+	}
+
+	members, err := sender.WaitForMultizoneMembers(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []MultizoneDevice{
+		{Capabilities: 196612, DeviceID: "kitchen", Name: "Kitchen"},
+		{Capabilities: 196612, DeviceID: "study", Name: "Study"},
+	}
+	if !reflect.DeepEqual(members, want) {
+		t.Fatalf("got %+v, want %+v", members, want)
+	}
+}
+
+func TestWaitForMultizoneMembersTimesOut(t *testing.T) {
+	sender := testSender()
+	if _, err := sender.WaitForMultizoneMembers(10 * time.Millisecond); err == nil {
+		t.Fatal("expected timeout")
+	}
+}
@@ -109,6 +109,10 @@ type Sender struct {
 	status          *ReceiverStatus
 	availability    map[string]string
 	youtubeScreenID string
+	webrtcAnswers   map[int]string
+	members         []MultizoneDevice
+	deviceInfo      *DeviceInfo
+	eurekaInfo      *EurekaInfo
 	err             error
 	closed          bool
 }
@@ -476,6 +480,14 @@ func (s *Sender) readLoop() {
 			s.handleReceiverMessage(castMessage)
 		case youtubeNamespace:
 			s.handleYouTubeMessage(castMessage)
+		case common.WebRTCNamespace:
+			s.handleWebrtcMessage(castMessage)
+		case common.MultizoneNamespace:
+			s.handleMultizoneMessage(castMessage)
+		case common.DiscoveryNamespace:
+			s.handleDiscoveryMessage(castMessage)
+		case common.SetupNamespace:
+			s.handleSetupMessage(castMessage)
 		}
 	}
 
@@ -179,3 +179,26 @@ func receiverMessage(payload string) *channel.CastMessage {
 	namespace := common.ReceiverNamespace
 	return &channel.CastMessage{Namespace: &namespace, PayloadUtf8: &payload}
 }
+
+func TestWaitForWebrtcAnswer(t *testing.T) {
+	sender := testSender()
+	namespace := common.WebRTCNamespace
+	for _, payload := range []string{
+		`{"seqNum":1,"type":"STATUS_RESPONSE","result":"ok"}`,
+		`{"seqNum":2,"type":"ANSWER","result":"ok","answer":{"udpPort":50000}}`,
+	} {
+		sender.handleWebrtcMessage(&channel.CastMessage{Namespace: &namespace, PayloadUtf8: &payload})
+	}
+
+	answer, err := sender.WaitForWebrtcAnswer(2, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if answer != `{"seqNum":2,"type":"ANSWER","result":"ok","answer":{"udpPort":50000}}` {
+		t.Fatalf("unexpected answer %q", answer)
+	}
+
+	if _, err := sender.WaitForWebrtcAnswer(1, 10*time.Millisecond); err == nil {
+		t.Fatal("expected a timeout for an offer that was not answered")
+	}
+}
@@ -18,12 +18,23 @@ import (
 	"github.com/tristanpenman/go-cast/internal/transport"
 )
 
+// ErrDeviceAuth is wrapped by the error that NewClient returns when the
+// receiver fails device authentication, or does not complete it in time.
+var ErrDeviceAuth = errors.New("authenticate receiver")
+
+// connectTimeout bounds connecting to a receiver, including the TLS handshake,
+// so that an unreachable receiver fails quickly instead of after the operating
+// system's TCP timeout.
+var connectTimeout = 5 * time.Second
+
 type ServerConnection struct {
 	castChannel     transport.CastChannel
 	conn            net.Conn
 	peerCertificate []byte
 	authResult      chan error
 	authOnce        sync.Once
+	connectDuration time.Duration
+	authDuration    time.Duration
 	Incoming        chan *channel.CastMessage
 	log             hclog.Logger
 }
@@ -70,14 +81,16 @@ func (client *ServerConnection) completeDeviceAuth(err error) {
 func NewClient(hostname string, port uint, authChallenge bool, wg *sync.WaitGroup) (*ServerConnection, error) {
 	var log = common.NewLogger("client")
 
-	addr := fmt.Sprintf("%s:%d", hostname, port)
+	addr := net.JoinHostPort(hostname, fmt.Sprint(port))
 	log.Info(fmt.Sprintf("addr: %s", addr))
 
 	config := tls.Config{InsecureSkipVerify: true}
-	conn, err := tls.Dial("tcp", addr, &config)
+	connectStarted := time.Now()
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: connectTimeout}, "tcp", addr, &config)
 	if err != nil {
 		return nil, fmt.Errorf("connect to receiver: %w", err)
 	}
+	connectDuration := time.Since(connectStarted)
 	connectionState := conn.ConnectionState()
 	if len(connectionState.PeerCertificates) == 0 {
 		_ = conn.Close()
@@ -92,6 +105,7 @@ func NewClient(hostname string, port uint, authChallenge bool, wg *sync.WaitGrou
 		castChannel:     castChannel,
 		conn:            conn,
 		peerCertificate: connectionState.PeerCertificates[0].Raw,
+		connectDuration: connectDuration,
 		Incoming:        make(chan *channel.CastMessage, 64),
 		log:             log,
 	}
@@ -139,6 +153,7 @@ func NewClient(hostname string, port uint, authChallenge bool, wg *sync.WaitGrou
 	}()
 
 	if authChallenge {
+		authStarted := time.Now()
 		if err := client.sendDeviceAuthChallenge(); err != nil {
 			_ = client.Close()
 			return nil, err
@@ -147,12 +162,13 @@ func NewClient(hostname string, port uint, authChallenge bool, wg *sync.WaitGrou
 		case err := <-client.authResult:
 			if err != nil {
 				_ = client.Close()
-				return nil, fmt.Errorf("authenticate receiver: %w", err)
+				return nil, fmt.Errorf("%w: %w", ErrDeviceAuth, err)
 			}
+			client.authDuration = time.Since(authStarted)
 			log.Info("device authentication succeeded")
 		case <-time.After(5 * time.Second):
 			_ = client.Close()
-			return nil, errors.New("authenticate receiver: timed out waiting for device-auth response")
+			return nil, fmt.Errorf("%w: timed out waiting for device-auth response", ErrDeviceAuth)
 		}
 	}
 
@@ -179,6 +195,18 @@ func (client *ServerConnection) SendMessage(castMessage *channel.CastMessage) {
 	client.castChannel.Send(castMessage)
 }
 
+// ConnectDuration returns how long it took to establish the TLS connection.
+func (client *ServerConnection) ConnectDuration() time.Duration {
+	return client.connectDuration
+}
+
+// AuthDuration returns how long device authentication took, from sending the
+// challenge until the response was verified. It is zero if authentication was
+// not requested.
+func (client *ServerConnection) AuthDuration() time.Duration {
+	return client.authDuration
+}
+
 func (client *ServerConnection) Close() error {
 	return client.conn.Close()
 }
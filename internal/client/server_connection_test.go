@@ -0,0 +1,38 @@
+package client
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/common"
+)
+
+func TestNewClientTimesOutWhenReceiverNeverAnswers(t *testing.T) {
+	// the listener completes TCP handshakes, but never accepts a connection,
+	// so the TLS handshake is never answered
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = listener.Close()
+	}()
+
+	previous := connectTimeout
+	connectTimeout = 100 * time.Millisecond
+	defer func() {
+		connectTimeout = previous
+	}()
+
+	started := time.Now()
+	connection, err := NewClient("127.0.0.1", uint(common.GetPort(listener.Addr())), false, nil)
+	if err == nil {
+		_ = connection.Close()
+		t.Fatal("connected to a receiver that never answered")
+	}
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Fatalf("gave up after %v, want about %v", elapsed, connectTimeout)
+	}
+}
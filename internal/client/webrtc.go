@@ -0,0 +1,55 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/tristanpenman/go-cast/internal/channel"
+)
+
+type webrtcMessage struct {
+	SeqNum int    `json:"seqNum"`
+	Type   string `json:"type"`
+}
+
+// WaitForWebrtcAnswer blocks until the receiver answers the webrtc OFFER with
+// the given sequence number, and returns the raw ANSWER payload.
+func (s *Sender) WaitForWebrtcAnswer(seqNum int, timeout time.Duration) (string, error) {
+	deadline := time.Now().Add(timeout)
+	timer := s.broadcastAtTimeout(timeout)
+	defer timer.Stop()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for {
+		if answer, ok := s.webrtcAnswers[seqNum]; ok {
+			delete(s.webrtcAnswers, seqNum)
+			return answer, nil
+		}
+		if err := s.waitErrorLocked(deadline, "webrtc answer"); err != nil {
+			return "", err
+		}
+		s.cond.Wait()
+	}
+}
+
+func (s *Sender) handleWebrtcMessage(castMessage *channel.CastMessage) {
+	if castMessage.PayloadUtf8 == nil {
+		return
+	}
+	var message webrtcMessage
+	if err := json.Unmarshal([]byte(*castMessage.PayloadUtf8), &message); err != nil {
+		s.log.Warn("failed to parse webrtc message", "err", err)
+		return
+	}
+	if message.Type != "ANSWER" {
+		return
+	}
+	s.mu.Lock()
+	if s.webrtcAnswers == nil {
+		s.webrtcAnswers = make(map[int]string)
+	}
+	s.webrtcAnswers[message.SeqNum] = *castMessage.PayloadUtf8
+	s.cond.Broadcast()
+	s.mu.Unlock()
+}
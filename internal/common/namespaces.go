@@ -7,6 +7,7 @@ const (
 	DiscoveryNamespace  = "urn:x-cast:com.google.cast.receiver.discovery"
 	HeartbeatNamespace  = "urn:x-cast:com.google.cast.tp.heartbeat"
 	MediaNamespace      = "urn:x-cast:com.google.cast.media"
+	MultizoneNamespace  = "urn:x-cast:com.google.cast.multizone"
 	ReceiverNamespace   = "urn:x-cast:com.google.cast.receiver"
 	RemotingNamespace   = "urn:x-cast:com.google.cast.remoting"
 	SetupNamespace      = "urn:x-cast:com.google.cast.setup"
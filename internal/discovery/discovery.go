@@ -2,6 +2,7 @@
 package discovery
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"sort"
@@ -9,11 +10,13 @@ import (
 	"time"
 
 	"github.com/hashicorp/mdns"
+
+	"github.com/tristanpenman/go-cast/internal/mdnsnet"
 )
 
 const service = "_googlecast._tcp"
 
-// Device is the subset of a Google Cast mDNS advertisement needed by clients.
+// Device is a Google Cast receiver, as described by its mDNS advertisement.
 type Device struct {
 	ID              string `json:"id"`
 	Name            string `json:"name"`
@@ -21,62 +24,129 @@ type Device struct {
 	Host            string `json:"host"`
 	Port            int    `json:"port"`
 	ConnectionState string `json:"connectionState"`
+
+	// Busy is set while the device is running an app, which StatusText
+	// describes, such as "YouTube" or "Now Casting".
+	Busy       bool   `json:"busy"`
+	StatusText string `json:"statusText"`
+
+	Capabilities    Capabilities `json:"capabilities"`
+	IconPath        string       `json:"iconPath"`
+	ProtocolVersion int          `json:"protocolVersion"`
+
+	// GroupLeader is set while the device leads a speaker group. Multizone is
+	// set when the device advertises _googlezone._tcp, so that it can be a
+	// member of speaker groups.
+	GroupLeader bool `json:"groupLeader"`
+	Multizone   bool `json:"multizone"`
+
+	// Favorite is set by Store.Merge for devices that the user has marked.
+	Favorite bool `json:"favorite"`
+
+	// BS, CD and RM are undocumented identifiers, reported as they are.
+	BS string `json:"bs"`
+	CD string `json:"cd"`
+	RM string `json:"rm"`
+
+	// Firmware, UDN and the latencies are only known once the device has been
+	// enriched, which also moves ConnectionState on from "discovered".
+	Firmware       string        `json:"firmware"`
+	UDN            string        `json:"udn"`
+	ConnectLatency time.Duration `json:"connectLatency"`
+	AuthLatency    time.Duration `json:"authLatency"`
 }
 
-// Discover searches for Cast receivers until timeout expires. Duplicate mDNS
-// responses are collapsed and the result is sorted by friendly name.
-func Discover(timeout time.Duration) ([]Device, error) {
+// Discover searches for Cast receivers and speaker groups until timeout
+// expires. A device or group that responds more than once, or on more than one
+// interface, is reported once, and the results are sorted by friendly name.
+func Discover(timeout time.Duration, options Options) ([]Device, []Group, error) {
 	if timeout <= 0 {
-		return nil, fmt.Errorf("discovery timeout must be positive")
+		return nil, nil, fmt.Errorf("discovery timeout must be positive")
 	}
 
-	entries := make(chan *mdns.ServiceEntry, 16)
-	results := make(chan []Device, 1)
-
-	go func() {
-		seen := make(map[string]Device)
-		for entry := range entries {
-			device := deviceFromEntry(entry)
-			key := device.ID
-			if key == "" {
-				key = net.JoinHostPort(device.Host, fmt.Sprint(device.Port))
-			}
-			seen[key] = device
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	events, err := watch(ctx, options)
+	if err != nil {
+		return nil, nil, fmt.Errorf("discover cast devices: %w", err)
+	}
+
+	instances := make(map[string]Event)
+	for event := range events {
+		if event.Type == Removed {
+			delete(instances, event.Instance)
+		} else {
+			instances[event.Instance] = event
 		}
+	}
 
-		result := make([]Device, 0, len(seen))
-		for _, device := range seen {
-			result = append(result, device)
+	seenDevices := make(map[string]Device)
+	seenGroups := make(map[string]Group)
+	for _, event := range instances {
+		if event.Group != nil {
+			group := *event.Group
+			seenGroups[group.key()] = group
+		} else {
+			seenDevices[event.Device.key()] = event.Device
 		}
-		sort.Slice(result, func(i, j int) bool {
-			return strings.ToLower(result[i].Name) < strings.ToLower(result[j].Name)
-		})
-		results <- result
-	}()
-
-	params := mdns.DefaultParams(service)
-	params.Entries = entries
-	params.DisableIPv6 = true
-	params.Timeout = timeout
-
-	err := mdns.Query(params)
-	close(entries)
-	result := <-results
-	if err != nil {
-		return result, fmt.Errorf("discover cast devices: %w", err)
 	}
-	return result, nil
+
+	devices := make([]Device, 0, len(seenDevices))
+	for _, device := range seenDevices {
+		devices = append(devices, device)
+	}
+	SortDevices(devices)
+
+	groups := make([]Group, 0, len(seenGroups))
+	for _, group := range seenGroups {
+		groups = append(groups, group)
+	}
+	SortGroups(groups)
+
+	return devices, groups, nil
 }
 
-func deviceFromEntry(entry *mdns.ServiceEntry) Device {
-	txt := make(map[string]string, len(entry.InfoFields))
-	for _, field := range entry.InfoFields {
-		key, value, ok := strings.Cut(field, "=")
-		if ok {
-			txt[key] = value
+// Resolve browses until the device with the given ID is found, and returns it
+// as it was advertised. It allows a known device to be found again after its
+// address has changed. IDs are compared ignoring case and hyphens.
+func Resolve(ctx context.Context, id string, options Options) (Device, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	events, err := watch(ctx, options)
+	if err != nil {
+		return Device{}, fmt.Errorf("resolve device %s: %w", id, err)
+	}
+	for event := range events {
+		if event.Group == nil && event.Type != Removed && event.Device.ID != "" &&
+			normalizeID(event.Device.ID) == normalizeID(id) {
+			return event.Device, nil
 		}
 	}
+	return Device{}, fmt.Errorf("resolve device %s: %w", id, ctx.Err())
+}
+
+// SortDevices sorts devices by friendly name, ignoring case.
+func SortDevices(devices []Device) {
+	sortByName(devices, func(device Device) string { return device.Name })
+}
+
+func sortByName[T any](values []T, name func(T) string) {
+	sort.Slice(values, func(i, j int) bool {
+		return strings.ToLower(name(values[i])) < strings.ToLower(name(values[j]))
+	})
+}
 
+// key identifies a device, using its address when it has no ID.
+func (device Device) key() string {
+	if device.ID != "" {
+		return device.ID
+	}
+	return net.JoinHostPort(device.Host, fmt.Sprint(device.Port))
+}
+
+func deviceFromEntry(entry *mdns.ServiceEntry) Device {
 	host := strings.TrimSuffix(entry.Host, ".")
 	if entry.AddrV4 != nil {
 		host = entry.AddrV4.String()
@@ -84,20 +154,19 @@ func deviceFromEntry(entry *mdns.ServiceEntry) Device {
 		host = entry.AddrV6.String()
 	}
 
-	name := txt["fn"]
-	if name == "" {
-		name = strings.TrimSuffix(entry.Name, ".")
-		if serviceIndex := strings.Index(name, "."+service); serviceIndex >= 0 {
-			name = name[:serviceIndex]
-		}
-	}
-
-	return Device{
-		ID:              txt["id"],
-		Name:            name,
-		Model:           txt["md"],
+	device := Device{
 		Host:            host,
 		Port:            entry.Port,
 		ConnectionState: "discovered",
 	}
+	device.applyTXT(parseTXT(entry.InfoFields))
+
+	if device.Name == "" {
+		device.Name = strings.TrimSuffix(mdnsnet.Unescape(entry.Name), ".")
+		if serviceIndex := strings.Index(device.Name, "."+service); serviceIndex >= 0 {
+			device.Name = device.Name[:serviceIndex]
+		}
+	}
+
+	return device
 }
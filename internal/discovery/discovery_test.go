@@ -1,12 +1,73 @@
 package discovery
 
 import (
+	"context"
 	"net"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/brutella/dnssd"
 	"github.com/hashicorp/mdns"
+
+	"github.com/tristanpenman/go-cast/internal/mdnsnet"
 )
 
+// castService returns a Cast service for a responder to advertise. The TXT
+// record is given as "key=value" strings.
+func castService(t *testing.T, name string, ip string, txt ...string) dnssd.Service {
+	t.Helper()
+
+	text := make(map[string]string)
+	for _, entry := range txt {
+		key, value, _ := strings.Cut(entry, "=")
+		text[key] = value
+	}
+	service, err := dnssd.NewService(dnssd.Config{
+		Name: name,
+		Type: service,
+		Host: strings.ReplaceAll(name, " ", "-"),
+		Port: 8009,
+		Text: text,
+		IPs:  []net.IP{net.ParseIP(ip)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service
+}
+
+// advertise runs a responder for service on network, until the test ends.
+func advertise(t *testing.T, network *mdnsnet.Network, service dnssd.Service) *mdnsnet.Responder {
+	t.Helper()
+
+	responder := mdnsnet.NewResponder(network.Join(), service)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = responder.Respond(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	return responder
+}
+
+// browse returns options that browse network, as if from the given number of
+// interfaces.
+func browse(network *mdnsnet.Network, interfaces int) Options {
+	return Options{Listen: func() ([]mdnsnet.Conn, error) {
+		conns := make([]mdnsnet.Conn, interfaces)
+		for i := range conns {
+			conns[i] = network.Join()
+		}
+		return conns, nil
+	}}
+}
+
 func TestDeviceFromEntry(t *testing.T) {
 	entry := &mdns.ServiceEntry{
 		Name:       "Living Room._googlecast._tcp.local.",
@@ -45,7 +106,106 @@ func TestDeviceFromEntryFallsBackToHostAndServiceName(t *testing.T) {
 }
 
 func TestDiscoverRejectsInvalidTimeout(t *testing.T) {
-	if _, err := Discover(0); err == nil {
+	if _, _, err := Discover(0, Options{}); err == nil {
 		t.Fatal("expected an error")
 	}
 }
+
+func TestDiscoverOverNetwork(t *testing.T) {
+	network := mdnsnet.NewNetwork()
+	ultra := castService(t, "Chromecast-Ultra-5f1e", "192.0.2.10", readTXTFixture(t, "chromecast-ultra.txt")...)
+	advertise(t, network, ultra)
+	advertise(t, network, ultra)
+	advertise(t, network, castService(t, "Office TV", "192.0.2.20"))
+
+	// the device that answers twice, on each of two interfaces, is reported
+	// once
+	devices, groups, err := Discover(200*time.Millisecond, browse(network, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) != 2 || len(groups) != 0 {
+		t.Fatalf("got devices %+v and groups %+v, want 2 devices", devices, groups)
+	}
+
+	// TXT records survive encoding, and instance names are unescaped when
+	// they are used as the friendly name
+	want := Device{
+		ID: "5f1e1c2a9b3d4e6f8a7b6c5d4e3f2a1b", Name: "Living Room TV", Model: "Chromecast Ultra",
+		Host: "192.0.2.10", Port: 8009, ConnectionState: "discovered",
+		Busy: true, StatusText: "YouTube",
+		Capabilities: Capabilities(4101), IconPath: "/setup/icon.png", ProtocolVersion: 5,
+		BS: "FA8FCA3C1D2E", CD: "A3F19C0E5B7D2468ACE13579BDF02468",
+	}
+	if devices[0] != want {
+		t.Fatalf("got %+v, want %+v", devices[0], want)
+	}
+	if devices[1].Name != "Office TV" || devices[1].Host != "192.0.2.20" {
+		t.Fatalf("unexpected device %+v", devices[1])
+	}
+}
+
+func TestDiscoverGroupsOverNetwork(t *testing.T) {
+	network := mdnsnet.NewNetwork()
+	advertise(t, network, castService(t, "Google-Nest-Mini-0b8c", "192.0.2.30", readTXTFixture(t, "nest-mini.txt")...))
+	advertise(t, network, castService(t, "Chromecast-Ultra-5f1e", "192.0.2.10", readTXTFixture(t, "chromecast-ultra.txt")...))
+
+	// the speaker's zone advertisement uses a hyphenated ID
+	zone := castService(t, "0b8c2e74-d1a9-4f53-a6e2-c71f9d0b3e48", "192.0.2.30", "id=0b8c2e74-d1a9-4f53-a6e2-c71f9d0b3e48")
+	zone.Type = zoneService
+	advertise(t, network, zone)
+
+	// the group is advertised from its leader's address, on another port
+	group := castService(t, "Google-Cast-Group-9a7c", "192.0.2.30", readTXTFixture(t, "speaker-group.txt")...)
+	group.Port = 32187
+	advertise(t, network, group)
+
+	devices, groups, err := Discover(200*time.Millisecond, browse(network, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) != 2 || len(groups) != 1 {
+		t.Fatalf("got devices %+v and groups %+v, want 2 devices and a group", devices, groups)
+	}
+
+	speaker, tv := devices[0], devices[1]
+	if speaker.Name != "Kitchen speaker" || !speaker.GroupLeader || !speaker.Multizone {
+		t.Fatalf("unexpected speaker %+v", speaker)
+	}
+	if tv.Name != "Living Room TV" || tv.GroupLeader || tv.Multizone {
+		t.Fatalf("unexpected TV %+v", tv)
+	}
+
+	want := Group{
+		ID: "9a7c1e3b-5d2f-4b8a-9c6e-1f3a5b7d9e0c", Name: "Downstairs",
+		Host: "192.0.2.30", Port: 32187, Capabilities: Capabilities(199204),
+		LeaderID: "0b8c2e74d1a94f53a6e2c71f9d0b3e48",
+	}
+	if groups[0] != want {
+		t.Fatalf("got group %+v, want %+v", groups[0], want)
+	}
+}
+
+func TestResolveFindsDeviceByID(t *testing.T) {
+	network := mdnsnet.NewNetwork()
+	advertise(t, network, castService(t, "Office TV", "192.0.2.20", "id=0b8c2e74d1a94f53a6e2c71f9d0b3e48", "fn=Office TV"))
+	advertise(t, network, castService(t, "Kitchen", "192.0.2.30", "id=7e24b1c05a9f3d68e1b2c4a6f8d0e2c4", "fn=Kitchen"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// IDs are matched however they are formatted
+	device, err := Resolve(ctx, "7E24B1C0-5A9F-3D68-E1B2-C4A6F8D0E2C4", browse(network, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if device.Name != "Kitchen" || device.Host != "192.0.2.30" {
+		t.Fatalf("resolved %+v", device)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := Resolve(ctx, "missing", browse(network, 1)); err == nil {
+		t.Fatal("expected an error for a device that is not advertised")
+	}
+}
@@ -0,0 +1,105 @@
+package discovery
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/client"
+)
+
+// EnrichOptions controls how devices are queried by Enrich.
+type EnrichOptions struct {
+	// DeviceAuth challenges each device to prove that it is a genuine Cast
+	// device. Devices that fail are reported as "auth-failed", and are not
+	// queried.
+	DeviceAuth bool
+
+	// Timeout bounds each query. Connecting and authenticating are bounded
+	// separately, by the client, to a few seconds each.
+	Timeout time.Duration
+}
+
+// connect opens a connection to a receiver, as client.NewClient does.
+type connect func(host string, port uint, authChallenge bool) (*client.ServerConnection, error)
+
+func dial(host string, port uint, authChallenge bool) (*client.ServerConnection, error) {
+	return client.NewClient(host, port, authChallenge, nil)
+}
+
+// Enrich connects to each device, and completes what its advertisement said
+// with what the device reports about itself, on the discovery and setup
+// namespaces. Advertisements can be truncated or stale, so the reported name,
+// model and capabilities are preferred. Devices are queried concurrently, and
+// returned in the same order.
+//
+// ConnectionState is set to "reachable" for each device that could be
+// connected to, even if it did not answer every query, "auth-failed" if it did
+// not pass device authentication, and "unreachable" otherwise.
+func Enrich(devices []Device, options EnrichOptions) []Device {
+	return enrich(devices, options, dial)
+}
+
+func enrich(devices []Device, options EnrichOptions, connect connect) []Device {
+	enriched := make([]Device, len(devices))
+	var wg sync.WaitGroup
+	for i, device := range devices {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			enriched[i] = enrichDevice(device, options, connect)
+		}()
+	}
+	wg.Wait()
+	return enriched
+}
+
+func enrichDevice(device Device, options EnrichOptions, connect connect) Device {
+	if device.Port < 1 || device.Port > 65535 {
+		device.ConnectionState = "unreachable"
+		return device
+	}
+
+	connection, err := connect(device.Host, uint(device.Port), options.DeviceAuth)
+	if errors.Is(err, client.ErrDeviceAuth) {
+		device.ConnectionState = "auth-failed"
+		return device
+	}
+	if err != nil {
+		device.ConnectionState = "unreachable"
+		return device
+	}
+	defer connection.Close()
+
+	device.ConnectionState = "reachable"
+	device.ConnectLatency = connection.ConnectDuration()
+	device.AuthLatency = connection.AuthDuration()
+
+	sender := client.NewSender(connection, nil)
+	sender.Connect()
+	sender.RequestDeviceInfo()
+	sender.RequestEurekaInfo()
+
+	if info, err := sender.WaitForDeviceInfo(options.Timeout); err == nil {
+		// manually added devices are only identified once they are connected to
+		if device.ID == "" {
+			device.ID = info.DeviceID
+		}
+		if info.Capabilities != 0 {
+			device.Capabilities = Capabilities(info.Capabilities)
+		}
+		if info.Model != "" {
+			device.Model = info.Model
+		}
+		if info.Name != "" {
+			device.Name = info.Name
+		}
+	}
+	if info, err := sender.WaitForEurekaInfo(options.Timeout); err == nil {
+		device.Firmware = info.Firmware
+		device.UDN = info.UDN
+	}
+
+	return device
+}
@@ -0,0 +1,54 @@
+package discovery
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/tristanpenman/go-cast/internal/client"
+)
+
+func TestEnrichReportsUnreachableDevices(t *testing.T) {
+	// a port that was just released is not being listened on
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	_ = listener.Close()
+
+	devices := []Device{
+		{Name: "Closed", Host: "127.0.0.1", Port: port, ConnectionState: "discovered"},
+		{Name: "No port", Host: "127.0.0.1", ConnectionState: "discovered"},
+	}
+	for _, device := range Enrich(devices, EnrichOptions{Timeout: time.Second}) {
+		if device.ConnectionState != "unreachable" {
+			t.Fatalf("%s: connection state %q, want unreachable", device.Name, device.ConnectionState)
+		}
+	}
+}
+
+func TestEnrichDistinguishesFailedDeviceAuth(t *testing.T) {
+	connect := func(host string, port uint, authChallenge bool) (*client.ServerConnection, error) {
+		if !authChallenge {
+			t.Error("device auth was not requested")
+		}
+		if host == "192.0.2.10" {
+			return nil, fmt.Errorf("%w: untrusted certificate", client.ErrDeviceAuth)
+		}
+		return nil, fmt.Errorf("connect to receiver: connection refused")
+	}
+
+	devices := []Device{
+		{Name: "Impostor", Host: "192.0.2.10", Port: 8009},
+		{Name: "Offline", Host: "192.0.2.11", Port: 8009},
+	}
+	enriched := enrich(devices, EnrichOptions{DeviceAuth: true, Timeout: time.Second}, connect)
+	if enriched[0].ConnectionState != "auth-failed" || enriched[1].ConnectionState != "unreachable" {
+		t.Fatalf("unexpected connection states %q and %q", enriched[0].ConnectionState, enriched[1].ConnectionState)
+	}
+	if enriched[0].Name != "Impostor" || enriched[1].Name != "Offline" {
+		t.Fatal("devices were reordered")
+	}
+}
@@ -0,0 +1,62 @@
+package discovery
+
+import "strings"
+
+// zoneService is advertised by devices that can be members of speaker groups.
+const zoneService = "_googlezone._tcp"
+
+// Group is a speaker group. A group is advertised like a Cast device, but from
+// the address of its leader, which is the member that currently hosts the
+// group's Cast endpoint, on a port of its own. Its members are read by
+// connecting to the group, on the multizone namespace.
+type Group struct {
+	ID           string       `json:"id"`
+	Name         string       `json:"name"`
+	Host         string       `json:"host"`
+	Port         int          `json:"port"`
+	Capabilities Capabilities `json:"capabilities"`
+
+	// Busy is set while the group is playing, which StatusText describes.
+	Busy       bool   `json:"busy"`
+	StatusText string `json:"statusText"`
+
+	// LeaderID is the ID of the device at the group's address, or empty if that
+	// device has not been found.
+	LeaderID string `json:"leaderId"`
+}
+
+func newGroup(device Device, leaderID string) *Group {
+	return &Group{
+		ID:           device.ID,
+		Name:         device.Name,
+		Host:         device.Host,
+		Port:         device.Port,
+		Capabilities: device.Capabilities,
+		Busy:         device.Busy,
+		StatusText:   device.StatusText,
+		LeaderID:     leaderID,
+	}
+}
+
+// key identifies a group, using its address when it has no ID.
+func (group Group) key() string {
+	return Device{ID: group.ID, Host: group.Host, Port: group.Port}.key()
+}
+
+// isGroup reports whether a Cast advertisement is for a speaker group rather
+// than a device.
+func (device Device) isGroup() bool {
+	return device.Capabilities.Has(MultizoneGroup)
+}
+
+// SortGroups sorts groups by friendly name, ignoring case.
+func SortGroups(groups []Group) {
+	sortByName(groups, func(group Group) string { return group.Name })
+}
+
+// normalizeID allows device IDs to be compared across advertisements, which
+// format them differently. Cast advertisements use 32 hex digits, while zone
+// and group advertisements use hyphenated UUIDs.
+func normalizeID(id string) string {
+	return strings.ToLower(strings.ReplaceAll(id, "-", ""))
+}
@@ -0,0 +1,298 @@
+package discovery
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	// third-party
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/mdnsnet"
+)
+
+var (
+	mdnsGroupV4 = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+	mdnsGroupV6 = &net.UDPAddr{IP: net.ParseIP("ff02::fb"), Port: 5353}
+)
+
+// Options selects the networks that are browsed for devices. The zero value
+// browses the IPv4 mDNS group on every multicast interface.
+type Options struct {
+	// Interface limits browsing to one network interface, given by name or by
+	// one of its addresses, like the receiver's --iface flag. When it is
+	// empty, loopback and point-to-point interfaces, such as most VPN tunnels,
+	// are skipped.
+	Interface string
+
+	// IPv6 also browses the IPv6 mDNS group, and allows devices to be reported
+	// with IPv6 addresses. Link-local addresses carry the zone of the
+	// interface that they were found on, such as "fe80::1%eth0".
+	IPv6 bool
+
+	// Listen opens the connections that are browsed, instead of multicast
+	// sockets on the interfaces that Interface and IPv6 select. Interfaces are
+	// not known for these connections, so every response is used. It allows
+	// browsing another network, such as an mdnsnet.Network.
+	Listen func() ([]mdnsnet.Conn, error)
+}
+
+// link is a browsed network interface, and the networks that it is attached
+// to. Addresses on those networks are assumed to be reachable.
+type link struct {
+	name     string
+	networks []*net.IPNet
+}
+
+func (l link) contains(ip net.IP) bool {
+	for _, network := range l.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l link) hasFamily(ipv4 bool) bool {
+	for _, network := range l.networks {
+		if (network.IP.To4() != nil) == ipv4 {
+			return true
+		}
+	}
+	return false
+}
+
+// selectInterfaces returns the interfaces to browse, as described by
+// Options.Interface.
+func selectInterfaces(value string) ([]net.Interface, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("list network interfaces: %w", err)
+	}
+
+	value = strings.TrimSpace(value)
+	if value == "" {
+		var selected []net.Interface
+		for _, iface := range interfaces {
+			if iface.Flags&net.FlagUp != 0 && iface.Flags&net.FlagMulticast != 0 &&
+				iface.Flags&(net.FlagLoopback|net.FlagPointToPoint) == 0 {
+				selected = append(selected, iface)
+			}
+		}
+		if len(selected) == 0 {
+			return nil, errors.New("no multicast network interfaces")
+		}
+		return selected, nil
+	}
+
+	// addresses may carry a zone, as link-local addresses do in --iface values
+	if ip := net.ParseIP(strings.SplitN(value, "%", 2)[0]); ip != nil {
+		for _, iface := range interfaces {
+			addrs, err := iface.Addrs()
+			if err != nil {
+				continue
+			}
+			for _, addr := range addrs {
+				if network, ok := addr.(*net.IPNet); ok && network.IP.Equal(ip) {
+					return []net.Interface{iface}, nil
+				}
+			}
+		}
+		return nil, fmt.Errorf("no network interface owns address %s", value)
+	}
+
+	for _, iface := range interfaces {
+		if iface.Name == value {
+			return []net.Interface{iface}, nil
+		}
+	}
+	return nil, fmt.Errorf("no network interface named %s", value)
+}
+
+// newLinks describes interfaces by index.
+func newLinks(interfaces []net.Interface) (map[int]link, error) {
+	links := make(map[int]link, len(interfaces))
+	for _, iface := range interfaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, fmt.Errorf("list addresses for interface %s: %w", iface.Name, err)
+		}
+
+		l := link{name: iface.Name}
+		for _, addr := range addrs {
+			if network, ok := addr.(*net.IPNet); ok {
+				l.networks = append(l.networks, network)
+			}
+		}
+		links[iface.Index] = l
+	}
+	return links, nil
+}
+
+// listen opens an mDNS socket for each address family that options select,
+// joined to the mDNS group on each of the interfaces that has an address in
+// that family. It is an error if no socket can be opened, but a family that
+// fails is otherwise skipped, so that single-stack networks can be browsed.
+func listen(interfaces []net.Interface, links map[int]link, options Options) ([]mdnsnet.Conn, error) {
+	var conns []mdnsnet.Conn
+	var errs []error
+
+	if conn, err := listenIPv4(interfaces, links); err != nil {
+		errs = append(errs, err)
+	} else if conn != nil {
+		conns = append(conns, conn)
+	}
+
+	if options.IPv6 {
+		if conn, err := listenIPv6(interfaces, links); err != nil {
+			errs = append(errs, err)
+		} else if conn != nil {
+			conns = append(conns, conn)
+		}
+	}
+
+	if len(conns) == 0 {
+		if len(errs) == 0 {
+			return nil, errors.New("no network interface has an address to browse from")
+		}
+		return nil, errors.Join(errs...)
+	}
+	return conns, nil
+}
+
+type ipv4Conn struct {
+	conn       *ipv4.PacketConn
+	interfaces []net.Interface
+}
+
+// listenIPv4 returns nil if no interface has an IPv4 address.
+func listenIPv4(interfaces []net.Interface, links map[int]link) (*ipv4Conn, error) {
+	var joinable []net.Interface
+	for _, iface := range interfaces {
+		if links[iface.Index].hasFamily(true) {
+			joinable = append(joinable, iface)
+		}
+	}
+	if len(joinable) == 0 {
+		return nil, nil
+	}
+
+	// listening on the group address binds the wildcard address, and allows
+	// the port to be shared with other mDNS responders
+	packetConn, err := net.ListenPacket("udp4", mdnsGroupV4.String())
+	if err != nil {
+		return nil, fmt.Errorf("listen for ipv4 mdns: %w", err)
+	}
+
+	conn := &ipv4Conn{conn: ipv4.NewPacketConn(packetConn)}
+	for i := range joinable {
+		if err := conn.conn.JoinGroup(&joinable[i], mdnsGroupV4); err == nil {
+			conn.interfaces = append(conn.interfaces, joinable[i])
+		}
+	}
+	if len(conn.interfaces) == 0 {
+		_ = packetConn.Close()
+		return nil, errors.New("join ipv4 mdns group: no interface could join")
+	}
+	if err := conn.conn.SetControlMessage(ipv4.FlagInterface, true); err != nil {
+		_ = packetConn.Close()
+		return nil, fmt.Errorf("listen for ipv4 mdns: %w", err)
+	}
+
+	return conn, nil
+}
+
+func (c *ipv4Conn) Read(buffer []byte) (int, int, error) {
+	n, cm, _, err := c.conn.ReadFrom(buffer)
+	if cm == nil {
+		return n, 0, err
+	}
+	return n, cm.IfIndex, err
+}
+
+func (c *ipv4Conn) Send(message []byte) error {
+	var errs []error
+	for i := range c.interfaces {
+		if err := c.conn.SetMulticastInterface(&c.interfaces[i]); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if _, err := c.conn.WriteTo(message, nil, mdnsGroupV4); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *ipv4Conn) Close() error {
+	return c.conn.Close()
+}
+
+type ipv6Conn struct {
+	conn       *ipv6.PacketConn
+	interfaces []net.Interface
+}
+
+// listenIPv6 returns nil if no interface has an IPv6 address.
+func listenIPv6(interfaces []net.Interface, links map[int]link) (*ipv6Conn, error) {
+	var joinable []net.Interface
+	for _, iface := range interfaces {
+		if links[iface.Index].hasFamily(false) {
+			joinable = append(joinable, iface)
+		}
+	}
+	if len(joinable) == 0 {
+		return nil, nil
+	}
+
+	packetConn, err := net.ListenPacket("udp6", mdnsGroupV6.String())
+	if err != nil {
+		return nil, fmt.Errorf("listen for ipv6 mdns: %w", err)
+	}
+
+	conn := &ipv6Conn{conn: ipv6.NewPacketConn(packetConn)}
+	for i := range joinable {
+		if err := conn.conn.JoinGroup(&joinable[i], mdnsGroupV6); err == nil {
+			conn.interfaces = append(conn.interfaces, joinable[i])
+		}
+	}
+	if len(conn.interfaces) == 0 {
+		_ = packetConn.Close()
+		return nil, errors.New("join ipv6 mdns group: no interface could join")
+	}
+	if err := conn.conn.SetControlMessage(ipv6.FlagInterface, true); err != nil {
+		_ = packetConn.Close()
+		return nil, fmt.Errorf("listen for ipv6 mdns: %w", err)
+	}
+
+	return conn, nil
+}
+
+func (c *ipv6Conn) Read(buffer []byte) (int, int, error) {
+	n, cm, _, err := c.conn.ReadFrom(buffer)
+	if cm == nil {
+		return n, 0, err
+	}
+	return n, cm.IfIndex, err
+}
+
+func (c *ipv6Conn) Send(message []byte) error {
+	var errs []error
+	for i := range c.interfaces {
+		if err := c.conn.SetMulticastInterface(&c.interfaces[i]); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if _, err := c.conn.WriteTo(message, nil, mdnsGroupV6); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *ipv6Conn) Close() error {
+	return c.conn.Close()
+}
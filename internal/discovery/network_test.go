@@ -0,0 +1,79 @@
+package discovery
+
+import (
+	"net"
+	"testing"
+)
+
+func loopbackInterface(t *testing.T) net.Interface {
+	t.Helper()
+
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			return iface
+		}
+	}
+	t.Skip("no loopback interface")
+	return net.Interface{}
+}
+
+func TestSelectInterfacesByNameOrAddress(t *testing.T) {
+	loopback := loopbackInterface(t)
+
+	for _, value := range []string{loopback.Name, " " + loopback.Name + " ", "127.0.0.1"} {
+		selected, err := selectInterfaces(value)
+		if err != nil {
+			t.Fatalf("select %q: %v", value, err)
+		}
+		if len(selected) != 1 || selected[0].Index != loopback.Index {
+			t.Fatalf("select %q: got %+v, want %s", value, selected, loopback.Name)
+		}
+	}
+
+	for _, value := range []string{"no-such-interface0", "192.0.2.254"} {
+		if _, err := selectInterfaces(value); err == nil {
+			t.Fatalf("select %q: expected an error", value)
+		}
+	}
+}
+
+func TestSelectInterfacesSkipsLoopbackByDefault(t *testing.T) {
+	loopback := loopbackInterface(t)
+
+	selected, err := selectInterfaces("")
+	if err != nil {
+		// there may be no other interfaces to select
+		return
+	}
+	for _, iface := range selected {
+		if iface.Index == loopback.Index {
+			t.Fatalf("loopback interface %s was selected", iface.Name)
+		}
+		if iface.Flags&net.FlagMulticast == 0 {
+			t.Fatalf("non-multicast interface %s was selected", iface.Name)
+		}
+	}
+}
+
+func TestNewLinksDescribesNetworks(t *testing.T) {
+	loopback := loopbackInterface(t)
+
+	links, err := newLinks([]net.Interface{loopback})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, ok := links[loopback.Index]
+	if !ok || l.name != loopback.Name {
+		t.Fatalf("got links %+v, want %s", links, loopback.Name)
+	}
+	if !l.contains(net.ParseIP("127.0.0.1")) || !l.hasFamily(true) {
+		t.Fatalf("loopback link %+v does not contain 127.0.0.1", l)
+	}
+	if l.contains(net.ParseIP("192.0.2.1")) {
+		t.Fatalf("loopback link %+v contains 192.0.2.1", l)
+	}
+}
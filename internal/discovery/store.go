@@ -0,0 +1,353 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KnownDevice is a device that a Store remembers, as it was last seen.
+// Manually added hosts have no ID until they are seen by discovery or
+// enrichment.
+type KnownDevice struct {
+	ID       string    `json:"id,omitempty"`
+	Name     string    `json:"name"`
+	Model    string    `json:"model,omitempty"`
+	Host     string    `json:"host"`
+	Port     int       `json:"port"`
+	Favorite bool      `json:"favorite,omitempty"`
+	Manual   bool      `json:"manual,omitempty"`
+	LastSeen time.Time `json:"lastSeen,omitzero"`
+}
+
+// Key identifies the device in its store, by its ID, or by its address if it
+// has no ID.
+func (device KnownDevice) Key() string {
+	if device.ID != "" {
+		return normalizeID(device.ID)
+	}
+	return net.JoinHostPort(device.Host, fmt.Sprint(device.Port))
+}
+
+type storeFile struct {
+	Devices []KnownDevice `json:"devices"`
+}
+
+// Store is a list of known devices, saved as JSON, so that devices can be
+// listed and connected to on networks where discovery fails, and favorites are
+// kept between runs. It is safe for concurrent use.
+type Store struct {
+	mu      sync.Mutex
+	devices []KnownDevice
+	now     func() time.Time
+	path    string
+}
+
+// DefaultStorePath returns the path of the store that is shared by the
+// remote and the command line tools, in the user's configuration directory.
+func DefaultStorePath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("find user config dir: %w", err)
+	}
+	return filepath.Join(dir, "go-cast", "devices.json"), nil
+}
+
+// OpenStore reads the store at path. A store that does not exist yet is empty,
+// and is created when it is first changed.
+func OpenStore(path string) (*Store, error) {
+	store := &Store{now: time.Now, path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+
+	var file storeFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", path, err)
+	}
+	store.devices = file.Devices
+	return store, nil
+}
+
+// Devices returns the known devices, with favorites first, then by name.
+func (s *Store) Devices() []KnownDevice {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	devices := append([]KnownDevice(nil), s.devices...)
+	sortFavoritesFirst(devices, func(device KnownDevice) (bool, string) { return device.Favorite, device.Name })
+	return devices
+}
+
+// Remember records where discovered devices were seen, and what they were
+// called. Devices are matched by ID, so a device that has moved to a new
+// address is updated rather than added again. A manually added host takes the
+// ID of the device that is found at its address. Devices without an ID are
+// only remembered if they were added manually.
+func (s *Store) Remember(devices ...Device) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	changed := false
+	for _, device := range devices {
+		if device.Host == "" || device.Port == 0 {
+			continue
+		}
+
+		i := s.indexLocked(device.ID, device.Host, device.Port)
+		if i < 0 {
+			if device.ID == "" {
+				continue
+			}
+			s.devices = append(s.devices, KnownDevice{ID: device.ID})
+			i = len(s.devices) - 1
+		}
+
+		known := &s.devices[i]
+		if known.ID == "" {
+			known.ID = device.ID
+		}
+		known.Host = device.Host
+		known.Port = device.Port
+		if device.Name != "" {
+			known.Name = device.Name
+		}
+		if device.Model != "" {
+			known.Model = device.Model
+		}
+		known.LastSeen = now
+		changed = true
+		s.absorbLocked(i)
+	}
+
+	if !changed {
+		return nil
+	}
+	return s.saveLocked()
+}
+
+// Find returns the known device with the given key, ID or name. Names are
+// compared ignoring case, and must be unambiguous.
+func (s *Store) Find(query string) (KnownDevice, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if i := s.keyIndexLocked(query); i >= 0 {
+		return s.devices[i], nil
+	}
+
+	var matches []KnownDevice
+	for _, known := range s.devices {
+		if strings.EqualFold(known.Name, query) {
+			matches = append(matches, known)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return KnownDevice{}, fmt.Errorf("find device: no known device %q", query)
+	case 1:
+		return matches[0], nil
+	default:
+		return KnownDevice{}, fmt.Errorf("find device: %d known devices are named %q", len(matches), query)
+	}
+}
+
+// Relocate browses for the device with the given ID, for when it cannot be
+// reached at its last known address, such as after DHCP has given it a new
+// one. The address that it is found at is remembered.
+func (s *Store) Relocate(ctx context.Context, id string, options Options) (Device, error) {
+	device, err := Resolve(ctx, id, options)
+	if err != nil {
+		return Device{}, err
+	}
+	if err := s.Remember(device); err != nil {
+		return Device{}, err
+	}
+	return device, nil
+}
+
+// AddManual adds a host that discovery may not find, such as a device on
+// another subnet. Adding a host that is already known marks it as manually
+// added.
+func (s *Store) AddManual(host string, port int) (KnownDevice, error) {
+	host = strings.TrimSpace(host)
+	if host == "" || port < 1 || port > 65535 {
+		return KnownDevice{}, fmt.Errorf("invalid device address %q", net.JoinHostPort(host, fmt.Sprint(port)))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexLocked("", host, port)
+	if i < 0 {
+		s.devices = append(s.devices, KnownDevice{Name: host, Host: host, Port: port})
+		i = len(s.devices) - 1
+	}
+	s.devices[i].Manual = true
+
+	if err := s.saveLocked(); err != nil {
+		return KnownDevice{}, err
+	}
+	return s.devices[i], nil
+}
+
+// SetFavorite marks or unmarks the device with the given key as a favorite.
+// The key can also be a device ID that is formatted differently.
+func (s *Store) SetFavorite(key string, favorite bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.keyIndexLocked(key)
+	if i < 0 {
+		return fmt.Errorf("set favorite: unknown device %q", key)
+	}
+	s.devices[i].Favorite = favorite
+	return s.saveLocked()
+}
+
+// Forget removes the device with the given key.
+func (s *Store) Forget(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.keyIndexLocked(key)
+	if i < 0 {
+		return fmt.Errorf("forget device: unknown device %q", key)
+	}
+	s.devices = append(s.devices[:i], s.devices[i+1:]...)
+	return s.saveLocked()
+}
+
+// Merge combines discovered devices with the known devices that were not
+// discovered, which are reported with the ConnectionState "remembered" at the
+// address that they were last seen at. Favorites are marked, and listed first.
+func (s *Store) Merge(discovered []Device) []Device {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	merged := make([]Device, 0, len(discovered)+len(s.devices))
+	found := make(map[int]bool)
+	for _, device := range discovered {
+		if i := s.indexLocked(device.ID, device.Host, device.Port); i >= 0 {
+			device.Favorite = s.devices[i].Favorite
+			found[i] = true
+		}
+		merged = append(merged, device)
+	}
+
+	for i, known := range s.devices {
+		if found[i] {
+			continue
+		}
+		merged = append(merged, Device{
+			ID:              known.ID,
+			Name:            known.Name,
+			Model:           known.Model,
+			Host:            known.Host,
+			Port:            known.Port,
+			ConnectionState: "remembered",
+			Favorite:        known.Favorite,
+		})
+	}
+
+	sortFavoritesFirst(merged, func(device Device) (bool, string) { return device.Favorite, device.Name })
+	return merged
+}
+
+// absorbLocked merges a manually added host without an ID into the device at
+// index i, once that device has been seen at the host's address.
+func (s *Store) absorbLocked(i int) {
+	known := s.devices[i]
+	for j, other := range s.devices {
+		if j != i && other.ID == "" && other.Host == known.Host && other.Port == known.Port {
+			s.devices[i].Favorite = known.Favorite || other.Favorite
+			s.devices[i].Manual = known.Manual || other.Manual
+			s.devices = append(s.devices[:j], s.devices[j+1:]...)
+			return
+		}
+	}
+}
+
+// indexLocked finds a device by ID, or failing that, a device without an ID at
+// the given address.
+func (s *Store) indexLocked(id, host string, port int) int {
+	if id != "" {
+		for i, known := range s.devices {
+			if known.ID != "" && normalizeID(known.ID) == normalizeID(id) {
+				return i
+			}
+		}
+	}
+	for i, known := range s.devices {
+		if known.ID == "" && known.Host == host && known.Port == port {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Store) keyIndexLocked(key string) int {
+	for i, known := range s.devices {
+		if known.Key() == key || (known.ID != "" && normalizeID(known.ID) == normalizeID(key)) {
+			return i
+		}
+	}
+	return -1
+}
+
+// saveLocked replaces the store's file, so that it is never left partially
+// written.
+func (s *Store) saveLocked() error {
+	data, err := json.MarshalIndent(storeFile{Devices: s.devices}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", s.path, err)
+	}
+	data = append(data, '\n')
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("create %s: %w", filepath.Dir(s.path), err)
+	}
+	file, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("write %s: %w", s.path, err)
+	}
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		return fmt.Errorf("write %s: %w", s.path, err)
+	}
+	if err := file.Close(); err != nil {
+		_ = os.Remove(file.Name())
+		return fmt.Errorf("write %s: %w", s.path, err)
+	}
+	if err := os.Rename(file.Name(), s.path); err != nil {
+		_ = os.Remove(file.Name())
+		return fmt.Errorf("write %s: %w", s.path, err)
+	}
+	return nil
+}
+
+func sortFavoritesFirst[T any](values []T, describe func(T) (favorite bool, name string)) {
+	sort.SliceStable(values, func(i, j int) bool {
+		favoriteI, nameI := describe(values[i])
+		favoriteJ, nameJ := describe(values[j])
+		if favoriteI != favoriteJ {
+			return favoriteI
+		}
+		return strings.ToLower(nameI) < strings.ToLower(nameJ)
+	})
+}
@@ -0,0 +1,208 @@
+package discovery
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tristanpenman/go-cast/internal/mdnsnet"
+)
+
+func openTestStore(t *testing.T, path string) *Store {
+	t.Helper()
+
+	store, err := OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.now = func() time.Time { return time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC) }
+	return store
+}
+
+func TestStoreRemembersDevicesBetweenRuns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "go-cast", "devices.json")
+	store := openTestStore(t, path)
+	if len(store.Devices()) != 0 {
+		t.Fatal("new store is not empty")
+	}
+
+	if err := store.Remember(
+		Device{ID: "5f1e1c2a9b3d4e6f8a7b6c5d4e3f2a1b", Name: "Living Room TV", Model: "Chromecast Ultra", Host: "192.0.2.10", Port: 8009},
+		Device{Name: "Unidentified", Host: "192.0.2.99", Port: 8009},
+	); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetFavorite("5F1E1C2A-9B3D-4E6F-8A7B-6C5D4E3F2A1B", true); err != nil {
+		t.Fatal(err)
+	}
+
+	// devices without an ID are not remembered unless they were added by hand
+	reopened := openTestStore(t, path)
+	devices := reopened.Devices()
+	if len(devices) != 1 {
+		t.Fatalf("remembered %+v, want one device", devices)
+	}
+	want := KnownDevice{
+		ID: "5f1e1c2a9b3d4e6f8a7b6c5d4e3f2a1b", Name: "Living Room TV", Model: "Chromecast Ultra",
+		Host: "192.0.2.10", Port: 8009, Favorite: true, LastSeen: time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC),
+	}
+	if !devices[0].LastSeen.Equal(want.LastSeen) {
+		t.Fatalf("last seen %v, want %v", devices[0].LastSeen, want.LastSeen)
+	}
+	devices[0].LastSeen = want.LastSeen
+	if devices[0] != want {
+		t.Fatalf("got %+v, want %+v", devices[0], want)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("store is readable by others: %v", info.Mode())
+	}
+}
+
+func TestStoreFollowsDevicesToNewAddresses(t *testing.T) {
+	store := openTestStore(t, filepath.Join(t.TempDir(), "devices.json"))
+	if err := store.Remember(Device{ID: "0b8c2e74d1a94f53a6e2c71f9d0b3e48", Name: "Kitchen", Host: "192.0.2.30", Port: 8009}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the same device, after its DHCP lease moved it, with its ID hyphenated
+	if err := store.Remember(Device{ID: "0b8c2e74-d1a9-4f53-a6e2-c71f9d0b3e48", Name: "Kitchen", Host: "192.0.2.31", Port: 8009}); err != nil {
+		t.Fatal(err)
+	}
+	devices := store.Devices()
+	if len(devices) != 1 || devices[0].Host != "192.0.2.31" {
+		t.Fatalf("got %+v, want the device at its new address", devices)
+	}
+}
+
+func TestStoreIdentifiesManualHosts(t *testing.T) {
+	store := openTestStore(t, filepath.Join(t.TempDir(), "devices.json"))
+	if _, err := store.AddManual("192.0.2.40", 0); err == nil {
+		t.Fatal("expected an error for an invalid port")
+	}
+	manual, err := store.AddManual("192.0.2.40", 8009)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetFavorite(manual.Key(), true); err != nil {
+		t.Fatal(err)
+	}
+
+	// the device at the manual address is identified once it is seen
+	if err := store.Remember(Device{ID: "7e24b1c05a9f3d68e1b2c4a6f8d0e2c4", Name: "Garage", Host: "192.0.2.40", Port: 8009}); err != nil {
+		t.Fatal(err)
+	}
+	devices := store.Devices()
+	if len(devices) != 1 || devices[0].ID != "7e24b1c05a9f3d68e1b2c4a6f8d0e2c4" || devices[0].Name != "Garage" ||
+		!devices[0].Manual || !devices[0].Favorite {
+		t.Fatalf("got %+v, want the manual host identified", devices)
+	}
+
+	if err := store.Forget("7e24b1c05a9f3d68e1b2c4a6f8d0e2c4"); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.Devices()) != 0 {
+		t.Fatal("device was not forgotten")
+	}
+	if err := store.Forget("7e24b1c05a9f3d68e1b2c4a6f8d0e2c4"); err == nil {
+		t.Fatal("expected an error for an unknown device")
+	}
+}
+
+func TestStoreMergesWithDiscoveredDevices(t *testing.T) {
+	store := openTestStore(t, filepath.Join(t.TempDir(), "devices.json"))
+	if err := store.Remember(
+		Device{ID: "5f1e1c2a9b3d4e6f8a7b6c5d4e3f2a1b", Name: "Living Room TV", Host: "192.0.2.10", Port: 8009},
+		Device{ID: "0b8c2e74d1a94f53a6e2c71f9d0b3e48", Name: "Kitchen", Host: "192.0.2.30", Port: 8009},
+	); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetFavorite("0b8c2e74d1a94f53a6e2c71f9d0b3e48", true); err != nil {
+		t.Fatal(err)
+	}
+
+	merged := store.Merge([]Device{
+		{ID: "5f1e1c2a9b3d4e6f8a7b6c5d4e3f2a1b", Name: "Living Room TV", Host: "192.0.2.10", Port: 8009, ConnectionState: "discovered"},
+		{ID: "3d1f6a2e8b4c4e5a9f7d2c1b0a9e8d7f", Name: "Bedroom", Host: "192.0.2.50", Port: 8009, ConnectionState: "discovered"},
+	})
+
+	var got []string
+	for _, device := range merged {
+		got = append(got, device.Name+"/"+device.ConnectionState)
+	}
+	want := []string{"Kitchen/remembered", "Bedroom/discovered", "Living Room TV/discovered"}
+	if len(got) != len(want) {
+		t.Fatalf("merged %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("merged %v, want %v", got, want)
+		}
+	}
+	if !merged[0].Favorite || merged[1].Favorite {
+		t.Fatalf("favorites not marked: %+v", merged)
+	}
+}
+
+func TestOpenStoreRejectsCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "devices.json")
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenStore(path); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestStoreFindsDevicesByNameOrID(t *testing.T) {
+	store := openTestStore(t, filepath.Join(t.TempDir(), "devices.json"))
+	if err := store.Remember(
+		Device{ID: "5f1e1c2a9b3d4e6f8a7b6c5d4e3f2a1b", Name: "Living Room TV", Host: "192.0.2.10", Port: 8009},
+		Device{ID: "0b8c2e74d1a94f53a6e2c71f9d0b3e48", Name: "Speaker", Host: "192.0.2.30", Port: 8009},
+		Device{ID: "7e24b1c05a9f3d68e1b2c4a6f8d0e2c4", Name: "speaker", Host: "192.0.2.31", Port: 8009},
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if device, err := store.Find("living room tv"); err != nil || device.Host != "192.0.2.10" {
+		t.Fatalf("found %+v, %v by name", device, err)
+	}
+	if device, err := store.Find("0B8C2E74-D1A9-4F53-A6E2-C71F9D0B3E48"); err != nil || device.Host != "192.0.2.30" {
+		t.Fatalf("found %+v, %v by ID", device, err)
+	}
+	if _, err := store.Find("Speaker"); err == nil {
+		t.Fatal("expected an error for an ambiguous name")
+	}
+	if _, err := store.Find("Garage"); err == nil {
+		t.Fatal("expected an error for an unknown device")
+	}
+}
+
+func TestStoreRelocatesMovedDevices(t *testing.T) {
+	store := openTestStore(t, filepath.Join(t.TempDir(), "devices.json"))
+	if err := store.Remember(Device{ID: "0b8c2e74d1a94f53a6e2c71f9d0b3e48", Name: "Kitchen", Host: "192.0.2.30", Port: 8009}); err != nil {
+		t.Fatal(err)
+	}
+
+	network := mdnsnet.NewNetwork()
+	advertise(t, network, castService(t, "Kitchen", "192.0.2.31", "id=0b8c2e74d1a94f53a6e2c71f9d0b3e48", "fn=Kitchen"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	device, err := store.Relocate(ctx, "0b8c2e74d1a94f53a6e2c71f9d0b3e48", browse(network, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if device.Host != "192.0.2.31" {
+		t.Fatalf("relocated to %s", device.Host)
+	}
+	if known, err := store.Find("Kitchen"); err != nil || known.Host != "192.0.2.31" {
+		t.Fatalf("store has %+v, %v", known, err)
+	}
+}
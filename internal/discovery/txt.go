@@ -0,0 +1,87 @@
+package discovery
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Capabilities is the bitmask that a Cast device advertises in the "ca" TXT
+// key. Devices set further bits whose meaning is not known, which are kept.
+type Capabilities uint32
+
+const (
+	VideoOut Capabilities = 1 << iota
+	VideoIn
+	AudioOut
+	AudioIn
+	DevMode
+	MultizoneGroup
+)
+
+var capabilityNames = []struct {
+	capability Capabilities
+	name       string
+}{
+	{VideoOut, "video_out"},
+	{VideoIn, "video_in"},
+	{AudioOut, "audio_out"},
+	{AudioIn, "audio_in"},
+	{DevMode, "dev_mode"},
+	{MultizoneGroup, "multizone_group"},
+}
+
+// Has reports whether every capability in c is set.
+func (capabilities Capabilities) Has(c Capabilities) bool {
+	return capabilities&c == c
+}
+
+// String lists the known capabilities, separated by "|".
+func (capabilities Capabilities) String() string {
+	var names []string
+	for _, known := range capabilityNames {
+		if capabilities.Has(known.capability) {
+			names = append(names, known.name)
+		}
+	}
+
+	return strings.Join(names, "|")
+}
+
+// parseTXT splits TXT record strings into keys and values. Keys are case
+// insensitive, so they are lowercased, and the first occurrence of a key wins.
+// Values may themselves contain "=".
+func parseTXT(fields []string) map[string]string {
+	txt := make(map[string]string, len(fields))
+	for _, field := range fields {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			continue
+		}
+
+		key = strings.ToLower(key)
+		if _, seen := txt[key]; !seen {
+			txt[key] = value
+		}
+	}
+
+	return txt
+}
+
+// applyTXT fills in the device's metadata from its TXT record. Numeric values
+// that cannot be parsed are left as zero.
+func (device *Device) applyTXT(txt map[string]string) {
+	capabilities, _ := strconv.ParseUint(txt["ca"], 10, 32)
+	version, _ := strconv.Atoi(txt["ve"])
+
+	device.BS = txt["bs"]
+	device.Busy = txt["st"] == "1"
+	device.Capabilities = Capabilities(capabilities)
+	device.CD = txt["cd"]
+	device.IconPath = txt["ic"]
+	device.ID = txt["id"]
+	device.Model = txt["md"]
+	device.Name = txt["fn"]
+	device.ProtocolVersion = version
+	device.RM = txt["rm"]
+	device.StatusText = txt["rs"]
+}
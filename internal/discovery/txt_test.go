@@ -0,0 +1,127 @@
+package discovery
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/mdns"
+)
+
+// readTXTFixture reads a TXT record from testdata, with one string per line.
+func readTXTFixture(t *testing.T, name string) []string {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+}
+
+func TestDeviceFromTXTFixtures(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    Device
+	}{
+		{
+			fixture: "chromecast-ultra.txt",
+			want: Device{
+				ID: "5f1e1c2a9b3d4e6f8a7b6c5d4e3f2a1b", Name: "Living Room TV", Model: "Chromecast Ultra",
+				Busy: true, StatusText: "YouTube",
+				Capabilities: Capabilities(4101), IconPath: "/setup/icon.png", ProtocolVersion: 5,
+				BS: "FA8FCA3C1D2E", CD: "A3F19C0E5B7D2468ACE13579BDF02468",
+			},
+		},
+		{
+			fixture: "nest-mini.txt",
+			want: Device{
+				ID: "0b8c2e74d1a94f53a6e2c71f9d0b3e48", Name: "Kitchen speaker", Model: "Google Nest Mini",
+				Capabilities: Capabilities(199172), IconPath: "/setup/icon.png", ProtocolVersion: 5,
+				BS: "FA8FCA5E7F80", CD: "7E24B1C05A9F3D68E1B2C4A6F8D0E2C4", RM: "6D1A3B5C7E9F0A2B",
+			},
+		},
+		{
+			fixture: "speaker-group.txt",
+			want: Device{
+				ID: "9a7c1e3b-5d2f-4b8a-9c6e-1f3a5b7d9e0c", Name: "Downstairs", Model: "Google Cast Group",
+				Capabilities: Capabilities(199204), IconPath: "/setup/icon.png", ProtocolVersion: 5,
+				BS: "FA8FCA5E7F80",
+			},
+		},
+		{
+			fixture: "gocast.txt",
+			want: Device{
+				ID: "3d1f6a2e-8b4c-4e5a-9f7d-2c1b0a9e8d7f", Name: "GoCast Receiver", Model: "GoCast",
+				Capabilities: Capabilities(4101), IconPath: "/setup/icon.png", ProtocolVersion: 2,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.fixture, func(t *testing.T) {
+			got := deviceFromEntry(&mdns.ServiceEntry{
+				Host:       "device.local.",
+				InfoFields: readTXTFixture(t, test.fixture),
+				Name:       "Device._googlecast._tcp.local.",
+				Port:       8009,
+			})
+
+			want := test.want
+			want.ConnectionState = "discovered"
+			want.Host = "device.local"
+			want.Port = 8009
+			if got != want {
+				t.Fatalf("got %+v\nwant %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	tests := []struct {
+		capabilities Capabilities
+		want         string
+		video        bool
+		group        bool
+	}{
+		{capabilities: 4101, want: "video_out|audio_out", video: true},
+		{capabilities: 199172, want: "audio_out"},
+		{capabilities: 199204, want: "audio_out|multizone_group", group: true},
+		{capabilities: 0, want: ""},
+	}
+
+	for _, test := range tests {
+		if got := test.capabilities.String(); got != test.want {
+			t.Fatalf("%d: got %q, want %q", uint32(test.capabilities), got, test.want)
+		}
+		if test.capabilities.Has(VideoOut) != test.video || test.capabilities.Has(MultizoneGroup) != test.group {
+			t.Fatalf("%d: unexpected capabilities %s", uint32(test.capabilities), test.capabilities)
+		}
+	}
+
+	if !Capabilities(4101).Has(VideoOut | AudioOut) {
+		t.Fatal("expected video and audio out")
+	}
+}
+
+func TestParseTXT(t *testing.T) {
+	txt := parseTXT([]string{"FN=Living Room", "rs=a=b", "fn=ignored", "nf", "rm="})
+	if txt["fn"] != "Living Room" || txt["rs"] != "a=b" {
+		t.Fatalf("unexpected values %v", txt)
+	}
+	if value, ok := txt["rm"]; !ok || value != "" {
+		t.Fatalf("empty value not kept: %v", txt)
+	}
+	if _, ok := txt["nf"]; ok {
+		t.Fatalf("key without value was kept: %v", txt)
+	}
+
+	device := Device{}
+	device.applyTXT(parseTXT([]string{"ca=not-a-number", "ve=x"}))
+	if device.Capabilities != 0 || device.ProtocolVersion != 0 {
+		t.Fatalf("unexpected device %+v", device)
+	}
+}
@@ -0,0 +1,505 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	// third-party
+	"github.com/hashicorp/mdns"
+	"github.com/miekg/dns"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/mdnsnet"
+)
+
+const (
+	// initialQueryInterval is the delay before the second query. The interval
+	// doubles after each query, up to maxQueryInterval, which is short enough
+	// to refresh records before their TTLs expire.
+	initialQueryInterval = time.Second
+	maxQueryInterval     = time.Minute
+
+	// expiryCheckInterval is how often records are checked for expiry.
+	expiryCheckInterval = time.Second
+)
+
+// EventType identifies what happened to a device.
+type EventType int
+
+const (
+	Added EventType = iota
+	Updated
+	Removed
+)
+
+func (eventType EventType) String() string {
+	switch eventType {
+	case Added:
+		return "added"
+	case Updated:
+		return "updated"
+	case Removed:
+		return "removed"
+	default:
+		return fmt.Sprintf("EventType(%d)", int(eventType))
+	}
+}
+
+// Event reports that a device or speaker group was added, updated or removed.
+// Group is set for speaker groups, and Device is then empty. Instance is the
+// name of the mDNS service instance, which identifies the device or group
+// across events even when it has no ID. Removed events carry the device or
+// group as it was last seen.
+type Event struct {
+	Device   Device
+	Group    *Group
+	Instance string
+	Type     EventType
+}
+
+// Watch browses for Cast receivers and speaker groups until ctx is cancelled,
+// and reports them as they appear, change and disappear. A device is updated
+// when its TXT record or address changes, when it starts or stops leading a
+// group, or when its zone advertisement appears or disappears. Devices and
+// groups are removed when their records expire or they send a goodbye. A
+// device that is found on several interfaces is reported once. The returned
+// channel is closed once ctx is cancelled.
+func Watch(ctx context.Context, options Options) (<-chan Event, error) {
+	events, err := watch(ctx, options)
+	if err != nil {
+		return nil, fmt.Errorf("watch cast devices: %w", err)
+	}
+	return events, nil
+}
+
+func watch(ctx context.Context, options Options) (<-chan Event, error) {
+	if options.Listen != nil {
+		conns, err := options.Listen()
+		if err != nil {
+			return nil, err
+		}
+
+		events := make(chan Event)
+		go newWatcher(nil, options.IPv6).run(ctx, conns, events)
+		return events, nil
+	}
+
+	interfaces, err := selectInterfaces(options.Interface)
+	if err != nil {
+		return nil, err
+	}
+	links, err := newLinks(interfaces)
+	if err != nil {
+		return nil, err
+	}
+	conns, err := listen(interfaces, links, options)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan Event)
+	go newWatcher(links, options.IPv6).run(ctx, conns, events)
+
+	return events, nil
+}
+
+// watchedInstance is the cached state of a service instance. The instance is
+// reported once its SRV record is known.
+type watchedInstance struct {
+	device     Device
+	expires    time.Time
+	group      *Group
+	host       string
+	port       int
+	reported   bool
+	service    string
+	srvExpires time.Time
+	txt        []string
+	txtExpires time.Time
+}
+
+// cachedAddress is a host address, and the interface that it was last
+// received on.
+type cachedAddress struct {
+	expires time.Time
+	ifIndex int
+	ip      net.IP
+}
+
+// received is an mDNS response, and the interface that it arrived on.
+type received struct {
+	ifIndex int
+	msg     *dns.Msg
+}
+
+// watcher is an mDNS cache for Cast and zone service instances, and the host
+// addresses that they refer to. Addresses are keyed by the form in which they
+// are reported, so a link-local address that is found on two interfaces is
+// cached once for each zone.
+type watcher struct {
+	addresses   map[string]map[string]cachedAddress
+	instances   map[string]*watchedInstance
+	ipv6        bool
+	links       map[int]link
+	service     string
+	zoneService string
+}
+
+// newWatcher returns a watcher for responses received on links. Responses
+// from other interfaces are ignored, unless links is empty, in which case
+// interfaces are not known. AAAA records are only used if ipv6 is set.
+func newWatcher(links map[int]link, ipv6 bool) *watcher {
+	return &watcher{
+		addresses:   make(map[string]map[string]cachedAddress),
+		instances:   make(map[string]*watchedInstance),
+		ipv6:        ipv6,
+		links:       links,
+		service:     service + ".local.",
+		zoneService: zoneService + ".local.",
+	}
+}
+
+func (w *watcher) run(ctx context.Context, conns []mdnsnet.Conn, events chan<- Event) {
+	defer close(events)
+
+	messages := make(chan received)
+	for _, conn := range conns {
+		go func(conn mdnsnet.Conn) {
+			buffer := make([]byte, 9000)
+			for {
+				n, ifIndex, err := conn.Read(buffer)
+				if err != nil {
+					return
+				}
+
+				msg := new(dns.Msg)
+				if err := msg.Unpack(buffer[:n]); err != nil || !msg.Response {
+					continue
+				}
+
+				select {
+				case messages <- received{ifIndex: ifIndex, msg: msg}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(conn)
+	}
+	defer func() {
+		for _, conn := range conns {
+			_ = conn.Close()
+		}
+	}()
+
+	query := new(dns.Msg)
+	query.SetQuestion(w.service, dns.TypePTR)
+	query.Question = append(query.Question, dns.Question{Name: w.zoneService, Qtype: dns.TypePTR, Qclass: dns.ClassINET})
+	query.RecursionDesired = false
+	packed, err := query.Pack()
+	if err != nil {
+		return
+	}
+
+	queryInterval := initialQueryInterval
+	queryTimer := time.NewTimer(0)
+	defer queryTimer.Stop()
+
+	expiryTicker := time.NewTicker(expiryCheckInterval)
+	defer expiryTicker.Stop()
+
+	for {
+		var changes []Event
+		select {
+		case <-ctx.Done():
+			return
+		case <-queryTimer.C:
+			// a failed query is retried at the next interval
+			for _, conn := range conns {
+				_ = conn.Send(packed)
+			}
+			queryTimer.Reset(queryInterval)
+			queryInterval = min(2*queryInterval, maxQueryInterval)
+		case now := <-expiryTicker.C:
+			changes = w.update(now)
+		case message := <-messages:
+			now := time.Now()
+			w.handleMessage(message.msg, message.ifIndex, now)
+			changes = w.update(now)
+		}
+
+		for _, event := range changes {
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// handleMessage caches the records in an mDNS response. Records with a TTL of
+// zero are goodbyes, which remove the records that they match. ifIndex is the
+// interface that the response arrived on.
+func (w *watcher) handleMessage(msg *dns.Msg, ifIndex int, now time.Time) {
+	if _, ok := w.links[ifIndex]; len(w.links) > 0 && !ok {
+		return
+	}
+
+	records := make([]dns.RR, 0, len(msg.Answer)+len(msg.Extra))
+	records = append(records, msg.Answer...)
+	records = append(records, msg.Extra...)
+
+	// instances are added before their SRV and TXT records are applied, since
+	// those are often sent as additional records
+	for _, record := range records {
+		switch rr := record.(type) {
+		case *dns.A:
+			w.addAddress(rr.Hdr, rr.A, ifIndex, now)
+		case *dns.AAAA:
+			if w.ipv6 {
+				w.addAddress(rr.Hdr, rr.AAAA, ifIndex, now)
+			}
+		case *dns.PTR:
+			var service string
+			switch {
+			case strings.EqualFold(rr.Hdr.Name, w.service):
+				service = w.service
+			case strings.EqualFold(rr.Hdr.Name, w.zoneService):
+				service = w.zoneService
+			default:
+				continue
+			}
+			instance := w.instances[rr.Ptr]
+			if instance == nil {
+				if rr.Hdr.Ttl == 0 {
+					continue
+				}
+				instance = &watchedInstance{service: service}
+				w.instances[rr.Ptr] = instance
+			}
+			instance.expires = expiry(rr.Hdr, now)
+		}
+	}
+
+	for _, record := range records {
+		switch rr := record.(type) {
+		case *dns.SRV:
+			if instance := w.instances[rr.Hdr.Name]; instance != nil {
+				instance.host = rr.Target
+				instance.port = int(rr.Port)
+				instance.srvExpires = expiry(rr.Hdr, now)
+			}
+		case *dns.TXT:
+			if instance := w.instances[rr.Hdr.Name]; instance != nil {
+				instance.txt = rr.Txt
+				instance.txtExpires = expiry(rr.Hdr, now)
+			}
+		}
+	}
+}
+
+func (w *watcher) addAddress(header dns.RR_Header, ip net.IP, ifIndex int, now time.Time) {
+	if ip == nil || ip.IsUnspecified() || ip.IsLoopback() || ip.IsMulticast() {
+		return
+	}
+
+	// link-local addresses are only usable with the zone of the interface that
+	// they were found on
+	key := ip.String()
+	if ip.To4() == nil && ip.IsLinkLocalUnicast() {
+		if l, ok := w.links[ifIndex]; ok {
+			key += "%" + l.name
+		}
+	}
+
+	addresses := w.addresses[header.Name]
+	if addresses == nil {
+		if header.Ttl == 0 {
+			return
+		}
+		addresses = make(map[string]cachedAddress)
+		w.addresses[header.Name] = addresses
+	}
+
+	addresses[key] = cachedAddress{expires: expiry(header, now), ifIndex: ifIndex, ip: ip}
+}
+
+// expiry returns the time that a record expires. Goodbyes expire immediately.
+func expiry(header dns.RR_Header, now time.Time) time.Time {
+	return now.Add(time.Duration(header.Ttl) * time.Second)
+}
+
+// update discards expired records, and returns events for the instances that
+// have changed as a result of the records received so far. Events are ordered
+// by instance name. Zone instances are not reported themselves, but they, and
+// groups, change the devices that they refer to.
+func (w *watcher) update(now time.Time) []Event {
+	for host, addresses := range w.addresses {
+		for key, address := range addresses {
+			if !address.expires.After(now) {
+				delete(addresses, key)
+			}
+		}
+		if len(addresses) == 0 {
+			delete(w.addresses, host)
+		}
+	}
+
+	names := make([]string, 0, len(w.instances))
+	for name := range w.instances {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var events []Event
+	var current []string
+	devices := make(map[string]Device)
+	zones := make(map[string]bool)
+	for _, name := range names {
+		instance := w.instances[name]
+		if !instance.srvExpires.After(now) {
+			instance.host = ""
+			instance.port = 0
+		}
+		if !instance.txtExpires.After(now) {
+			instance.txt = nil
+		}
+
+		if !instance.expires.After(now) || (instance.reported && instance.port == 0) {
+			delete(w.instances, name)
+			if instance.reported && instance.service == w.service {
+				events = append(events, instance.event(name, Removed))
+			}
+			continue
+		}
+
+		if instance.port == 0 {
+			continue
+		}
+
+		device := deviceFromEntry(w.entry(name, instance))
+		if instance.service == w.zoneService {
+			instance.reported = true
+			zones[normalizeID(device.ID)] = true
+			zones[device.Host] = true
+			continue
+		}
+		current = append(current, name)
+		devices[name] = device
+	}
+
+	// groups are advertised from the address of their leader
+	leaders := make(map[string]string)
+	for _, name := range current {
+		if device := devices[name]; !device.isGroup() {
+			leaders[device.Host] = device.ID
+		}
+	}
+	groupHosts := make(map[string]bool)
+	for _, name := range current {
+		if device := devices[name]; device.isGroup() {
+			groupHosts[device.Host] = true
+		}
+	}
+
+	for _, name := range current {
+		instance := w.instances[name]
+		device := devices[name]
+
+		var group *Group
+		if device.isGroup() {
+			group = newGroup(device, leaders[device.Host])
+			device = Device{}
+		} else {
+			device.GroupLeader = groupHosts[device.Host]
+			device.Multizone = zones[device.Host] || (device.ID != "" && zones[normalizeID(device.ID)])
+		}
+
+		changed := device != instance.device || (group == nil) != (instance.group == nil) ||
+			(group != nil && *group != *instance.group)
+		if instance.reported && !changed {
+			continue
+		}
+
+		eventType := Updated
+		if !instance.reported {
+			eventType = Added
+		}
+		instance.device = device
+		instance.group = group
+		instance.reported = true
+		events = append(events, instance.event(name, eventType))
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Instance < events[j].Instance
+	})
+	return events
+}
+
+// event reports the instance as it was last seen.
+func (instance *watchedInstance) event(name string, eventType EventType) Event {
+	event := Event{Device: instance.device, Instance: name, Type: eventType}
+	if instance.group != nil {
+		group := *instance.group
+		event.Group = &group
+	}
+	return event
+}
+
+// entry describes an instance in the form used by deviceFromEntry, with the
+// host replaced by its best address, if it has one.
+func (w *watcher) entry(name string, instance *watchedInstance) *mdns.ServiceEntry {
+	entry := &mdns.ServiceEntry{
+		Host:       instance.host,
+		InfoFields: instance.txt,
+		Name:       name,
+		Port:       instance.port,
+	}
+	if address := w.address(instance.host); address != "" {
+		entry.Host = address
+	}
+	return entry
+}
+
+// address returns the address that is most likely to reach host. Addresses
+// on the network of the interface that they were found on are preferred,
+// then IPv4 addresses over global IPv6 addresses, and those over link-local
+// IPv6 addresses. The lowest of equally ranked addresses is used, so that the
+// address reported for a device only changes when the address itself does.
+func (w *watcher) address(host string) string {
+	best, bestRank := "", 0
+	for key, address := range w.addresses[host] {
+		rank := w.rank(address)
+		if best == "" || rank < bestRank || (rank == bestRank && key < best) {
+			best, bestRank = key, rank
+		}
+	}
+	return best
+}
+
+func (w *watcher) rank(address cachedAddress) int {
+	l, known := w.links[address.ifIndex]
+
+	rank := 0
+	switch {
+	case address.ip.To4() != nil:
+	case !address.ip.IsLinkLocalUnicast():
+		rank = 1
+	default:
+		// link-local addresses are on the network exactly when their zone
+		// is known
+		if known {
+			return 2
+		}
+		return 5
+	}
+	if !known || !l.contains(address.ip) {
+		rank += 3
+	}
+	return rank
+}
@@ -0,0 +1,428 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	// third-party
+	"github.com/miekg/dns"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/mdnsnet"
+)
+
+const testInstance = "Chromecast-device-1._googlecast._tcp.local."
+
+func header(name string, rrtype uint16, ttl uint32) dns.RR_Header {
+	return dns.RR_Header{Name: name, Rrtype: rrtype, Class: dns.ClassINET, Ttl: ttl}
+}
+
+// testResponse builds the response that a Cast device sends to a query for
+// its service, with the given TTL on every record.
+func testResponse(ttl uint32, address string, txt ...string) *dns.Msg {
+	msg := new(dns.Msg)
+	msg.Response = true
+	msg.Answer = []dns.RR{
+		&dns.PTR{Hdr: header("_googlecast._tcp.local.", dns.TypePTR, ttl), Ptr: testInstance},
+	}
+	msg.Extra = []dns.RR{
+		&dns.SRV{Hdr: header(testInstance, dns.TypeSRV, ttl), Port: 8009, Target: "device-1.local."},
+		&dns.TXT{Hdr: header(testInstance, dns.TypeTXT, ttl), Txt: txt},
+		&dns.A{Hdr: header("device-1.local.", dns.TypeA, ttl), A: net.ParseIP(address)},
+	}
+	return msg
+}
+
+// addressResponse announces another address for the test device's host.
+func addressResponse(ttl uint32, address string) *dns.Msg {
+	ip := net.ParseIP(address)
+	if ip.To4() != nil {
+		return &dns.Msg{MsgHdr: dns.MsgHdr{Response: true}, Answer: []dns.RR{
+			&dns.A{Hdr: header("device-1.local.", dns.TypeA, ttl), A: ip},
+		}}
+	}
+	return &dns.Msg{MsgHdr: dns.MsgHdr{Response: true}, Answer: []dns.RR{
+		&dns.AAAA{Hdr: header("device-1.local.", dns.TypeAAAA, ttl), AAAA: ip},
+	}}
+}
+
+// unicastConn stands in for an mDNS socket, by sending queries to a unicast
+// peer.
+type unicastConn struct {
+	conn net.PacketConn
+	peer net.Addr
+}
+
+func (c *unicastConn) Read(buffer []byte) (int, int, error) {
+	n, _, err := c.conn.ReadFrom(buffer)
+	return n, 0, err
+}
+
+func (c *unicastConn) Send(message []byte) error {
+	_, err := c.conn.WriteTo(message, c.peer)
+	return err
+}
+
+func (c *unicastConn) Close() error {
+	return c.conn.Close()
+}
+
+func expectEvents(t *testing.T, events []Event, want ...EventType) {
+	t.Helper()
+
+	if len(events) != len(want) {
+		t.Fatalf("got events %+v, want %v", events, want)
+	}
+	for i, event := range events {
+		if event.Type != want[i] || event.Instance != testInstance {
+			t.Fatalf("got event %+v, want %v for %s", event, want[i], testInstance)
+		}
+	}
+}
+
+func TestWatcherReportsChanges(t *testing.T) {
+	w := newWatcher(nil, false)
+	now := time.Unix(1000, 0)
+
+	w.handleMessage(testResponse(120, "192.0.2.10", "id=device-1", "fn=Living Room", "md=Chromecast"), 0, now)
+	events := w.update(now)
+	expectEvents(t, events, Added)
+	device := events[0].Device
+	if device.ID != "device-1" || device.Name != "Living Room" || device.Host != "192.0.2.10" || device.Port != 8009 {
+		t.Fatalf("unexpected device %+v", device)
+	}
+
+	// repeated answers are not changes
+	w.handleMessage(testResponse(120, "192.0.2.10", "id=device-1", "fn=Living Room", "md=Chromecast"), 0, now.Add(time.Second))
+	expectEvents(t, w.update(now.Add(time.Second)))
+
+	// TXT changes, such as renames, are updates
+	w.handleMessage(testResponse(120, "192.0.2.10", "id=device-1", "fn=Kitchen", "md=Chromecast"), 0, now.Add(2*time.Second))
+	events = w.update(now.Add(2 * time.Second))
+	expectEvents(t, events, Updated)
+	if events[0].Device.Name != "Kitchen" {
+		t.Fatalf("updated device %+v, want name Kitchen", events[0].Device)
+	}
+
+	// so are address changes, once the old address is withdrawn
+	w.handleMessage(&dns.Msg{MsgHdr: dns.MsgHdr{Response: true}, Answer: []dns.RR{
+		&dns.A{Hdr: header("device-1.local.", dns.TypeA, 0), A: net.ParseIP("192.0.2.10")},
+		&dns.A{Hdr: header("device-1.local.", dns.TypeA, 120), A: net.ParseIP("192.0.2.20")},
+	}}, 0, now.Add(3*time.Second))
+	events = w.update(now.Add(3 * time.Second))
+	expectEvents(t, events, Updated)
+	if events[0].Device.Host != "192.0.2.20" {
+		t.Fatalf("updated device %+v, want host 192.0.2.20", events[0].Device)
+	}
+
+	// a goodbye removes the device
+	w.handleMessage(&dns.Msg{MsgHdr: dns.MsgHdr{Response: true}, Answer: []dns.RR{
+		&dns.PTR{Hdr: header("_googlecast._tcp.local.", dns.TypePTR, 0), Ptr: testInstance},
+	}}, 0, now.Add(4*time.Second))
+	events = w.update(now.Add(4 * time.Second))
+	expectEvents(t, events, Removed)
+	if events[0].Device.Name != "Kitchen" {
+		t.Fatalf("removed device %+v, want the device as last seen", events[0].Device)
+	}
+	if len(w.instances) != 0 {
+		t.Fatalf("instances remain after goodbye: %+v", w.instances)
+	}
+}
+
+func TestWatcherExpiresRecords(t *testing.T) {
+	w := newWatcher(nil, false)
+	now := time.Unix(1000, 0)
+
+	w.handleMessage(testResponse(120, "192.0.2.10", "id=device-1"), 0, now)
+	expectEvents(t, w.update(now), Added)
+
+	// refreshed records live on
+	w.handleMessage(testResponse(120, "192.0.2.10", "id=device-1"), 0, now.Add(100*time.Second))
+	expectEvents(t, w.update(now.Add(150*time.Second)))
+
+	expectEvents(t, w.update(now.Add(220*time.Second)), Removed)
+}
+
+func TestWatcherWaitsForService(t *testing.T) {
+	w := newWatcher(nil, false)
+	now := time.Unix(1000, 0)
+
+	// records for other services are ignored
+	other := testResponse(120, "192.0.2.10")
+	other.Answer[0].(*dns.PTR).Hdr.Name = "_airplay._tcp.local."
+	w.handleMessage(other, 0, now)
+	expectEvents(t, w.update(now))
+
+	// instances are not reported until their SRV record arrives
+	response := testResponse(120, "192.0.2.10", "id=device-1")
+	w.handleMessage(&dns.Msg{MsgHdr: dns.MsgHdr{Response: true}, Answer: response.Answer}, 0, now)
+	expectEvents(t, w.update(now))
+
+	w.handleMessage(&dns.Msg{MsgHdr: dns.MsgHdr{Response: true}, Answer: response.Extra}, 0, now)
+	expectEvents(t, w.update(now), Added)
+}
+
+func testLinks() map[int]link {
+	_, lan, _ := net.ParseCIDR("192.0.2.0/24")
+	_, lanV6, _ := net.ParseCIDR("2001:db8::/64")
+	_, linkLocal, _ := net.ParseCIDR("fe80::/64")
+	_, vpn, _ := net.ParseCIDR("198.51.100.0/24")
+	return map[int]link{
+		2: {name: "eth0", networks: []*net.IPNet{lan, lanV6, linkLocal}},
+		3: {name: "tun0", networks: []*net.IPNet{vpn}},
+	}
+}
+
+func TestWatcherIgnoresAAAAWithoutIPv6(t *testing.T) {
+	w := newWatcher(testLinks(), false)
+	now := time.Unix(1000, 0)
+
+	w.handleMessage(addressResponse(120, "2001:db8::10"), 2, now)
+	w.handleMessage(testResponse(120, "192.0.2.10", "id=device-1"), 2, now)
+	events := w.update(now)
+	expectEvents(t, events, Added)
+	if events[0].Device.Host != "192.0.2.10" {
+		t.Fatalf("got host %q, want the IPv4 address", events[0].Device.Host)
+	}
+	if len(w.addresses["device-1.local."]) != 1 {
+		t.Fatalf("AAAA record was cached: %+v", w.addresses)
+	}
+}
+
+func TestWatcherZonesLinkLocalAddresses(t *testing.T) {
+	w := newWatcher(testLinks(), true)
+	now := time.Unix(1000, 0)
+
+	// an IPv6-only device is reported by its link-local address, with the
+	// zone of the interface that it was found on
+	response := testResponse(120, "192.0.2.10", "id=device-1")
+	response.Extra[2] = addressResponse(120, "fe80::10").Answer[0]
+	w.handleMessage(response, 2, now)
+	events := w.update(now)
+	expectEvents(t, events, Added)
+	if events[0].Device.Host != "fe80::10%eth0" {
+		t.Fatalf("got host %q, want fe80::10%%eth0", events[0].Device.Host)
+	}
+
+	// a global address on the interface's network is preferred
+	w.handleMessage(addressResponse(120, "2001:db8::10"), 2, now)
+	events = w.update(now)
+	expectEvents(t, events, Updated)
+	if events[0].Device.Host != "2001:db8::10" {
+		t.Fatalf("got host %q, want 2001:db8::10", events[0].Device.Host)
+	}
+
+	// and IPv4 is preferred over both
+	w.handleMessage(addressResponse(120, "192.0.2.10"), 2, now)
+	events = w.update(now)
+	expectEvents(t, events, Updated)
+	if events[0].Device.Host != "192.0.2.10" {
+		t.Fatalf("got host %q, want 192.0.2.10", events[0].Device.Host)
+	}
+}
+
+func TestWatcherPrefersReachableAddresses(t *testing.T) {
+	w := newWatcher(testLinks(), true)
+	now := time.Unix(1000, 0)
+
+	// the device is heard on both interfaces, but is reported once, with the
+	// address that is on the network it was found on
+	w.handleMessage(testResponse(120, "203.0.113.10", "id=device-1"), 2, now)
+	w.handleMessage(testResponse(120, "203.0.113.10", "id=device-1"), 3, now)
+	w.handleMessage(addressResponse(120, "2001:db8::10"), 2, now)
+	events := w.update(now)
+	expectEvents(t, events, Added)
+	if events[0].Device.Host != "2001:db8::10" {
+		t.Fatalf("got host %q, want the on-link IPv6 address", events[0].Device.Host)
+	}
+
+	w.handleMessage(addressResponse(120, "192.0.2.10"), 2, now)
+	events = w.update(now)
+	expectEvents(t, events, Updated)
+	if events[0].Device.Host != "192.0.2.10" {
+		t.Fatalf("got host %q, want the on-link IPv4 address", events[0].Device.Host)
+	}
+}
+
+func TestWatcherIgnoresOtherInterfaces(t *testing.T) {
+	links := testLinks()
+	delete(links, 3)
+	w := newWatcher(links, true)
+	now := time.Unix(1000, 0)
+
+	w.handleMessage(testResponse(120, "198.51.100.10", "id=device-1"), 3, now)
+	expectEvents(t, w.update(now))
+
+	w.handleMessage(testResponse(120, "192.0.2.10", "id=device-1"), 2, now)
+	expectEvents(t, w.update(now), Added)
+}
+
+// groupResponse announces a speaker group led by the test device, from its
+// host on another port.
+func groupResponse(ttl uint32) *dns.Msg {
+	const instance = "Google-Cast-Group-1._googlecast._tcp.local."
+	return &dns.Msg{MsgHdr: dns.MsgHdr{Response: true}, Answer: []dns.RR{
+		&dns.PTR{Hdr: header("_googlecast._tcp.local.", dns.TypePTR, ttl), Ptr: instance},
+		&dns.SRV{Hdr: header(instance, dns.TypeSRV, ttl), Port: 32187, Target: "device-1.local."},
+		&dns.TXT{Hdr: header(instance, dns.TypeTXT, ttl), Txt: []string{"id=group-1", "fn=Downstairs", "ca=199204"}},
+	}}
+}
+
+func TestWatcherReportsGroupsAndLeaders(t *testing.T) {
+	w := newWatcher(nil, false)
+	now := time.Unix(1000, 0)
+
+	w.handleMessage(testResponse(120, "192.0.2.10", "id=device-1", "fn=Kitchen speaker", "ca=199172"), 0, now)
+	expectEvents(t, w.update(now), Added)
+
+	// a group makes its leader an updated device
+	w.handleMessage(groupResponse(120), 0, now)
+	events := w.update(now)
+	if len(events) != 2 || events[0].Type != Updated || events[1].Type != Added {
+		t.Fatalf("unexpected events %+v", events)
+	}
+	if device := events[0].Device; !device.GroupLeader || device.Multizone {
+		t.Fatalf("unexpected leader %+v", device)
+	}
+	group := events[1].Group
+	if group == nil || group.Name != "Downstairs" || group.Host != "192.0.2.10" || group.Port != 32187 || group.LeaderID != "device-1" {
+		t.Fatalf("unexpected group %+v", group)
+	}
+	if events[1].Device != (Device{}) {
+		t.Fatalf("group event carries a device %+v", events[1].Device)
+	}
+
+	// the speaker's zone advertisement marks it as multizone
+	const zoneInstance = "device-1._googlezone._tcp.local."
+	w.handleMessage(&dns.Msg{MsgHdr: dns.MsgHdr{Response: true}, Answer: []dns.RR{
+		&dns.PTR{Hdr: header("_googlezone._tcp.local.", dns.TypePTR, 120), Ptr: zoneInstance},
+		&dns.SRV{Hdr: header(zoneInstance, dns.TypeSRV, 120), Port: 10001, Target: "device-1.local."},
+		&dns.TXT{Hdr: header(zoneInstance, dns.TypeTXT, 120), Txt: []string{"id=DEVICE-1"}},
+	}}, 0, now)
+	events = w.update(now)
+	expectEvents(t, events, Updated)
+	if !events[0].Device.Multizone {
+		t.Fatalf("unexpected device %+v", events[0].Device)
+	}
+
+	// a group's goodbye removes it, and its leader is updated
+	w.handleMessage(groupResponse(0), 0, now)
+	events = w.update(now)
+	if len(events) != 2 || events[0].Type != Updated || events[0].Device.GroupLeader || events[1].Type != Removed || events[1].Group == nil {
+		t.Fatalf("unexpected events %+v", events)
+	}
+}
+
+func TestWatcherQueriesAndReportsDevices(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a unicast socket stands in for the multicast group, and answers the
+	// watcher's query
+	responder, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = responder.Close()
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := make(chan Event)
+	go newWatcher(nil, false).run(ctx, []mdnsnet.Conn{&unicastConn{conn: conn, peer: responder.LocalAddr()}}, events)
+
+	buffer := make([]byte, 9000)
+	_ = responder.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, addr, err := responder.ReadFrom(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := new(dns.Msg)
+	if err := query.Unpack(buffer[:n]); err != nil {
+		t.Fatal(err)
+	}
+	if len(query.Question) != 2 || query.Question[0].Name != "_googlecast._tcp.local." || query.Question[0].Qtype != dns.TypePTR ||
+		query.Question[1].Name != "_googlezone._tcp.local." || query.Question[1].Qtype != dns.TypePTR {
+		t.Fatalf("unexpected query %v", query)
+	}
+
+	packed, err := testResponse(120, "192.0.2.10", "id=device-1", "fn=Living Room").Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := responder.WriteTo(packed, addr); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case event := <-events:
+		if event.Type != Added || event.Device.Name != "Living Room" {
+			t.Fatalf("unexpected event %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("device was not reported")
+	}
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestWatchOverNetworkReportsRenamesAndGoodbyes(t *testing.T) {
+	network := mdnsnet.NewNetwork()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := Watch(ctx, browse(network, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next := func(want EventType) Device {
+		t.Helper()
+		select {
+		case event := <-events:
+			if event.Type != want {
+				t.Fatalf("got event %+v, want %v", event, want)
+			}
+			return event.Device
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no %v event", want)
+			return Device{}
+		}
+	}
+
+	// a device that starts advertising after the watch starts is announced
+	responderCtx, stop := context.WithCancel(context.Background())
+	defer stop()
+	responder := mdnsnet.NewResponder(network.Join(), castService(t, "Chromecast-device-1", "192.0.2.10", "id=device-1", "fn=Living Room", "md=Chromecast"))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = responder.Respond(responderCtx)
+	}()
+
+	if device := next(Added); device.Name != "Living Room" || device.Host != "192.0.2.10" {
+		t.Fatalf("unexpected device %+v", device)
+	}
+
+	if err := responder.UpdateText(map[string]string{"id": "device-1", "fn": "Kitchen", "md": "Chromecast"}); err != nil {
+		t.Fatal(err)
+	}
+	if device := next(Updated); device.Name != "Kitchen" || device.ID != "device-1" {
+		t.Fatalf("unexpected device %+v", device)
+	}
+
+	stop()
+	<-done
+	if device := next(Removed); device.Name != "Kitchen" {
+		t.Fatalf("unexpected device %+v", device)
+	}
+}
@@ -0,0 +1,154 @@
+package latency
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	// third-party
+	"github.com/hashicorp/go-hclog"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/channel"
+	"github.com/tristanpenman/go-cast/internal/sender"
+	"github.com/tristanpenman/go-cast/internal/session"
+)
+
+const (
+	loopbackAppID       = "0F5096E8"
+	loopbackHost        = "127.0.0.1"
+	loopbackSenderID    = "sender-0"
+	loopbackTransportID = "latency-0"
+
+	// reportTimeout is how long to wait, once every frame has been sent, for
+	// the receiver to report decoding them. Receiver logs are sent with the
+	// feedback that follows each sender report.
+	reportTimeout = 2 * time.Second
+)
+
+// Config describes the test pattern streamed by Measure.
+type Config struct {
+	Bitrate     int
+	Duration    time.Duration
+	FrameRate   int
+	Height      int
+	TargetDelay time.Duration
+	Width       int
+}
+
+// loopbackReceiver passes Cast messages directly between a sender and a
+// session in the same process. The session answers offers synchronously, so
+// an answer is always available by the time the sender waits for it.
+type loopbackReceiver struct {
+	mu      sync.Mutex
+	answers []string
+	session *session.Session
+}
+
+func (receiver *loopbackReceiver) SendUTF8(_ string, payloadUTF8 *string, _ string, _ string) {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+
+	receiver.answers = append(receiver.answers, *payloadUTF8)
+}
+
+func (receiver *loopbackReceiver) SendAppMessage(namespace string, transportID string, payload string) {
+	sourceID := loopbackSenderID
+	receiver.session.HandleCastMessage(&channel.CastMessage{
+		DestinationId: &transportID,
+		Namespace:     &namespace,
+		PayloadUtf8:   &payload,
+		SourceId:      &sourceID,
+	})
+}
+
+func (receiver *loopbackReceiver) WaitForWebrtcAnswer(seqNum int, _ time.Duration) (string, error) {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+
+	for _, answer := range receiver.answers {
+		var message session.WebrtcMessage
+		if json.Unmarshal([]byte(answer), &message) == nil && int(message.SeqNum) == seqNum {
+			return answer, nil
+		}
+	}
+
+	return "", errors.New("offer was not answered")
+}
+
+// Measure streams a test pattern, with a timecode burned into each frame, to
+// a receiver session on loopback, and reports how long frames took to be
+// displayed. The sender and receiver share a clock, so the receiver's event
+// log can be used to break the latency down into stages.
+func Measure(config Config, log hclog.Logger) (Report, error) {
+	if config.Width < MinTimecodeWidth {
+		return Report{}, fmt.Errorf("measure latency: frames must be at least %d pixels wide", MinTimecodeWidth)
+	}
+	if config.Duration <= 0 {
+		return Report{}, errors.New("measure latency: duration must be positive")
+	}
+
+	pattern, err := sender.NewPattern(config.Width, config.Height, config.FrameRate, config.Bitrate, config.Duration)
+	if err != nil {
+		return Report{}, fmt.Errorf("measure latency: %w", err)
+	}
+	defer func() {
+		_ = pattern.Close()
+	}()
+	pattern.SetOverlay(DrawTimecode)
+
+	sink := NewSink()
+	receiver := &loopbackReceiver{}
+	listen := session.ListenConfig{Host: loopbackHost}
+	receiverSession, err := session.NewSession(loopbackAppID, session.CaptureConfig{}, 0, receiver, "Latency", sink, listen, session.RecordingConfig{}, "latency", loopbackTransportID)
+	if err != nil {
+		return Report{}, fmt.Errorf("measure latency: %w", err)
+	}
+	receiver.session = receiverSession
+	receiverSession.Start()
+	defer receiverSession.Stop()
+
+	streamConfig, err := sender.Negotiate(receiver, loopbackTransportID, config.TargetDelay, time.Second)
+	if err != nil {
+		return Report{}, fmt.Errorf("measure latency: %w", err)
+	}
+
+	stream, err := sender.NewStream(streamConfig, loopbackHost, log)
+	if err != nil {
+		return Report{}, fmt.Errorf("measure latency: %w", err)
+	}
+	defer func() {
+		_ = stream.Close()
+	}()
+	stream.RecordTimings()
+
+	if err := stream.Run(pattern); err != nil {
+		return Report{}, fmt.Errorf("measure latency: %w", err)
+	}
+	waitForReports(stream, config.TargetDelay+reportTimeout)
+
+	return NewReport(sink.Samples(), stream.Timings(), sink.Unreadable()), nil
+}
+
+// waitForReports waits until the receiver has reported decoding every frame,
+// or the timeout elapses. Frames that the receiver dropped are never reported,
+// so a timeout is not an error.
+func waitForReports(stream *sender.Stream, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		reported := 0
+		timings := stream.Timings()
+		for _, timing := range timings {
+			if !timing.DecodedAt.IsZero() {
+				reported++
+			}
+		}
+		if reported == len(timings) {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,49 @@
+package latency
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	// third-party
+	"github.com/hashicorp/go-hclog"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/sender"
+)
+
+func TestMeasureRejectsInvalidConfig(t *testing.T) {
+	if _, err := Measure(Config{Duration: time.Second, FrameRate: 30, Height: 120, Width: MinTimecodeWidth - 2}, hclog.NewNullLogger()); err == nil {
+		t.Fatal("expected an error for frames that are too narrow for a timecode")
+	}
+	if _, err := Measure(Config{FrameRate: 30, Height: 120, Width: 320}, hclog.NewNullLogger()); err == nil {
+		t.Fatal("expected an error for a stream without a duration")
+	}
+}
+
+func TestMeasureOverLoopback(t *testing.T) {
+	report, err := Measure(Config{
+		Bitrate:     1000000,
+		Duration:    time.Second,
+		FrameRate:   30,
+		Height:      240,
+		TargetDelay: 50 * time.Millisecond,
+		Width:       320,
+	}, hclog.NewNullLogger())
+	if errors.Is(err, sender.ErrCodecUnavailable) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.FramesSent != 30 {
+		t.Fatalf("sent %d frames, want 30", report.FramesSent)
+	}
+	if report.GlassToGlass.Count < 25 || report.FramesUnreadable > 0 {
+		t.Fatalf("only %d of %d frames had readable timecodes", report.GlassToGlass.Count, report.FramesSent)
+	}
+	if report.GlassToGlass.P50 < 50*time.Millisecond || report.Decode.Count < 25 {
+		t.Fatalf("unexpected latency %+v", report)
+	}
+}
@@ -0,0 +1,132 @@
+package latency
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/sender"
+)
+
+// Distribution summarises a set of latency measurements.
+type Distribution struct {
+	Count int
+	Max   time.Duration
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+}
+
+// newDistribution summarises values using nearest-rank percentiles.
+func newDistribution(values []time.Duration) Distribution {
+	if len(values) == 0 {
+		return Distribution{}
+	}
+
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	percentile := func(p int) time.Duration {
+		rank := (p*len(sorted) + 99) / 100
+		return sorted[max(rank, 1)-1]
+	}
+
+	return Distribution{
+		Count: len(sorted),
+		Max:   sorted[len(sorted)-1],
+		P50:   percentile(50),
+		P90:   percentile(90),
+		P99:   percentile(99),
+	}
+}
+
+// Report breaks down the latency of frames sent to a receiver. Glass-to-glass
+// latency runs from when a frame was captured until the receiver displayed
+// it, and is made up of the other stages: sending, the network, the
+// receiver's jitter buffer, and decoding.
+type Report struct {
+	Decode       Distribution
+	GlassToGlass Distribution
+	JitterBuffer Distribution
+	Network      Distribution
+	Send         Distribution
+
+	FramesDisplayed  int
+	FramesSent       int
+	FramesUnreadable int
+}
+
+// stageDuration measures a stage between two times. The receiver reports
+// times to the millisecond, so short stages can appear to be slightly
+// negative; they are clamped to zero.
+func stageDuration(start time.Time, end time.Time) time.Duration {
+	return max(end.Sub(start), 0)
+}
+
+// NewReport matches the frames displayed by the receiver to the frames that
+// were sent, using their timecodes.
+func NewReport(samples []Sample, timings []sender.FrameTiming, unreadable int) Report {
+	var decode, glassToGlass, jitterBuffer, network, send []time.Duration
+
+	byTimecode := make(map[uint32]sender.FrameTiming, len(timings))
+	for _, timing := range timings {
+		byTimecode[uint32(timing.Timestamp.Milliseconds())] = timing
+
+		send = append(send, stageDuration(timing.CapturedAt, timing.SentAt))
+		if timing.ReceivedAt.IsZero() || timing.PlayedOutAt.IsZero() || timing.DecodedAt.IsZero() {
+			continue
+		}
+		network = append(network, stageDuration(timing.SentAt, timing.ReceivedAt))
+		jitterBuffer = append(jitterBuffer, stageDuration(timing.ReceivedAt, timing.PlayedOutAt))
+		decode = append(decode, stageDuration(timing.PlayedOutAt, timing.DecodedAt))
+	}
+
+	for _, sample := range samples {
+		if timing, ok := byTimecode[uint32(sample.Timecode.Milliseconds())]; ok {
+			glassToGlass = append(glassToGlass, sample.DisplayedAt.Sub(timing.CapturedAt))
+		}
+	}
+
+	return Report{
+		Decode:           newDistribution(decode),
+		GlassToGlass:     newDistribution(glassToGlass),
+		JitterBuffer:     newDistribution(jitterBuffer),
+		Network:          newDistribution(network),
+		Send:             newDistribution(send),
+		FramesDisplayed:  len(samples),
+		FramesSent:       len(timings),
+		FramesUnreadable: unreadable,
+	}
+}
+
+// formatLatency formats a duration in milliseconds.
+func formatLatency(value time.Duration) string {
+	return fmt.Sprintf("%.1fms", float64(value)/float64(time.Millisecond))
+}
+
+// Write prints the report as a table.
+func (report Report) Write(output io.Writer) error {
+	fmt.Fprintf(output, "frames sent: %d, displayed: %d, unreadable: %d\n\n",
+		report.FramesSent, report.FramesDisplayed, report.FramesUnreadable)
+
+	table := tabwriter.NewWriter(output, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(table, "stage\tframes\tp50\tp90\tp99\tmax\t")
+	for _, stage := range []struct {
+		name         string
+		distribution Distribution
+	}{
+		{"glass-to-glass", report.GlassToGlass},
+		{"send", report.Send},
+		{"network", report.Network},
+		{"jitter buffer", report.JitterBuffer},
+		{"decode", report.Decode},
+	} {
+		d := stage.distribution
+		fmt.Fprintf(table, "%s\t%d\t%s\t%s\t%s\t%s\t\n",
+			stage.name, d.Count, formatLatency(d.P50), formatLatency(d.P90), formatLatency(d.P99), formatLatency(d.Max))
+	}
+
+	return table.Flush()
+}
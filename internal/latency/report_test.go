@@ -0,0 +1,105 @@
+package latency
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/sender"
+)
+
+func TestNewDistribution(t *testing.T) {
+	var values []time.Duration
+	for i := 100; i >= 1; i-- {
+		values = append(values, time.Duration(i)*time.Millisecond)
+	}
+
+	got := newDistribution(values)
+	want := Distribution{
+		Count: 100,
+		Max:   100 * time.Millisecond,
+		P50:   50 * time.Millisecond,
+		P90:   90 * time.Millisecond,
+		P99:   99 * time.Millisecond,
+	}
+	if got != want {
+		t.Fatalf("newDistribution() = %+v, want %+v", got, want)
+	}
+	if values[0] != 100*time.Millisecond {
+		t.Fatal("newDistribution() sorted its argument")
+	}
+
+	if single := newDistribution(values[:1]); single.P50 != 100*time.Millisecond || single.P99 != single.Max {
+		t.Fatalf("unexpected distribution for one value %+v", single)
+	}
+	if empty := newDistribution(nil); empty != (Distribution{}) {
+		t.Fatalf("unexpected distribution for no values %+v", empty)
+	}
+}
+
+func TestNewReportBreaksDownLatency(t *testing.T) {
+	start := time.Unix(1000, 0)
+	at := func(milliseconds int) time.Time {
+		return start.Add(time.Duration(milliseconds) * time.Millisecond)
+	}
+
+	timings := []sender.FrameTiming{
+		{
+			CapturedAt:  at(0),
+			SentAt:      at(1),
+			ReceivedAt:  at(3),
+			PlayedOutAt: at(103),
+			DecodedAt:   at(105),
+			Timestamp:   0,
+		},
+		{
+			// the receiver's times are truncated to the millisecond
+			CapturedAt:  at(33),
+			SentAt:      start.Add(33500 * time.Microsecond),
+			ReceivedAt:  at(33),
+			PlayedOutAt: at(133),
+			DecodedAt:   at(134),
+			Timestamp:   33 * time.Millisecond,
+		},
+		{
+			// never reported by the receiver
+			CapturedAt: at(66),
+			SentAt:     at(66),
+			Timestamp:  66 * time.Millisecond,
+		},
+	}
+	samples := []Sample{
+		{DisplayedAt: at(106), Timecode: 0},
+		{DisplayedAt: at(135), Timecode: 33 * time.Millisecond},
+		{DisplayedAt: at(200), Timecode: time.Hour},
+	}
+
+	report := NewReport(samples, timings, 2)
+	if report.FramesSent != 3 || report.FramesDisplayed != 3 || report.FramesUnreadable != 2 {
+		t.Fatalf("unexpected frame counts %+v", report)
+	}
+	if report.GlassToGlass.Count != 2 || report.GlassToGlass.P50 != 102*time.Millisecond || report.GlassToGlass.Max != 106*time.Millisecond {
+		t.Fatalf("unexpected glass-to-glass latency %+v", report.GlassToGlass)
+	}
+	if report.Send.Count != 3 || report.Send.Max != time.Millisecond {
+		t.Fatalf("unexpected send latency %+v", report.Send)
+	}
+	if report.Network.Count != 2 || report.Network.P50 != 0 || report.Network.Max != 2*time.Millisecond {
+		t.Fatalf("unexpected network latency %+v", report.Network)
+	}
+	if report.JitterBuffer.P50 != 100*time.Millisecond || report.Decode.Max != 2*time.Millisecond {
+		t.Fatalf("unexpected receiver latency %+v %+v", report.JitterBuffer, report.Decode)
+	}
+
+	var output bytes.Buffer
+	if err := report.Write(&output); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"frames sent: 3, displayed: 3, unreadable: 2", "glass-to-glass", "106.0ms", "jitter buffer"} {
+		if !strings.Contains(output.String(), want) {
+			t.Fatalf("report is missing %q:\n%s", want, output.String())
+		}
+	}
+}
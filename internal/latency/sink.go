@@ -0,0 +1,68 @@
+package latency
+
+import (
+	"sync"
+	"time"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/media"
+)
+
+// Sample records when the receiver displayed a frame, identified by the
+// timecode burned into it.
+type Sample struct {
+	DisplayedAt time.Time
+	Timecode    time.Duration
+}
+
+// Sink is a frame sink that reads the timecode of each frame as the receiver
+// displays it. Reading a timecode only samples a few hundred pixels, so the
+// sink can be written to directly from the decoding goroutine.
+type Sink struct {
+	mu sync.Mutex
+
+	now        func() time.Time
+	samples    []Sample
+	unreadable int
+}
+
+func NewSink() *Sink {
+	return &Sink{now: time.Now}
+}
+
+func (sink *Sink) WriteFrame(frame media.Frame) {
+	displayedAt := sink.now()
+	if frame.Image == nil {
+		return
+	}
+
+	timecode, ok := ReadTimecode(frame.Image)
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	if !ok {
+		sink.unreadable++
+		return
+	}
+	sink.samples = append(sink.samples, Sample{DisplayedAt: displayedAt, Timecode: timecode})
+}
+
+// Samples returns the frames displayed so far, in the order that they were
+// displayed.
+func (sink *Sink) Samples() []Sample {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	samples := make([]Sample, len(sink.samples))
+	copy(samples, sink.samples)
+	return samples
+}
+
+// Unreadable returns the number of frames whose timecode could not be read.
+func (sink *Sink) Unreadable() int {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	return sink.unreadable
+}
@@ -0,0 +1,33 @@
+package latency
+
+import (
+	"testing"
+	"time"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/media"
+)
+
+func TestSinkRecordsTimecodes(t *testing.T) {
+	now := time.Unix(1000, 0)
+	sink := NewSink()
+	sink.now = func() time.Time {
+		return now
+	}
+
+	img := newTestImage(320, 240)
+	DrawTimecode(img, 100*time.Millisecond)
+	sink.WriteFrame(media.Frame{Image: img})
+
+	now = now.Add(40 * time.Millisecond)
+	sink.WriteFrame(media.Frame{Image: newTestImage(320, 240)})
+	sink.WriteFrame(media.Frame{})
+
+	samples := sink.Samples()
+	if len(samples) != 1 || samples[0] != (Sample{DisplayedAt: time.Unix(1000, 0), Timecode: 100 * time.Millisecond}) {
+		t.Fatalf("unexpected samples %+v", samples)
+	}
+	if sink.Unreadable() != 1 {
+		t.Fatalf("%d unreadable frames, want 1", sink.Unreadable())
+	}
+}
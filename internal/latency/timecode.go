@@ -0,0 +1,99 @@
+package latency
+
+import (
+	"encoding/binary"
+	"image"
+	"time"
+)
+
+// A timecode is burned into the top of each frame as a barcode: a row of
+// black and white cells holding the frame's timestamp in milliseconds,
+// followed by a check byte. The cells are a whole macroblock tall and several
+// pixels wide, so they survive compression at any reasonable bitrate.
+
+const (
+	timecodeBits   = 40
+	timecodeHeight = 16
+	timecodeCheck  = 0xa5
+
+	timecodeBlack = 16
+	timecodeWhite = 235
+	timecodeGrey  = (timecodeBlack + timecodeWhite) / 2
+
+	// MinTimecodeWidth is the narrowest frame that a timecode fits in.
+	MinTimecodeWidth = timecodeBits * 4
+)
+
+// timecodeBytes encodes a timestamp, which wraps after about 49 days.
+func timecodeBytes(timestamp time.Duration) []byte {
+	data := binary.BigEndian.AppendUint32(nil, uint32(timestamp.Milliseconds()))
+	return append(data, timecodeChecksum(data))
+}
+
+func timecodeChecksum(data []byte) uint8 {
+	check := uint8(timecodeCheck)
+	for _, b := range data {
+		check ^= b
+	}
+	return check
+}
+
+// DrawTimecode burns a timestamp into the top of a frame. Frames that are
+// smaller than the timecode are left unchanged.
+func DrawTimecode(img *image.YCbCr, timestamp time.Duration) {
+	width := img.Rect.Dx()
+	if width < MinTimecodeWidth || img.Rect.Dy() < timecodeHeight {
+		return
+	}
+
+	cellWidth := width / timecodeBits
+	data := timecodeBytes(timestamp)
+
+	for y := img.Rect.Min.Y; y < img.Rect.Min.Y+timecodeHeight; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			luma := uint8(timecodeBlack)
+			if bit := (x - img.Rect.Min.X) / cellWidth; bit < timecodeBits && data[bit/8]&(0x80>>(bit%8)) != 0 {
+				luma = timecodeWhite
+			}
+			img.Y[img.YOffset(x, y)] = luma
+
+			offset := img.COffset(x, y)
+			img.Cb[offset] = 128
+			img.Cr[offset] = 128
+		}
+	}
+}
+
+// ReadTimecode reads the timestamp burned into a frame by DrawTimecode. Each
+// cell is sampled away from its edges, where compression blurs it into its
+// neighbours. It reports false if the frame does not carry a valid timecode.
+func ReadTimecode(img *image.YCbCr) (time.Duration, bool) {
+	width := img.Rect.Dx()
+	if width < MinTimecodeWidth || img.Rect.Dy() < timecodeHeight {
+		return 0, false
+	}
+
+	cellWidth := width / timecodeBits
+	data := make([]byte, timecodeBits/8)
+
+	for bit := 0; bit < timecodeBits; bit++ {
+		left := img.Rect.Min.X + bit*cellWidth
+		sum, count := 0, 0
+		for y := img.Rect.Min.Y + timecodeHeight/4; y < img.Rect.Min.Y+timecodeHeight*3/4; y++ {
+			for x := left + cellWidth/4; x < left+cellWidth*3/4; x++ {
+				sum += int(img.Y[img.YOffset(x, y)])
+				count++
+			}
+		}
+
+		if sum/count > timecodeGrey {
+			data[bit/8] |= 0x80 >> (bit % 8)
+		}
+	}
+
+	if timecodeChecksum(data[:4]) != data[4] {
+		return 0, false
+	}
+
+	return time.Duration(binary.BigEndian.Uint32(data)) * time.Millisecond, true
+}
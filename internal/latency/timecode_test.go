@@ -0,0 +1,89 @@
+package latency
+
+import (
+	"image"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func newTestImage(width int, height int) *image.YCbCr {
+	return image.NewYCbCr(image.Rect(0, 0, width, height), image.YCbCrSubsampleRatio420)
+}
+
+func TestTimecodeRoundTrip(t *testing.T) {
+	img := newTestImage(320, 240)
+	for _, timestamp := range []time.Duration{0, 33 * time.Millisecond, 90 * time.Minute, 40 * 24 * time.Hour} {
+		DrawTimecode(img, timestamp)
+
+		got, ok := ReadTimecode(img)
+		if !ok || got != timestamp {
+			t.Fatalf("ReadTimecode() = %v, %t; want %v", got, ok, timestamp)
+		}
+	}
+}
+
+func TestTimecodeSurvivesNoise(t *testing.T) {
+	img := newTestImage(640, 360)
+	DrawTimecode(img, 1234567*time.Millisecond)
+
+	// distort every sample, and smear each cell boundary, as lossy
+	// compression might
+	random := rand.New(rand.NewSource(1))
+	for i, luma := range img.Y {
+		img.Y[i] = uint8(min(max(int(luma)+random.Intn(81)-40, 0), 255))
+	}
+	cellWidth := 640 / timecodeBits
+	for y := 0; y < timecodeHeight; y++ {
+		for bit := 1; bit < timecodeBits; bit++ {
+			x := bit * cellWidth
+			img.Y[img.YOffset(x, y)] = timecodeGrey
+			img.Y[img.YOffset(x-1, y)] = timecodeGrey
+		}
+	}
+
+	got, ok := ReadTimecode(img)
+	if !ok || got != 1234567*time.Millisecond {
+		t.Fatalf("ReadTimecode() = %v, %t", got, ok)
+	}
+}
+
+func TestReadTimecodeRejectsFramesWithoutTimecodes(t *testing.T) {
+	blank := newTestImage(320, 240)
+	if _, ok := ReadTimecode(blank); ok {
+		t.Fatal("read a timecode from a black frame")
+	}
+
+	for i := range blank.Y {
+		blank.Y[i] = 255
+	}
+	if _, ok := ReadTimecode(blank); ok {
+		t.Fatal("read a timecode from a white frame")
+	}
+
+	corrupt := newTestImage(320, 240)
+	DrawTimecode(corrupt, time.Second)
+	cellWidth := 320 / timecodeBits
+	for y := 0; y < timecodeHeight; y++ {
+		for x := 0; x < cellWidth; x++ {
+			corrupt.Y[corrupt.YOffset(x, y)] = timecodeWhite
+		}
+	}
+	if _, ok := ReadTimecode(corrupt); ok {
+		t.Fatal("read a timecode with a flipped bit")
+	}
+}
+
+func TestTimecodeIgnoresSmallFrames(t *testing.T) {
+	img := newTestImage(MinTimecodeWidth-2, 120)
+	DrawTimecode(img, time.Second)
+
+	for _, luma := range img.Y {
+		if luma != 0 {
+			t.Fatal("timecode was drawn into a frame that is too small")
+		}
+	}
+	if _, ok := ReadTimecode(img); ok {
+		t.Fatal("read a timecode from a frame that is too small")
+	}
+}
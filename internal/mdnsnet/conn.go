@@ -0,0 +1,58 @@
+// Package mdnsnet connects mDNS browsers and responders to networks. Besides
+// the host's multicast sockets, which are opened by the packages that use
+// them, it provides an in-memory network, so that advertising and browsing can
+// be tested together without touching the host network.
+package mdnsnet
+
+import (
+	"strings"
+)
+
+// Conn is an mDNS socket, which carries packed DNS messages.
+type Conn interface {
+	// Read receives a message, and returns the index of the interface that it
+	// arrived on, or zero if that is not known. It returns an error once the
+	// connection is closed.
+	Read(buffer []byte) (n int, ifIndex int, err error)
+
+	// Send multicasts a message to the mDNS group.
+	Send(message []byte) error
+
+	Close() error
+}
+
+// Unescape returns a domain name in presentation format, such as a record's
+// name after unpacking, with its escapes removed. Instance names often contain
+// characters that are escaped, such as "Living\ Room._googlecast._tcp.local.",
+// which is unescaped as "Living Room._googlecast._tcp.local.". Escaped dots
+// are unescaped too, so labels can no longer be split on dots.
+func Unescape(name string) string {
+	if !strings.Contains(name, `\`) {
+		return name
+	}
+
+	var unescaped strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] != '\\' || i+1 == len(name) {
+			unescaped.WriteByte(name[i])
+			continue
+		}
+
+		// \DDD is a decimal byte value, and \X is the character X
+		if i+3 < len(name) && isDigit(name[i+1]) && isDigit(name[i+2]) && isDigit(name[i+3]) {
+			value := int(name[i+1]-'0')*100 + int(name[i+2]-'0')*10 + int(name[i+3]-'0')
+			if value <= 255 {
+				unescaped.WriteByte(byte(value))
+				i += 3
+				continue
+			}
+		}
+		unescaped.WriteByte(name[i+1])
+		i++
+	}
+	return unescaped.String()
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,19 @@
+package mdnsnet
+
+import "testing"
+
+func TestUnescape(t *testing.T) {
+	tests := map[string]string{
+		"_googlecast._tcp.local.":                  "_googlecast._tcp.local.",
+		`Living\ Room._googlecast._tcp.local.`:     "Living Room._googlecast._tcp.local.",
+		`Bob\'s\ TV\.\ Upstairs._googlecast._tcp.`: "Bob's TV. Upstairs._googlecast._tcp.",
+		`Caf\195\169._googlecast._tcp.local.`:      "Café._googlecast._tcp.local.",
+		`trailing\`:                                `trailing\`,
+		`too\ large\999`:                           "too large999",
+	}
+	for escaped, want := range tests {
+		if got := Unescape(escaped); got != want {
+			t.Errorf("Unescape(%q) = %q, want %q", escaped, got, want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package mdnsnet
+
+import (
+	"net"
+	"sync"
+)
+
+// Network is an in-memory multicast network. A message sent by one of its
+// connections is delivered to every other connection that has joined it, as
+// a copy of the packed message, in the order that messages were sent.
+type Network struct {
+	conns map[*networkConn]struct{}
+	mu    sync.Mutex
+}
+
+func NewNetwork() *Network {
+	return &Network{conns: make(map[*networkConn]struct{})}
+}
+
+// Join returns a connection to the network. It receives the messages that are
+// sent after it joins, until it is closed.
+func (network *Network) Join() Conn {
+	conn := &networkConn{network: network}
+	conn.cond = sync.NewCond(&conn.mu)
+
+	network.mu.Lock()
+	network.conns[conn] = struct{}{}
+	network.mu.Unlock()
+
+	return conn
+}
+
+func (network *Network) deliver(from *networkConn, message []byte) {
+	network.mu.Lock()
+	defer network.mu.Unlock()
+
+	for conn := range network.conns {
+		if conn != from {
+			conn.enqueue(append([]byte(nil), message...))
+		}
+	}
+}
+
+func (network *Network) leave(conn *networkConn) {
+	network.mu.Lock()
+	delete(network.conns, conn)
+	network.mu.Unlock()
+}
+
+// networkConn queues the messages delivered to it, so that sending never
+// blocks on a slow reader.
+type networkConn struct {
+	closed  bool
+	cond    *sync.Cond
+	mu      sync.Mutex
+	network *Network
+	queue   [][]byte
+}
+
+func (conn *networkConn) enqueue(message []byte) {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	if !conn.closed {
+		conn.queue = append(conn.queue, message)
+		conn.cond.Signal()
+	}
+}
+
+func (conn *networkConn) Read(buffer []byte) (int, int, error) {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	for len(conn.queue) == 0 && !conn.closed {
+		conn.cond.Wait()
+	}
+	if conn.closed {
+		return 0, 0, net.ErrClosed
+	}
+
+	message := conn.queue[0]
+	conn.queue = conn.queue[1:]
+	return copy(buffer, message), 0, nil
+}
+
+func (conn *networkConn) Send(message []byte) error {
+	conn.mu.Lock()
+	closed := conn.closed
+	conn.mu.Unlock()
+	if closed {
+		return net.ErrClosed
+	}
+
+	conn.network.deliver(conn, message)
+	return nil
+}
+
+func (conn *networkConn) Close() error {
+	conn.mu.Lock()
+	conn.closed = true
+	conn.queue = nil
+	conn.cond.Broadcast()
+	conn.mu.Unlock()
+
+	conn.network.leave(conn)
+	return nil
+}
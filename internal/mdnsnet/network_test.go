@@ -0,0 +1,125 @@
+package mdnsnet
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func readMessage(t *testing.T, conn Conn) string {
+	t.Helper()
+
+	read := make(chan string, 1)
+	go func() {
+		buffer := make([]byte, 9000)
+		n, _, err := conn.Read(buffer)
+		if err != nil {
+			read <- "error: " + err.Error()
+			return
+		}
+		read <- string(buffer[:n])
+	}()
+
+	select {
+	case message := <-read:
+		return message
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message was read")
+		return ""
+	}
+}
+
+func TestNetworkDeliversToOtherConns(t *testing.T) {
+	network := NewNetwork()
+	sender := network.Join()
+	first := network.Join()
+	second := network.Join()
+
+	for _, message := range []string{"one", "two"} {
+		if err := sender.Send([]byte(message)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, conn := range []Conn{first, second} {
+		if got := readMessage(t, conn); got != "one" {
+			t.Fatalf("got %q, want one", got)
+		}
+		if got := readMessage(t, conn); got != "two" {
+			t.Fatalf("got %q, want two", got)
+		}
+	}
+
+	// senders do not receive their own messages, and late joiners only
+	// receive messages sent after they join
+	late := network.Join()
+	if err := first.Send([]byte("three")); err != nil {
+		t.Fatal(err)
+	}
+	if got := readMessage(t, sender); got != "three" {
+		t.Fatalf("sender got %q, want three", got)
+	}
+	if got := readMessage(t, late); got != "three" {
+		t.Fatalf("late joiner got %q, want three", got)
+	}
+	if got := readMessage(t, second); got != "three" {
+		t.Fatalf("got %q, want three", got)
+	}
+}
+
+func TestNetworkCopiesMessages(t *testing.T) {
+	network := NewNetwork()
+	sender := network.Join()
+	receiver := network.Join()
+
+	message := []byte("one")
+	if err := sender.Send(message); err != nil {
+		t.Fatal(err)
+	}
+	message[0] = 'x'
+
+	if got := readMessage(t, receiver); got != "one" {
+		t.Fatalf("got %q, want one", got)
+	}
+}
+
+func TestNetworkCloseUnblocksRead(t *testing.T) {
+	network := NewNetwork()
+	conn := network.Join()
+	other := network.Join()
+
+	read := make(chan error, 1)
+	go func() {
+		_, _, err := conn.Read(make([]byte, 512))
+		read <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	if err := conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-read:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Fatalf("got %v, want net.ErrClosed", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Read did not return after Close")
+	}
+
+	if err := conn.Send([]byte("one")); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("send after close: got %v, want net.ErrClosed", err)
+	}
+
+	// closed connections leave the network
+	if err := other.Send([]byte("one")); err != nil {
+		t.Fatal(err)
+	}
+	network.mu.Lock()
+	defer network.mu.Unlock()
+	if len(network.conns) != 1 {
+		t.Fatalf("got %d connections, want 1", len(network.conns))
+	}
+}
@@ -0,0 +1,256 @@
+package mdnsnet
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	// third-party
+	"github.com/brutella/dnssd"
+	"github.com/miekg/dns"
+)
+
+// Responder is a minimal mDNS responder for one service, which answers with
+// the same records as dnssd.Responder. When Respond is called, it probes for
+// the service's instance name, and renames the service if another responder
+// answers for that name at a different host or port, as dnssd.Responder does.
+// It then announces the service, answers queries for it while Respond runs,
+// and sends a goodbye when Respond returns.
+//
+// The service's addresses are taken from its IPs, and are sent on every
+// interface.
+type Responder struct {
+	conn    Conn
+	mu      sync.Mutex
+	service dnssd.Service
+}
+
+// NewResponder returns a responder for service that answers on conn. The
+// responder closes conn when Respond returns.
+func NewResponder(conn Conn, service dnssd.Service) *Responder {
+	return &Responder{conn: conn, service: service}
+}
+
+// Respond announces the service and answers queries until ctx is cancelled.
+// It returns ctx.Err() once the goodbye has been sent, or an error if the
+// connection fails.
+func (responder *Responder) Respond(ctx context.Context) error {
+	defer func() {
+		_ = responder.conn.Close()
+	}()
+
+	failed := make(chan error, 1)
+	messages := make(chan *dns.Msg)
+	go func() {
+		buffer := make([]byte, 9000)
+		for {
+			n, _, err := responder.conn.Read(buffer)
+			if err != nil {
+				failed <- err
+				return
+			}
+
+			msg := new(dns.Msg)
+			if err := msg.Unpack(buffer[:n]); err != nil {
+				continue
+			}
+
+			select {
+			case messages <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	if err := responder.probe(ctx, messages, failed); err != nil {
+		return err
+	}
+	if err := responder.announce(); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			service := responder.Service()
+			goodbye := dnssd.PTR(service)
+			goodbye.Hdr.Ttl = 0
+			if err := responder.send(&dns.Msg{Answer: []dns.RR{goodbye}}); err != nil {
+				return err
+			}
+			return ctx.Err()
+		case err := <-failed:
+			return err
+		case msg := <-messages:
+			if msg.Response {
+				continue
+			}
+			if err := responder.answer(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Service returns a copy of the service as it is currently advertised.
+func (responder *Responder) Service() dnssd.Service {
+	responder.mu.Lock()
+	defer responder.mu.Unlock()
+
+	return *responder.service.Copy()
+}
+
+// UpdateText replaces the service's TXT record, and announces the change, as
+// dnssd.ServiceHandle.UpdateText does.
+func (responder *Responder) UpdateText(text map[string]string) error {
+	responder.mu.Lock()
+	responder.service.Text = text
+	txt := dnssd.TXT(responder.service)
+	responder.mu.Unlock()
+
+	return responder.send(&dns.Msg{Answer: []dns.RR{txt}})
+}
+
+// ProbeWait is how long a responder waits for answers to its probe. Messages
+// on an in-memory network are delivered straight away, so it is much shorter
+// than the 250ms that RFC 6762 asks for.
+var ProbeWait = 25 * time.Millisecond
+
+// probe asks for the service's instance name, and while another responder
+// answers for it, tries the name with a number appended, starting from 2.
+func (responder *Responder) probe(ctx context.Context, messages <-chan *dns.Msg, failed <-chan error) error {
+	name := responder.Service().Name
+	for attempt := 2; ; attempt++ {
+		service := responder.Service()
+		query := new(dns.Msg)
+		query.SetQuestion(service.ServiceInstanceName(), dns.TypeANY)
+		query.Ns = []dns.RR{dnssd.SRV(service)}
+		packed, err := query.Pack()
+		if err != nil {
+			return err
+		}
+		if err := responder.conn.Send(packed); err != nil {
+			return err
+		}
+
+		conflict := false
+		wait := time.After(ProbeWait)
+		for waiting := true; waiting; {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case err := <-failed:
+				return err
+			case msg := <-messages:
+				if msg.Response && denies(msg, service) {
+					conflict = true
+				}
+			case <-wait:
+				waiting = false
+			}
+		}
+		if !conflict {
+			return nil
+		}
+
+		responder.mu.Lock()
+		responder.service.Name = fmt.Sprintf("%s %d", name, attempt)
+		responder.mu.Unlock()
+	}
+}
+
+// denies reports whether msg has a SRV record for the service's instance name
+// that points at another host or port.
+func denies(msg *dns.Msg, service dnssd.Service) bool {
+	for _, record := range append(append(msg.Answer, msg.Ns...), msg.Extra...) {
+		srv, ok := record.(*dns.SRV)
+		if !ok || !strings.EqualFold(Unescape(srv.Hdr.Name), service.ServiceInstanceName()) {
+			continue
+		}
+		if !strings.EqualFold(Unescape(srv.Target), service.Hostname()) || int(srv.Port) != service.Port {
+			return true
+		}
+	}
+	return false
+}
+
+func (responder *Responder) announce() error {
+	service := responder.Service()
+
+	msg := &dns.Msg{Answer: []dns.RR{dnssd.PTR(service)}}
+	msg.Extra = append([]dns.RR{dnssd.SRV(service), dnssd.TXT(service)}, addresses(service)...)
+	return responder.send(msg)
+}
+
+// answer responds to the questions in query that are about the service. The
+// answers to all of the questions are sent in one response.
+func (responder *Responder) answer(query *dns.Msg) error {
+	service := responder.Service()
+
+	var answers, extra []dns.RR
+	for _, question := range query.Question {
+		name := Unescape(question.Name)
+		switch {
+		case strings.EqualFold(name, service.ServiceName()) && matches(question, dns.TypePTR):
+			answers = append(answers, dnssd.PTR(service))
+			extra = append(extra, dnssd.SRV(service), dnssd.TXT(service))
+			extra = append(extra, addresses(service)...)
+		case strings.EqualFold(name, service.ServicesMetaQueryName()) && matches(question, dns.TypePTR):
+			answers = append(answers, dnssd.DNSSDServicesPTR(service))
+		case strings.EqualFold(name, service.ServiceInstanceName()):
+			if matches(question, dns.TypeSRV) {
+				answers = append(answers, dnssd.SRV(service))
+				extra = append(extra, addresses(service)...)
+			}
+			if matches(question, dns.TypeTXT) {
+				answers = append(answers, dnssd.TXT(service))
+			}
+		case strings.EqualFold(name, service.Hostname()):
+			for _, record := range addresses(service) {
+				if matches(question, record.Header().Rrtype) {
+					answers = append(answers, record)
+				}
+			}
+		}
+	}
+	if len(answers) == 0 {
+		return nil
+	}
+
+	return responder.send(&dns.Msg{Answer: answers, Extra: extra})
+}
+
+func (responder *Responder) send(msg *dns.Msg) error {
+	msg.Response = true
+	msg.Authoritative = true
+
+	packed, err := msg.Pack()
+	if err != nil {
+		return err
+	}
+	return responder.conn.Send(packed)
+}
+
+func matches(question dns.Question, rrtype uint16) bool {
+	return question.Qtype == rrtype || question.Qtype == dns.TypeANY
+}
+
+// addresses returns A and AAAA records for the service's addresses.
+func addresses(service dnssd.Service) []dns.RR {
+	var records []dns.RR
+	for _, ip := range service.IPs {
+		header := dns.RR_Header{Name: service.Hostname(), Class: dns.ClassINET, Ttl: dnssd.TTLHostname}
+		if ip4 := ip.To4(); ip4 != nil {
+			header.Rrtype = dns.TypeA
+			records = append(records, &dns.A{Hdr: header, A: ip4})
+		} else if ip.To16() != nil {
+			header.Rrtype = dns.TypeAAAA
+			records = append(records, &dns.AAAA{Hdr: header, AAAA: append(net.IP(nil), ip...)})
+		}
+	}
+	return records
+}
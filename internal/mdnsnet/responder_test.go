@@ -0,0 +1,204 @@
+package mdnsnet
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	// third-party
+	"github.com/brutella/dnssd"
+	"github.com/miekg/dns"
+)
+
+func testService(t *testing.T) dnssd.Service {
+	t.Helper()
+
+	service, err := dnssd.NewService(dnssd.Config{
+		Name: "Living Room",
+		Type: "_googlecast._tcp",
+		Host: "device-1",
+		Port: 8009,
+		Text: map[string]string{"id": "device-1", "fn": "Living Room"},
+		IPs:  []net.IP{net.ParseIP("192.0.2.10"), net.ParseIP("2001:db8::10")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service
+}
+
+func readResponse(t *testing.T, conn Conn) *dns.Msg {
+	t.Helper()
+
+	msg := new(dns.Msg)
+	if err := msg.Unpack([]byte(readMessage(t, conn))); err != nil {
+		t.Fatal(err)
+	}
+	if !msg.Response {
+		t.Fatalf("got a query, want a response: %v", msg)
+	}
+	return msg
+}
+
+// readProbe reads the query that a responder sends before announcing its
+// service, and returns the instance name that it probes for.
+func readProbe(t *testing.T, conn Conn) string {
+	t.Helper()
+
+	msg := new(dns.Msg)
+	if err := msg.Unpack([]byte(readMessage(t, conn))); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Response || len(msg.Question) != 1 || msg.Question[0].Qtype != dns.TypeANY {
+		t.Fatalf("got %v, want a probe", msg)
+	}
+	return Unescape(msg.Question[0].Name)
+}
+
+func sendQuery(t *testing.T, conn Conn, name string, qtype uint16) {
+	t.Helper()
+
+	query := new(dns.Msg)
+	query.SetQuestion(name, qtype)
+	packed, err := query.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Send(packed); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func countTypes(records []dns.RR) map[uint16]int {
+	counts := make(map[uint16]int)
+	for _, record := range records {
+		counts[record.Header().Rrtype]++
+	}
+	return counts
+}
+
+func TestResponderAnnouncesAnswersAndSaysGoodbye(t *testing.T) {
+	network := NewNetwork()
+	browser := network.Join()
+	responder := NewResponder(network.Join(), testService(t))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- responder.Respond(ctx)
+	}()
+
+	if name := readProbe(t, browser); name != "Living Room._googlecast._tcp.local." {
+		t.Fatalf("probed for %q", name)
+	}
+	announcement := readResponse(t, browser)
+	if len(announcement.Answer) != 1 || announcement.Answer[0].(*dns.PTR).Ptr != `Living\ Room._googlecast._tcp.local.` {
+		t.Fatalf("unexpected announcement %v", announcement)
+	}
+	if counts := countTypes(announcement.Extra); counts[dns.TypeSRV] != 1 || counts[dns.TypeTXT] != 1 || counts[dns.TypeA] != 1 || counts[dns.TypeAAAA] != 1 {
+		t.Fatalf("unexpected announcement records %v", announcement.Extra)
+	}
+
+	sendQuery(t, browser, "device-1.local.", dns.TypeAAAA)
+	answer := readResponse(t, browser)
+	if len(answer.Answer) != 1 || !answer.Answer[0].(*dns.AAAA).AAAA.Equal(net.ParseIP("2001:db8::10")) {
+		t.Fatalf("unexpected answer %v", answer)
+	}
+
+	// questions about other names are not answered
+	sendQuery(t, browser, "_airplay._tcp.local.", dns.TypePTR)
+	sendQuery(t, browser, "Living Room._googlecast._tcp.local.", dns.TypeTXT)
+	answer = readResponse(t, browser)
+	if len(answer.Answer) != 1 || answer.Answer[0].Header().Rrtype != dns.TypeTXT {
+		t.Fatalf("unexpected answer %v", answer)
+	}
+
+	cancel()
+	goodbye := readResponse(t, browser)
+	if len(goodbye.Answer) != 1 || goodbye.Answer[0].Header().Rrtype != dns.TypePTR || goodbye.Answer[0].Header().Ttl != 0 {
+		t.Fatalf("unexpected goodbye %v", goodbye)
+	}
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Respond returned %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Respond did not return")
+	}
+}
+
+func TestResponderAnnouncesTextUpdates(t *testing.T) {
+	network := NewNetwork()
+	browser := network.Join()
+	responder := NewResponder(network.Join(), testService(t))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = responder.Respond(ctx)
+	}()
+	readProbe(t, browser)
+	readResponse(t, browser)
+
+	if err := responder.UpdateText(map[string]string{"id": "device-1", "fn": "Kitchen"}); err != nil {
+		t.Fatal(err)
+	}
+	update := readResponse(t, browser)
+	if len(update.Answer) != 1 {
+		t.Fatalf("unexpected update %v", update)
+	}
+	txt := update.Answer[0].(*dns.TXT).Txt
+	if len(txt) != 2 || txt[0] != "fn=Kitchen" || txt[1] != "id=device-1" {
+		t.Fatalf("unexpected TXT record %v", txt)
+	}
+	if responder.Service().Text["fn"] != "Kitchen" {
+		t.Fatalf("service was not updated: %+v", responder.Service())
+	}
+}
+
+func TestResponderRenamesConflictingService(t *testing.T) {
+	network := NewNetwork()
+	browser := network.Join()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	first := NewResponder(network.Join(), testService(t))
+	go func() {
+		_ = first.Respond(ctx)
+	}()
+	readProbe(t, browser)
+	readResponse(t, browser)
+
+	// the same name on another host is taken, so the second responder answers
+	// for the next name that is free
+	service := testService(t)
+	service.Host = "device-2"
+	second := NewResponder(network.Join(), service)
+	go func() {
+		_ = second.Respond(ctx)
+	}()
+	if name := readProbe(t, browser); name != "Living Room._googlecast._tcp.local." {
+		t.Fatalf("probed for %q", name)
+	}
+	readResponse(t, browser)
+	if name := readProbe(t, browser); name != "Living Room 2._googlecast._tcp.local." {
+		t.Fatalf("probed for %q after a conflict", name)
+	}
+	announcement := readResponse(t, browser)
+	if ptr := announcement.Answer[0].(*dns.PTR).Ptr; Unescape(ptr) != "Living Room 2._googlecast._tcp.local." {
+		t.Fatalf("announced %q", ptr)
+	}
+	if first.Service().Name != "Living Room" || second.Service().Name != "Living Room 2" {
+		t.Fatalf("unexpected names %q and %q", first.Service().Name, second.Service().Name)
+	}
+
+	// a responder that answers for its own name at the same address is not a
+	// conflict
+	if denies(&dns.Msg{Answer: []dns.RR{dnssd.SRV(first.Service())}}, first.Service()) {
+		t.Fatal("a responder's own record was treated as a conflict")
+	}
+}
@@ -0,0 +1,52 @@
+package media
+
+import (
+	"image"
+	"time"
+)
+
+// StreamInfo identifies the stream that a frame was decoded from.
+type StreamInfo struct {
+	CodecName string
+	SessionID string
+	SSRC      uint32
+}
+
+// Frame is a decoded video frame. The image may be shared between several
+// sinks, so sinks must not modify it.
+type Frame struct {
+	FrameID      int64
+	Image        *image.YCbCr
+	PlayoutTime  time.Time
+	RtpTimestamp uint32
+	Stream       StreamInfo
+
+	// Timestamp is the frame's media time, relative to the first frame of the
+	// stream.
+	Timestamp time.Duration
+}
+
+// Size returns the number of bytes of pixel data in the frame.
+func (frame Frame) Size() int {
+	if frame.Image == nil {
+		return 0
+	}
+
+	return len(frame.Image.Y) + len(frame.Image.Cb) + len(frame.Image.Cr)
+}
+
+// FrameSink consumes decoded frames. WriteFrame is called from the decoding
+// goroutine, so it must not block; slow sinks should be wrapped in an
+// AsyncSink.
+type FrameSink interface {
+	WriteFrame(frame Frame)
+}
+
+// FanOut delivers each frame to several sinks, in order.
+type FanOut []FrameSink
+
+func (fanOut FanOut) WriteFrame(frame Frame) {
+	for _, sink := range fanOut {
+		sink.WriteFrame(frame)
+	}
+}
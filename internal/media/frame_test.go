@@ -0,0 +1,50 @@
+package media
+
+import (
+	"image"
+	"testing"
+)
+
+type recordingSink struct {
+	frames []int64
+	name   string
+	order  *[]string
+}
+
+func (sink *recordingSink) WriteFrame(frame Frame) {
+	sink.frames = append(sink.frames, frame.FrameID)
+	if sink.order != nil {
+		*sink.order = append(*sink.order, sink.name)
+	}
+}
+
+func testFrame(frameID int64) Frame {
+	return Frame{
+		FrameID: frameID,
+		Image:   image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio420),
+	}
+}
+
+func TestFrameSize(t *testing.T) {
+	if size := testFrame(1).Size(); size != 8+2+2 {
+		t.Fatalf("expected 12 bytes, got %d", size)
+	}
+	if size := (Frame{}).Size(); size != 0 {
+		t.Fatalf("expected frame without image to be empty, got %d bytes", size)
+	}
+}
+
+func TestFanOutWritesToEverySinkInOrder(t *testing.T) {
+	var order []string
+	first := &recordingSink{name: "first", order: &order}
+	second := &recordingSink{name: "second", order: &order}
+
+	FanOut{first, second}.WriteFrame(testFrame(7))
+
+	if len(order) != 2 || order[0] != "first" || order[1] != "second" {
+		t.Fatalf("unexpected delivery order %v", order)
+	}
+	if len(first.frames) != 1 || first.frames[0] != 7 || len(second.frames) != 1 || second.frames[0] != 7 {
+		t.Fatalf("expected both sinks to receive frame 7, got %v and %v", first.frames, second.frames)
+	}
+}
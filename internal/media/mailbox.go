@@ -0,0 +1,108 @@
+package media
+
+import (
+	"sync"
+)
+
+// Mailbox holds the most recent frame written to it. Writing never blocks: a
+// frame that has not been taken by the time the next one arrives is dropped.
+type Mailbox struct {
+	mu sync.Mutex
+
+	dropped uint64
+	frame   *Frame
+	ready   chan struct{}
+}
+
+func NewMailbox() *Mailbox {
+	return &Mailbox{
+		ready: make(chan struct{}, 1),
+	}
+}
+
+func (mailbox *Mailbox) WriteFrame(frame Frame) {
+	mailbox.mu.Lock()
+	if mailbox.frame != nil {
+		mailbox.dropped++
+	}
+	mailbox.frame = &frame
+	mailbox.mu.Unlock()
+
+	select {
+	case mailbox.ready <- struct{}{}:
+	default:
+	}
+}
+
+// Ready receives a value when a frame may be waiting to be taken.
+func (mailbox *Mailbox) Ready() <-chan struct{} {
+	return mailbox.ready
+}
+
+// Take removes and returns the waiting frame, if there is one.
+func (mailbox *Mailbox) Take() (Frame, bool) {
+	mailbox.mu.Lock()
+	defer mailbox.mu.Unlock()
+
+	if mailbox.frame == nil {
+		return Frame{}, false
+	}
+
+	frame := *mailbox.frame
+	mailbox.frame = nil
+	return frame, true
+}
+
+// Dropped returns the number of frames that were replaced before they could
+// be taken.
+func (mailbox *Mailbox) Dropped() uint64 {
+	mailbox.mu.Lock()
+	defer mailbox.mu.Unlock()
+
+	return mailbox.dropped
+}
+
+// AsyncSink delivers frames to a slow sink from its own goroutine, via a
+// mailbox, so that the slow sink skips stale frames instead of blocking the
+// decoder.
+type AsyncSink struct {
+	*Mailbox
+
+	done chan struct{}
+	stop chan struct{}
+}
+
+func NewAsyncSink(sink FrameSink) *AsyncSink {
+	async := &AsyncSink{
+		Mailbox: NewMailbox(),
+		done:    make(chan struct{}),
+		stop:    make(chan struct{}),
+	}
+
+	go func() {
+		defer close(async.done)
+
+		for {
+			select {
+			case <-async.stop:
+				if frame, ok := async.Take(); ok {
+					sink.WriteFrame(frame)
+				}
+				return
+			case <-async.Ready():
+				if frame, ok := async.Take(); ok {
+					sink.WriteFrame(frame)
+				}
+			}
+		}
+	}()
+
+	return async
+}
+
+// Close stops delivering frames, after writing any frame that is still waiting
+// to the underlying sink.
+func (async *AsyncSink) Close() {
+	close(async.stop)
+	<-async.done
+}
@@ -0,0 +1,78 @@
+package media
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMailboxKeepsLatestFrame(t *testing.T) {
+	mailbox := NewMailbox()
+	if _, ok := mailbox.Take(); ok {
+		t.Fatal("expected empty mailbox")
+	}
+
+	mailbox.WriteFrame(testFrame(1))
+	mailbox.WriteFrame(testFrame(2))
+	mailbox.WriteFrame(testFrame(3))
+
+	select {
+	case <-mailbox.Ready():
+	default:
+		t.Fatal("expected mailbox to be ready")
+	}
+
+	frame, ok := mailbox.Take()
+	if !ok || frame.FrameID != 3 {
+		t.Fatalf("expected frame 3, got %d (ok=%v)", frame.FrameID, ok)
+	}
+	if _, ok := mailbox.Take(); ok {
+		t.Fatal("expected frame to be taken only once")
+	}
+	if dropped := mailbox.Dropped(); dropped != 2 {
+		t.Fatalf("expected 2 dropped frames, got %d", dropped)
+	}
+}
+
+type blockingSink struct {
+	frames  chan int64
+	release chan struct{}
+}
+
+func (sink *blockingSink) WriteFrame(frame Frame) {
+	<-sink.release
+	sink.frames <- frame.FrameID
+}
+
+func TestAsyncSinkDoesNotBlockWriter(t *testing.T) {
+	sink := &blockingSink{frames: make(chan int64, 10), release: make(chan struct{})}
+	async := NewAsyncSink(sink)
+
+	done := make(chan struct{})
+	go func() {
+		for frameID := int64(1); frameID <= 5; frameID++ {
+			async.WriteFrame(testFrame(frameID))
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("writes blocked on a slow sink")
+	}
+
+	close(sink.release)
+	async.Close()
+	close(sink.frames)
+
+	var delivered []int64
+	for frameID := range sink.frames {
+		delivered = append(delivered, frameID)
+	}
+	if len(delivered) == 0 || delivered[len(delivered)-1] != 5 {
+		t.Fatalf("expected the latest frame to be delivered last, got %v", delivered)
+	}
+	if len(delivered) > 2 {
+		t.Fatalf("expected stale frames to be dropped, got %v", delivered)
+	}
+}
@@ -0,0 +1,76 @@
+package media
+
+import (
+	"fmt"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+
+	// third-party
+	"github.com/hashicorp/go-hclog"
+)
+
+// SnapshotFormat identifies the image format used for snapshots.
+type SnapshotFormat string
+
+const (
+	JPEGSnapshot SnapshotFormat = "jpg"
+	PNGSnapshot  SnapshotFormat = "png"
+)
+
+// SnapshotSink writes each frame to a numbered image file. Encoding is slow,
+// so it is normally wrapped in an AsyncSink.
+type SnapshotSink struct {
+	count  int
+	dir    string
+	format SnapshotFormat
+	log    hclog.Logger
+}
+
+func NewSnapshotSink(dir string, format SnapshotFormat, log hclog.Logger) (*SnapshotSink, error) {
+	if format != JPEGSnapshot && format != PNGSnapshot {
+		return nil, fmt.Errorf("create snapshot sink: unsupported format %q", format)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create snapshot sink: %w", err)
+	}
+
+	return &SnapshotSink{
+		dir:    dir,
+		format: format,
+		log:    log,
+	}, nil
+}
+
+func (sink *SnapshotSink) WriteFrame(frame Frame) {
+	sink.count++
+	snapshotPath := filepath.Join(sink.dir, fmt.Sprintf("%d.%s", sink.count, sink.format))
+	if err := sink.write(snapshotPath, frame); err != nil {
+		sink.log.Error("failed to write snapshot", "path", snapshotPath, "err", err)
+	}
+}
+
+// EncodeSnapshot encodes a frame as a JPEG or PNG image.
+func EncodeSnapshot(output io.Writer, frame Frame, format SnapshotFormat) error {
+	if format == PNGSnapshot {
+		return png.Encode(output, frame.Image)
+	}
+
+	return jpeg.Encode(output, frame.Image, nil)
+}
+
+func (sink *SnapshotSink) write(snapshotPath string, frame Frame) error {
+	file, err := os.Create(snapshotPath)
+	if err != nil {
+		return err
+	}
+
+	err = EncodeSnapshot(file, frame, sink.format)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
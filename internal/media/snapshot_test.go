@@ -0,0 +1,45 @@
+package media
+
+import (
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+
+	// third-party
+	"github.com/hashicorp/go-hclog"
+)
+
+func TestSnapshotSinkWritesNumberedFiles(t *testing.T) {
+	for _, format := range []SnapshotFormat{JPEGSnapshot, PNGSnapshot} {
+		dir := t.TempDir()
+		sink, err := NewSnapshotSink(dir, format, hclog.NewNullLogger())
+		if err != nil {
+			t.Fatalf("create snapshot sink: %v", err)
+		}
+
+		sink.WriteFrame(testFrame(1))
+		sink.WriteFrame(testFrame(2))
+
+		for _, name := range []string{"1." + string(format), "2." + string(format)} {
+			file, err := os.Open(filepath.Join(dir, name))
+			if err != nil {
+				t.Fatalf("expected snapshot %s: %v", name, err)
+			}
+			_, decodedFormat, err := image.DecodeConfig(file)
+			_ = file.Close()
+			if err != nil {
+				t.Fatalf("decode snapshot %s: %v", name, err)
+			}
+			if (format == PNGSnapshot) != (decodedFormat == "png") {
+				t.Fatalf("snapshot %s has format %s", name, decodedFormat)
+			}
+		}
+	}
+}
+
+func TestSnapshotSinkRejectsUnknownFormat(t *testing.T) {
+	if _, err := NewSnapshotSink(t.TempDir(), "gif", hclog.NewNullLogger()); err == nil {
+		t.Fatal("expected an error for an unsupported format")
+	}
+}
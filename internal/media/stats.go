@@ -0,0 +1,31 @@
+package media
+
+import (
+	"sync/atomic"
+)
+
+// FrameStats counts the frames written to a StatsSink.
+type FrameStats struct {
+	Bytes  uint64
+	Frames uint64
+}
+
+// StatsSink counts frames and the size of their pixel data, without holding
+// on to the frames themselves.
+type StatsSink struct {
+	bytes  atomic.Uint64
+	frames atomic.Uint64
+}
+
+func (sink *StatsSink) WriteFrame(frame Frame) {
+	sink.bytes.Add(uint64(frame.Size()))
+	sink.frames.Add(1)
+}
+
+// Snapshot returns the current totals.
+func (sink *StatsSink) Snapshot() FrameStats {
+	return FrameStats{
+		Bytes:  sink.bytes.Load(),
+		Frames: sink.frames.Load(),
+	}
+}
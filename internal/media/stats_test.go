@@ -0,0 +1,16 @@
+package media
+
+import (
+	"testing"
+)
+
+func TestStatsSinkCountsFrames(t *testing.T) {
+	stats := &StatsSink{}
+	stats.WriteFrame(testFrame(1))
+	stats.WriteFrame(testFrame(2))
+
+	snapshot := stats.Snapshot()
+	if snapshot.Frames != 2 || snapshot.Bytes != 24 {
+		t.Fatalf("unexpected stats %+v", snapshot)
+	}
+}
@@ -0,0 +1,249 @@
+package media
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	// third-party
+	"github.com/hashicorp/go-hclog"
+)
+
+// YUV4MPEG2 streams start with a single line header, such as:
+//
+//	YUV4MPEG2 W1280 H720 F30:1 Ip A1:1 C420jpeg
+//
+// Each frame is then written as a 'FRAME' line, followed by the Y, Cb and Cr
+// planes without padding. The header cannot describe a change of resolution.
+// When writing to files, a new stream (a segment) is started in a new file
+// whenever the resolution changes. Stdout and FIFOs have a single stream, and
+// readers such as ffmpeg stop at a second header, so frames of other
+// resolutions are scaled to the first frame's resolution instead.
+// Mirroring streams do not have a fixed frame rate; frames are written as they
+// are played out, and the header advertises a nominal rate.
+
+const y4mFrameRate = "30:1"
+
+// Y4MSink writes frames to a YUV4MPEG2 stream, for processing by tools such as
+// ffmpeg. Writes block while the reader is busy, so it is normally wrapped in
+// an AsyncSink.
+type Y4MSink struct {
+	// create opens the output for a segment. Pipes and stdout have a single
+	// output, so it is nil and frames are scaled to fit the first segment.
+	create func(segment int) (io.WriteCloser, error)
+
+	failed     bool
+	height     int
+	log        hclog.Logger
+	output     io.WriteCloser
+	scaled     *image.YCbCr
+	scaledFrom image.Point
+	segment    int
+	writer     *bufio.Writer
+	width      int
+}
+
+// NewY4MSink writes a single stream to output, scaling frames to the
+// resolution of the first frame.
+func NewY4MSink(output io.WriteCloser, log hclog.Logger) *Y4MSink {
+	return &Y4MSink{
+		log:    log,
+		output: output,
+		writer: bufio.NewWriter(output),
+	}
+}
+
+// CreateY4MSink writes to stdout when outputPath is "-", and to a FIFO if
+// outputPath names one. Otherwise, each segment is written to its own file,
+// with segments after the first numbered from 1 (e.g. out.y4m, out-1.y4m).
+func CreateY4MSink(outputPath string, log hclog.Logger) (*Y4MSink, error) {
+	if outputPath == "-" {
+		return NewY4MSink(os.Stdout, log), nil
+	}
+
+	if info, err := os.Stat(outputPath); err == nil && info.Mode()&os.ModeNamedPipe != 0 {
+		fifo, err := os.OpenFile(outputPath, os.O_WRONLY, 0)
+		if err != nil {
+			return nil, fmt.Errorf("open y4m output: %w", err)
+		}
+
+		return NewY4MSink(fifo, log), nil
+	}
+
+	create := func(segment int) (io.WriteCloser, error) {
+		file, err := os.Create(y4mSegmentPath(outputPath, segment))
+		if err != nil {
+			return nil, fmt.Errorf("create y4m output: %w", err)
+		}
+
+		return file, nil
+	}
+
+	// create the first segment up front, so that a bad path is reported
+	// before any frames arrive
+	output, err := create(0)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Y4MSink{
+		create: create,
+		log:    log,
+		output: output,
+		writer: bufio.NewWriter(output),
+	}, nil
+}
+
+func y4mSegmentPath(outputPath string, segment int) string {
+	if segment == 0 {
+		return outputPath
+	}
+
+	ext := filepath.Ext(outputPath)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(outputPath, ext), segment, ext)
+}
+
+// WriteFrame writes a frame. If its resolution differs from the previous
+// frame, it starts a new segment, or is scaled when there is a single output.
+// The sink stops writing after the first error, which
+// usually means that the reader has gone away.
+func (sink *Y4MSink) WriteFrame(frame Frame) {
+	if sink.failed {
+		return
+	}
+
+	if err := sink.writeFrame(frame.Image); err != nil {
+		sink.log.Error("stopping y4m output", "err", err)
+		sink.failed = true
+	}
+}
+
+func (sink *Y4MSink) writeFrame(img *image.YCbCr) error {
+	if img.SubsampleRatio != image.YCbCrSubsampleRatio420 {
+		return fmt.Errorf("write y4m frame: unsupported subsample ratio %v", img.SubsampleRatio)
+	}
+
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	if width != sink.width || height != sink.height {
+		if sink.width == 0 || sink.create != nil {
+			if err := sink.startSegment(width, height); err != nil {
+				return err
+			}
+		} else {
+			img = sink.scale(img)
+			width, height = sink.width, sink.height
+		}
+	}
+
+	if _, err := sink.writer.WriteString("FRAME\n"); err != nil {
+		return fmt.Errorf("write y4m frame: %w", err)
+	}
+
+	rect := img.Rect
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		offset := img.YOffset(rect.Min.X, y)
+		if _, err := sink.writer.Write(img.Y[offset : offset+width]); err != nil {
+			return fmt.Errorf("write y4m frame: %w", err)
+		}
+	}
+
+	chromaWidth := (rect.Max.X+1)/2 - rect.Min.X/2
+	for _, plane := range [][]byte{img.Cb, img.Cr} {
+		for y := rect.Min.Y; y < rect.Max.Y; y += 2 {
+			offset := img.COffset(rect.Min.X, y)
+			if _, err := sink.writer.Write(plane[offset : offset+chromaWidth]); err != nil {
+				return fmt.Errorf("write y4m frame: %w", err)
+			}
+		}
+	}
+
+	if err := sink.writer.Flush(); err != nil {
+		return fmt.Errorf("write y4m frame: %w", err)
+	}
+
+	return nil
+}
+
+func (sink *Y4MSink) startSegment(width int, height int) error {
+	if sink.width != 0 {
+		sink.segment++
+		sink.log.Info("resolution changed, starting new y4m segment", "segment", sink.segment, "width", width, "height", height)
+
+		if err := sink.output.Close(); err != nil {
+			return fmt.Errorf("close y4m segment: %w", err)
+		}
+
+		output, err := sink.create(sink.segment)
+		if err != nil {
+			return err
+		}
+
+		sink.output = output
+		sink.writer = bufio.NewWriter(output)
+	}
+
+	sink.width = width
+	sink.height = height
+
+	header := fmt.Sprintf("YUV4MPEG2 W%d H%d F%s Ip A1:1 C420jpeg\n", width, height, y4mFrameRate)
+	if _, err := sink.writer.WriteString(header); err != nil {
+		return fmt.Errorf("write y4m header: %w", err)
+	}
+
+	return nil
+}
+
+// scale resizes img to the resolution of the stream, using nearest neighbour
+// sampling, which is crude but cheap enough to keep up with playout. The
+// result is only valid until the next call.
+func (sink *Y4MSink) scale(img *image.YCbCr) *image.YCbCr {
+	srcWidth, srcHeight := img.Rect.Dx(), img.Rect.Dy()
+	if from := image.Pt(srcWidth, srcHeight); from != sink.scaledFrom {
+		sink.log.Info("resolution changed, scaling frames to fit the y4m stream", "width", srcWidth, "height", srcHeight)
+		sink.scaledFrom = from
+	}
+
+	if sink.scaled == nil {
+		sink.scaled = image.NewYCbCr(image.Rect(0, 0, sink.width, sink.height), image.YCbCrSubsampleRatio420)
+	}
+
+	dst := sink.scaled
+	for y := 0; y < sink.height; y++ {
+		srcY := img.Rect.Min.Y + y*srcHeight/sink.height
+		for x := 0; x < sink.width; x++ {
+			srcX := img.Rect.Min.X + x*srcWidth/sink.width
+			dst.Y[dst.YOffset(x, y)] = img.Y[img.YOffset(srcX, srcY)]
+		}
+	}
+
+	// each chroma sample covers a 2x2 block of luma samples, so it is taken
+	// from the source sample under the block's top left corner
+	for y := 0; y < sink.height; y += 2 {
+		srcY := img.Rect.Min.Y + y*srcHeight/sink.height
+		for x := 0; x < sink.width; x += 2 {
+			srcX := img.Rect.Min.X + x*srcWidth/sink.width
+			dstOffset, srcOffset := dst.COffset(x, y), img.COffset(srcX, srcY)
+			dst.Cb[dstOffset] = img.Cb[srcOffset]
+			dst.Cr[dstOffset] = img.Cr[srcOffset]
+		}
+	}
+
+	return dst
+}
+
+// Close closes the output.
+func (sink *Y4MSink) Close() error {
+	err := sink.writer.Flush()
+	if closeErr := sink.output.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("close y4m output: %w", err)
+	}
+
+	return nil
+}
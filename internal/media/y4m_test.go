@@ -0,0 +1,151 @@
+package media
+
+import (
+	"bytes"
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+
+	// third-party
+	"github.com/hashicorp/go-hclog"
+)
+
+type bufferWriteCloser struct {
+	bytes.Buffer
+}
+
+func (buffer *bufferWriteCloser) Close() error {
+	return nil
+}
+
+func y4mTestFrame(width int, height int, luma byte) Frame {
+	img := image.NewYCbCr(image.Rect(0, 0, width, height), image.YCbCrSubsampleRatio420)
+	for i := range img.Y {
+		img.Y[i] = luma
+	}
+	for i := range img.Cb {
+		img.Cb[i] = 0x80
+		img.Cr[i] = 0x81
+	}
+
+	return Frame{Image: img}
+}
+
+func TestY4MSinkWritesHeaderAndPlanes(t *testing.T) {
+	output := &bufferWriteCloser{}
+	sink := NewY4MSink(output, hclog.NewNullLogger())
+
+	sink.WriteFrame(y4mTestFrame(4, 2, 0x10))
+	sink.WriteFrame(y4mTestFrame(4, 2, 0x20))
+
+	var expected bytes.Buffer
+	expected.WriteString("YUV4MPEG2 W4 H2 F30:1 Ip A1:1 C420jpeg\n")
+	for _, luma := range []byte{0x10, 0x20} {
+		expected.WriteString("FRAME\n")
+		expected.Write(bytes.Repeat([]byte{luma}, 8))
+		expected.Write([]byte{0x80, 0x80, 0x81, 0x81})
+	}
+
+	if !bytes.Equal(output.Bytes(), expected.Bytes()) {
+		t.Fatalf("unexpected y4m stream:\n%q\nexpected:\n%q", output.Bytes(), expected.Bytes())
+	}
+}
+
+func TestY4MSinkSkipsPadding(t *testing.T) {
+	output := &bufferWriteCloser{}
+	sink := NewY4MSink(output, hclog.NewNullLogger())
+
+	frame := y4mTestFrame(4, 4, 0x10)
+	frame.Image = frame.Image.SubImage(image.Rect(0, 0, 2, 2)).(*image.YCbCr)
+	sink.WriteFrame(frame)
+
+	header := "YUV4MPEG2 W2 H2 F30:1 Ip A1:1 C420jpeg\nFRAME\n"
+	if expected := len(header) + 4 + 1 + 1; output.Len() != expected {
+		t.Fatalf("expected %d bytes, got %d", expected, output.Len())
+	}
+}
+
+func TestY4MSinkScalesFramesOnResolutionChange(t *testing.T) {
+	output := &bufferWriteCloser{}
+	sink := NewY4MSink(output, hclog.NewNullLogger())
+
+	sink.WriteFrame(y4mTestFrame(4, 2, 0x10))
+	sink.WriteFrame(y4mTestFrame(2, 2, 0x20))
+
+	// a second header would end the stream for readers such as ffmpeg, so the
+	// smaller frame is scaled up to the first frame's resolution
+	var expected bytes.Buffer
+	expected.WriteString("YUV4MPEG2 W4 H2 F30:1 Ip A1:1 C420jpeg\n")
+	for _, luma := range []byte{0x10, 0x20} {
+		expected.WriteString("FRAME\n")
+		expected.Write(bytes.Repeat([]byte{luma}, 8))
+		expected.Write([]byte{0x80, 0x80, 0x81, 0x81})
+	}
+
+	if !bytes.Equal(output.Bytes(), expected.Bytes()) {
+		t.Fatalf("unexpected y4m stream:\n%q\nexpected:\n%q", output.Bytes(), expected.Bytes())
+	}
+}
+
+func TestY4MSinkScalingSamplesSourceImage(t *testing.T) {
+	sink := NewY4MSink(&bufferWriteCloser{}, hclog.NewNullLogger())
+	sink.WriteFrame(y4mTestFrame(4, 4, 0x10))
+
+	// a 2x2 frame, offset within its buffer, whose left column is dark and
+	// right column is bright
+	frame := y4mTestFrame(4, 4, 0x00)
+	img := frame.Image.SubImage(image.Rect(2, 2, 4, 4)).(*image.YCbCr)
+	for y := 2; y < 4; y++ {
+		img.Y[img.YOffset(3, y)] = 0xff
+	}
+	img.Cb[img.COffset(2, 2)] = 0x40
+
+	scaled := sink.scale(img)
+	for y := 0; y < 4; y++ {
+		if row := scaled.Y[y*scaled.YStride : y*scaled.YStride+4]; !bytes.Equal(row, []byte{0x00, 0x00, 0xff, 0xff}) {
+			t.Fatalf("unexpected luma in row %d: %x", y, row)
+		}
+	}
+	if !bytes.Equal(scaled.Cb, []byte{0x40, 0x40, 0x40, 0x40}) || !bytes.Equal(scaled.Cr, []byte{0x81, 0x81, 0x81, 0x81}) {
+		t.Fatalf("unexpected chroma: %x %x", scaled.Cb, scaled.Cr)
+	}
+}
+
+func TestCreateY4MSinkWritesSegmentFiles(t *testing.T) {
+	outputPath := filepath.Join(t.TempDir(), "out.y4m")
+	sink, err := CreateY4MSink(outputPath, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatalf("create y4m sink: %v", err)
+	}
+
+	sink.WriteFrame(y4mTestFrame(4, 2, 0x10))
+	sink.WriteFrame(y4mTestFrame(2, 2, 0x10))
+	sink.WriteFrame(y4mTestFrame(4, 2, 0x10))
+	if err := sink.Close(); err != nil {
+		t.Fatalf("close y4m sink: %v", err)
+	}
+
+	for i, name := range []string{"out.y4m", "out-1.y4m", "out-2.y4m"} {
+		data, err := os.ReadFile(filepath.Join(filepath.Dir(outputPath), name))
+		if err != nil {
+			t.Fatalf("expected segment %d: %v", i, err)
+		}
+		if count := bytes.Count(data, []byte("YUV4MPEG2 ")); count != 1 {
+			t.Fatalf("expected segment %s to have one header, got %d", name, count)
+		}
+	}
+}
+
+func TestY4MSinkRejectsUnsupportedSubsampling(t *testing.T) {
+	output := &bufferWriteCloser{}
+	sink := NewY4MSink(output, hclog.NewNullLogger())
+
+	img := image.NewYCbCr(image.Rect(0, 0, 2, 2), image.YCbCrSubsampleRatio444)
+	sink.WriteFrame(Frame{Image: img})
+	sink.WriteFrame(y4mTestFrame(2, 2, 0x10))
+
+	if output.Len() != 0 {
+		t.Fatalf("expected nothing to be written after an error, got %d bytes", output.Len())
+	}
+}
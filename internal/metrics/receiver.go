@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/server"
+	"github.com/tristanpenman/go-cast/internal/session"
+)
+
+// SessionLister lists the sessions to report stream metrics for.
+type SessionLister interface {
+	ActiveSessions() []*session.Session
+}
+
+// ConnectionLister reports message counts for open client connections.
+type ConnectionLister interface {
+	ConnectionStats() []server.ConnectionStats
+}
+
+// streamMetric describes a per-stream metric, and how to read it from the
+// stream's statistics.
+type streamMetric struct {
+	help       string
+	metricType MetricType
+	name       string
+	value      func(stats session.StreamStats) float64
+}
+
+var streamMetrics = []streamMetric{
+	{"Payload bitrate over the most recent second.", Gauge, "gocast_stream_bitrate_bits_per_second",
+		func(stats session.StreamStats) float64 { return stats.Bitrate }},
+	{"RTP payload bytes received.", Counter, "gocast_stream_bytes_received_total",
+		func(stats session.StreamStats) float64 { return float64(stats.BytesReceived) }},
+	{"Frames that failed to decode.", Counter, "gocast_stream_decode_errors_total",
+		func(stats session.StreamStats) float64 { return float64(stats.DecodeErrors) }},
+	{"Frames passed to the decoder.", Counter, "gocast_stream_frames_decoded_total",
+		func(stats session.StreamStats) float64 { return float64(stats.FramesDecoded) }},
+	{"Frames that were late, failed to decode or were skipped.", Counter, "gocast_stream_frames_dropped_total",
+		func(stats session.StreamStats) float64 { return float64(stats.FramesDropped) }},
+	{"Frames that were decoded and rendered.", Counter, "gocast_stream_frames_rendered_total",
+		func(stats session.StreamStats) float64 { return float64(stats.FramesRendered) }},
+	{"Interarrival jitter, as defined by RFC 3550.", Gauge, "gocast_stream_jitter_seconds",
+		func(stats session.StreamStats) float64 { return stats.Jitter.Seconds() }},
+	{"Packets that repeated a packet that had already been received.", Counter, "gocast_stream_packets_duplicated_total",
+		func(stats session.StreamStats) float64 { return float64(stats.PacketsDuplicated) }},
+	{"Packets expected less packets received.", Gauge, "gocast_stream_packets_lost",
+		func(stats session.StreamStats) float64 { return float64(stats.PacketsLost) }},
+	{"RTP packets received.", Counter, "gocast_stream_packets_received_total",
+		func(stats session.StreamStats) float64 { return float64(stats.PacketsReceived) }},
+	{"Delay between capture and playout requested by the sender.", Gauge, "gocast_stream_playout_delay_seconds",
+		func(stats session.StreamStats) float64 { return stats.PlayoutDelay.Seconds() }},
+}
+
+// Gather collects metrics for every active session and open connection.
+func Gather(sessions SessionLister, connections ConnectionLister) []Family {
+	activeSessions := sessions.ActiveSessions()
+
+	families := []Family{{
+		Help:    "Mirroring sessions that are running.",
+		Name:    "gocast_sessions_active",
+		Samples: []Sample{{Value: float64(len(activeSessions))}},
+		Type:    Gauge,
+	}}
+
+	streamFamilies := make([]Family, len(streamMetrics))
+	for i, metric := range streamMetrics {
+		streamFamilies[i] = Family{Help: metric.help, Name: metric.name, Type: metric.metricType}
+	}
+	for _, activeSession := range activeSessions {
+		for _, stats := range activeSession.StreamStats() {
+			labels := []Label{
+				{Name: "app_id", Value: activeSession.AppId},
+				{Name: "session_id", Value: activeSession.SessionId},
+				{Name: "ssrc", Value: strconv.FormatUint(uint64(stats.SSRC), 10)},
+			}
+			for i, metric := range streamMetrics {
+				streamFamilies[i].Samples = append(streamFamilies[i].Samples, Sample{Labels: labels, Value: metric.value(stats)})
+			}
+		}
+	}
+	families = append(families, streamFamilies...)
+
+	received := Family{Help: "Cast messages received from the sender.", Name: "gocast_connection_messages_received_total", Type: Counter}
+	sent := Family{Help: "Cast messages sent to the sender.", Name: "gocast_connection_messages_sent_total", Type: Counter}
+	for _, stats := range connections.ConnectionStats() {
+		received.Samples = append(received.Samples, namespaceSamples(stats, stats.MessagesReceived)...)
+		sent.Samples = append(sent.Samples, namespaceSamples(stats, stats.MessagesSent)...)
+	}
+
+	return append(families, received, sent)
+}
+
+func namespaceSamples(stats server.ConnectionStats, counts map[string]uint64) []Sample {
+	connection := strconv.Itoa(stats.ID)
+
+	samples := make([]Sample, 0, len(counts))
+	for _, namespace := range slices.Sorted(maps.Keys(counts)) {
+		samples = append(samples, Sample{
+			Labels: []Label{{Name: "connection", Value: connection}, {Name: "namespace", Value: namespace}},
+			Value:  float64(counts[namespace]),
+		})
+	}
+
+	return samples
+}
+
+// NewHandler serves metrics in the Prometheus text format.
+func NewHandler(sessions SessionLister, connections ConnectionLister) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = WriteText(w, Gather(sessions, connections))
+	})
+}
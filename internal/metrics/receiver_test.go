@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/server"
+	"github.com/tristanpenman/go-cast/internal/session"
+)
+
+type testSessions []*session.Session
+
+func (sessions testSessions) ActiveSessions() []*session.Session {
+	return sessions
+}
+
+type testConnections []server.ConnectionStats
+
+func (connections testConnections) ConnectionStats() []server.ConnectionStats {
+	return connections
+}
+
+func findFamily(t *testing.T, families []Family, name string) Family {
+	for _, family := range families {
+		if family.Name == name {
+			return family
+		}
+	}
+
+	t.Fatalf("missing metric family %s", name)
+	return Family{}
+}
+
+func TestGatherReportsConnectionMessages(t *testing.T) {
+	connections := testConnections{{
+		ID:               3,
+		MessagesReceived: map[string]uint64{"urn:b": 2, "urn:a": 1},
+		MessagesSent:     map[string]uint64{"urn:a": 4},
+	}}
+
+	families := Gather(testSessions{}, connections)
+
+	if active := findFamily(t, families, "gocast_sessions_active"); active.Samples[0].Value != 0 {
+		t.Fatalf("expected no active sessions, got %v", active.Samples[0].Value)
+	}
+
+	received := findFamily(t, families, "gocast_connection_messages_received_total")
+	if len(received.Samples) != 2 {
+		t.Fatalf("expected 2 received samples, got %+v", received.Samples)
+	}
+	first := received.Samples[0]
+	if first.Labels[0] != (Label{Name: "connection", Value: "3"}) || first.Labels[1] != (Label{Name: "namespace", Value: "urn:a"}) || first.Value != 1 {
+		t.Fatalf("unexpected first sample %+v", first)
+	}
+
+	sent := findFamily(t, families, "gocast_connection_messages_sent_total")
+	if len(sent.Samples) != 1 || sent.Samples[0].Value != 4 {
+		t.Fatalf("unexpected sent samples %+v", sent.Samples)
+	}
+
+	// stream families are declared even when there are no streams
+	if packets := findFamily(t, families, "gocast_stream_packets_received_total"); len(packets.Samples) != 0 {
+		t.Fatalf("expected no stream samples, got %+v", packets.Samples)
+	}
+}
+
+func TestHandlerServesTextFormat(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	NewHandler(testSessions{}, testConnections{}).ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	if contentType := recorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Fatalf("unexpected content type %q", contentType)
+	}
+
+	body, _ := io.ReadAll(recorder.Body)
+	if !strings.Contains(string(body), "gocast_sessions_active 0\n") {
+		t.Fatalf("expected active sessions gauge in:\n%s", body)
+	}
+}
@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MetricType is the type of a metric family, as declared in the Prometheus
+// text exposition format.
+type MetricType string
+
+const (
+	Counter MetricType = "counter"
+	Gauge   MetricType = "gauge"
+)
+
+// Label is a name and value that distinguishes a sample from others in the
+// same family.
+type Label struct {
+	Name  string
+	Value string
+}
+
+type Sample struct {
+	Labels []Label
+	Value  float64
+}
+
+// Family is a set of samples that share a name, help text and type.
+type Family struct {
+	Help    string
+	Name    string
+	Samples []Sample
+	Type    MetricType
+}
+
+// WriteText writes families in the Prometheus text exposition format. Families
+// are written in name order, so that output is stable between scrapes.
+func WriteText(output io.Writer, families []Family) error {
+	sorted := append([]Family(nil), families...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	writer := bufio.NewWriter(output)
+	for _, family := range sorted {
+		_, _ = fmt.Fprintf(writer, "# HELP %s %s\n", family.Name, escapeHelp(family.Help))
+		_, _ = fmt.Fprintf(writer, "# TYPE %s %s\n", family.Name, family.Type)
+		for _, sample := range family.Samples {
+			_, _ = writer.WriteString(family.Name)
+			writeLabels(writer, sample.Labels)
+			_, _ = fmt.Fprintf(writer, " %s\n", formatValue(sample.Value))
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("write metrics: %w", err)
+	}
+
+	return nil
+}
+
+func writeLabels(writer *bufio.Writer, labels []Label) {
+	if len(labels) == 0 {
+		return
+	}
+
+	_ = writer.WriteByte('{')
+	for i, label := range labels {
+		if i > 0 {
+			_ = writer.WriteByte(',')
+		}
+		_, _ = fmt.Fprintf(writer, "%s=\"%s\"", label.Name, escapeLabelValue(label.Value))
+	}
+	_ = writer.WriteByte('}')
+}
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
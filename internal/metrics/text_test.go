@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestWriteTextFormatsFamiliesInNameOrder(t *testing.T) {
+	families := []Family{
+		{
+			Help: "Second family.",
+			Name: "b_total",
+			Samples: []Sample{
+				{Labels: []Label{{Name: "kind", Value: `quote " and \ slash`}}, Value: 3},
+				{Labels: []Label{{Name: "kind", Value: "line\nbreak"}}, Value: math.Inf(1)},
+			},
+			Type: Counter,
+		},
+		{
+			Help:    "First family,\nwith a newline.",
+			Name:    "a_seconds",
+			Samples: []Sample{{Value: 0.25}},
+			Type:    Gauge,
+		},
+		{
+			Help: "Family without samples.",
+			Name: "c",
+			Type: Gauge,
+		},
+	}
+
+	var output bytes.Buffer
+	if err := WriteText(&output, families); err != nil {
+		t.Fatalf("write text: %v", err)
+	}
+
+	expected := `# HELP a_seconds First family,\nwith a newline.
+# TYPE a_seconds gauge
+a_seconds 0.25
+# HELP b_total Second family.
+# TYPE b_total counter
+b_total{kind="quote \" and \\ slash"} 3
+b_total{kind="line\nbreak"} +Inf
+# HELP c Family without samples.
+# TYPE c gauge
+`
+	if output.String() != expected {
+		t.Fatalf("unexpected output:\n%s\nexpected:\n%s", output.String(), expected)
+	}
+}
@@ -0,0 +1,86 @@
+package preview
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/media"
+)
+
+// FrameCache holds the most recent frame for the preview server. Writing a
+// frame only stores it; frames are encoded when a client asks for them, at
+// most once per frame however many clients are watching.
+type FrameCache struct {
+	mu sync.Mutex
+
+	frame    media.Frame
+	frameSeq uint64
+	stats    media.StatsSink
+	updated  chan struct{}
+
+	encodeMu sync.Mutex
+	jpeg     []byte
+	jpegSeq  uint64
+}
+
+func NewFrameCache() *FrameCache {
+	return &FrameCache{
+		updated: make(chan struct{}),
+	}
+}
+
+func (cache *FrameCache) WriteFrame(frame media.Frame) {
+	cache.stats.WriteFrame(frame)
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.frame = frame
+	cache.frameSeq++
+	close(cache.updated)
+	cache.updated = make(chan struct{})
+}
+
+// Stats returns the number of frames written to the cache.
+func (cache *FrameCache) Stats() media.FrameStats {
+	return cache.stats.Snapshot()
+}
+
+// latest returns the sequence number of the most recent frame, and a channel
+// that is closed when the next frame arrives.
+func (cache *FrameCache) latest() (uint64, <-chan struct{}) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	return cache.frameSeq, cache.updated
+}
+
+// latestJpeg encodes the most recent frame, reusing the previous encoding if
+// there has not been a new frame since. It returns nil if no frames have been
+// written.
+func (cache *FrameCache) latestJpeg() ([]byte, uint64, error) {
+	cache.encodeMu.Lock()
+	defer cache.encodeMu.Unlock()
+
+	cache.mu.Lock()
+	frame, frameSeq := cache.frame, cache.frameSeq
+	cache.mu.Unlock()
+
+	if frameSeq == 0 {
+		return nil, 0, nil
+	}
+	if frameSeq == cache.jpegSeq {
+		return cache.jpeg, frameSeq, nil
+	}
+
+	var buffer bytes.Buffer
+	if err := media.EncodeSnapshot(&buffer, frame, media.JPEGSnapshot); err != nil {
+		return nil, 0, fmt.Errorf("encode preview: %w", err)
+	}
+
+	cache.jpeg = buffer.Bytes()
+	cache.jpegSeq = frameSeq
+	return cache.jpeg, frameSeq, nil
+}
@@ -0,0 +1,161 @@
+package preview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	// third-party
+	"github.com/hashicorp/go-hclog"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/session"
+)
+
+const mjpegBoundary = "frame"
+
+// SessionLister lists the sessions that are shown on the status page.
+type SessionLister interface {
+	ActiveSessions() []*session.Session
+}
+
+// Server serves the most recent frame in a FrameCache over HTTP, as a JPEG
+// snapshot and as an MJPEG stream, along with a status page.
+type Server struct {
+	cache      *FrameCache
+	done       chan struct{}
+	httpServer *http.Server
+	listener   net.Listener
+	log        hclog.Logger
+	mux        *http.ServeMux
+	sessions   SessionLister
+	startedAt  time.Time
+}
+
+// NewServer starts serving on addr. Paths:
+//
+//	/              status page
+//	/snapshot.jpg  most recent frame
+//	/stream.mjpeg  multipart MJPEG stream
+func NewServer(addr string, cache *FrameCache, sessions SessionLister, log hclog.Logger) (*Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen for http preview: %w", err)
+	}
+
+	server := &Server{
+		cache:     cache,
+		done:      make(chan struct{}),
+		listener:  listener,
+		log:       log,
+		mux:       http.NewServeMux(),
+		sessions:  sessions,
+		startedAt: time.Now(),
+	}
+
+	server.mux.HandleFunc("/", server.handleStatus)
+	server.mux.HandleFunc("/snapshot.jpg", server.handleSnapshot)
+	server.mux.HandleFunc("/stream.mjpeg", server.handleStream)
+	server.httpServer = &http.Server{Handler: server.mux, ReadHeaderTimeout: 10 * time.Second}
+
+	log.Info("serving http preview", "addr", listener.Addr())
+
+	go func() {
+		if err := server.httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("http preview stopped", "err", err)
+		}
+	}()
+
+	return server, nil
+}
+
+// Addr returns the address that the server is listening on.
+func (server *Server) Addr() net.Addr {
+	return server.listener.Addr()
+}
+
+// Handle serves an additional path, alongside the preview.
+func (server *Server) Handle(pattern string, handler http.Handler) {
+	server.mux.Handle(pattern, handler)
+}
+
+// Close stops the server, ending any MJPEG streams.
+func (server *Server) Close() error {
+	close(server.done)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := server.httpServer.Shutdown(ctx); err != nil {
+		return fmt.Errorf("stop http preview: %w", err)
+	}
+
+	return nil
+}
+
+func (server *Server) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	data, _, err := server.cache.latestJpeg()
+	if err != nil {
+		server.log.Error("failed to encode snapshot", "err", err)
+		http.Error(w, "failed to encode snapshot", http.StatusInternalServerError)
+		return
+	}
+	if data == nil {
+		http.Error(w, "no frames received", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "image/jpeg")
+	_, _ = w.Write(data)
+}
+
+func (server *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary="+mjpegBoundary)
+	flusher.Flush()
+
+	var sentSeq uint64
+	for {
+		frameSeq, updated := server.cache.latest()
+		if frameSeq == sentSeq {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-server.done:
+				return
+			case <-updated:
+				continue
+			}
+		}
+
+		data, encodedSeq, err := server.cache.latestJpeg()
+		if err != nil {
+			server.log.Error("failed to encode stream frame", "err", err)
+			return
+		}
+
+		header := fmt.Sprintf("--%s\r\nContent-Type: image/jpeg\r\nContent-Length: %d\r\n\r\n", mjpegBoundary, len(data))
+		if _, err := w.Write([]byte(header)); err != nil {
+			return
+		}
+		if _, err := w.Write(data); err != nil {
+			return
+		}
+		if _, err := w.Write([]byte("\r\n")); err != nil {
+			return
+		}
+		flusher.Flush()
+
+		sentSeq = encodedSeq
+	}
+}
@@ -0,0 +1,176 @@
+package preview
+
+import (
+	"bufio"
+	"image"
+	"image/jpeg"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	// third-party
+	"github.com/hashicorp/go-hclog"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/media"
+	"github.com/tristanpenman/go-cast/internal/session"
+)
+
+type noSessions struct{}
+
+func (noSessions) ActiveSessions() []*session.Session {
+	return nil
+}
+
+func testFrame() media.Frame {
+	return media.Frame{
+		Image: image.NewYCbCr(image.Rect(0, 0, 16, 8), image.YCbCrSubsampleRatio420),
+	}
+}
+
+func newTestServer(t *testing.T) (*Server, *FrameCache, string) {
+	cache := NewFrameCache()
+	server, err := NewServer("127.0.0.1:0", cache, noSessions{}, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatalf("start server: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = server.Close()
+	})
+
+	return server, cache, "http://" + server.Addr().String()
+}
+
+func TestSnapshotUnavailableBeforeFirstFrame(t *testing.T) {
+	_, _, baseURL := newTestServer(t)
+
+	response, err := http.Get(baseURL + "/snapshot.jpg")
+	if err != nil {
+		t.Fatalf("get snapshot: %v", err)
+	}
+	_ = response.Body.Close()
+
+	if response.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected status 503, got %d", response.StatusCode)
+	}
+}
+
+func TestSnapshotServesLatestFrame(t *testing.T) {
+	_, cache, baseURL := newTestServer(t)
+	cache.WriteFrame(testFrame())
+
+	response, err := http.Get(baseURL + "/snapshot.jpg")
+	if err != nil {
+		t.Fatalf("get snapshot: %v", err)
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+
+	if contentType := response.Header.Get("Content-Type"); contentType != "image/jpeg" {
+		t.Fatalf("unexpected content type %q", contentType)
+	}
+
+	config, err := jpeg.DecodeConfig(response.Body)
+	if err != nil {
+		t.Fatalf("decode snapshot: %v", err)
+	}
+	if config.Width != 16 || config.Height != 8 {
+		t.Fatalf("unexpected snapshot size %dx%d", config.Width, config.Height)
+	}
+}
+
+func TestStreamSendsEachNewFrame(t *testing.T) {
+	_, cache, baseURL := newTestServer(t)
+	cache.WriteFrame(testFrame())
+
+	response, err := http.Get(baseURL + "/stream.mjpeg")
+	if err != nil {
+		t.Fatalf("get stream: %v", err)
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+
+	mediaType, params, err := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/x-mixed-replace" {
+		t.Fatalf("unexpected content type %q", response.Header.Get("Content-Type"))
+	}
+
+	reader := multipart.NewReader(bufio.NewReader(response.Body), params["boundary"])
+	for i := 0; i < 2; i++ {
+		part, err := reader.NextPart()
+		if err != nil {
+			t.Fatalf("read part %d: %v", i, err)
+		}
+		if contentType := part.Header.Get("Content-Type"); contentType != "image/jpeg" {
+			t.Fatalf("unexpected part content type %q", contentType)
+		}
+		if _, err := jpeg.DecodeConfig(part); err != nil {
+			t.Fatalf("decode part %d: %v", i, err)
+		}
+
+		if i == 0 {
+			// the second part is only sent once there is a new frame, and the
+			// end of the first part cannot be read until then
+			time.AfterFunc(10*time.Millisecond, func() {
+				cache.WriteFrame(testFrame())
+			})
+		}
+	}
+}
+
+func TestStatusPageReportsFrames(t *testing.T) {
+	_, cache, baseURL := newTestServer(t)
+	cache.WriteFrame(testFrame())
+
+	response, err := http.Get(baseURL + "/")
+	if err != nil {
+		t.Fatalf("get status: %v", err)
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatalf("read status: %v", err)
+	}
+	for _, expected := range []string{"1 frames received", "No active sessions", "stream.mjpeg"} {
+		if !strings.Contains(string(body), expected) {
+			t.Fatalf("expected status page to contain %q", expected)
+		}
+	}
+
+	response, err = http.Get(baseURL + "/missing")
+	if err != nil {
+		t.Fatalf("get missing page: %v", err)
+	}
+	_ = response.Body.Close()
+	if response.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected status 404, got %d", response.StatusCode)
+	}
+}
+
+func TestFrameCacheEncodesEachFrameOnce(t *testing.T) {
+	cache := NewFrameCache()
+	cache.WriteFrame(testFrame())
+
+	first, firstSeq, err := cache.latestJpeg()
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	second, secondSeq, _ := cache.latestJpeg()
+	if firstSeq != secondSeq || &first[0] != &second[0] {
+		t.Fatal("expected the cached encoding to be reused")
+	}
+
+	cache.WriteFrame(testFrame())
+	if _, thirdSeq, _ := cache.latestJpeg(); thirdSeq == firstSeq {
+		t.Fatal("expected a new frame to be encoded")
+	}
+}
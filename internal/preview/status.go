@@ -0,0 +1,91 @@
+package preview
+
+import (
+	"html/template"
+	"net/http"
+	"time"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/media"
+	"github.com/tristanpenman/go-cast/internal/session"
+)
+
+var statusTemplate = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>go-cast receiver</title>
+<style>
+body { font-family: sans-serif; margin: 1em 2em; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 0.25em 0.75em; text-align: left; }
+img { max-width: 100%; background: #000; }
+</style>
+</head>
+<body>
+<h1>go-cast receiver</h1>
+<p>Up {{.Uptime}}. {{.Frames.Frames}} frames received ({{.Frames.Bytes}} bytes).</p>
+<h2>Sessions</h2>
+{{range .Sessions}}
+<h3>{{.DisplayName}} ({{.AppID}})</h3>
+<p>Session {{.SessionID}}</p>
+<table>
+<tr><th>SSRC</th><th>Packets</th><th>Lost</th><th>Jitter</th><th>Bitrate (bps)</th><th>Playout delay</th><th>Frames rendered</th><th>Frames dropped</th></tr>
+{{range .Streams}}
+<tr><td>{{.SSRC}}</td><td>{{.PacketsReceived}}</td><td>{{.PacketsLost}}</td><td>{{.Jitter}}</td><td>{{printf "%.0f" .Bitrate}}</td><td>{{.PlayoutDelay}}</td><td>{{.FramesRendered}}</td><td>{{.FramesDropped}}</td></tr>
+{{else}}
+<tr><td colspan="8">Waiting for an offer</td></tr>
+{{end}}
+</table>
+{{else}}
+<p>No active sessions.</p>
+{{end}}
+<h2>Preview</h2>
+<p><a href="snapshot.jpg">Snapshot</a> &middot; <a href="stream.mjpeg">MJPEG stream</a></p>
+<img src="stream.mjpeg" alt="preview">
+</body>
+</html>
+`))
+
+type sessionStatus struct {
+	AppID       string
+	DisplayName string
+	SessionID   string
+	Streams     []session.StreamStats
+}
+
+type status struct {
+	Frames   media.FrameStats
+	Sessions []sessionStatus
+	Uptime   time.Duration
+}
+
+func (server *Server) status() status {
+	var sessions []sessionStatus
+	for _, activeSession := range server.sessions.ActiveSessions() {
+		sessions = append(sessions, sessionStatus{
+			AppID:       activeSession.AppId,
+			DisplayName: activeSession.DisplayName,
+			SessionID:   activeSession.SessionId,
+			Streams:     activeSession.StreamStats(),
+		})
+	}
+
+	return status{
+		Frames:   server.cache.Stats(),
+		Sessions: sessions,
+		Uptime:   time.Since(server.startedAt).Round(time.Second),
+	}
+}
+
+func (server *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := statusTemplate.Execute(w, server.status()); err != nil {
+		server.log.Error("failed to render status page", "err", err)
+	}
+}
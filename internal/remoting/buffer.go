@@ -0,0 +1,95 @@
+package remoting
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	// third-party
+	"google.golang.org/protobuf/proto"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/remoting/wire"
+)
+
+// DecoderBuffer is one encoded audio or video frame, as sent over Cast
+// Streaming by a remoting sender.
+type DecoderBuffer struct {
+	Data        []byte
+	Duration    time.Duration
+	EndOfStream bool
+	Keyframe    bool
+	Timestamp   time.Duration
+}
+
+// A serialized decoder buffer starts with a version byte, followed by the
+// size and contents of a protobuf describing the buffer, then the size and
+// contents of the frame data. Sizes are big endian.
+const bufferVersion = 0
+
+var errBufferTruncated = errors.New("decoder buffer truncated")
+
+// Marshal serializes the buffer.
+func (buffer *DecoderBuffer) Marshal() []byte {
+	// headers have no required fields, so encoding them cannot fail
+	header, _ := proto.Marshal(&wire.DecoderBuffer{
+		DurationUsec:  proto.Int64(buffer.Duration.Microseconds()),
+		IsEos:         proto.Bool(buffer.EndOfStream),
+		IsKeyFrame:    proto.Bool(buffer.Keyframe),
+		TimestampUsec: proto.Int64(buffer.Timestamp.Microseconds()),
+	})
+
+	data := make([]byte, 0, 1+2+len(header)+4+len(buffer.Data))
+	data = append(data, bufferVersion)
+	data = binary.BigEndian.AppendUint16(data, uint16(len(header)))
+	data = append(data, header...)
+	data = binary.BigEndian.AppendUint32(data, uint32(len(buffer.Data)))
+	return append(data, buffer.Data...)
+}
+
+// Unmarshal parses a serialized buffer. The frame data is not copied.
+func (buffer *DecoderBuffer) Unmarshal(data []byte) error {
+	if len(data) < 3 {
+		return errBufferTruncated
+	}
+	if data[0] != bufferVersion {
+		return fmt.Errorf("unsupported decoder buffer version %d", data[0])
+	}
+
+	size := int(binary.BigEndian.Uint16(data[1:]))
+	data = data[3:]
+	if len(data) < size {
+		return errBufferTruncated
+	}
+
+	var header wire.DecoderBuffer
+	if err := proto.Unmarshal(data[:size], &header); err != nil {
+		return fmt.Errorf("parse decoder buffer: %w", err)
+	}
+	data = data[size:]
+
+	*buffer = DecoderBuffer{
+		Duration:    time.Duration(header.GetDurationUsec()) * time.Microsecond,
+		EndOfStream: header.GetIsEos(),
+		Keyframe:    header.GetIsKeyFrame(),
+		Timestamp:   time.Duration(header.GetTimestampUsec()) * time.Microsecond,
+	}
+
+	// End of stream buffers have no frame data, and some senders omit its size
+	if len(data) == 0 && buffer.EndOfStream {
+		return nil
+	}
+	if len(data) < 4 {
+		return errBufferTruncated
+	}
+
+	size = int(binary.BigEndian.Uint32(data))
+	data = data[4:]
+	if len(data) < size {
+		return errBufferTruncated
+	}
+	buffer.Data = data[:size]
+
+	return nil
+}
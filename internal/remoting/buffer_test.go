@@ -0,0 +1,56 @@
+package remoting
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestDecoderBufferRoundTrip(t *testing.T) {
+	buffer := DecoderBuffer{
+		Data:      []byte{0x9d, 0x01, 0x2a, 0x00},
+		Duration:  33 * time.Millisecond,
+		Keyframe:  true,
+		Timestamp: 1234567 * time.Microsecond,
+	}
+
+	var decoded DecoderBuffer
+	if err := decoded.Unmarshal(buffer.Marshal()); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decoded.Data, buffer.Data) || decoded.Duration != buffer.Duration ||
+		decoded.EndOfStream || !decoded.Keyframe || decoded.Timestamp != buffer.Timestamp {
+		t.Fatalf("decoded %+v, want %+v", decoded, buffer)
+	}
+}
+
+func TestDecoderBufferEndOfStream(t *testing.T) {
+	data := (&DecoderBuffer{EndOfStream: true}).Marshal()
+
+	// the data size may be omitted from end of stream buffers
+	for _, raw := range [][]byte{data, data[:len(data)-4]} {
+		var decoded DecoderBuffer
+		if err := decoded.Unmarshal(raw); err != nil {
+			t.Fatal(err)
+		}
+		if !decoded.EndOfStream || len(decoded.Data) != 0 {
+			t.Fatalf("unexpected buffer %+v", decoded)
+		}
+	}
+}
+
+func TestDecoderBufferUnmarshalMalformed(t *testing.T) {
+	complete := (&DecoderBuffer{Data: []byte{1, 2, 3}, Timestamp: time.Second}).Marshal()
+	for i := 0; i < len(complete); i++ {
+		var decoded DecoderBuffer
+		if err := decoded.Unmarshal(complete[:i]); err == nil {
+			t.Fatalf("truncated to %d bytes: expected error", i)
+		}
+	}
+
+	unsupported := append([]byte{1}, complete[1:]...)
+	var decoded DecoderBuffer
+	if err := decoded.Unmarshal(unsupported); err == nil {
+		t.Fatal("expected error for unsupported version")
+	}
+}
@@ -0,0 +1,109 @@
+package remoting
+
+import (
+	// third-party
+	"google.golang.org/protobuf/proto"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/remoting/wire"
+)
+
+// Codecs, using the values of the media pipeline that remoting senders
+// serialize.
+const (
+	AudioCodecOpus int32 = 12
+	VideoCodecVP8  int32 = 6
+)
+
+// AudioDecoderConfig describes an audio stream.
+type AudioDecoderConfig struct {
+	ChannelLayout    int32
+	Codec            int32
+	ExtraData        []byte
+	SamplesPerSecond int32
+}
+
+// VideoDecoderConfig describes a video stream.
+type VideoDecoderConfig struct {
+	Codec       int32
+	CodedSize   Size
+	ExtraData   []byte
+	NaturalSize Size
+	Profile     int32
+}
+
+// Size is the size of a picture, in pixels.
+type Size struct {
+	Height int32
+	Width  int32
+}
+
+// Configs that are nil convert to nil, so that absent streams stay absent.
+
+func (config *AudioDecoderConfig) toWire() *wire.AudioDecoderConfig {
+	if config == nil {
+		return nil
+	}
+
+	return &wire.AudioDecoderConfig{
+		ChannelLayout:    proto.Int32(config.ChannelLayout),
+		Codec:            proto.Int32(config.Codec),
+		ExtraData:        config.ExtraData,
+		SamplesPerSecond: proto.Int32(config.SamplesPerSecond),
+	}
+}
+
+func audioDecoderConfigFromWire(config *wire.AudioDecoderConfig) *AudioDecoderConfig {
+	if config == nil {
+		return nil
+	}
+
+	return &AudioDecoderConfig{
+		ChannelLayout:    config.GetChannelLayout(),
+		Codec:            config.GetCodec(),
+		ExtraData:        config.GetExtraData(),
+		SamplesPerSecond: config.GetSamplesPerSecond(),
+	}
+}
+
+func (config *VideoDecoderConfig) toWire() *wire.VideoDecoderConfig {
+	if config == nil {
+		return nil
+	}
+
+	return &wire.VideoDecoderConfig{
+		Codec:       proto.Int32(config.Codec),
+		CodedSize:   config.CodedSize.toWire(),
+		ExtraData:   config.ExtraData,
+		NaturalSize: config.NaturalSize.toWire(),
+		Profile:     proto.Int32(config.Profile),
+	}
+}
+
+func videoDecoderConfigFromWire(config *wire.VideoDecoderConfig) *VideoDecoderConfig {
+	if config == nil {
+		return nil
+	}
+
+	return &VideoDecoderConfig{
+		Codec:       config.GetCodec(),
+		CodedSize:   sizeFromWire(config.GetCodedSize()),
+		ExtraData:   config.GetExtraData(),
+		NaturalSize: sizeFromWire(config.GetNaturalSize()),
+		Profile:     config.GetProfile(),
+	}
+}
+
+func (size Size) toWire() *wire.Size {
+	return &wire.Size{
+		Height: proto.Int32(size.Height),
+		Width:  proto.Int32(size.Width),
+	}
+}
+
+func sizeFromWire(size *wire.Size) Size {
+	return Size{
+		Height: size.GetHeight(),
+		Width:  size.GetWidth(),
+	}
+}
@@ -0,0 +1,54 @@
+package remoting
+
+import (
+	"reflect"
+	"testing"
+
+	// third-party
+	"google.golang.org/protobuf/proto"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/remoting/wire"
+)
+
+func TestVideoDecoderConfigRoundTrip(t *testing.T) {
+	config := VideoDecoderConfig{
+		Codec:       VideoCodecVP8,
+		CodedSize:   Size{Height: 368, Width: 640},
+		ExtraData:   []byte{1, 2, 3},
+		NaturalSize: Size{Height: 360, Width: 640},
+		Profile:     11,
+	}
+
+	data, err := proto.Marshal(config.toWire())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var encoded wire.VideoDecoderConfig
+	if err := proto.Unmarshal(data, &encoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded := videoDecoderConfigFromWire(&encoded); !reflect.DeepEqual(*decoded, config) {
+		t.Fatalf("decoded %+v, want %+v", *decoded, config)
+	}
+}
+
+func TestAudioDecoderConfigRoundTrip(t *testing.T) {
+	config := AudioDecoderConfig{
+		ChannelLayout:    3,
+		Codec:            AudioCodecOpus,
+		SamplesPerSecond: 48000,
+	}
+
+	data, err := proto.Marshal(config.toWire())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var encoded wire.AudioDecoderConfig
+	if err := proto.Unmarshal(data, &encoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded := audioDecoderConfigFromWire(&encoded); !reflect.DeepEqual(*decoded, config) {
+		t.Fatalf("decoded %+v, want %+v", *decoded, config)
+	}
+}
@@ -0,0 +1,365 @@
+// Package remoting implements the messages of Cast media remoting. Rather
+// than capturing and re-encoding a tab, a remoting sender forwards the demuxed
+// audio and video of a media element to the receiver, which plays them with
+// its own renderer. The sender and receiver control playback by exchanging
+// RPC messages on the remoting namespace, while the media itself is sent over
+// Cast Streaming, one serialized decoder buffer per frame.
+package remoting
+
+import (
+	"fmt"
+
+	// third-party
+	"google.golang.org/protobuf/proto"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/remoting/wire"
+)
+
+// Proc identifies the procedure that an RPC message invokes.
+type Proc int32
+
+const (
+	ProcAcquireRenderer     Proc = 1
+	ProcAcquireRendererDone Proc = 2
+
+	ProcRendererInitialize       Proc = 1000
+	ProcRendererFlushUntil       Proc = 1001
+	ProcRendererStartPlayingFrom Proc = 1002
+	ProcRendererSetPlaybackRate  Proc = 1003
+	ProcRendererSetVolume        Proc = 1004
+
+	ProcRendererInitializeCallback Proc = 1100
+	ProcRendererFlushUntilCallback Proc = 1101
+
+	ProcRendererClientOnTimeUpdate           Proc = 2000
+	ProcRendererClientOnBufferingStateChange Proc = 2001
+	ProcRendererClientOnEnded                Proc = 2002
+	ProcRendererClientOnError                Proc = 2003
+
+	ProcDemuxerStreamInitialize         Proc = 3000
+	ProcDemuxerStreamReadUntil          Proc = 3001
+	ProcDemuxerStreamOnError            Proc = 3003
+	ProcDemuxerStreamInitializeCallback Proc = 3100
+	ProcDemuxerStreamReadUntilCallback  Proc = 3101
+)
+
+var procNames = map[Proc]string{
+	ProcAcquireRenderer:                      "ACQUIRE_RENDERER",
+	ProcAcquireRendererDone:                  "ACQUIRE_RENDERER_DONE",
+	ProcRendererInitialize:                   "R_INITIALIZE",
+	ProcRendererFlushUntil:                   "R_FLUSHUNTIL",
+	ProcRendererStartPlayingFrom:             "R_STARTPLAYINGFROM",
+	ProcRendererSetPlaybackRate:              "R_SETPLAYBACKRATE",
+	ProcRendererSetVolume:                    "R_SETVOLUME",
+	ProcRendererInitializeCallback:           "R_INITIALIZE_CALLBACK",
+	ProcRendererFlushUntilCallback:           "R_FLUSHUNTIL_CALLBACK",
+	ProcRendererClientOnTimeUpdate:           "RC_ONTIMEUPDATE",
+	ProcRendererClientOnBufferingStateChange: "RC_ONBUFFERINGSTATECHANGE",
+	ProcRendererClientOnEnded:                "RC_ONENDED",
+	ProcRendererClientOnError:                "RC_ONERROR",
+	ProcDemuxerStreamInitialize:              "DS_INITIALIZE",
+	ProcDemuxerStreamReadUntil:               "DS_READUNTIL",
+	ProcDemuxerStreamOnError:                 "DS_ONERROR",
+	ProcDemuxerStreamInitializeCallback:      "DS_INITIALIZE_CALLBACK",
+	ProcDemuxerStreamReadUntilCallback:       "DS_READUNTIL_CALLBACK",
+}
+
+func (proc Proc) String() string {
+	if name, ok := procNames[proc]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("PROC_%d", int32(proc))
+}
+
+// Messages are addressed to handles, which each side allocates for the
+// objects that it exposes. A few handles are reserved for bootstrapping.
+const (
+	InvalidHandle         int32 = -1
+	AcquireRendererHandle int32 = 0
+	AcquireDemuxerHandle  int32 = 1
+	FirstHandle           int32 = 100
+)
+
+// Demuxer stream types, as reported by DS_INITIALIZE_CALLBACK.
+const (
+	StreamTypeAudio int32 = 1
+	StreamTypeVideo int32 = 2
+)
+
+// Demuxer read statuses, as reported by DS_READUNTIL_CALLBACK.
+const (
+	ReadStatusOK            int32 = 0
+	ReadStatusAborted       int32 = 1
+	ReadStatusConfigChanged int32 = 2
+	ReadStatusError         int32 = 3
+)
+
+// Buffering states, as reported by RC_ONBUFFERINGSTATECHANGE.
+const (
+	BufferingHaveNothing int32 = 0
+	BufferingHaveEnough  int32 = 1
+)
+
+// Message is an RPC message. Simple procedures carry a single scalar value,
+// while others carry one of the nested messages.
+type Message struct {
+	Handle int32
+	Proc   Proc
+
+	Boolean   bool
+	Double    float64
+	Integer   int32
+	Integer64 int64
+
+	BufferingState     *BufferingState
+	FlushUntil         *FlushUntil
+	Initialize         *RendererInitialize
+	InitializeCallback *InitializeCallback
+	ReadUntil          *ReadUntil
+	ReadUntilCallback  *ReadUntilCallback
+	TimeUpdate         *TimeUpdate
+}
+
+// RendererInitialize gives the receiver's renderer the handles of the
+// sender's renderer client and demuxer streams. Streams that are not present
+// have InvalidHandle.
+type RendererInitialize struct {
+	AudioDemuxerHandle int32
+	CallbackHandle     int32
+	ClientHandle       int32
+	VideoDemuxerHandle int32
+}
+
+// FlushUntil discards buffered frames, up to the given number of frames read
+// from each stream.
+type FlushUntil struct {
+	AudioCount     uint32
+	CallbackHandle int32
+	VideoCount     uint32
+}
+
+// TimeUpdate reports the renderer's media time.
+type TimeUpdate struct {
+	MaxTimeUsec int64
+	TimeUsec    int64
+}
+
+// BufferingState reports whether the renderer has enough data to play.
+type BufferingState struct {
+	State int32
+}
+
+// ReadUntil asks a demuxer stream to send frames until Count frames have been
+// read from it in total.
+type ReadUntil struct {
+	CallbackHandle int32
+	Count          uint32
+}
+
+// InitializeCallback describes a demuxer stream.
+type InitializeCallback struct {
+	Audio *AudioDecoderConfig
+	Type  int32
+	Video *VideoDecoderConfig
+}
+
+// ReadUntilCallback reports how many frames have been read from a demuxer
+// stream. A new decoder config accompanies ReadStatusConfigChanged.
+type ReadUntilCallback struct {
+	Audio  *AudioDecoderConfig
+	Count  uint32
+	Status int32
+	Video  *VideoDecoderConfig
+}
+
+// Marshal encodes the message.
+func (message *Message) Marshal() []byte {
+	// messages have no required fields, so encoding them cannot fail
+	data, _ := proto.Marshal(message.toWire())
+	return data
+}
+
+// Unmarshal decodes a message. Nested messages for procedures that the
+// receiver does not implement are skipped.
+func (message *Message) Unmarshal(data []byte) error {
+	var rpc wire.RpcMessage
+	if err := proto.Unmarshal(data, &rpc); err != nil {
+		return fmt.Errorf("parse rpc message: %w", err)
+	}
+
+	*message = Message{
+		Handle: rpc.GetHandle(),
+		Proc:   Proc(rpc.GetProc()),
+
+		Boolean:   rpc.GetBooleanValue(),
+		Double:    rpc.GetDoubleValue(),
+		Integer:   rpc.GetIntegerValue(),
+		Integer64: rpc.GetInteger64Value(),
+	}
+
+	switch value := rpc.RpcOneof.(type) {
+	case *wire.RpcMessage_RendererInitializeRpc:
+		message.Initialize = rendererInitializeFromWire(value.RendererInitializeRpc)
+	case *wire.RpcMessage_RendererFlushuntilRpc:
+		message.FlushUntil = flushUntilFromWire(value.RendererFlushuntilRpc)
+	case *wire.RpcMessage_RendererclientOntimeupdateRpc:
+		message.TimeUpdate = timeUpdateFromWire(value.RendererclientOntimeupdateRpc)
+	case *wire.RpcMessage_RendererclientOnbufferingstatechangeRpc:
+		message.BufferingState = bufferingStateFromWire(value.RendererclientOnbufferingstatechangeRpc)
+	case *wire.RpcMessage_DemuxerstreamReaduntilRpc:
+		message.ReadUntil = readUntilFromWire(value.DemuxerstreamReaduntilRpc)
+	case *wire.RpcMessage_DemuxerstreamInitializecbRpc:
+		message.InitializeCallback = initializeCallbackFromWire(value.DemuxerstreamInitializecbRpc)
+	case *wire.RpcMessage_DemuxerstreamReaduntilcbRpc:
+		message.ReadUntilCallback = readUntilCallbackFromWire(value.DemuxerstreamReaduntilcbRpc)
+	}
+
+	return nil
+}
+
+// toWire converts the message to its protobuf. A message carries at most one
+// value, so only the first value that is set is kept. Zero scalar values are
+// the same as no value.
+func (message *Message) toWire() *wire.RpcMessage {
+	rpc := &wire.RpcMessage{
+		Handle: proto.Int32(message.Handle),
+		Proc:   proto.Int32(int32(message.Proc)),
+	}
+
+	switch {
+	case message.Integer != 0:
+		rpc.RpcOneof = &wire.RpcMessage_IntegerValue{IntegerValue: message.Integer}
+	case message.Integer64 != 0:
+		rpc.RpcOneof = &wire.RpcMessage_Integer64Value{Integer64Value: message.Integer64}
+	case message.Boolean:
+		rpc.RpcOneof = &wire.RpcMessage_BooleanValue{BooleanValue: message.Boolean}
+	case message.Double != 0:
+		rpc.RpcOneof = &wire.RpcMessage_DoubleValue{DoubleValue: message.Double}
+	case message.Initialize != nil:
+		rpc.RpcOneof = &wire.RpcMessage_RendererInitializeRpc{RendererInitializeRpc: message.Initialize.toWire()}
+	case message.FlushUntil != nil:
+		rpc.RpcOneof = &wire.RpcMessage_RendererFlushuntilRpc{RendererFlushuntilRpc: message.FlushUntil.toWire()}
+	case message.TimeUpdate != nil:
+		rpc.RpcOneof = &wire.RpcMessage_RendererclientOntimeupdateRpc{RendererclientOntimeupdateRpc: message.TimeUpdate.toWire()}
+	case message.BufferingState != nil:
+		rpc.RpcOneof = &wire.RpcMessage_RendererclientOnbufferingstatechangeRpc{RendererclientOnbufferingstatechangeRpc: message.BufferingState.toWire()}
+	case message.ReadUntil != nil:
+		rpc.RpcOneof = &wire.RpcMessage_DemuxerstreamReaduntilRpc{DemuxerstreamReaduntilRpc: message.ReadUntil.toWire()}
+	case message.InitializeCallback != nil:
+		rpc.RpcOneof = &wire.RpcMessage_DemuxerstreamInitializecbRpc{DemuxerstreamInitializecbRpc: message.InitializeCallback.toWire()}
+	case message.ReadUntilCallback != nil:
+		rpc.RpcOneof = &wire.RpcMessage_DemuxerstreamReaduntilcbRpc{DemuxerstreamReaduntilcbRpc: message.ReadUntilCallback.toWire()}
+	}
+
+	return rpc
+}
+
+func (initialize *RendererInitialize) toWire() *wire.RendererInitialize {
+	return &wire.RendererInitialize{
+		AudioDemuxerHandle: proto.Int32(initialize.AudioDemuxerHandle),
+		CallbackHandle:     proto.Int32(initialize.CallbackHandle),
+		ClientHandle:       proto.Int32(initialize.ClientHandle),
+		VideoDemuxerHandle: proto.Int32(initialize.VideoDemuxerHandle),
+	}
+}
+
+func rendererInitializeFromWire(initialize *wire.RendererInitialize) *RendererInitialize {
+	return &RendererInitialize{
+		AudioDemuxerHandle: initialize.GetAudioDemuxerHandle(),
+		CallbackHandle:     initialize.GetCallbackHandle(),
+		ClientHandle:       initialize.GetClientHandle(),
+		VideoDemuxerHandle: initialize.GetVideoDemuxerHandle(),
+	}
+}
+
+func (flush *FlushUntil) toWire() *wire.RendererFlushUntil {
+	return &wire.RendererFlushUntil{
+		AudioCount:     proto.Uint32(flush.AudioCount),
+		CallbackHandle: proto.Int32(flush.CallbackHandle),
+		VideoCount:     proto.Uint32(flush.VideoCount),
+	}
+}
+
+func flushUntilFromWire(flush *wire.RendererFlushUntil) *FlushUntil {
+	return &FlushUntil{
+		AudioCount:     flush.GetAudioCount(),
+		CallbackHandle: flush.GetCallbackHandle(),
+		VideoCount:     flush.GetVideoCount(),
+	}
+}
+
+func (update *TimeUpdate) toWire() *wire.RendererClientOnTimeUpdate {
+	return &wire.RendererClientOnTimeUpdate{
+		MaxTimeUsec: proto.Int64(update.MaxTimeUsec),
+		TimeUsec:    proto.Int64(update.TimeUsec),
+	}
+}
+
+func timeUpdateFromWire(update *wire.RendererClientOnTimeUpdate) *TimeUpdate {
+	return &TimeUpdate{
+		MaxTimeUsec: update.GetMaxTimeUsec(),
+		TimeUsec:    update.GetTimeUsec(),
+	}
+}
+
+func (state *BufferingState) toWire() *wire.RendererClientOnBufferingStateChange {
+	return &wire.RendererClientOnBufferingStateChange{
+		State: proto.Int32(state.State),
+	}
+}
+
+func bufferingStateFromWire(state *wire.RendererClientOnBufferingStateChange) *BufferingState {
+	return &BufferingState{
+		State: state.GetState(),
+	}
+}
+
+func (read *ReadUntil) toWire() *wire.DemuxerStreamReadUntil {
+	return &wire.DemuxerStreamReadUntil{
+		CallbackHandle: proto.Int32(read.CallbackHandle),
+		Count:          proto.Uint32(read.Count),
+	}
+}
+
+func readUntilFromWire(read *wire.DemuxerStreamReadUntil) *ReadUntil {
+	return &ReadUntil{
+		CallbackHandle: read.GetCallbackHandle(),
+		Count:          read.GetCount(),
+	}
+}
+
+func (callback *InitializeCallback) toWire() *wire.DemuxerStreamInitializeCallback {
+	return &wire.DemuxerStreamInitializeCallback{
+		AudioDecoderConfig: callback.Audio.toWire(),
+		Type:               proto.Int32(callback.Type),
+		VideoDecoderConfig: callback.Video.toWire(),
+	}
+}
+
+func initializeCallbackFromWire(callback *wire.DemuxerStreamInitializeCallback) *InitializeCallback {
+	return &InitializeCallback{
+		Audio: audioDecoderConfigFromWire(callback.GetAudioDecoderConfig()),
+		Type:  callback.GetType(),
+		Video: videoDecoderConfigFromWire(callback.GetVideoDecoderConfig()),
+	}
+}
+
+func (callback *ReadUntilCallback) toWire() *wire.DemuxerStreamReadUntilCallback {
+	return &wire.DemuxerStreamReadUntilCallback{
+		AudioDecoderConfig: callback.Audio.toWire(),
+		Count:              proto.Uint32(callback.Count),
+		Status:             proto.Int32(callback.Status),
+		VideoDecoderConfig: callback.Video.toWire(),
+	}
+}
+
+func readUntilCallbackFromWire(callback *wire.DemuxerStreamReadUntilCallback) *ReadUntilCallback {
+	return &ReadUntilCallback{
+		Audio:  audioDecoderConfigFromWire(callback.GetAudioDecoderConfig()),
+		Count:  callback.GetCount(),
+		Status: callback.GetStatus(),
+		Video:  videoDecoderConfigFromWire(callback.GetVideoDecoderConfig()),
+	}
+}
@@ -0,0 +1,106 @@
+package remoting
+
+import (
+	"reflect"
+	"testing"
+
+	// third-party
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestMessageRoundTrip(t *testing.T) {
+	messages := []Message{
+		{Handle: AcquireRendererHandle, Proc: ProcAcquireRenderer, Integer: 7},
+		{Handle: 7, Proc: ProcAcquireRendererDone, Integer: FirstHandle},
+		{Handle: FirstHandle, Proc: ProcRendererInitialize, Initialize: &RendererInitialize{
+			AudioDemuxerHandle: InvalidHandle,
+			CallbackHandle:     9,
+			ClientHandle:       8,
+			VideoDemuxerHandle: 10,
+		}},
+		{Handle: FirstHandle, Proc: ProcRendererStartPlayingFrom, Integer64: 1500000},
+		{Handle: FirstHandle, Proc: ProcRendererSetPlaybackRate, Double: 1.5},
+		{Handle: 9, Proc: ProcRendererInitializeCallback, Boolean: true},
+		{Handle: FirstHandle, Proc: ProcRendererFlushUntil, FlushUntil: &FlushUntil{AudioCount: 3, CallbackHandle: 11, VideoCount: 4}},
+		{Handle: 8, Proc: ProcRendererClientOnTimeUpdate, TimeUpdate: &TimeUpdate{MaxTimeUsec: 2000, TimeUsec: 1000}},
+		{Handle: 8, Proc: ProcRendererClientOnBufferingStateChange, BufferingState: &BufferingState{State: BufferingHaveEnough}},
+		{Handle: 10, Proc: ProcDemuxerStreamReadUntil, ReadUntil: &ReadUntil{CallbackHandle: 101, Count: 30}},
+		{Handle: 101, Proc: ProcDemuxerStreamInitializeCallback, InitializeCallback: &InitializeCallback{
+			Type:  StreamTypeVideo,
+			Video: &VideoDecoderConfig{Codec: VideoCodecVP8, CodedSize: Size{Height: 720, Width: 1280}},
+		}},
+		{Handle: 101, Proc: ProcDemuxerStreamReadUntilCallback, ReadUntilCallback: &ReadUntilCallback{
+			Audio:  &AudioDecoderConfig{Codec: AudioCodecOpus, SamplesPerSecond: 48000},
+			Count:  30,
+			Status: ReadStatusConfigChanged,
+		}},
+	}
+
+	for _, message := range messages {
+		var decoded Message
+		if err := decoded.Unmarshal(message.Marshal()); err != nil {
+			t.Fatalf("%v: %v", message.Proc, err)
+		}
+		if !reflect.DeepEqual(decoded, message) {
+			t.Fatalf("%v: decoded %+v, want %+v", message.Proc, decoded, message)
+		}
+	}
+}
+
+func TestMessageUnmarshalSkipsUnknownFields(t *testing.T) {
+	handle := int64(InvalidHandle)
+	data := protowire.AppendTag(nil, 1, protowire.VarintType)
+	data = protowire.AppendVarint(data, uint64(handle))
+	data = protowire.AppendTag(data, 2, protowire.VarintType)
+	data = protowire.AppendVarint(data, uint64(ProcRendererSetVolume))
+	data = protowire.AppendTag(data, 7, protowire.BytesType)
+	data = protowire.AppendString(data, "ignored")
+	data = protowire.AppendTag(data, 999, protowire.BytesType)
+	data = protowire.AppendBytes(data, []byte{0x08, 0x01})
+
+	var message Message
+	if err := message.Unmarshal(data); err != nil {
+		t.Fatal(err)
+	}
+	if message.Handle != InvalidHandle || message.Proc != ProcRendererSetVolume {
+		t.Fatalf("unexpected message %+v", message)
+	}
+}
+
+func TestMessageUnmarshalMalformed(t *testing.T) {
+	read := &ReadUntil{Count: 3}
+	complete := (&Message{Handle: 5, Proc: ProcDemuxerStreamReadUntil, ReadUntil: read}).Marshal()
+
+	// nested message that claims to be longer than it is. It is encoded last,
+	// so its length comes just before its contents.
+	inner, err := proto.Marshal(read.toWire())
+	if err != nil {
+		t.Fatal(err)
+	}
+	nested := append([]byte(nil), complete...)
+	nested[len(nested)-len(inner)-1]++
+
+	inputs := [][]byte{
+		{0x08},             // missing varint
+		{0x08, 0x80},       // truncated varint
+		{0x31, 0x00, 0x00}, // truncated double
+		complete[:len(complete)-1],
+		nested,
+	}
+	for _, input := range inputs {
+		var message Message
+		if err := message.Unmarshal(input); err == nil {
+			t.Fatalf("%x: expected error", input)
+		}
+	}
+}
+
+func TestProcString(t *testing.T) {
+	if ProcRendererInitialize.String() != "R_INITIALIZE" {
+		t.Fatalf("unexpected name %q", ProcRendererInitialize.String())
+	}
+	if Proc(42).String() != "PROC_42" {
+		t.Fatalf("unexpected name %q", Proc(42).String())
+	}
+}
@@ -0,0 +1,1393 @@
+// Copyright 2020 The Chromium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// The subset of the Open Screen library's remoting.proto that the receiver
+// uses. Messages, field names and field numbers are unchanged. Enum fields are
+// declared as int32, since proto2 enums are closed, and values that a newer
+// sender adds would otherwise be dropped.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.30.0
+// 	protoc        (unknown)
+// source: internal/remoting/wire/remoting.proto
+
+package wire
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// DecoderBuffer is the header of one encoded frame that is sent over Cast
+// Streaming.
+type DecoderBuffer struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	TimestampUsec *int64 `protobuf:"varint,1,opt,name=timestamp_usec,json=timestampUsec" json:"timestamp_usec,omitempty"`
+	DurationUsec  *int64 `protobuf:"varint,2,opt,name=duration_usec,json=durationUsec" json:"duration_usec,omitempty"`
+	IsKeyFrame    *bool  `protobuf:"varint,3,opt,name=is_key_frame,json=isKeyFrame" json:"is_key_frame,omitempty"`
+	IsEos         *bool  `protobuf:"varint,9,opt,name=is_eos,json=isEos" json:"is_eos,omitempty"`
+}
+
+func (x *DecoderBuffer) Reset() {
+	*x = DecoderBuffer{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_internal_remoting_wire_remoting_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DecoderBuffer) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DecoderBuffer) ProtoMessage() {}
+
+func (x *DecoderBuffer) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_remoting_wire_remoting_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DecoderBuffer.ProtoReflect.Descriptor instead.
+func (*DecoderBuffer) Descriptor() ([]byte, []int) {
+	return file_internal_remoting_wire_remoting_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *DecoderBuffer) GetTimestampUsec() int64 {
+	if x != nil && x.TimestampUsec != nil {
+		return *x.TimestampUsec
+	}
+	return 0
+}
+
+func (x *DecoderBuffer) GetDurationUsec() int64 {
+	if x != nil && x.DurationUsec != nil {
+		return *x.DurationUsec
+	}
+	return 0
+}
+
+func (x *DecoderBuffer) GetIsKeyFrame() bool {
+	if x != nil && x.IsKeyFrame != nil {
+		return *x.IsKeyFrame
+	}
+	return false
+}
+
+func (x *DecoderBuffer) GetIsEos() bool {
+	if x != nil && x.IsEos != nil {
+		return *x.IsEos
+	}
+	return false
+}
+
+type Size struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Width  *int32 `protobuf:"varint,1,opt,name=width" json:"width,omitempty"`
+	Height *int32 `protobuf:"varint,2,opt,name=height" json:"height,omitempty"`
+}
+
+func (x *Size) Reset() {
+	*x = Size{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_internal_remoting_wire_remoting_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Size) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Size) ProtoMessage() {}
+
+func (x *Size) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_remoting_wire_remoting_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Size.ProtoReflect.Descriptor instead.
+func (*Size) Descriptor() ([]byte, []int) {
+	return file_internal_remoting_wire_remoting_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Size) GetWidth() int32 {
+	if x != nil && x.Width != nil {
+		return *x.Width
+	}
+	return 0
+}
+
+func (x *Size) GetHeight() int32 {
+	if x != nil && x.Height != nil {
+		return *x.Height
+	}
+	return 0
+}
+
+type AudioDecoderConfig struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// AudioDecoderConfig.Codec
+	Codec *int32 `protobuf:"varint,1,opt,name=codec" json:"codec,omitempty"`
+	// AudioDecoderConfig.ChannelLayout
+	ChannelLayout    *int32 `protobuf:"varint,4,opt,name=channel_layout,json=channelLayout" json:"channel_layout,omitempty"`
+	SamplesPerSecond *int32 `protobuf:"varint,5,opt,name=samples_per_second,json=samplesPerSecond" json:"samples_per_second,omitempty"`
+	ExtraData        []byte `protobuf:"bytes,8,opt,name=extra_data,json=extraData" json:"extra_data,omitempty"`
+}
+
+func (x *AudioDecoderConfig) Reset() {
+	*x = AudioDecoderConfig{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_internal_remoting_wire_remoting_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AudioDecoderConfig) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AudioDecoderConfig) ProtoMessage() {}
+
+func (x *AudioDecoderConfig) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_remoting_wire_remoting_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AudioDecoderConfig.ProtoReflect.Descriptor instead.
+func (*AudioDecoderConfig) Descriptor() ([]byte, []int) {
+	return file_internal_remoting_wire_remoting_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *AudioDecoderConfig) GetCodec() int32 {
+	if x != nil && x.Codec != nil {
+		return *x.Codec
+	}
+	return 0
+}
+
+func (x *AudioDecoderConfig) GetChannelLayout() int32 {
+	if x != nil && x.ChannelLayout != nil {
+		return *x.ChannelLayout
+	}
+	return 0
+}
+
+func (x *AudioDecoderConfig) GetSamplesPerSecond() int32 {
+	if x != nil && x.SamplesPerSecond != nil {
+		return *x.SamplesPerSecond
+	}
+	return 0
+}
+
+func (x *AudioDecoderConfig) GetExtraData() []byte {
+	if x != nil {
+		return x.ExtraData
+	}
+	return nil
+}
+
+type VideoDecoderConfig struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// VideoDecoderConfig.Codec
+	Codec *int32 `protobuf:"varint,1,opt,name=codec" json:"codec,omitempty"`
+	// VideoDecoderConfig.Profile
+	Profile     *int32 `protobuf:"varint,2,opt,name=profile" json:"profile,omitempty"`
+	CodedSize   *Size  `protobuf:"bytes,5,opt,name=coded_size,json=codedSize" json:"coded_size,omitempty"`
+	NaturalSize *Size  `protobuf:"bytes,7,opt,name=natural_size,json=naturalSize" json:"natural_size,omitempty"`
+	ExtraData   []byte `protobuf:"bytes,8,opt,name=extra_data,json=extraData" json:"extra_data,omitempty"`
+}
+
+func (x *VideoDecoderConfig) Reset() {
+	*x = VideoDecoderConfig{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_internal_remoting_wire_remoting_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *VideoDecoderConfig) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VideoDecoderConfig) ProtoMessage() {}
+
+func (x *VideoDecoderConfig) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_remoting_wire_remoting_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VideoDecoderConfig.ProtoReflect.Descriptor instead.
+func (*VideoDecoderConfig) Descriptor() ([]byte, []int) {
+	return file_internal_remoting_wire_remoting_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *VideoDecoderConfig) GetCodec() int32 {
+	if x != nil && x.Codec != nil {
+		return *x.Codec
+	}
+	return 0
+}
+
+func (x *VideoDecoderConfig) GetProfile() int32 {
+	if x != nil && x.Profile != nil {
+		return *x.Profile
+	}
+	return 0
+}
+
+func (x *VideoDecoderConfig) GetCodedSize() *Size {
+	if x != nil {
+		return x.CodedSize
+	}
+	return nil
+}
+
+func (x *VideoDecoderConfig) GetNaturalSize() *Size {
+	if x != nil {
+		return x.NaturalSize
+	}
+	return nil
+}
+
+func (x *VideoDecoderConfig) GetExtraData() []byte {
+	if x != nil {
+		return x.ExtraData
+	}
+	return nil
+}
+
+type RendererInitialize struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ClientHandle       *int32 `protobuf:"varint,1,opt,name=client_handle,json=clientHandle" json:"client_handle,omitempty"`
+	AudioDemuxerHandle *int32 `protobuf:"varint,2,opt,name=audio_demuxer_handle,json=audioDemuxerHandle" json:"audio_demuxer_handle,omitempty"`
+	VideoDemuxerHandle *int32 `protobuf:"varint,3,opt,name=video_demuxer_handle,json=videoDemuxerHandle" json:"video_demuxer_handle,omitempty"`
+	CallbackHandle     *int32 `protobuf:"varint,4,opt,name=callback_handle,json=callbackHandle" json:"callback_handle,omitempty"`
+}
+
+func (x *RendererInitialize) Reset() {
+	*x = RendererInitialize{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_internal_remoting_wire_remoting_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RendererInitialize) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RendererInitialize) ProtoMessage() {}
+
+func (x *RendererInitialize) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_remoting_wire_remoting_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RendererInitialize.ProtoReflect.Descriptor instead.
+func (*RendererInitialize) Descriptor() ([]byte, []int) {
+	return file_internal_remoting_wire_remoting_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *RendererInitialize) GetClientHandle() int32 {
+	if x != nil && x.ClientHandle != nil {
+		return *x.ClientHandle
+	}
+	return 0
+}
+
+func (x *RendererInitialize) GetAudioDemuxerHandle() int32 {
+	if x != nil && x.AudioDemuxerHandle != nil {
+		return *x.AudioDemuxerHandle
+	}
+	return 0
+}
+
+func (x *RendererInitialize) GetVideoDemuxerHandle() int32 {
+	if x != nil && x.VideoDemuxerHandle != nil {
+		return *x.VideoDemuxerHandle
+	}
+	return 0
+}
+
+func (x *RendererInitialize) GetCallbackHandle() int32 {
+	if x != nil && x.CallbackHandle != nil {
+		return *x.CallbackHandle
+	}
+	return 0
+}
+
+type RendererFlushUntil struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	AudioCount     *uint32 `protobuf:"varint,1,opt,name=audio_count,json=audioCount" json:"audio_count,omitempty"`
+	VideoCount     *uint32 `protobuf:"varint,2,opt,name=video_count,json=videoCount" json:"video_count,omitempty"`
+	CallbackHandle *int32  `protobuf:"varint,3,opt,name=callback_handle,json=callbackHandle" json:"callback_handle,omitempty"`
+}
+
+func (x *RendererFlushUntil) Reset() {
+	*x = RendererFlushUntil{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_internal_remoting_wire_remoting_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RendererFlushUntil) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RendererFlushUntil) ProtoMessage() {}
+
+func (x *RendererFlushUntil) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_remoting_wire_remoting_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RendererFlushUntil.ProtoReflect.Descriptor instead.
+func (*RendererFlushUntil) Descriptor() ([]byte, []int) {
+	return file_internal_remoting_wire_remoting_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *RendererFlushUntil) GetAudioCount() uint32 {
+	if x != nil && x.AudioCount != nil {
+		return *x.AudioCount
+	}
+	return 0
+}
+
+func (x *RendererFlushUntil) GetVideoCount() uint32 {
+	if x != nil && x.VideoCount != nil {
+		return *x.VideoCount
+	}
+	return 0
+}
+
+func (x *RendererFlushUntil) GetCallbackHandle() int32 {
+	if x != nil && x.CallbackHandle != nil {
+		return *x.CallbackHandle
+	}
+	return 0
+}
+
+type RendererClientOnTimeUpdate struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	TimeUsec    *int64 `protobuf:"varint,1,opt,name=time_usec,json=timeUsec" json:"time_usec,omitempty"`
+	MaxTimeUsec *int64 `protobuf:"varint,2,opt,name=max_time_usec,json=maxTimeUsec" json:"max_time_usec,omitempty"`
+}
+
+func (x *RendererClientOnTimeUpdate) Reset() {
+	*x = RendererClientOnTimeUpdate{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_internal_remoting_wire_remoting_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RendererClientOnTimeUpdate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RendererClientOnTimeUpdate) ProtoMessage() {}
+
+func (x *RendererClientOnTimeUpdate) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_remoting_wire_remoting_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RendererClientOnTimeUpdate.ProtoReflect.Descriptor instead.
+func (*RendererClientOnTimeUpdate) Descriptor() ([]byte, []int) {
+	return file_internal_remoting_wire_remoting_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *RendererClientOnTimeUpdate) GetTimeUsec() int64 {
+	if x != nil && x.TimeUsec != nil {
+		return *x.TimeUsec
+	}
+	return 0
+}
+
+func (x *RendererClientOnTimeUpdate) GetMaxTimeUsec() int64 {
+	if x != nil && x.MaxTimeUsec != nil {
+		return *x.MaxTimeUsec
+	}
+	return 0
+}
+
+type RendererClientOnBufferingStateChange struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// RendererClientOnBufferingStateChange.State
+	State *int32 `protobuf:"varint,1,opt,name=state" json:"state,omitempty"`
+}
+
+func (x *RendererClientOnBufferingStateChange) Reset() {
+	*x = RendererClientOnBufferingStateChange{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_internal_remoting_wire_remoting_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RendererClientOnBufferingStateChange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RendererClientOnBufferingStateChange) ProtoMessage() {}
+
+func (x *RendererClientOnBufferingStateChange) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_remoting_wire_remoting_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RendererClientOnBufferingStateChange.ProtoReflect.Descriptor instead.
+func (*RendererClientOnBufferingStateChange) Descriptor() ([]byte, []int) {
+	return file_internal_remoting_wire_remoting_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *RendererClientOnBufferingStateChange) GetState() int32 {
+	if x != nil && x.State != nil {
+		return *x.State
+	}
+	return 0
+}
+
+type DemuxerStreamReadUntil struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	CallbackHandle *int32  `protobuf:"varint,1,opt,name=callback_handle,json=callbackHandle" json:"callback_handle,omitempty"`
+	Count          *uint32 `protobuf:"varint,2,opt,name=count" json:"count,omitempty"`
+}
+
+func (x *DemuxerStreamReadUntil) Reset() {
+	*x = DemuxerStreamReadUntil{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_internal_remoting_wire_remoting_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DemuxerStreamReadUntil) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DemuxerStreamReadUntil) ProtoMessage() {}
+
+func (x *DemuxerStreamReadUntil) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_remoting_wire_remoting_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DemuxerStreamReadUntil.ProtoReflect.Descriptor instead.
+func (*DemuxerStreamReadUntil) Descriptor() ([]byte, []int) {
+	return file_internal_remoting_wire_remoting_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *DemuxerStreamReadUntil) GetCallbackHandle() int32 {
+	if x != nil && x.CallbackHandle != nil {
+		return *x.CallbackHandle
+	}
+	return 0
+}
+
+func (x *DemuxerStreamReadUntil) GetCount() uint32 {
+	if x != nil && x.Count != nil {
+		return *x.Count
+	}
+	return 0
+}
+
+type DemuxerStreamInitializeCallback struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Type               *int32              `protobuf:"varint,1,opt,name=type" json:"type,omitempty"`
+	AudioDecoderConfig *AudioDecoderConfig `protobuf:"bytes,2,opt,name=audio_decoder_config,json=audioDecoderConfig" json:"audio_decoder_config,omitempty"`
+	VideoDecoderConfig *VideoDecoderConfig `protobuf:"bytes,3,opt,name=video_decoder_config,json=videoDecoderConfig" json:"video_decoder_config,omitempty"`
+}
+
+func (x *DemuxerStreamInitializeCallback) Reset() {
+	*x = DemuxerStreamInitializeCallback{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_internal_remoting_wire_remoting_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DemuxerStreamInitializeCallback) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DemuxerStreamInitializeCallback) ProtoMessage() {}
+
+func (x *DemuxerStreamInitializeCallback) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_remoting_wire_remoting_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DemuxerStreamInitializeCallback.ProtoReflect.Descriptor instead.
+func (*DemuxerStreamInitializeCallback) Descriptor() ([]byte, []int) {
+	return file_internal_remoting_wire_remoting_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *DemuxerStreamInitializeCallback) GetType() int32 {
+	if x != nil && x.Type != nil {
+		return *x.Type
+	}
+	return 0
+}
+
+func (x *DemuxerStreamInitializeCallback) GetAudioDecoderConfig() *AudioDecoderConfig {
+	if x != nil {
+		return x.AudioDecoderConfig
+	}
+	return nil
+}
+
+func (x *DemuxerStreamInitializeCallback) GetVideoDecoderConfig() *VideoDecoderConfig {
+	if x != nil {
+		return x.VideoDecoderConfig
+	}
+	return nil
+}
+
+type DemuxerStreamReadUntilCallback struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// DemuxerStreamReadUntilCallback.Status
+	Status             *int32              `protobuf:"varint,1,opt,name=status" json:"status,omitempty"`
+	AudioDecoderConfig *AudioDecoderConfig `protobuf:"bytes,2,opt,name=audio_decoder_config,json=audioDecoderConfig" json:"audio_decoder_config,omitempty"`
+	VideoDecoderConfig *VideoDecoderConfig `protobuf:"bytes,3,opt,name=video_decoder_config,json=videoDecoderConfig" json:"video_decoder_config,omitempty"`
+	Count              *uint32             `protobuf:"varint,4,opt,name=count" json:"count,omitempty"`
+}
+
+func (x *DemuxerStreamReadUntilCallback) Reset() {
+	*x = DemuxerStreamReadUntilCallback{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_internal_remoting_wire_remoting_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DemuxerStreamReadUntilCallback) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DemuxerStreamReadUntilCallback) ProtoMessage() {}
+
+func (x *DemuxerStreamReadUntilCallback) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_remoting_wire_remoting_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DemuxerStreamReadUntilCallback.ProtoReflect.Descriptor instead.
+func (*DemuxerStreamReadUntilCallback) Descriptor() ([]byte, []int) {
+	return file_internal_remoting_wire_remoting_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *DemuxerStreamReadUntilCallback) GetStatus() int32 {
+	if x != nil && x.Status != nil {
+		return *x.Status
+	}
+	return 0
+}
+
+func (x *DemuxerStreamReadUntilCallback) GetAudioDecoderConfig() *AudioDecoderConfig {
+	if x != nil {
+		return x.AudioDecoderConfig
+	}
+	return nil
+}
+
+func (x *DemuxerStreamReadUntilCallback) GetVideoDecoderConfig() *VideoDecoderConfig {
+	if x != nil {
+		return x.VideoDecoderConfig
+	}
+	return nil
+}
+
+func (x *DemuxerStreamReadUntilCallback) GetCount() uint32 {
+	if x != nil && x.Count != nil {
+		return *x.Count
+	}
+	return 0
+}
+
+type RpcMessage struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Handle of the object that the message is addressed to.
+	Handle *int32 `protobuf:"varint,1,opt,name=handle" json:"handle,omitempty"`
+	// RpcMessage.RpcProc
+	Proc *int32 `protobuf:"varint,2,opt,name=proc" json:"proc,omitempty"`
+	// Types that are assignable to RpcOneof:
+	//	*RpcMessage_IntegerValue
+	//	*RpcMessage_Integer64Value
+	//	*RpcMessage_BooleanValue
+	//	*RpcMessage_DoubleValue
+	//	*RpcMessage_StringValue
+	//	*RpcMessage_RendererInitializeRpc
+	//	*RpcMessage_RendererFlushuntilRpc
+	//	*RpcMessage_RendererclientOntimeupdateRpc
+	//	*RpcMessage_RendererclientOnbufferingstatechangeRpc
+	//	*RpcMessage_DemuxerstreamReaduntilRpc
+	//	*RpcMessage_DemuxerstreamInitializecbRpc
+	//	*RpcMessage_DemuxerstreamReaduntilcbRpc
+	RpcOneof isRpcMessage_RpcOneof `protobuf_oneof:"rpc_oneof"`
+}
+
+func (x *RpcMessage) Reset() {
+	*x = RpcMessage{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_internal_remoting_wire_remoting_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RpcMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RpcMessage) ProtoMessage() {}
+
+func (x *RpcMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_remoting_wire_remoting_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RpcMessage.ProtoReflect.Descriptor instead.
+func (*RpcMessage) Descriptor() ([]byte, []int) {
+	return file_internal_remoting_wire_remoting_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *RpcMessage) GetHandle() int32 {
+	if x != nil && x.Handle != nil {
+		return *x.Handle
+	}
+	return 0
+}
+
+func (x *RpcMessage) GetProc() int32 {
+	if x != nil && x.Proc != nil {
+		return *x.Proc
+	}
+	return 0
+}
+
+func (m *RpcMessage) GetRpcOneof() isRpcMessage_RpcOneof {
+	if m != nil {
+		return m.RpcOneof
+	}
+	return nil
+}
+
+func (x *RpcMessage) GetIntegerValue() int32 {
+	if x, ok := x.GetRpcOneof().(*RpcMessage_IntegerValue); ok {
+		return x.IntegerValue
+	}
+	return 0
+}
+
+func (x *RpcMessage) GetInteger64Value() int64 {
+	if x, ok := x.GetRpcOneof().(*RpcMessage_Integer64Value); ok {
+		return x.Integer64Value
+	}
+	return 0
+}
+
+func (x *RpcMessage) GetBooleanValue() bool {
+	if x, ok := x.GetRpcOneof().(*RpcMessage_BooleanValue); ok {
+		return x.BooleanValue
+	}
+	return false
+}
+
+func (x *RpcMessage) GetDoubleValue() float64 {
+	if x, ok := x.GetRpcOneof().(*RpcMessage_DoubleValue); ok {
+		return x.DoubleValue
+	}
+	return 0
+}
+
+func (x *RpcMessage) GetStringValue() string {
+	if x, ok := x.GetRpcOneof().(*RpcMessage_StringValue); ok {
+		return x.StringValue
+	}
+	return ""
+}
+
+func (x *RpcMessage) GetRendererInitializeRpc() *RendererInitialize {
+	if x, ok := x.GetRpcOneof().(*RpcMessage_RendererInitializeRpc); ok {
+		return x.RendererInitializeRpc
+	}
+	return nil
+}
+
+func (x *RpcMessage) GetRendererFlushuntilRpc() *RendererFlushUntil {
+	if x, ok := x.GetRpcOneof().(*RpcMessage_RendererFlushuntilRpc); ok {
+		return x.RendererFlushuntilRpc
+	}
+	return nil
+}
+
+func (x *RpcMessage) GetRendererclientOntimeupdateRpc() *RendererClientOnTimeUpdate {
+	if x, ok := x.GetRpcOneof().(*RpcMessage_RendererclientOntimeupdateRpc); ok {
+		return x.RendererclientOntimeupdateRpc
+	}
+	return nil
+}
+
+func (x *RpcMessage) GetRendererclientOnbufferingstatechangeRpc() *RendererClientOnBufferingStateChange {
+	if x, ok := x.GetRpcOneof().(*RpcMessage_RendererclientOnbufferingstatechangeRpc); ok {
+		return x.RendererclientOnbufferingstatechangeRpc
+	}
+	return nil
+}
+
+func (x *RpcMessage) GetDemuxerstreamReaduntilRpc() *DemuxerStreamReadUntil {
+	if x, ok := x.GetRpcOneof().(*RpcMessage_DemuxerstreamReaduntilRpc); ok {
+		return x.DemuxerstreamReaduntilRpc
+	}
+	return nil
+}
+
+func (x *RpcMessage) GetDemuxerstreamInitializecbRpc() *DemuxerStreamInitializeCallback {
+	if x, ok := x.GetRpcOneof().(*RpcMessage_DemuxerstreamInitializecbRpc); ok {
+		return x.DemuxerstreamInitializecbRpc
+	}
+	return nil
+}
+
+func (x *RpcMessage) GetDemuxerstreamReaduntilcbRpc() *DemuxerStreamReadUntilCallback {
+	if x, ok := x.GetRpcOneof().(*RpcMessage_DemuxerstreamReaduntilcbRpc); ok {
+		return x.DemuxerstreamReaduntilcbRpc
+	}
+	return nil
+}
+
+type isRpcMessage_RpcOneof interface {
+	isRpcMessage_RpcOneof()
+}
+
+type RpcMessage_IntegerValue struct {
+	IntegerValue int32 `protobuf:"varint,3,opt,name=integer_value,json=integerValue,oneof"`
+}
+
+type RpcMessage_Integer64Value struct {
+	Integer64Value int64 `protobuf:"varint,4,opt,name=integer64_value,json=integer64Value,oneof"`
+}
+
+type RpcMessage_BooleanValue struct {
+	BooleanValue bool `protobuf:"varint,5,opt,name=boolean_value,json=booleanValue,oneof"`
+}
+
+type RpcMessage_DoubleValue struct {
+	DoubleValue float64 `protobuf:"fixed64,6,opt,name=double_value,json=doubleValue,oneof"`
+}
+
+type RpcMessage_StringValue struct {
+	StringValue string `protobuf:"bytes,7,opt,name=string_value,json=stringValue,oneof"`
+}
+
+type RpcMessage_RendererInitializeRpc struct {
+	RendererInitializeRpc *RendererInitialize `protobuf:"bytes,100,opt,name=renderer_initialize_rpc,json=rendererInitializeRpc,oneof"`
+}
+
+type RpcMessage_RendererFlushuntilRpc struct {
+	RendererFlushuntilRpc *RendererFlushUntil `protobuf:"bytes,101,opt,name=renderer_flushuntil_rpc,json=rendererFlushuntilRpc,oneof"`
+}
+
+type RpcMessage_RendererclientOntimeupdateRpc struct {
+	RendererclientOntimeupdateRpc *RendererClientOnTimeUpdate `protobuf:"bytes,200,opt,name=rendererclient_ontimeupdate_rpc,json=rendererclientOntimeupdateRpc,oneof"`
+}
+
+type RpcMessage_RendererclientOnbufferingstatechangeRpc struct {
+	RendererclientOnbufferingstatechangeRpc *RendererClientOnBufferingStateChange `protobuf:"bytes,203,opt,name=rendererclient_onbufferingstatechange_rpc,json=rendererclientOnbufferingstatechangeRpc,oneof"`
+}
+
+type RpcMessage_DemuxerstreamReaduntilRpc struct {
+	DemuxerstreamReaduntilRpc *DemuxerStreamReadUntil `protobuf:"bytes,300,opt,name=demuxerstream_readuntil_rpc,json=demuxerstreamReaduntilRpc,oneof"`
+}
+
+type RpcMessage_DemuxerstreamInitializecbRpc struct {
+	DemuxerstreamInitializecbRpc *DemuxerStreamInitializeCallback `protobuf:"bytes,301,opt,name=demuxerstream_initializecb_rpc,json=demuxerstreamInitializecbRpc,oneof"`
+}
+
+type RpcMessage_DemuxerstreamReaduntilcbRpc struct {
+	DemuxerstreamReaduntilcbRpc *DemuxerStreamReadUntilCallback `protobuf:"bytes,302,opt,name=demuxerstream_readuntilcb_rpc,json=demuxerstreamReaduntilcbRpc,oneof"`
+}
+
+func (*RpcMessage_IntegerValue) isRpcMessage_RpcOneof() {}
+
+func (*RpcMessage_Integer64Value) isRpcMessage_RpcOneof() {}
+
+func (*RpcMessage_BooleanValue) isRpcMessage_RpcOneof() {}
+
+func (*RpcMessage_DoubleValue) isRpcMessage_RpcOneof() {}
+
+func (*RpcMessage_StringValue) isRpcMessage_RpcOneof() {}
+
+func (*RpcMessage_RendererInitializeRpc) isRpcMessage_RpcOneof() {}
+
+func (*RpcMessage_RendererFlushuntilRpc) isRpcMessage_RpcOneof() {}
+
+func (*RpcMessage_RendererclientOntimeupdateRpc) isRpcMessage_RpcOneof() {}
+
+func (*RpcMessage_RendererclientOnbufferingstatechangeRpc) isRpcMessage_RpcOneof() {}
+
+func (*RpcMessage_DemuxerstreamReaduntilRpc) isRpcMessage_RpcOneof() {}
+
+func (*RpcMessage_DemuxerstreamInitializecbRpc) isRpcMessage_RpcOneof() {}
+
+func (*RpcMessage_DemuxerstreamReaduntilcbRpc) isRpcMessage_RpcOneof() {}
+
+var File_internal_remoting_wire_remoting_proto protoreflect.FileDescriptor
+
+var file_internal_remoting_wire_remoting_proto_rawDesc = []byte{
+	0x0a, 0x25, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f, 0x72, 0x65, 0x6d, 0x6f, 0x74,
+	0x69, 0x6e, 0x67, 0x2f, 0x77, 0x69, 0x72, 0x65, 0x2f, 0x72, 0x65, 0x6d, 0x6f, 0x74, 0x69, 0x6e,
+	0x67, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x0f, 0x6f, 0x70, 0x65, 0x6e, 0x73, 0x63, 0x72,
+	0x65, 0x65, 0x6e, 0x2e, 0x63, 0x61, 0x73, 0x74, 0x22, 0x94, 0x01, 0x0a, 0x0d, 0x44, 0x65, 0x63,
+	0x6f, 0x64, 0x65, 0x72, 0x42, 0x75, 0x66, 0x66, 0x65, 0x72, 0x12, 0x25, 0x0a, 0x0e, 0x74, 0x69,
+	0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x5f, 0x75, 0x73, 0x65, 0x63, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x03, 0x52, 0x0d, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x55, 0x73, 0x65,
+	0x63, 0x12, 0x23, 0x0a, 0x0d, 0x64, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x75, 0x73,
+	0x65, 0x63, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0c, 0x64, 0x75, 0x72, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x55, 0x73, 0x65, 0x63, 0x12, 0x20, 0x0a, 0x0c, 0x69, 0x73, 0x5f, 0x6b, 0x65, 0x79,
+	0x5f, 0x66, 0x72, 0x61, 0x6d, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0a, 0x69, 0x73,
+	0x4b, 0x65, 0x79, 0x46, 0x72, 0x61, 0x6d, 0x65, 0x12, 0x15, 0x0a, 0x06, 0x69, 0x73, 0x5f, 0x65,
+	0x6f, 0x73, 0x18, 0x09, 0x20, 0x01, 0x28, 0x08, 0x52, 0x05, 0x69, 0x73, 0x45, 0x6f, 0x73, 0x22,
+	0x34, 0x0a, 0x04, 0x53, 0x69, 0x7a, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x77, 0x69, 0x64, 0x74, 0x68,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x77, 0x69, 0x64, 0x74, 0x68, 0x12, 0x16, 0x0a,
+	0x06, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x68,
+	0x65, 0x69, 0x67, 0x68, 0x74, 0x22, 0x9e, 0x01, 0x0a, 0x12, 0x41, 0x75, 0x64, 0x69, 0x6f, 0x44,
+	0x65, 0x63, 0x6f, 0x64, 0x65, 0x72, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12, 0x14, 0x0a, 0x05,
+	0x63, 0x6f, 0x64, 0x65, 0x63, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x63, 0x6f, 0x64,
+	0x65, 0x63, 0x12, 0x25, 0x0a, 0x0e, 0x63, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x5f, 0x6c, 0x61,
+	0x79, 0x6f, 0x75, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0d, 0x63, 0x68, 0x61, 0x6e,
+	0x6e, 0x65, 0x6c, 0x4c, 0x61, 0x79, 0x6f, 0x75, 0x74, 0x12, 0x2c, 0x0a, 0x12, 0x73, 0x61, 0x6d,
+	0x70, 0x6c, 0x65, 0x73, 0x5f, 0x70, 0x65, 0x72, 0x5f, 0x73, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x18,
+	0x05, 0x20, 0x01, 0x28, 0x05, 0x52, 0x10, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x73, 0x50, 0x65,
+	0x72, 0x53, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x12, 0x1d, 0x0a, 0x0a, 0x65, 0x78, 0x74, 0x72, 0x61,
+	0x5f, 0x64, 0x61, 0x74, 0x61, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x65, 0x78, 0x74,
+	0x72, 0x61, 0x44, 0x61, 0x74, 0x61, 0x22, 0xd3, 0x01, 0x0a, 0x12, 0x56, 0x69, 0x64, 0x65, 0x6f,
+	0x44, 0x65, 0x63, 0x6f, 0x64, 0x65, 0x72, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12, 0x14, 0x0a,
+	0x05, 0x63, 0x6f, 0x64, 0x65, 0x63, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x63, 0x6f,
+	0x64, 0x65, 0x63, 0x12, 0x18, 0x0a, 0x07, 0x70, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x05, 0x52, 0x07, 0x70, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x12, 0x34, 0x0a,
+	0x0a, 0x63, 0x6f, 0x64, 0x65, 0x64, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x15, 0x2e, 0x6f, 0x70, 0x65, 0x6e, 0x73, 0x63, 0x72, 0x65, 0x65, 0x6e, 0x2e, 0x63,
+	0x61, 0x73, 0x74, 0x2e, 0x53, 0x69, 0x7a, 0x65, 0x52, 0x09, 0x63, 0x6f, 0x64, 0x65, 0x64, 0x53,
+	0x69, 0x7a, 0x65, 0x12, 0x38, 0x0a, 0x0c, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x61, 0x6c, 0x5f, 0x73,
+	0x69, 0x7a, 0x65, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x6f, 0x70, 0x65, 0x6e,
+	0x73, 0x63, 0x72, 0x65, 0x65, 0x6e, 0x2e, 0x63, 0x61, 0x73, 0x74, 0x2e, 0x53, 0x69, 0x7a, 0x65,
+	0x52, 0x0b, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x61, 0x6c, 0x53, 0x69, 0x7a, 0x65, 0x12, 0x1d, 0x0a,
+	0x0a, 0x65, 0x78, 0x74, 0x72, 0x61, 0x5f, 0x64, 0x61, 0x74, 0x61, 0x18, 0x08, 0x20, 0x01, 0x28,
+	0x0c, 0x52, 0x09, 0x65, 0x78, 0x74, 0x72, 0x61, 0x44, 0x61, 0x74, 0x61, 0x22, 0xc6, 0x01, 0x0a,
+	0x12, 0x52, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x65, 0x72, 0x49, 0x6e, 0x69, 0x74, 0x69, 0x61, 0x6c,
+	0x69, 0x7a, 0x65, 0x12, 0x23, 0x0a, 0x0d, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x5f, 0x68, 0x61,
+	0x6e, 0x64, 0x6c, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0c, 0x63, 0x6c, 0x69, 0x65,
+	0x6e, 0x74, 0x48, 0x61, 0x6e, 0x64, 0x6c, 0x65, 0x12, 0x30, 0x0a, 0x14, 0x61, 0x75, 0x64, 0x69,
+	0x6f, 0x5f, 0x64, 0x65, 0x6d, 0x75, 0x78, 0x65, 0x72, 0x5f, 0x68, 0x61, 0x6e, 0x64, 0x6c, 0x65,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x12, 0x61, 0x75, 0x64, 0x69, 0x6f, 0x44, 0x65, 0x6d,
+	0x75, 0x78, 0x65, 0x72, 0x48, 0x61, 0x6e, 0x64, 0x6c, 0x65, 0x12, 0x30, 0x0a, 0x14, 0x76, 0x69,
+	0x64, 0x65, 0x6f, 0x5f, 0x64, 0x65, 0x6d, 0x75, 0x78, 0x65, 0x72, 0x5f, 0x68, 0x61, 0x6e, 0x64,
+	0x6c, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x12, 0x76, 0x69, 0x64, 0x65, 0x6f, 0x44,
+	0x65, 0x6d, 0x75, 0x78, 0x65, 0x72, 0x48, 0x61, 0x6e, 0x64, 0x6c, 0x65, 0x12, 0x27, 0x0a, 0x0f,
+	0x63, 0x61, 0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b, 0x5f, 0x68, 0x61, 0x6e, 0x64, 0x6c, 0x65, 0x18,
+	0x04, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0e, 0x63, 0x61, 0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b, 0x48,
+	0x61, 0x6e, 0x64, 0x6c, 0x65, 0x22, 0x7f, 0x0a, 0x12, 0x52, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x65,
+	0x72, 0x46, 0x6c, 0x75, 0x73, 0x68, 0x55, 0x6e, 0x74, 0x69, 0x6c, 0x12, 0x1f, 0x0a, 0x0b, 0x61,
+	0x75, 0x64, 0x69, 0x6f, 0x5f, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d,
+	0x52, 0x0a, 0x61, 0x75, 0x64, 0x69, 0x6f, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x1f, 0x0a, 0x0b,
+	0x76, 0x69, 0x64, 0x65, 0x6f, 0x5f, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0d, 0x52, 0x0a, 0x76, 0x69, 0x64, 0x65, 0x6f, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x27, 0x0a,
+	0x0f, 0x63, 0x61, 0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b, 0x5f, 0x68, 0x61, 0x6e, 0x64, 0x6c, 0x65,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0e, 0x63, 0x61, 0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b,
+	0x48, 0x61, 0x6e, 0x64, 0x6c, 0x65, 0x22, 0x5d, 0x0a, 0x1a, 0x52, 0x65, 0x6e, 0x64, 0x65, 0x72,
+	0x65, 0x72, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x4f, 0x6e, 0x54, 0x69, 0x6d, 0x65, 0x55, 0x70,
+	0x64, 0x61, 0x74, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x5f, 0x75, 0x73, 0x65,
+	0x63, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x74, 0x69, 0x6d, 0x65, 0x55, 0x73, 0x65,
+	0x63, 0x12, 0x22, 0x0a, 0x0d, 0x6d, 0x61, 0x78, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x5f, 0x75, 0x73,
+	0x65, 0x63, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0b, 0x6d, 0x61, 0x78, 0x54, 0x69, 0x6d,
+	0x65, 0x55, 0x73, 0x65, 0x63, 0x22, 0x3c, 0x0a, 0x24, 0x52, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x65,
+	0x72, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x4f, 0x6e, 0x42, 0x75, 0x66, 0x66, 0x65, 0x72, 0x69,
+	0x6e, 0x67, 0x53, 0x74, 0x61, 0x74, 0x65, 0x43, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x12, 0x14, 0x0a,
+	0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x73, 0x74,
+	0x61, 0x74, 0x65, 0x22, 0x57, 0x0a, 0x16, 0x44, 0x65, 0x6d, 0x75, 0x78, 0x65, 0x72, 0x53, 0x74,
+	0x72, 0x65, 0x61, 0x6d, 0x52, 0x65, 0x61, 0x64, 0x55, 0x6e, 0x74, 0x69, 0x6c, 0x12, 0x27, 0x0a,
+	0x0f, 0x63, 0x61, 0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b, 0x5f, 0x68, 0x61, 0x6e, 0x64, 0x6c, 0x65,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0e, 0x63, 0x61, 0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b,
+	0x48, 0x61, 0x6e, 0x64, 0x6c, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x22, 0xe3, 0x01, 0x0a,
+	0x1f, 0x44, 0x65, 0x6d, 0x75, 0x78, 0x65, 0x72, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x49, 0x6e,
+	0x69, 0x74, 0x69, 0x61, 0x6c, 0x69, 0x7a, 0x65, 0x43, 0x61, 0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b,
+	0x12, 0x12, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04,
+	0x74, 0x79, 0x70, 0x65, 0x12, 0x55, 0x0a, 0x14, 0x61, 0x75, 0x64, 0x69, 0x6f, 0x5f, 0x64, 0x65,
+	0x63, 0x6f, 0x64, 0x65, 0x72, 0x5f, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x23, 0x2e, 0x6f, 0x70, 0x65, 0x6e, 0x73, 0x63, 0x72, 0x65, 0x65, 0x6e, 0x2e,
+	0x63, 0x61, 0x73, 0x74, 0x2e, 0x41, 0x75, 0x64, 0x69, 0x6f, 0x44, 0x65, 0x63, 0x6f, 0x64, 0x65,
+	0x72, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x52, 0x12, 0x61, 0x75, 0x64, 0x69, 0x6f, 0x44, 0x65,
+	0x63, 0x6f, 0x64, 0x65, 0x72, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12, 0x55, 0x0a, 0x14, 0x76,
+	0x69, 0x64, 0x65, 0x6f, 0x5f, 0x64, 0x65, 0x63, 0x6f, 0x64, 0x65, 0x72, 0x5f, 0x63, 0x6f, 0x6e,
+	0x66, 0x69, 0x67, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x23, 0x2e, 0x6f, 0x70, 0x65, 0x6e,
+	0x73, 0x63, 0x72, 0x65, 0x65, 0x6e, 0x2e, 0x63, 0x61, 0x73, 0x74, 0x2e, 0x56, 0x69, 0x64, 0x65,
+	0x6f, 0x44, 0x65, 0x63, 0x6f, 0x64, 0x65, 0x72, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x52, 0x12,
+	0x76, 0x69, 0x64, 0x65, 0x6f, 0x44, 0x65, 0x63, 0x6f, 0x64, 0x65, 0x72, 0x43, 0x6f, 0x6e, 0x66,
+	0x69, 0x67, 0x22, 0xfc, 0x01, 0x0a, 0x1e, 0x44, 0x65, 0x6d, 0x75, 0x78, 0x65, 0x72, 0x53, 0x74,
+	0x72, 0x65, 0x61, 0x6d, 0x52, 0x65, 0x61, 0x64, 0x55, 0x6e, 0x74, 0x69, 0x6c, 0x43, 0x61, 0x6c,
+	0x6c, 0x62, 0x61, 0x63, 0x6b, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x55, 0x0a,
+	0x14, 0x61, 0x75, 0x64, 0x69, 0x6f, 0x5f, 0x64, 0x65, 0x63, 0x6f, 0x64, 0x65, 0x72, 0x5f, 0x63,
+	0x6f, 0x6e, 0x66, 0x69, 0x67, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x23, 0x2e, 0x6f, 0x70,
+	0x65, 0x6e, 0x73, 0x63, 0x72, 0x65, 0x65, 0x6e, 0x2e, 0x63, 0x61, 0x73, 0x74, 0x2e, 0x41, 0x75,
+	0x64, 0x69, 0x6f, 0x44, 0x65, 0x63, 0x6f, 0x64, 0x65, 0x72, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67,
+	0x52, 0x12, 0x61, 0x75, 0x64, 0x69, 0x6f, 0x44, 0x65, 0x63, 0x6f, 0x64, 0x65, 0x72, 0x43, 0x6f,
+	0x6e, 0x66, 0x69, 0x67, 0x12, 0x55, 0x0a, 0x14, 0x76, 0x69, 0x64, 0x65, 0x6f, 0x5f, 0x64, 0x65,
+	0x63, 0x6f, 0x64, 0x65, 0x72, 0x5f, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x23, 0x2e, 0x6f, 0x70, 0x65, 0x6e, 0x73, 0x63, 0x72, 0x65, 0x65, 0x6e, 0x2e,
+	0x63, 0x61, 0x73, 0x74, 0x2e, 0x56, 0x69, 0x64, 0x65, 0x6f, 0x44, 0x65, 0x63, 0x6f, 0x64, 0x65,
+	0x72, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x52, 0x12, 0x76, 0x69, 0x64, 0x65, 0x6f, 0x44, 0x65,
+	0x63, 0x6f, 0x64, 0x65, 0x72, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12, 0x14, 0x0a, 0x05, 0x63,
+	0x6f, 0x75, 0x6e, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x63, 0x6f, 0x75, 0x6e,
+	0x74, 0x22, 0xb4, 0x08, 0x0a, 0x0a, 0x52, 0x70, 0x63, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
+	0x12, 0x16, 0x0a, 0x06, 0x68, 0x61, 0x6e, 0x64, 0x6c, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05,
+	0x52, 0x06, 0x68, 0x61, 0x6e, 0x64, 0x6c, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x72, 0x6f, 0x63,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x70, 0x72, 0x6f, 0x63, 0x12, 0x25, 0x0a, 0x0d,
+	0x69, 0x6e, 0x74, 0x65, 0x67, 0x65, 0x72, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x05, 0x48, 0x00, 0x52, 0x0c, 0x69, 0x6e, 0x74, 0x65, 0x67, 0x65, 0x72, 0x56, 0x61,
+	0x6c, 0x75, 0x65, 0x12, 0x29, 0x0a, 0x0f, 0x69, 0x6e, 0x74, 0x65, 0x67, 0x65, 0x72, 0x36, 0x34,
+	0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x48, 0x00, 0x52, 0x0e,
+	0x69, 0x6e, 0x74, 0x65, 0x67, 0x65, 0x72, 0x36, 0x34, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x25,
+	0x0a, 0x0d, 0x62, 0x6f, 0x6f, 0x6c, 0x65, 0x61, 0x6e, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18,
+	0x05, 0x20, 0x01, 0x28, 0x08, 0x48, 0x00, 0x52, 0x0c, 0x62, 0x6f, 0x6f, 0x6c, 0x65, 0x61, 0x6e,
+	0x56, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x23, 0x0a, 0x0c, 0x64, 0x6f, 0x75, 0x62, 0x6c, 0x65, 0x5f,
+	0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x01, 0x48, 0x00, 0x52, 0x0b, 0x64,
+	0x6f, 0x75, 0x62, 0x6c, 0x65, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x23, 0x0a, 0x0c, 0x73, 0x74,
+	0x72, 0x69, 0x6e, 0x67, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x07, 0x20, 0x01, 0x28, 0x09,
+	0x48, 0x00, 0x52, 0x0b, 0x73, 0x74, 0x72, 0x69, 0x6e, 0x67, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x12,
+	0x5d, 0x0a, 0x17, 0x72, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x65, 0x72, 0x5f, 0x69, 0x6e, 0x69, 0x74,
+	0x69, 0x61, 0x6c, 0x69, 0x7a, 0x65, 0x5f, 0x72, 0x70, 0x63, 0x18, 0x64, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x23, 0x2e, 0x6f, 0x70, 0x65, 0x6e, 0x73, 0x63, 0x72, 0x65, 0x65, 0x6e, 0x2e, 0x63, 0x61,
+	0x73, 0x74, 0x2e, 0x52, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x65, 0x72, 0x49, 0x6e, 0x69, 0x74, 0x69,
+	0x61, 0x6c, 0x69, 0x7a, 0x65, 0x48, 0x00, 0x52, 0x15, 0x72, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x65,
+	0x72, 0x49, 0x6e, 0x69, 0x74, 0x69, 0x61, 0x6c, 0x69, 0x7a, 0x65, 0x52, 0x70, 0x63, 0x12, 0x5d,
+	0x0a, 0x17, 0x72, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x65, 0x72, 0x5f, 0x66, 0x6c, 0x75, 0x73, 0x68,
+	0x75, 0x6e, 0x74, 0x69, 0x6c, 0x5f, 0x72, 0x70, 0x63, 0x18, 0x65, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x23, 0x2e, 0x6f, 0x70, 0x65, 0x6e, 0x73, 0x63, 0x72, 0x65, 0x65, 0x6e, 0x2e, 0x63, 0x61, 0x73,
+	0x74, 0x2e, 0x52, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x65, 0x72, 0x46, 0x6c, 0x75, 0x73, 0x68, 0x55,
+	0x6e, 0x74, 0x69, 0x6c, 0x48, 0x00, 0x52, 0x15, 0x72, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x65, 0x72,
+	0x46, 0x6c, 0x75, 0x73, 0x68, 0x75, 0x6e, 0x74, 0x69, 0x6c, 0x52, 0x70, 0x63, 0x12, 0x76, 0x0a,
+	0x1f, 0x72, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x65, 0x72, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x5f,
+	0x6f, 0x6e, 0x74, 0x69, 0x6d, 0x65, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x5f, 0x72, 0x70, 0x63,
+	0x18, 0xc8, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x2b, 0x2e, 0x6f, 0x70, 0x65, 0x6e, 0x73, 0x63,
+	0x72, 0x65, 0x65, 0x6e, 0x2e, 0x63, 0x61, 0x73, 0x74, 0x2e, 0x52, 0x65, 0x6e, 0x64, 0x65, 0x72,
+	0x65, 0x72, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x4f, 0x6e, 0x54, 0x69, 0x6d, 0x65, 0x55, 0x70,
+	0x64, 0x61, 0x74, 0x65, 0x48, 0x00, 0x52, 0x1d, 0x72, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x65, 0x72,
+	0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x4f, 0x6e, 0x74, 0x69, 0x6d, 0x65, 0x75, 0x70, 0x64, 0x61,
+	0x74, 0x65, 0x52, 0x70, 0x63, 0x12, 0x94, 0x01, 0x0a, 0x29, 0x72, 0x65, 0x6e, 0x64, 0x65, 0x72,
+	0x65, 0x72, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x5f, 0x6f, 0x6e, 0x62, 0x75, 0x66, 0x66, 0x65,
+	0x72, 0x69, 0x6e, 0x67, 0x73, 0x74, 0x61, 0x74, 0x65, 0x63, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x5f,
+	0x72, 0x70, 0x63, 0x18, 0xcb, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x35, 0x2e, 0x6f, 0x70, 0x65,
+	0x6e, 0x73, 0x63, 0x72, 0x65, 0x65, 0x6e, 0x2e, 0x63, 0x61, 0x73, 0x74, 0x2e, 0x52, 0x65, 0x6e,
+	0x64, 0x65, 0x72, 0x65, 0x72, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x4f, 0x6e, 0x42, 0x75, 0x66,
+	0x66, 0x65, 0x72, 0x69, 0x6e, 0x67, 0x53, 0x74, 0x61, 0x74, 0x65, 0x43, 0x68, 0x61, 0x6e, 0x67,
+	0x65, 0x48, 0x00, 0x52, 0x27, 0x72, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x65, 0x72, 0x63, 0x6c, 0x69,
+	0x65, 0x6e, 0x74, 0x4f, 0x6e, 0x62, 0x75, 0x66, 0x66, 0x65, 0x72, 0x69, 0x6e, 0x67, 0x73, 0x74,
+	0x61, 0x74, 0x65, 0x63, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x52, 0x70, 0x63, 0x12, 0x6a, 0x0a, 0x1b,
+	0x64, 0x65, 0x6d, 0x75, 0x78, 0x65, 0x72, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x5f, 0x72, 0x65,
+	0x61, 0x64, 0x75, 0x6e, 0x74, 0x69, 0x6c, 0x5f, 0x72, 0x70, 0x63, 0x18, 0xac, 0x02, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x27, 0x2e, 0x6f, 0x70, 0x65, 0x6e, 0x73, 0x63, 0x72, 0x65, 0x65, 0x6e, 0x2e,
+	0x63, 0x61, 0x73, 0x74, 0x2e, 0x44, 0x65, 0x6d, 0x75, 0x78, 0x65, 0x72, 0x53, 0x74, 0x72, 0x65,
+	0x61, 0x6d, 0x52, 0x65, 0x61, 0x64, 0x55, 0x6e, 0x74, 0x69, 0x6c, 0x48, 0x00, 0x52, 0x19, 0x64,
+	0x65, 0x6d, 0x75, 0x78, 0x65, 0x72, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x52, 0x65, 0x61, 0x64,
+	0x75, 0x6e, 0x74, 0x69, 0x6c, 0x52, 0x70, 0x63, 0x12, 0x79, 0x0a, 0x1e, 0x64, 0x65, 0x6d, 0x75,
+	0x78, 0x65, 0x72, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x5f, 0x69, 0x6e, 0x69, 0x74, 0x69, 0x61,
+	0x6c, 0x69, 0x7a, 0x65, 0x63, 0x62, 0x5f, 0x72, 0x70, 0x63, 0x18, 0xad, 0x02, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x30, 0x2e, 0x6f, 0x70, 0x65, 0x6e, 0x73, 0x63, 0x72, 0x65, 0x65, 0x6e, 0x2e, 0x63,
+	0x61, 0x73, 0x74, 0x2e, 0x44, 0x65, 0x6d, 0x75, 0x78, 0x65, 0x72, 0x53, 0x74, 0x72, 0x65, 0x61,
+	0x6d, 0x49, 0x6e, 0x69, 0x74, 0x69, 0x61, 0x6c, 0x69, 0x7a, 0x65, 0x43, 0x61, 0x6c, 0x6c, 0x62,
+	0x61, 0x63, 0x6b, 0x48, 0x00, 0x52, 0x1c, 0x64, 0x65, 0x6d, 0x75, 0x78, 0x65, 0x72, 0x73, 0x74,
+	0x72, 0x65, 0x61, 0x6d, 0x49, 0x6e, 0x69, 0x74, 0x69, 0x61, 0x6c, 0x69, 0x7a, 0x65, 0x63, 0x62,
+	0x52, 0x70, 0x63, 0x12, 0x76, 0x0a, 0x1d, 0x64, 0x65, 0x6d, 0x75, 0x78, 0x65, 0x72, 0x73, 0x74,
+	0x72, 0x65, 0x61, 0x6d, 0x5f, 0x72, 0x65, 0x61, 0x64, 0x75, 0x6e, 0x74, 0x69, 0x6c, 0x63, 0x62,
+	0x5f, 0x72, 0x70, 0x63, 0x18, 0xae, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x2f, 0x2e, 0x6f, 0x70,
+	0x65, 0x6e, 0x73, 0x63, 0x72, 0x65, 0x65, 0x6e, 0x2e, 0x63, 0x61, 0x73, 0x74, 0x2e, 0x44, 0x65,
+	0x6d, 0x75, 0x78, 0x65, 0x72, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x52, 0x65, 0x61, 0x64, 0x55,
+	0x6e, 0x74, 0x69, 0x6c, 0x43, 0x61, 0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b, 0x48, 0x00, 0x52, 0x1b,
+	0x64, 0x65, 0x6d, 0x75, 0x78, 0x65, 0x72, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x52, 0x65, 0x61,
+	0x64, 0x75, 0x6e, 0x74, 0x69, 0x6c, 0x63, 0x62, 0x52, 0x70, 0x63, 0x42, 0x0b, 0x0a, 0x09, 0x72,
+	0x70, 0x63, 0x5f, 0x6f, 0x6e, 0x65, 0x6f, 0x66, 0x42, 0x3b, 0x48, 0x03, 0x5a, 0x37, 0x67, 0x69,
+	0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x74, 0x72, 0x69, 0x73, 0x74, 0x61, 0x6e,
+	0x70, 0x65, 0x6e, 0x6d, 0x61, 0x6e, 0x2f, 0x67, 0x6f, 0x2d, 0x63, 0x61, 0x73, 0x74, 0x2f, 0x69,
+	0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f, 0x72, 0x65, 0x6d, 0x6f, 0x74, 0x69, 0x6e, 0x67,
+	0x2f, 0x77, 0x69, 0x72, 0x65,
+}
+
+var (
+	file_internal_remoting_wire_remoting_proto_rawDescOnce sync.Once
+	file_internal_remoting_wire_remoting_proto_rawDescData = file_internal_remoting_wire_remoting_proto_rawDesc
+)
+
+func file_internal_remoting_wire_remoting_proto_rawDescGZIP() []byte {
+	file_internal_remoting_wire_remoting_proto_rawDescOnce.Do(func() {
+		file_internal_remoting_wire_remoting_proto_rawDescData = protoimpl.X.CompressGZIP(file_internal_remoting_wire_remoting_proto_rawDescData)
+	})
+	return file_internal_remoting_wire_remoting_proto_rawDescData
+}
+
+var file_internal_remoting_wire_remoting_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_internal_remoting_wire_remoting_proto_goTypes = []interface{}{
+	(*DecoderBuffer)(nil),                        // 0: openscreen.cast.DecoderBuffer
+	(*Size)(nil),                                 // 1: openscreen.cast.Size
+	(*AudioDecoderConfig)(nil),                   // 2: openscreen.cast.AudioDecoderConfig
+	(*VideoDecoderConfig)(nil),                   // 3: openscreen.cast.VideoDecoderConfig
+	(*RendererInitialize)(nil),                   // 4: openscreen.cast.RendererInitialize
+	(*RendererFlushUntil)(nil),                   // 5: openscreen.cast.RendererFlushUntil
+	(*RendererClientOnTimeUpdate)(nil),           // 6: openscreen.cast.RendererClientOnTimeUpdate
+	(*RendererClientOnBufferingStateChange)(nil), // 7: openscreen.cast.RendererClientOnBufferingStateChange
+	(*DemuxerStreamReadUntil)(nil),               // 8: openscreen.cast.DemuxerStreamReadUntil
+	(*DemuxerStreamInitializeCallback)(nil),      // 9: openscreen.cast.DemuxerStreamInitializeCallback
+	(*DemuxerStreamReadUntilCallback)(nil),       // 10: openscreen.cast.DemuxerStreamReadUntilCallback
+	(*RpcMessage)(nil),                           // 11: openscreen.cast.RpcMessage
+}
+var file_internal_remoting_wire_remoting_proto_depIdxs = []int32{
+	1,  // 0: openscreen.cast.VideoDecoderConfig.coded_size:type_name -> openscreen.cast.Size
+	1,  // 1: openscreen.cast.VideoDecoderConfig.natural_size:type_name -> openscreen.cast.Size
+	2,  // 2: openscreen.cast.DemuxerStreamInitializeCallback.audio_decoder_config:type_name -> openscreen.cast.AudioDecoderConfig
+	3,  // 3: openscreen.cast.DemuxerStreamInitializeCallback.video_decoder_config:type_name -> openscreen.cast.VideoDecoderConfig
+	2,  // 4: openscreen.cast.DemuxerStreamReadUntilCallback.audio_decoder_config:type_name -> openscreen.cast.AudioDecoderConfig
+	3,  // 5: openscreen.cast.DemuxerStreamReadUntilCallback.video_decoder_config:type_name -> openscreen.cast.VideoDecoderConfig
+	4,  // 6: openscreen.cast.RpcMessage.renderer_initialize_rpc:type_name -> openscreen.cast.RendererInitialize
+	5,  // 7: openscreen.cast.RpcMessage.renderer_flushuntil_rpc:type_name -> openscreen.cast.RendererFlushUntil
+	6,  // 8: openscreen.cast.RpcMessage.rendererclient_ontimeupdate_rpc:type_name -> openscreen.cast.RendererClientOnTimeUpdate
+	7,  // 9: openscreen.cast.RpcMessage.rendererclient_onbufferingstatechange_rpc:type_name -> openscreen.cast.RendererClientOnBufferingStateChange
+	8,  // 10: openscreen.cast.RpcMessage.demuxerstream_readuntil_rpc:type_name -> openscreen.cast.DemuxerStreamReadUntil
+	9,  // 11: openscreen.cast.RpcMessage.demuxerstream_initializecb_rpc:type_name -> openscreen.cast.DemuxerStreamInitializeCallback
+	10, // 12: openscreen.cast.RpcMessage.demuxerstream_readuntilcb_rpc:type_name -> openscreen.cast.DemuxerStreamReadUntilCallback
+	13, // [13:13] is the sub-list for method output_type
+	13, // [13:13] is the sub-list for method input_type
+	13, // [13:13] is the sub-list for extension type_name
+	13, // [13:13] is the sub-list for extension extendee
+	0,  // [0:13] is the sub-list for field type_name
+}
+
+func init() { file_internal_remoting_wire_remoting_proto_init() }
+func file_internal_remoting_wire_remoting_proto_init() {
+	if File_internal_remoting_wire_remoting_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_internal_remoting_wire_remoting_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DecoderBuffer); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_internal_remoting_wire_remoting_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Size); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_internal_remoting_wire_remoting_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AudioDecoderConfig); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_internal_remoting_wire_remoting_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*VideoDecoderConfig); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_internal_remoting_wire_remoting_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RendererInitialize); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_internal_remoting_wire_remoting_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RendererFlushUntil); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_internal_remoting_wire_remoting_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RendererClientOnTimeUpdate); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_internal_remoting_wire_remoting_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RendererClientOnBufferingStateChange); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_internal_remoting_wire_remoting_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DemuxerStreamReadUntil); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_internal_remoting_wire_remoting_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DemuxerStreamInitializeCallback); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_internal_remoting_wire_remoting_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DemuxerStreamReadUntilCallback); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_internal_remoting_wire_remoting_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RpcMessage); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_internal_remoting_wire_remoting_proto_msgTypes[11].OneofWrappers = []interface{}{
+		(*RpcMessage_IntegerValue)(nil),
+		(*RpcMessage_Integer64Value)(nil),
+		(*RpcMessage_BooleanValue)(nil),
+		(*RpcMessage_DoubleValue)(nil),
+		(*RpcMessage_StringValue)(nil),
+		(*RpcMessage_RendererInitializeRpc)(nil),
+		(*RpcMessage_RendererFlushuntilRpc)(nil),
+		(*RpcMessage_RendererclientOntimeupdateRpc)(nil),
+		(*RpcMessage_RendererclientOnbufferingstatechangeRpc)(nil),
+		(*RpcMessage_DemuxerstreamReaduntilRpc)(nil),
+		(*RpcMessage_DemuxerstreamInitializecbRpc)(nil),
+		(*RpcMessage_DemuxerstreamReaduntilcbRpc)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_internal_remoting_wire_remoting_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   12,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_internal_remoting_wire_remoting_proto_goTypes,
+		DependencyIndexes: file_internal_remoting_wire_remoting_proto_depIdxs,
+		MessageInfos:      file_internal_remoting_wire_remoting_proto_msgTypes,
+	}.Build()
+	File_internal_remoting_wire_remoting_proto = out.File
+	file_internal_remoting_wire_remoting_proto_rawDesc = nil
+	file_internal_remoting_wire_remoting_proto_goTypes = nil
+	file_internal_remoting_wire_remoting_proto_depIdxs = nil
+}
@@ -0,0 +1,156 @@
+package sender
+
+/*
+#cgo pkg-config: vpx
+#include <stdlib.h>
+#include <vpx/vpx_encoder.h>
+#include <vpx/vp8cx.h>
+
+// The encoder API relies on a macro for initialisation, and returns frames in
+// a union, neither of which can be used from Go directly.
+
+static vpx_codec_err_t vp8_encoder_init(vpx_codec_ctx_t *ctx, vpx_codec_enc_cfg_t *cfg) {
+	return vpx_codec_enc_init(ctx, vpx_codec_vp8_cx(), cfg, 0);
+}
+
+static int vp8_encoder_next_frame(vpx_codec_ctx_t *ctx, vpx_codec_iter_t *iter, void **buf, size_t *size, int *keyframe) {
+	const vpx_codec_cx_pkt_t *pkt;
+	while ((pkt = vpx_codec_get_cx_data(ctx, iter)) != NULL) {
+		if (pkt->kind == VPX_CODEC_CX_FRAME_PKT) {
+			*buf = pkt->data.frame.buf;
+			*size = pkt->data.frame.sz;
+			*keyframe = (pkt->data.frame.flags & VPX_FRAME_IS_KEY) != 0;
+			return 1;
+		}
+	}
+	return 0;
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"unsafe"
+)
+
+// vp8Encoder encodes I420 images with libvpx, configured for real-time
+// streaming: constant bitrate, no lookahead, and error resilience, so that a
+// lost frame does not corrupt the frames after it.
+type vp8Encoder struct {
+	cfg    *C.vpx_codec_enc_cfg_t
+	ctx    *C.vpx_codec_ctx_t
+	height int
+	img    *C.vpx_image_t
+	width  int
+}
+
+// newVP8Encoder creates an encoder. The context and configuration are
+// allocated by C, since libvpx keeps a pointer to the configuration.
+func newVP8Encoder(width int, height int, frameRate int, bitrate int) (*vp8Encoder, error) {
+	encoder := &vp8Encoder{
+		cfg:    (*C.vpx_codec_enc_cfg_t)(C.calloc(1, C.sizeof_vpx_codec_enc_cfg_t)),
+		ctx:    (*C.vpx_codec_ctx_t)(C.calloc(1, C.sizeof_vpx_codec_ctx_t)),
+		height: height,
+		width:  width,
+	}
+
+	if err := C.vpx_codec_enc_config_default(C.vpx_codec_vp8_cx(), encoder.cfg, 0); err != C.VPX_CODEC_OK {
+		encoder.free()
+		return nil, fmt.Errorf("configure vp8 encoder: %w (error %d)", ErrCodecUnavailable, err)
+	}
+
+	encoder.cfg.g_w = C.uint(width)
+	encoder.cfg.g_h = C.uint(height)
+	encoder.cfg.g_timebase.num = 1
+	encoder.cfg.g_timebase.den = C.int(frameRate)
+	encoder.cfg.g_lag_in_frames = 0
+	encoder.cfg.g_error_resilient = C.VPX_ERROR_RESILIENT_DEFAULT
+	encoder.cfg.rc_end_usage = C.VPX_CBR
+	encoder.cfg.rc_target_bitrate = C.uint(bitrate / 1000)
+	encoder.cfg.kf_max_dist = C.uint(frameRate * 10)
+
+	if err := C.vp8_encoder_init(encoder.ctx, encoder.cfg); err != C.VPX_CODEC_OK {
+		encoder.free()
+		return nil, fmt.Errorf("initialise vp8 encoder: error %d", err)
+	}
+
+	encoder.img = C.vpx_img_alloc(nil, C.VPX_IMG_FMT_I420, C.uint(width), C.uint(height), 16)
+	if encoder.img == nil {
+		_ = C.vpx_codec_destroy(encoder.ctx)
+		encoder.free()
+		return nil, errors.New("initialise vp8 encoder: failed to allocate image")
+	}
+
+	return encoder, nil
+}
+
+// copyPlane copies one plane of an image into the encoder's image, which may
+// use a different stride.
+func (encoder *vp8Encoder) copyPlane(plane int, src []byte, srcStride int, width int, height int) {
+	stride := int(encoder.img.stride[plane])
+	dst := unsafe.Slice((*byte)(unsafe.Pointer(encoder.img.planes[plane])), stride*height)
+	for y := 0; y < height; y++ {
+		copy(dst[y*stride:y*stride+width], src[y*srcStride:y*srcStride+width])
+	}
+}
+
+// encode compresses an image, which must match the encoder's dimensions and
+// use 4:2:0 subsampling. The time base is the frame rate, so the presentation
+// timestamp is the frame number. The encoder may drop frames to meet its
+// bitrate, so an image can produce no frames at all.
+func (encoder *vp8Encoder) encode(img *image.YCbCr, frameNumber int64, forceKeyframe bool) ([]Frame, error) {
+	if img.Rect.Dx() != encoder.width || img.Rect.Dy() != encoder.height || img.SubsampleRatio != image.YCbCrSubsampleRatio420 {
+		return nil, errors.New("encode frame: image does not match encoder")
+	}
+
+	chromaWidth := (encoder.width + 1) / 2
+	chromaHeight := (encoder.height + 1) / 2
+	encoder.copyPlane(0, img.Y, img.YStride, encoder.width, encoder.height)
+	encoder.copyPlane(1, img.Cb, img.CStride, chromaWidth, chromaHeight)
+	encoder.copyPlane(2, img.Cr, img.CStride, chromaWidth, chromaHeight)
+
+	var flags C.vpx_enc_frame_flags_t
+	if forceKeyframe {
+		flags |= C.VPX_EFLAG_FORCE_KF
+	}
+
+	if err := C.vpx_codec_encode(encoder.ctx, encoder.img, C.vpx_codec_pts_t(frameNumber), 1, flags, C.VPX_DL_REALTIME); err != C.VPX_CODEC_OK {
+		return nil, fmt.Errorf("encode frame: %s", C.GoString(C.vpx_codec_error(encoder.ctx)))
+	}
+
+	var frames []Frame
+	var iter C.vpx_codec_iter_t
+	var buf unsafe.Pointer
+	var size C.size_t
+	var keyframe C.int
+	for C.vp8_encoder_next_frame(encoder.ctx, &iter, &buf, &size, &keyframe) != 0 {
+		frames = append(frames, Frame{
+			Data:     C.GoBytes(buf, C.int(size)),
+			Keyframe: keyframe != 0,
+		})
+	}
+
+	return frames, nil
+}
+
+func (encoder *vp8Encoder) free() {
+	if encoder.img != nil {
+		C.vpx_img_free(encoder.img)
+		encoder.img = nil
+	}
+	C.free(unsafe.Pointer(encoder.ctx))
+	C.free(unsafe.Pointer(encoder.cfg))
+}
+
+// close releases the encoder. It must not be used afterwards.
+func (encoder *vp8Encoder) close() error {
+	err := C.vpx_codec_destroy(encoder.ctx)
+	encoder.free()
+	if err != C.VPX_CODEC_OK {
+		return fmt.Errorf("destroy vp8 encoder: error %d", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package sender
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// IVF files start with a 32-byte header, which identifies the codec and the
+// time base of the frame timestamps. Each frame is preceded by a 12-byte
+// header containing its size and a 64-bit timestamp, in units of the time
+// base. The layout is described alongside the writer, in the session package.
+
+const (
+	ivfSignature         = "DKIF"
+	ivfFrameHeaderLength = 12
+	ivfMinHeaderLength   = 32
+
+	// maxIvfFrameSize rejects corrupt frame headers, rather than attempting to
+	// allocate an enormous frame.
+	maxIvfFrameSize = 16 << 20
+)
+
+// ivfReader reads VP8 frames from an IVF file.
+type ivfReader struct {
+	firstFrame bool
+	input      io.ReadCloser
+	origin     uint64
+	reader     *bufio.Reader
+	timebase   uint64
+	timescale  uint64
+}
+
+func newIvfReader(input io.ReadCloser) (*ivfReader, error) {
+	reader := bufio.NewReader(input)
+
+	header := make([]byte, ivfMinHeaderLength)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, fmt.Errorf("read ivf header: %w", err)
+	}
+	if string(header[:4]) != ivfSignature {
+		return nil, errors.New("read ivf header: missing DKIF signature")
+	}
+	if fourcc := string(header[8:12]); fourcc != "VP80" {
+		return nil, fmt.Errorf("read ivf header: unsupported codec %q", fourcc)
+	}
+
+	headerLength := int(binary.LittleEndian.Uint16(header[6:]))
+	if headerLength > ivfMinHeaderLength {
+		if _, err := reader.Discard(headerLength - ivfMinHeaderLength); err != nil {
+			return nil, fmt.Errorf("read ivf header: %w", err)
+		}
+	}
+
+	timescale := uint64(binary.LittleEndian.Uint32(header[16:]))
+	timebase := uint64(binary.LittleEndian.Uint32(header[20:]))
+	if timescale == 0 || timebase == 0 {
+		return nil, errors.New("read ivf header: invalid time base")
+	}
+
+	return &ivfReader{
+		firstFrame: true,
+		input:      input,
+		reader:     reader,
+		timebase:   timebase,
+		timescale:  timescale,
+	}, nil
+}
+
+// NextFrame reads the next frame. Timestamps are relative to the first frame,
+// since IVF files cut from a longer stream need not start at zero.
+func (reader *ivfReader) NextFrame() (Frame, error) {
+	header := make([]byte, ivfFrameHeaderLength)
+	if _, err := io.ReadFull(reader.reader, header); err != nil {
+		if errors.Is(err, io.EOF) {
+			return Frame{}, io.EOF
+		}
+		return Frame{}, fmt.Errorf("read ivf frame header: %w", err)
+	}
+
+	size := binary.LittleEndian.Uint32(header)
+	if size > maxIvfFrameSize {
+		return Frame{}, fmt.Errorf("read ivf frame: frame size %d is too large", size)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(reader.reader, data); err != nil {
+		return Frame{}, fmt.Errorf("read ivf frame: %w", err)
+	}
+
+	timestamp := binary.LittleEndian.Uint64(header[4:])
+	if reader.firstFrame {
+		reader.firstFrame = false
+		reader.origin = timestamp
+	}
+
+	return Frame{
+		Data:      data,
+		Keyframe:  vp8Keyframe(data),
+		Timestamp: reader.duration(timestamp - reader.origin),
+	}, nil
+}
+
+// duration converts a timestamp, in units of the time base, to a duration.
+func (reader *ivfReader) duration(ticks uint64) time.Duration {
+	seconds := ticks * reader.timebase / reader.timescale
+	remainder := ticks*reader.timebase - seconds*reader.timescale
+	return time.Duration(seconds)*time.Second + time.Duration(remainder)*time.Second/time.Duration(reader.timescale)
+}
+
+func (reader *ivfReader) Close() error {
+	return reader.input.Close()
+}
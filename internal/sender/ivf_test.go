@@ -0,0 +1,78 @@
+package sender
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+)
+
+func testIvfFile(fourcc string, timescale uint32, timebase uint32, timestamps ...uint64) []byte {
+	file := make([]byte, ivfMinHeaderLength)
+	copy(file, ivfSignature)
+	binary.LittleEndian.PutUint16(file[6:], ivfMinHeaderLength)
+	copy(file[8:], fourcc)
+	binary.LittleEndian.PutUint32(file[16:], timescale)
+	binary.LittleEndian.PutUint32(file[20:], timebase)
+
+	for _, timestamp := range timestamps {
+		header := make([]byte, ivfFrameHeaderLength)
+		binary.LittleEndian.PutUint32(header, 3)
+		binary.LittleEndian.PutUint64(header[4:], timestamp)
+		file = append(append(file, header...), 0x11, 0x00, 0x00)
+	}
+
+	return file
+}
+
+func TestIvfReaderUsesTimebaseRelativeToFirstFrame(t *testing.T) {
+	file := testIvfFile("VP80", 30, 1, 100, 101, 130)
+	reader, err := newIvfReader(io.NopCloser(bytes.NewReader(file)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []time.Duration{0, time.Second / 30, time.Second}
+	for _, timestamp := range want {
+		frame, err := reader.NextFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if frame.Timestamp != timestamp || frame.Keyframe {
+			t.Fatalf("read frame at %v (keyframe %t), want delta frame at %v", frame.Timestamp, frame.Keyframe, timestamp)
+		}
+	}
+
+	if _, err := reader.NextFrame(); err != io.EOF {
+		t.Fatalf("read past last frame returned %v, want EOF", err)
+	}
+}
+
+func TestIvfReaderRejectsInvalidFiles(t *testing.T) {
+	tests := map[string][]byte{
+		"codec":     testIvfFile("VP90", 30, 1),
+		"time base": testIvfFile("VP80", 0, 1),
+		"truncated": testIvfFile("VP80", 30, 1)[:20],
+	}
+
+	for name, file := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := newIvfReader(io.NopCloser(bytes.NewReader(file))); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestIvfReaderRejectsTruncatedFrames(t *testing.T) {
+	file := testIvfFile("VP80", 30, 1, 0)
+	reader, err := newIvfReader(io.NopCloser(bytes.NewReader(file[:len(file)-1])))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := reader.NextFrame(); err == nil || err == io.EOF {
+		t.Fatalf("reading a truncated frame returned %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package sender
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/common"
+	"github.com/tristanpenman/go-cast/internal/session"
+)
+
+const (
+	videoClockRate   = 90000
+	videoPayloadType = 96
+	videoStreamIndex = 0
+)
+
+// Messenger exchanges webrtc messages with a receiver application. It is
+// implemented by client.Sender.
+type Messenger interface {
+	SendAppMessage(namespace string, transportID string, payload string)
+	WaitForWebrtcAnswer(seqNum int, timeout time.Duration) (string, error)
+}
+
+// offerSeqNum numbers offers, so that each answer can be matched to its offer.
+var offerSeqNum atomic.Uint32
+
+type webrtcOfferMessage struct {
+	*session.WebrtcMessage
+
+	Offer session.Offer `json:"offer"`
+}
+
+type webrtcAnswerMessage struct {
+	*session.WebrtcMessage
+
+	Answer session.Answer `json:"answer"`
+	Result string         `json:"result"`
+}
+
+// StreamConfig describes a video stream that has been negotiated with a
+// receiver.
+type StreamConfig struct {
+	AESIVMask    []byte
+	AESKey       []byte
+	ClockRate    uint32
+	PayloadType  uint8
+	ReceiverSSRC uint32
+	SSRC         uint32
+	TargetDelay  time.Duration
+	UDPPort      int
+}
+
+func randomBytes(length int) ([]byte, error) {
+	data := make([]byte, length)
+	if _, err := rand.Read(data); err != nil {
+		return nil, fmt.Errorf("generate random bytes: %w", err)
+	}
+
+	return data, nil
+}
+
+// newStreamConfig generates a fresh SSRC, AES key and IV mask for a stream.
+// SSRCs are kept below 2^31, leaving room for the receiver's SSRC, which is
+// conventionally the next one along.
+func newStreamConfig(targetDelay time.Duration) (StreamConfig, error) {
+	ssrc, err := randomBytes(4)
+	if err != nil {
+		return StreamConfig{}, err
+	}
+	aesKey, err := randomBytes(16)
+	if err != nil {
+		return StreamConfig{}, err
+	}
+	aesIVMask, err := randomBytes(16)
+	if err != nil {
+		return StreamConfig{}, err
+	}
+
+	return StreamConfig{
+		AESIVMask:   aesIVMask,
+		AESKey:      aesKey,
+		ClockRate:   videoClockRate,
+		PayloadType: videoPayloadType,
+		SSRC:        binary.BigEndian.Uint32(ssrc)>>1 | 1,
+		TargetDelay: targetDelay,
+	}, nil
+}
+
+func (config *StreamConfig) offer() session.Offer {
+	return session.Offer{
+		CastMode:          "mirroring",
+		ReceiverGetStatus: true,
+		SupportedStreams: []session.SupportedStream{{
+			AesIvMask:      hex.EncodeToString(config.AESIVMask),
+			AesKey:         hex.EncodeToString(config.AESKey),
+			CodecName:      "vp8",
+			Index:          videoStreamIndex,
+			RtpPayloadType: int(config.PayloadType),
+			Ssrc:           config.SSRC,
+			TargetDelay:    int(config.TargetDelay.Milliseconds()),
+			TimeBase:       fmt.Sprintf("1/%d", config.ClockRate),
+			Type:           "video_source",
+		}},
+	}
+}
+
+// Negotiate offers a VP8 video stream to a mirroring application, which must
+// already be running on the receiver, and waits for its answer.
+func Negotiate(messenger Messenger, transportID string, targetDelay time.Duration, timeout time.Duration) (StreamConfig, error) {
+	config, err := newStreamConfig(targetDelay)
+	if err != nil {
+		return StreamConfig{}, fmt.Errorf("negotiate stream: %w", err)
+	}
+
+	seqNum := offerSeqNum.Add(1)
+	offer := webrtcOfferMessage{
+		WebrtcMessage: &session.WebrtcMessage{SeqNum: seqNum, Type: "OFFER"},
+		Offer:         config.offer(),
+	}
+	payload, err := json.Marshal(&offer)
+	if err != nil {
+		return StreamConfig{}, fmt.Errorf("negotiate stream: %w", err)
+	}
+
+	messenger.SendAppMessage(common.WebRTCNamespace, transportID, string(payload))
+	answerPayload, err := messenger.WaitForWebrtcAnswer(int(seqNum), timeout)
+	if err != nil {
+		return StreamConfig{}, fmt.Errorf("negotiate stream: %w", err)
+	}
+
+	var answer webrtcAnswerMessage
+	if err := json.Unmarshal([]byte(answerPayload), &answer); err != nil {
+		return StreamConfig{}, fmt.Errorf("negotiate stream: parse answer: %w", err)
+	}
+	if answer.Result != "ok" {
+		return StreamConfig{}, fmt.Errorf("negotiate stream: receiver answered with result %q", answer.Result)
+	}
+
+	streamPosition := slices.Index(answer.Answer.SendIndexes, videoStreamIndex)
+	if streamPosition < 0 || streamPosition >= len(answer.Answer.Ssrcs) {
+		return StreamConfig{}, errors.New("negotiate stream: receiver did not accept the video stream")
+	}
+	if answer.Answer.UdpPort < 1 || answer.Answer.UdpPort > 65535 {
+		return StreamConfig{}, fmt.Errorf("negotiate stream: receiver answered with invalid port %d", answer.Answer.UdpPort)
+	}
+
+	config.ReceiverSSRC = answer.Answer.Ssrcs[streamPosition]
+	config.UDPPort = answer.Answer.UdpPort
+	return config, nil
+}
@@ -0,0 +1,73 @@
+package sender
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/common"
+)
+
+// testMessenger answers offers with a canned answer.
+type testMessenger struct {
+	answer string
+	offer  webrtcOfferMessage
+}
+
+func (messenger *testMessenger) SendAppMessage(namespace string, _ string, payload string) {
+	if namespace == common.WebRTCNamespace {
+		_ = json.Unmarshal([]byte(payload), &messenger.offer)
+	}
+}
+
+func (messenger *testMessenger) WaitForWebrtcAnswer(seqNum int, _ time.Duration) (string, error) {
+	if messenger.offer.WebrtcMessage == nil || int(messenger.offer.SeqNum) != seqNum {
+		return "", errors.New("no offer with that sequence number")
+	}
+
+	return messenger.answer, nil
+}
+
+func TestNegotiateOffersEncryptedVP8Stream(t *testing.T) {
+	messenger := &testMessenger{
+		answer: `{"type":"ANSWER","result":"ok","answer":{"udpPort":50123,"sendIndexes":[0],"ssrcs":[43]}}`,
+	}
+
+	config, err := Negotiate(messenger, "transport-1", 100*time.Millisecond, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	streams := messenger.offer.Offer.SupportedStreams
+	if messenger.offer.Type != "OFFER" || len(streams) != 1 {
+		t.Fatalf("unexpected offer %+v", messenger.offer)
+	}
+	if streams[0].CodecName != "vp8" || streams[0].Ssrc != config.SSRC || streams[0].TargetDelay != 100 {
+		t.Fatalf("unexpected stream %+v", streams[0])
+	}
+	if len(streams[0].AesKey) != 32 || len(streams[0].AesIvMask) != 32 {
+		t.Fatalf("offer has key %q and iv mask %q, want 16 hex encoded bytes", streams[0].AesKey, streams[0].AesIvMask)
+	}
+	if config.ReceiverSSRC != 43 || config.UDPPort != 50123 {
+		t.Fatalf("negotiated %+v", config)
+	}
+}
+
+func TestNegotiateRejectsUnusableAnswers(t *testing.T) {
+	tests := map[string]string{
+		"error":     `{"type":"ANSWER","result":"error"}`,
+		"no stream": `{"type":"ANSWER","result":"ok","answer":{"udpPort":50123,"sendIndexes":[],"ssrcs":[]}}`,
+		"no port":   `{"type":"ANSWER","result":"ok","answer":{"sendIndexes":[0],"ssrcs":[43]}}`,
+	}
+
+	for name, answer := range tests {
+		t.Run(name, func(t *testing.T) {
+			messenger := &testMessenger{answer: answer}
+			if config, err := Negotiate(messenger, "transport-1", 0, time.Second); err == nil {
+				t.Fatalf("negotiated %+v, want error", config)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package sender
+
+import (
+	"encoding/binary"
+)
+
+// Each RTP packet carries part of one frame, following a Cast header that
+// identifies the frame and the packet's position within it. The header is
+// described alongside the parser, in the session package.
+
+const (
+	castHeaderLength = 6
+	rtpHeaderLength  = 12
+
+	// maxPacketSize keeps packets, along with their IP and UDP headers,
+	// within a typical 1500 byte MTU.
+	maxPacketSize = 1400
+
+	maxPayloadSize = maxPacketSize - rtpHeaderLength - castHeaderLength - 1
+)
+
+// packetize splits a frame into Cast RTP payloads. Delta frames reference the
+// frame before them, which is the only frame that they may depend on.
+func packetize(data []byte, frameID int64, keyframe bool) [][]byte {
+	packetCount := max(1, (len(data)+maxPayloadSize-1)/maxPayloadSize)
+	maxPacketID := uint16(packetCount - 1)
+
+	payloads := make([][]byte, 0, packetCount)
+	for packetID := 0; packetID < packetCount; packetID++ {
+		chunk := data[min(packetID*maxPayloadSize, len(data)):min((packetID+1)*maxPayloadSize, len(data))]
+
+		header := make([]byte, castHeaderLength, castHeaderLength+1+len(chunk))
+		if keyframe {
+			header[0] |= 0x80
+		}
+		header[1] = uint8(frameID)
+		binary.BigEndian.PutUint16(header[2:], uint16(packetID))
+		binary.BigEndian.PutUint16(header[4:], maxPacketID)
+		if !keyframe {
+			header[0] |= 0x40
+			header = append(header, uint8(frameID-1))
+		}
+
+		payloads = append(payloads, append(header, chunk...))
+	}
+
+	return payloads
+}
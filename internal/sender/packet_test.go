@@ -0,0 +1,49 @@
+package sender
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestPacketizeSplitsFrames(t *testing.T) {
+	data := bytes.Repeat([]byte{0x5a}, maxPayloadSize*2+1)
+	payloads := packetize(data, 257, false)
+
+	if len(payloads) != 3 {
+		t.Fatalf("frame split into %d packets, want 3", len(payloads))
+	}
+
+	var reassembled []byte
+	for packetID, payload := range payloads {
+		if payload[0] != 0x40 {
+			t.Fatalf("packet %d has flags 0x%02x, want a reference frame id", packetID, payload[0])
+		}
+		if payload[1] != 1 || payload[6] != 0 {
+			t.Fatalf("packet %d has frame id %d and reference %d, want 1 and 0", packetID, payload[1], payload[6])
+		}
+		if got := binary.BigEndian.Uint16(payload[2:]); got != uint16(packetID) {
+			t.Fatalf("packet %d has packet id %d", packetID, got)
+		}
+		if got := binary.BigEndian.Uint16(payload[4:]); got != 2 {
+			t.Fatalf("packet %d has max packet id %d, want 2", packetID, got)
+		}
+		if len(payload) > maxPacketSize-rtpHeaderLength {
+			t.Fatalf("packet %d is %d bytes", packetID, len(payload))
+		}
+		reassembled = append(reassembled, payload[castHeaderLength+1:]...)
+	}
+
+	if !bytes.Equal(reassembled, data) {
+		t.Fatal("reassembled frame does not match")
+	}
+}
+
+func TestPacketizeMarksKeyframes(t *testing.T) {
+	payloads := packetize(nil, 0, true)
+
+	want := []byte{0x80, 0, 0, 0, 0, 0}
+	if len(payloads) != 1 || !bytes.Equal(payloads[0], want) {
+		t.Fatalf("packetized empty keyframe as %x, want %x", payloads, want)
+	}
+}
@@ -0,0 +1,151 @@
+package sender
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// patternBars are the Y, Cb and Cr values of the colour bars in the test
+// pattern: white, yellow, cyan, green, magenta, red, blue and black.
+var patternBars = [][3]uint8{
+	{235, 128, 128},
+	{210, 16, 146},
+	{170, 166, 16},
+	{145, 54, 34},
+	{106, 202, 222},
+	{81, 90, 240},
+	{41, 240, 110},
+	{16, 128, 128},
+}
+
+// Pattern is a source of synthetic video: colour bars, with a box that moves
+// across them so that motion and dropped frames are easy to see. Frames are
+// encoded as they are requested, so the pattern can produce a keyframe
+// whenever the receiver asks for one.
+type Pattern struct {
+	duration          time.Duration
+	encoder           *vp8Encoder
+	frameCount        int64
+	frameRate         int
+	image             *image.YCbCr
+	keyframeRequested atomic.Bool
+	overlay           func(img *image.YCbCr, timestamp time.Duration)
+}
+
+// ErrCodecUnavailable is returned when libvpx cannot provide a VP8 encoder,
+// such as when it was built without one.
+var ErrCodecUnavailable = errors.New("vp8 encoder unavailable")
+
+// NewPattern creates a test pattern with the given dimensions and frame rate.
+// The pattern ends after duration, or continues indefinitely if duration is
+// zero.
+func NewPattern(width int, height int, frameRate int, bitrate int, duration time.Duration) (*Pattern, error) {
+	if width <= 0 || height <= 0 || width%2 != 0 || height%2 != 0 {
+		return nil, fmt.Errorf("create test pattern: invalid size %dx%d", width, height)
+	}
+	if frameRate <= 0 {
+		return nil, fmt.Errorf("create test pattern: invalid frame rate %d", frameRate)
+	}
+
+	encoder, err := newVP8Encoder(width, height, frameRate, bitrate)
+	if err != nil {
+		return nil, fmt.Errorf("create test pattern: %w", err)
+	}
+
+	return &Pattern{
+		duration:  duration,
+		encoder:   encoder,
+		frameRate: frameRate,
+		image:     image.NewYCbCr(image.Rect(0, 0, width, height), image.YCbCrSubsampleRatio420),
+	}, nil
+}
+
+// SetOverlay sets a function that draws over each frame of the pattern before
+// it is encoded, given the frame's timestamp. It must be called before the
+// first frame is read.
+func (pattern *Pattern) SetOverlay(overlay func(img *image.YCbCr, timestamp time.Duration)) {
+	pattern.overlay = overlay
+}
+
+func (pattern *Pattern) timestamp(frameCount int64) time.Duration {
+	return time.Duration(frameCount) * time.Second / time.Duration(pattern.frameRate)
+}
+
+// draw renders the pattern as it appears in the given frame.
+func (pattern *Pattern) draw(frameCount int64) {
+	img := pattern.image
+	width := img.Rect.Dx()
+	height := img.Rect.Dy()
+
+	boxSize := max(height/4, 2) &^ 1
+	boxX := int(frameCount*4) % max(width-boxSize, 1) &^ 1
+	boxY := (height - boxSize) / 2 &^ 1
+
+	colour := func(x int, y int) [3]uint8 {
+		if x >= boxX && x < boxX+boxSize && y >= boxY && y < boxY+boxSize {
+			return [3]uint8{128, 128, 128}
+		}
+		return patternBars[x*len(patternBars)/width]
+	}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Y[img.YOffset(x, y)] = colour(x, y)[0]
+		}
+	}
+	for y := 0; y < height; y += 2 {
+		for x := 0; x < width; x += 2 {
+			c := colour(x, y)
+			offset := img.COffset(x, y)
+			img.Cb[offset] = c[1]
+			img.Cr[offset] = c[2]
+		}
+	}
+
+	if pattern.overlay != nil {
+		pattern.overlay(img, pattern.timestamp(frameCount))
+	}
+}
+
+// NextFrame renders and encodes the next frame of the pattern.
+func (pattern *Pattern) NextFrame() (Frame, error) {
+	for {
+		timestamp := pattern.timestamp(pattern.frameCount)
+		if pattern.duration > 0 && timestamp >= pattern.duration {
+			return Frame{}, io.EOF
+		}
+
+		pattern.draw(pattern.frameCount)
+		forceKeyframe := pattern.frameCount == 0 || pattern.keyframeRequested.Swap(false)
+		frames, err := pattern.encoder.encode(pattern.image, pattern.frameCount, forceKeyframe)
+		pattern.frameCount++
+		if err != nil {
+			return Frame{}, err
+		}
+
+		// the encoder drops frames when it exceeds its bitrate
+		if len(frames) == 0 {
+			if forceKeyframe {
+				pattern.keyframeRequested.Store(true)
+			}
+			continue
+		}
+
+		frame := frames[0]
+		frame.Timestamp = timestamp
+		return frame, nil
+	}
+}
+
+// RequestKeyframe makes the next frame a keyframe.
+func (pattern *Pattern) RequestKeyframe() {
+	pattern.keyframeRequested.Store(true)
+}
+
+func (pattern *Pattern) Close() error {
+	return pattern.encoder.close()
+}
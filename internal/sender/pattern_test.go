@@ -0,0 +1,94 @@
+package sender
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestPatternBoxMoves(t *testing.T) {
+	pattern := &Pattern{image: image.NewYCbCr(image.Rect(0, 0, 64, 48), image.YCbCrSubsampleRatio420)}
+
+	pattern.draw(0)
+	first := bytes.Clone(pattern.image.Y)
+	pattern.draw(1)
+
+	if bytes.Equal(first, pattern.image.Y) {
+		t.Fatal("consecutive frames are identical")
+	}
+	if got := pattern.image.Y[pattern.image.YOffset(63, 0)]; got != patternBars[len(patternBars)-1][0] {
+		t.Fatalf("last bar has luma %d", got)
+	}
+}
+
+func TestPatternAppliesOverlay(t *testing.T) {
+	pattern := &Pattern{frameRate: 25, image: image.NewYCbCr(image.Rect(0, 0, 64, 48), image.YCbCrSubsampleRatio420)}
+
+	var timestamps []time.Duration
+	pattern.SetOverlay(func(img *image.YCbCr, timestamp time.Duration) {
+		img.Y[0] = 1
+		timestamps = append(timestamps, timestamp)
+	})
+	pattern.draw(0)
+	pattern.draw(3)
+
+	if len(timestamps) != 2 || timestamps[0] != 0 || timestamps[1] != 120*time.Millisecond {
+		t.Fatalf("overlay called with timestamps %v", timestamps)
+	}
+	if pattern.image.Y[0] != 1 {
+		t.Fatal("overlay was drawn under the pattern")
+	}
+}
+
+func TestNewPatternRejectsInvalidSizes(t *testing.T) {
+	if _, err := NewPattern(63, 48, 30, 500000, 0); err == nil {
+		t.Fatal("expected an error for an odd width")
+	}
+	if _, err := NewPattern(64, 48, 0, 500000, 0); err == nil {
+		t.Fatal("expected an error for a zero frame rate")
+	}
+}
+
+func TestPatternProducesRequestedKeyframes(t *testing.T) {
+	pattern, err := NewPattern(64, 48, 30, 500000, 200*time.Millisecond)
+	if errors.Is(err, ErrCodecUnavailable) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = pattern.Close()
+	}()
+
+	var frames []Frame
+	for {
+		frame, err := pattern.NextFrame()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(frames) == 2 {
+			pattern.RequestKeyframe()
+		}
+		frames = append(frames, frame)
+	}
+
+	if len(frames) != 6 {
+		t.Fatalf("pattern produced %d frames, want 6", len(frames))
+	}
+	for i, frame := range frames {
+		keyframe := i == 0 || i == 3
+		if frame.Keyframe != keyframe || vp8Keyframe(frame.Data) != keyframe {
+			t.Fatalf("frame %d has keyframe %t, want %t", i, frame.Keyframe, keyframe)
+		}
+		if frame.Timestamp != time.Duration(i)*time.Second/30 {
+			t.Fatalf("frame %d has timestamp %v", i, frame.Timestamp)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package sender
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Frame is a single compressed VP8 frame, along with the time at which it
+// should be presented, relative to the start of the stream.
+type Frame struct {
+	Data      []byte
+	Keyframe  bool
+	Timestamp time.Duration
+}
+
+// Source produces the frames that are streamed to a receiver. NextFrame
+// returns io.EOF once there are no frames left.
+type Source interface {
+	NextFrame() (Frame, error)
+	Close() error
+}
+
+// keyframeRequester is implemented by sources that can produce a keyframe on
+// demand, such as those backed by an encoder. Keyframe requests from the
+// receiver are ignored for other sources.
+type keyframeRequester interface {
+	RequestKeyframe()
+}
+
+// ErrUnsupportedFile is returned when a file is neither IVF nor WebM.
+var ErrUnsupportedFile = errors.New("unsupported file format")
+
+// OpenSource opens an IVF or WebM file containing VP8 video. The format is
+// identified from the start of the file, rather than its extension.
+func OpenSource(path string) (Source, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open source: %w", err)
+	}
+
+	signature := make([]byte, 4)
+	if _, err := io.ReadFull(file, signature); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+
+	var source Source
+	switch {
+	case bytes.Equal(signature, []byte(ivfSignature)):
+		source, err = newIvfReader(file)
+	case bytes.Equal(signature, webmSignature):
+		source, err = newWebmReader(file), nil
+	default:
+		err = ErrUnsupportedFile
+	}
+
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+
+	return source, nil
+}
+
+// vp8Keyframe reports whether a frame is a keyframe, using the frame type bit
+// of the VP8 frame tag, as described in RFC 6386, section 9.1.
+func vp8Keyframe(frame []byte) bool {
+	return len(frame) > 0 && frame[0]&0x01 == 0
+}
@@ -0,0 +1,151 @@
+package sender
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/session"
+)
+
+// testFrames builds a keyframe, with the uncompressed header of a VP8
+// keyframe, followed by delta frames, at 30 frames per second.
+func testFrames(count int) []Frame {
+	keyframe := []byte{0x10, 0x00, 0x00, 0x9d, 0x01, 0x2a, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint16(keyframe[6:], 320)
+	binary.LittleEndian.PutUint16(keyframe[8:], 240)
+
+	frames := []Frame{{Data: append(keyframe, bytes.Repeat([]byte{0xaa}, 2000)...), Keyframe: true}}
+	for i := 1; i < count; i++ {
+		frames = append(frames, Frame{
+			Data:      append([]byte{0x11, 0x00, 0x00}, bytes.Repeat([]byte{byte(i)}, 100+i)...),
+			Timestamp: time.Duration(i) * time.Second / 30,
+		})
+	}
+
+	return frames
+}
+
+// writeTestFile records frames to a file in the given format, interleaved with
+// audio when the format supports it.
+func writeTestFile(t *testing.T, format session.RecordingFormat, frames []Frame) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test."+string(format))
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var audio *session.AudioTrack
+	if format == session.WebMFormat {
+		audio = &session.AudioTrack{Channels: 2, ClockRate: 48000}
+	}
+
+	recorder, err := session.NewRecorder(file, format, videoClockRate, audio)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	for _, frame := range frames {
+		rtpTimestamp := uint32(frame.Timestamp * videoClockRate / time.Second)
+		if err := recorder.WriteVideoFrame(frame.Data, frame.Keyframe, rtpTimestamp, start.Add(frame.Timestamp)); err != nil {
+			t.Fatal(err)
+		}
+		audioTimestamp := uint32(frame.Timestamp * 48000 / time.Second)
+		if err := recorder.WriteAudioFrame([]byte{0xfc, 0xff, 0xfe}, audioTimestamp, start.Add(frame.Timestamp)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// sliceSource is a source of frames that are already in memory.
+type sliceSource struct {
+	frames []Frame
+}
+
+func (source *sliceSource) NextFrame() (Frame, error) {
+	if len(source.frames) == 0 {
+		return Frame{}, io.EOF
+	}
+
+	frame := source.frames[0]
+	source.frames = source.frames[1:]
+	return frame, nil
+}
+
+func (source *sliceSource) Close() error {
+	return nil
+}
+
+func readFrames(t *testing.T, source Source) []Frame {
+	t.Helper()
+
+	var frames []Frame
+	for {
+		frame, err := source.NextFrame()
+		if errors.Is(err, io.EOF) {
+			return frames
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		frames = append(frames, frame)
+	}
+}
+
+func TestOpenSourceReadsRecordings(t *testing.T) {
+	frames := testFrames(5)
+
+	for _, format := range []session.RecordingFormat{session.IVFFormat, session.WebMFormat} {
+		t.Run(string(format), func(t *testing.T) {
+			source, err := OpenSource(writeTestFile(t, format, frames))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				_ = source.Close()
+			}()
+
+			// webm timecodes count milliseconds
+			got := readFrames(t, source)
+			for i := range got {
+				got[i].Timestamp = got[i].Timestamp.Truncate(time.Millisecond)
+			}
+			want := make([]Frame, len(frames))
+			for i, frame := range frames {
+				want[i] = frame
+				want[i].Timestamp = frame.Timestamp.Truncate(time.Millisecond)
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("read %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestOpenSourceRejectsUnsupportedFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.y4m")
+	if err := os.WriteFile(path, []byte("YUV4MPEG2 W320 H240\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := OpenSource(path); !errors.Is(err, ErrUnsupportedFile) {
+		t.Fatalf("OpenSource returned %v, want %v", err, ErrUnsupportedFile)
+	}
+}
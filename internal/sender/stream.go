@@ -0,0 +1,491 @@
+package sender
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	// third-party
+	"github.com/hashicorp/go-hclog"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/session"
+)
+
+const (
+	// maxUnackedFrames is the number of frames that may be sent before the
+	// receiver acknowledges them. Each unacknowledged frame is kept so that
+	// its packets can be retransmitted.
+	maxUnackedFrames = 120
+
+	// ackTimeout is how long the stream waits for the receiver to
+	// acknowledge a frame, once it can send no more.
+	ackTimeout = 5 * time.Second
+
+	// senderReportInterval is how often sender reports are sent, so that the
+	// receiver can map RTP timestamps to the sender's clock.
+	senderReportInterval = 500 * time.Millisecond
+
+	// retransmitInterval is the minimum time between retransmissions of the
+	// same packet, which suppresses repeated requests for a packet that is
+	// already on its way.
+	retransmitInterval = 10 * time.Millisecond
+
+	ntpEpochOffset = 2208988800
+)
+
+// ErrStreamClosed is returned by Run when the stream is closed before its
+// source has been sent.
+var ErrStreamClosed = errors.New("stream closed")
+
+// sentFrame is a frame that has been sent, but not yet acknowledged.
+type sentFrame struct {
+	packets       [][]byte
+	retransmitted []time.Time
+}
+
+// StreamStats are the transmission counters for a stream.
+type StreamStats struct {
+	BytesSent          uint64
+	FramesAcked        uint64
+	FramesSent         uint64
+	KeyframesRequested uint64
+	PacketsSent        uint64
+	Retransmissions    uint64
+}
+
+// Stream sends frames to a receiver as a Cast Streaming video stream. Frames
+// are encrypted with the negotiated key, split into RTP packets and paced
+// according to their timestamps. Lost packets are retransmitted when the
+// receiver reports them, and keyframes are requested from the source when
+// the receiver asks for one.
+type Stream struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	ackedFrameID   int64
+	closed         bool
+	config         StreamConfig
+	conn           net.Conn
+	done           chan struct{}
+	encrypter      *session.Encrypter
+	frames         map[int64]*sentFrame
+	keyframeWanted bool
+	log            hclog.Logger
+	nextFrameID    int64
+	sequenceNumber uint16
+	startedAt      time.Time
+	stats          StreamStats
+	timingIndex    map[uint32]int
+	timings        []FrameTiming
+}
+
+// NewStream creates a stream that sends to the receiver at host, on the port
+// from its answer.
+func NewStream(config StreamConfig, host string, log hclog.Logger) (*Stream, error) {
+	conn, err := net.Dial("udp", net.JoinHostPort(host, strconv.Itoa(config.UDPPort)))
+	if err != nil {
+		return nil, fmt.Errorf("connect to receiver: %w", err)
+	}
+
+	stream, err := newStream(config, conn, log)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return stream, nil
+}
+
+func newStream(config StreamConfig, conn net.Conn, log hclog.Logger) (*Stream, error) {
+	encrypter, err := session.NewEncrypter(config.AESKey, config.AESIVMask)
+	if err != nil {
+		return nil, fmt.Errorf("create stream: %w", err)
+	}
+
+	stream := &Stream{
+		ackedFrameID: -1,
+		config:       config,
+		conn:         conn,
+		done:         make(chan struct{}),
+		encrypter:    encrypter,
+		frames:       make(map[int64]*sentFrame),
+		log:          log,
+	}
+	stream.cond = sync.NewCond(&stream.mu)
+
+	return stream, nil
+}
+
+func toNtpTime(t time.Time) uint64 {
+	seconds := uint64(t.Unix() + ntpEpochOffset)
+	fraction := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+	return seconds<<32 | fraction
+}
+
+// rtpTime converts a frame timestamp to the RTP clock.
+func (stream *Stream) rtpTime(timestamp time.Duration) uint32 {
+	seconds := int64(timestamp / time.Second)
+	remainder := int64(timestamp % time.Second)
+	clockRate := int64(stream.config.ClockRate)
+	return uint32(seconds*clockRate + remainder*clockRate/int64(time.Second))
+}
+
+// Run sends every frame from source, then waits for the receiver to
+// acknowledge them. Frames are sent no earlier than their timestamps, relative
+// to when Run was called.
+func (stream *Stream) Run(source Source) error {
+	stream.mu.Lock()
+	stream.startedAt = time.Now()
+	stream.mu.Unlock()
+
+	go stream.readRtcp()
+	go stream.sendReports()
+
+	for {
+		frame, err := source.NextFrame()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read frame: %w", err)
+		}
+
+		if err := stream.waitUntil(stream.startedAt.Add(frame.Timestamp)); err != nil {
+			return err
+		}
+		if err := stream.sendFrame(frame); err != nil {
+			return err
+		}
+
+		if requester, ok := source.(keyframeRequester); ok && stream.takeKeyframeRequest() {
+			stream.log.Info("requesting keyframe from source")
+			requester.RequestKeyframe()
+		}
+	}
+
+	return stream.waitForAcks(func() bool { return len(stream.frames) == 0 })
+}
+
+// waitUntil paces frames, returning early if the stream is closed.
+func (stream *Stream) waitUntil(at time.Time) error {
+	timer := time.NewTimer(time.Until(at))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-stream.done:
+		return ErrStreamClosed
+	}
+}
+
+// waitForAcks blocks until done reports true, which it is called with the
+// lock held to check.
+func (stream *Stream) waitForAcks(done func() bool) error {
+	deadline := time.Now().Add(ackTimeout)
+	timer := time.AfterFunc(ackTimeout, func() {
+		stream.mu.Lock()
+		stream.cond.Broadcast()
+		stream.mu.Unlock()
+	})
+	defer timer.Stop()
+
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+	for !done() {
+		if stream.closed {
+			return ErrStreamClosed
+		}
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("timed out waiting for receiver to acknowledge frame %d", stream.ackedFrameID+1)
+		}
+		stream.cond.Wait()
+	}
+
+	return nil
+}
+
+func (stream *Stream) takeKeyframeRequest() bool {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	wanted := stream.keyframeWanted
+	stream.keyframeWanted = false
+	return wanted
+}
+
+// sendFrame encrypts a frame and sends its packets, once there is room for
+// another unacknowledged frame.
+func (stream *Stream) sendFrame(frame Frame) error {
+	if err := stream.waitForAcks(func() bool { return len(stream.frames) < maxUnackedFrames }); err != nil {
+		return err
+	}
+
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	frameID := stream.nextFrameID
+	stream.nextFrameID++
+
+	ciphertext := make([]byte, len(frame.Data))
+	stream.encrypter.Reset(int(frameID))
+	stream.encrypter.Encrypt(frame.Data, ciphertext)
+
+	rtpTime := stream.rtpTime(frame.Timestamp)
+
+	payloads := packetize(ciphertext, frameID, frame.Keyframe)
+	sentAt := time.Now()
+	sent := &sentFrame{
+		packets:       make([][]byte, len(payloads)),
+		retransmitted: make([]time.Time, len(payloads)),
+	}
+	for packetID, payload := range payloads {
+		packet := rtp.Packet{
+			Header: rtp.Header{
+				Version:        2,
+				Marker:         packetID == len(payloads)-1,
+				PayloadType:    stream.config.PayloadType,
+				SequenceNumber: stream.sequenceNumber,
+				Timestamp:      rtpTime,
+				SSRC:           stream.config.SSRC,
+			},
+			Payload: payload,
+		}
+		stream.sequenceNumber++
+
+		datagram, err := packet.Marshal()
+		if err != nil {
+			return fmt.Errorf("send frame %d: %w", frameID, err)
+		}
+		sent.packets[packetID] = datagram
+		stream.writePacket(datagram)
+	}
+
+	stream.frames[frameID] = sent
+	stream.recordSent(frame, rtpTime, sentAt)
+	stream.stats.FramesSent++
+	stream.log.Debug("sent frame", "frameID", frameID, "keyframe", frame.Keyframe, "packets", len(payloads))
+
+	return nil
+}
+
+// writePacket sends a packet. Write errors, which are usually caused by the
+// receiver going away, are logged rather than returned, so that the stream
+// ends when acknowledgements stop arriving.
+func (stream *Stream) writePacket(datagram []byte) {
+	if _, err := stream.conn.Write(datagram); err != nil {
+		stream.log.Warn("failed to write packet", "err", err)
+		return
+	}
+
+	stream.stats.PacketsSent++
+	stream.stats.BytesSent += uint64(len(datagram))
+}
+
+// readRtcp handles feedback from the receiver until the stream is closed.
+func (stream *Stream) readRtcp() {
+	buffer := make([]byte, 1500)
+	for {
+		n, err := stream.conn.Read(buffer)
+		if err != nil {
+			select {
+			case <-stream.done:
+			default:
+				stream.log.Warn("failed to read feedback", "err", err)
+			}
+			return
+		}
+
+		stream.handleRtcp(buffer[:n], time.Now())
+	}
+}
+
+// handleRtcp processes a compound RTCP packet from the receiver. Cast
+// feedback is not understood by the rtcp package, so the packets are
+// separated using their headers.
+func (stream *Stream) handleRtcp(data []byte, now time.Time) {
+	for len(data) > 0 {
+		var header rtcp.Header
+		if err := header.Unmarshal(data); err != nil {
+			stream.log.Warn("failed to parse rtcp header", "err", err)
+			return
+		}
+
+		length := (int(header.Length) + 1) * 4
+		if length > len(data) {
+			stream.log.Warn("truncated rtcp packet", "length", length, "available", len(data))
+			return
+		}
+
+		packet := data[:length]
+		data = data[length:]
+
+		if header.Type == rtcp.TypeApplicationDefined {
+			var receiverLog session.ReceiverLog
+			if err := receiverLog.Unmarshal(packet, now); err != nil {
+				stream.log.Debug("skipping application packet", "err", err)
+				continue
+			}
+			stream.handleReceiverLog(&receiverLog)
+			continue
+		}
+		if header.Type != rtcp.TypePayloadSpecificFeedback {
+			continue
+		}
+
+		switch header.Count {
+		case rtcp.FormatPLI:
+			stream.handlePLI()
+		case rtcp.FormatREMB:
+			var feedback session.CastFeedback
+			if err := feedback.Unmarshal(packet); err != nil {
+				stream.log.Debug("skipping feedback", "err", err)
+				continue
+			}
+			stream.handleFeedback(&feedback, now)
+		}
+	}
+}
+
+func (stream *Stream) handlePLI() {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	stream.log.Info("receiver requested keyframe")
+	stream.keyframeWanted = true
+	stream.stats.KeyframesRequested++
+}
+
+// expandFrameID recovers a full frame ID from the truncated 8-bit value that
+// appears in feedback, choosing the candidate nearest to expected.
+func expandFrameID(truncated uint8, expected int64) int64 {
+	return expected + int64(int8(truncated-uint8(expected)))
+}
+
+// handleFeedback releases frames up to the receiver's checkpoint, and
+// retransmits the packets that it reports as lost.
+func (stream *Stream) handleFeedback(feedback *session.CastFeedback, now time.Time) {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	if feedback.SenderSSRC != stream.config.SSRC {
+		return
+	}
+
+	checkpointFrameID := expandFrameID(feedback.CkPtFrameId, stream.ackedFrameID+1)
+	if checkpointFrameID >= stream.nextFrameID {
+		stream.log.Warn("ignoring acknowledgement of unsent frame", "frameID", checkpointFrameID)
+	} else if checkpointFrameID > stream.ackedFrameID {
+		for frameID := stream.ackedFrameID + 1; frameID <= checkpointFrameID; frameID++ {
+			delete(stream.frames, frameID)
+			stream.stats.FramesAcked++
+		}
+		stream.ackedFrameID = checkpointFrameID
+		stream.cond.Broadcast()
+	}
+
+	for _, field := range feedback.LossFields {
+		frameID := expandFrameID(field.FrameId, stream.ackedFrameID+1)
+		frame := stream.frames[frameID]
+		if frame == nil {
+			continue
+		}
+
+		if field.PacketId == session.AllPacketsLost {
+			for packetID := range frame.packets {
+				stream.retransmit(frame, packetID, now)
+			}
+			continue
+		}
+
+		stream.retransmit(frame, int(field.PacketId), now)
+		for bit := 0; bit < 8; bit++ {
+			if field.Bitmask&(1<<bit) != 0 {
+				stream.retransmit(frame, int(field.PacketId)+bit+1, now)
+			}
+		}
+	}
+}
+
+func (stream *Stream) retransmit(frame *sentFrame, packetID int, now time.Time) {
+	if packetID >= len(frame.packets) || now.Sub(frame.retransmitted[packetID]) < retransmitInterval {
+		return
+	}
+
+	frame.retransmitted[packetID] = now
+	stream.stats.Retransmissions++
+	stream.writePacket(frame.packets[packetID])
+}
+
+// sendReports periodically sends sender reports, until the stream is closed.
+func (stream *Stream) sendReports() {
+	ticker := time.NewTicker(senderReportInterval)
+	defer ticker.Stop()
+
+	stream.sendSenderReport(time.Now())
+	for {
+		select {
+		case <-stream.done:
+			return
+		case now := <-ticker.C:
+			stream.sendSenderReport(now)
+		}
+	}
+}
+
+func (stream *Stream) sendSenderReport(now time.Time) {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	report := rtcp.SenderReport{
+		SSRC:        stream.config.SSRC,
+		NTPTime:     toNtpTime(now),
+		RTPTime:     stream.rtpTime(now.Sub(stream.startedAt)),
+		PacketCount: uint32(stream.stats.PacketsSent),
+		OctetCount:  uint32(stream.stats.BytesSent),
+	}
+
+	payload, err := report.Marshal()
+	if err != nil {
+		stream.log.Warn("failed to prepare sender report", "err", err)
+		return
+	}
+
+	if _, err := stream.conn.Write(payload); err != nil {
+		stream.log.Warn("failed to write sender report", "err", err)
+	}
+}
+
+// Stats returns a snapshot of the stream's counters.
+func (stream *Stream) Stats() StreamStats {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	return stream.stats
+}
+
+// Close stops the stream. Run returns ErrStreamClosed if it is still sending.
+func (stream *Stream) Close() error {
+	stream.mu.Lock()
+	if stream.closed {
+		stream.mu.Unlock()
+		return nil
+	}
+	stream.closed = true
+	close(stream.done)
+	stream.cond.Broadcast()
+	stream.mu.Unlock()
+
+	if err := stream.conn.Close(); err != nil {
+		return fmt.Errorf("close stream: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,277 @@
+package sender
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	// third-party
+	"github.com/hashicorp/go-hclog"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/channel"
+	"github.com/tristanpenman/go-cast/internal/media"
+	"github.com/tristanpenman/go-cast/internal/session"
+)
+
+// lossyConn drops the first transmission of the RTP packets with the given
+// sequence numbers, and records every packet that it sends.
+type lossyConn struct {
+	net.Conn
+
+	mu      sync.Mutex
+	drop    map[uint16]bool
+	written [][]byte
+}
+
+func (conn *lossyConn) Write(data []byte) (int, error) {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	var packet rtp.Packet
+	if data[1]&0x7f == videoPayloadType && packet.Unmarshal(data) == nil && conn.drop[packet.SequenceNumber] {
+		delete(conn.drop, packet.SequenceNumber)
+		return len(data), nil
+	}
+
+	conn.written = append(conn.written, append([]byte(nil), data...))
+	if conn.Conn == nil {
+		return len(data), nil
+	}
+	return conn.Conn.Write(data)
+}
+
+func (conn *lossyConn) Close() error {
+	if conn.Conn == nil {
+		return nil
+	}
+	return conn.Conn.Close()
+}
+
+func (conn *lossyConn) packets() [][]byte {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	return conn.written
+}
+
+func testStreamConfig() StreamConfig {
+	return StreamConfig{
+		AESIVMask:    []byte("fedcba9876543210"),
+		AESKey:       []byte("0123456789abcdef"),
+		ClockRate:    videoClockRate,
+		PayloadType:  videoPayloadType,
+		ReceiverSSRC: 1001,
+		SSRC:         1000,
+		TargetDelay:  20 * time.Millisecond,
+	}
+}
+
+func testFeedback(t *testing.T, feedback session.CastFeedback) []byte {
+	t.Helper()
+
+	pli, err := (&rtcp.PictureLossIndication{SenderSSRC: 1001, MediaSSRC: 1000}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cast, err := feedback.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return append(pli, cast...)
+}
+
+func TestStreamHandlesFeedback(t *testing.T) {
+	conn := &lossyConn{}
+	stream, err := newStream(testStreamConfig(), conn, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, frame := range testFrames(3) {
+		if err := stream.sendFrame(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sent := len(conn.packets())
+
+	stream.handleRtcp(testFeedback(t, session.CastFeedback{
+		ReceiverSSRC: 1001,
+		SenderSSRC:   1000,
+		CkPtFrameId:  0,
+		LossFields:   []session.CastLossField{{FrameId: 2, PacketId: session.AllPacketsLost}},
+	}), time.Now())
+
+	stats := stream.Stats()
+	if stats.FramesAcked != 1 || stats.KeyframesRequested != 1 || stats.Retransmissions != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if len(stream.frames) != 2 || stream.ackedFrameID != 0 {
+		t.Fatalf("%d frames unacknowledged after frame %d", len(stream.frames), stream.ackedFrameID)
+	}
+
+	packets := conn.packets()
+	if len(packets) != sent+1 || !bytes.Equal(packets[sent], packets[sent-1]) {
+		t.Fatal("expected the last frame to be retransmitted")
+	}
+}
+
+// loopbackReceiver connects a sender to a session in the same process, in
+// place of a Cast connection.
+type loopbackReceiver struct {
+	mu      sync.Mutex
+	answers []string
+	session *session.Session
+}
+
+func (receiver *loopbackReceiver) SendUTF8(_ string, payloadUtf8 *string, _ string, _ string) {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	receiver.answers = append(receiver.answers, *payloadUtf8)
+}
+
+func (receiver *loopbackReceiver) SendAppMessage(namespace string, transportID string, payload string) {
+	sourceID := "sender-0"
+	receiver.session.HandleCastMessage(&channel.CastMessage{
+		DestinationId: &transportID,
+		Namespace:     &namespace,
+		PayloadUtf8:   &payload,
+		SourceId:      &sourceID,
+	})
+}
+
+func (receiver *loopbackReceiver) WaitForWebrtcAnswer(seqNum int, _ time.Duration) (string, error) {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+
+	for _, answer := range receiver.answers {
+		var message session.WebrtcMessage
+		if json.Unmarshal([]byte(answer), &message) == nil && int(message.SeqNum) == seqNum {
+			return answer, nil
+		}
+	}
+
+	return "", errors.New("offer was not answered")
+}
+
+func TestStreamToLoopbackSession(t *testing.T) {
+	dir := t.TempDir()
+	receiver := &loopbackReceiver{}
+	listen := session.ListenConfig{Host: "127.0.0.1"}
+	recording := session.RecordingConfig{Dir: dir, Format: session.IVFFormat}
+	loopbackSession, err := session.NewSession("0F5096E8", session.CaptureConfig{}, 1, receiver, "Loopback", media.FanOut{}, listen, recording, "session-1", "pid-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiver.session = loopbackSession
+	loopbackSession.Start()
+
+	// the recording is only complete once the session stops
+	var stopOnce sync.Once
+	stopSession := func() {
+		stopOnce.Do(loopbackSession.Stop)
+	}
+	defer stopSession()
+
+	config, err := Negotiate(receiver, "pid-1", 20*time.Millisecond, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	udpConn, err := net.Dial("udp", net.JoinHostPort("127.0.0.1", strconv.Itoa(config.UDPPort)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// lose the second packet of the keyframe, and all of the fourth frame
+	conn := &lossyConn{Conn: udpConn, drop: map[uint16]bool{1: true, 4: true}}
+	stream, err := newStream(config, conn, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = stream.Close()
+	}()
+
+	stream.RecordTimings()
+
+	frames := testFrames(10)
+	if err := stream.Run(&sliceSource{frames: frames}); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := stream.Stats()
+	if stats.FramesAcked != uint64(len(frames)) || stats.Retransmissions < 2 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		streamStats := loopbackSession.StreamStats()
+		if len(streamStats) == 1 && streamStats[0].FramesDecoded == uint64(len(frames)) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("frames were not played out: %+v", streamStats)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// decoding is reported in the receiver log that follows the next sender
+	// report
+	for {
+		timings := stream.Timings()
+		reported := 0
+		for _, timing := range timings {
+			if !timing.DecodedAt.IsZero() {
+				reported++
+				if timing.ReceivedAt.After(timing.PlayedOutAt) || timing.PlayedOutAt.After(timing.DecodedAt) {
+					t.Fatalf("receiver events out of order %+v", timing)
+				}
+			}
+		}
+		if reported == len(frames) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("decoding was reported for %d of %d frames", reported, len(frames))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	stopSession()
+
+	recordings, err := filepath.Glob(filepath.Join(dir, "*.ivf"))
+	if err != nil || len(recordings) != 1 {
+		t.Fatalf("found recordings %v, err %v", recordings, err)
+	}
+	file, err := os.Open(recordings[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, err := newIvfReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = source.Close()
+	}()
+
+	recorded := readFrames(t, source)
+	if len(recorded) != len(frames) {
+		t.Fatalf("recorded %d frames, want %d", len(recorded), len(frames))
+	}
+	for i := range frames {
+		if !bytes.Equal(recorded[i].Data, frames[i].Data) {
+			t.Fatalf("recorded frame %d does not match", i)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package sender
+
+import (
+	"time"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/session"
+)
+
+// FrameTiming traces a frame from capture to decoding. The receiver's times
+// come from its event log, which has millisecond resolution and is only
+// comparable with the sender's times when both share a clock, as they do on
+// loopback. Times that the receiver has not reported are zero.
+type FrameTiming struct {
+	// CapturedAt is when the frame was due to be sent, according to its
+	// timestamp.
+	CapturedAt time.Time
+
+	// DecodedAt is when the receiver finished decoding the frame.
+	DecodedAt time.Time
+
+	// PlayedOutAt is when the frame left the receiver's jitter buffer.
+	PlayedOutAt time.Time
+
+	// ReceivedAt is when the last packet of the frame arrived, including
+	// any retransmissions.
+	ReceivedAt time.Time
+
+	// SentAt is when the frame was first sent.
+	SentAt    time.Time
+	Timestamp time.Duration
+}
+
+// RecordTimings makes the stream trace each frame that it sends. It must be
+// called before Run, and the traces are returned by Timings.
+func (stream *Stream) RecordTimings() {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	if stream.timingIndex == nil {
+		stream.timingIndex = make(map[uint32]int)
+	}
+}
+
+// Timings returns the traces of the frames sent so far, in the order that
+// they were sent.
+func (stream *Stream) Timings() []FrameTiming {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	timings := make([]FrameTiming, len(stream.timings))
+	copy(timings, stream.timings)
+	return timings
+}
+
+// recordSent starts the trace of a frame, if timings are being recorded.
+// Frames are identified by their RTP timestamps, as they are in receiver logs.
+func (stream *Stream) recordSent(frame Frame, rtpTime uint32, now time.Time) {
+	if stream.timingIndex == nil {
+		return
+	}
+
+	stream.timingIndex[rtpTime] = len(stream.timings)
+	stream.timings = append(stream.timings, FrameTiming{
+		CapturedAt: stream.startedAt.Add(frame.Timestamp),
+		SentAt:     now,
+		Timestamp:  frame.Timestamp,
+	})
+}
+
+// handleReceiverLog adds the receiver's events to the traces of the frames
+// that they describe.
+func (stream *Stream) handleReceiverLog(receiverLog *session.ReceiverLog) {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	if receiverLog.ReceiverSSRC != stream.config.ReceiverSSRC || stream.timingIndex == nil {
+		return
+	}
+
+	for _, event := range receiverLog.Events {
+		index, ok := stream.timingIndex[event.RTPTimestamp]
+		if !ok {
+			continue
+		}
+
+		timing := &stream.timings[index]
+		switch event.Type {
+		case session.PacketReceivedEvent:
+			if event.At.After(timing.ReceivedAt) {
+				timing.ReceivedAt = event.At
+			}
+		case session.FramePlayoutEvent:
+			timing.PlayedOutAt = event.At
+		case session.FrameDecodedEvent:
+			timing.DecodedAt = event.At
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package sender
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	// third-party
+	"github.com/hashicorp/go-hclog"
+	"github.com/pion/rtcp"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/session"
+)
+
+// testReceiverLog builds a receiver log describing a single frame, with
+// events given as type, millisecond offset from base and value.
+func testReceiverLog(t *testing.T, receiverSSRC uint32, rtpTimestamp uint32, base time.Time, events ...[3]uint16) []byte {
+	t.Helper()
+
+	body := make([]byte, 16, 16+len(events)*4)
+	binary.BigEndian.PutUint32(body, receiverSSRC)
+	copy(body[4:], "CAST")
+	binary.BigEndian.PutUint32(body[8:], rtpTimestamp)
+	binary.BigEndian.PutUint32(body[12:], uint32(base.UnixMilli())&0xffffff)
+	body[12] = uint8(len(events) - 1)
+	for _, event := range events {
+		body = binary.BigEndian.AppendUint16(body, event[2])
+		body = binary.BigEndian.AppendUint16(body, event[0]<<12|event[1])
+	}
+
+	header := rtcp.Header{Count: 2, Type: rtcp.TypeApplicationDefined, Length: uint16((4+len(body))/4 - 1)}
+	raw, err := header.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return append(raw, body...)
+}
+
+func TestStreamRecordsTimings(t *testing.T) {
+	conn := &lossyConn{}
+	stream, err := newStream(testStreamConfig(), conn, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream.RecordTimings()
+	stream.startedAt = time.Now()
+
+	frames := testFrames(2)
+	for _, frame := range frames {
+		if err := stream.sendFrame(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	base := time.UnixMilli(time.Now().UnixMilli())
+	rtpTime := stream.rtpTime(frames[1].Timestamp)
+	stream.handleRtcp(testReceiverLog(t, 1001, rtpTime, base,
+		[3]uint16{uint16(session.PacketReceivedEvent), 3, 1},
+		[3]uint16{uint16(session.PacketReceivedEvent), 1, 0},
+		[3]uint16{uint16(session.FramePlayoutEvent), 20, 0},
+		[3]uint16{uint16(session.FrameDecodedEvent), 24, 0},
+	), base)
+
+	// events from another receiver are ignored
+	stream.handleRtcp(testReceiverLog(t, 2001, stream.rtpTime(frames[0].Timestamp), base,
+		[3]uint16{uint16(session.FrameDecodedEvent), 1, 0},
+	), base)
+
+	timings := stream.Timings()
+	if len(timings) != 2 {
+		t.Fatalf("%d timings, want 2", len(timings))
+	}
+	if timings[0].Timestamp != frames[0].Timestamp || !timings[0].DecodedAt.IsZero() {
+		t.Fatalf("unexpected timing for first frame %+v", timings[0])
+	}
+
+	timing := timings[1]
+	if !timing.CapturedAt.Equal(stream.startedAt.Add(frames[1].Timestamp)) || timing.SentAt.IsZero() {
+		t.Fatalf("unexpected sender times %+v", timing)
+	}
+	if !timing.ReceivedAt.Equal(base.Add(3*time.Millisecond)) ||
+		!timing.PlayedOutAt.Equal(base.Add(20*time.Millisecond)) ||
+		!timing.DecodedAt.Equal(base.Add(24*time.Millisecond)) {
+		t.Fatalf("unexpected receiver times %+v", timing)
+	}
+}
+
+func TestStreamWithoutRecordedTimings(t *testing.T) {
+	stream, err := newStream(testStreamConfig(), &lossyConn{}, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := stream.sendFrame(testFrames(1)[0]); err != nil {
+		t.Fatal(err)
+	}
+	if timings := stream.Timings(); len(timings) != 0 {
+		t.Fatalf("recorded %d timings without being asked to", len(timings))
+	}
+}
@@ -0,0 +1,258 @@
+package sender
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"strings"
+	"time"
+)
+
+// WebM files are built from EBML elements, each of which is an ID, followed by
+// a variable length size and then its data. The reader walks the elements in
+// file order, descending into the few master elements that lead to the video
+// blocks and skipping everything else, so it also handles live streams whose
+// segment and clusters have an unknown size.
+
+const (
+	segmentID       = 0x18538067
+	infoID          = 0x1549a966
+	timecodeScaleID = 0x2ad7b1
+	tracksID        = 0x1654ae6b
+	trackEntryID    = 0xae
+	trackNumberID   = 0xd7
+	codecID         = 0x86
+	clusterID       = 0x1f43b675
+	timecodeID      = 0xe7
+	blockGroupID    = 0xa0
+	blockID         = 0xa1
+	simpleBlockID   = 0xa3
+
+	// defaultTimecodeScale makes block timecodes count milliseconds.
+	defaultTimecodeScale = 1000000
+
+	// maxWebmElementSize rejects corrupt element sizes, rather than
+	// attempting to allocate an enormous element.
+	maxWebmElementSize = 16 << 20
+
+	vp8CodecID = "V_VP8"
+)
+
+var webmSignature = []byte{0x1a, 0x45, 0xdf, 0xa3}
+
+// errUnknownSize is returned for an element of unknown size that cannot be
+// descended into.
+var errUnknownSize = errors.New("element has unknown size")
+
+type webmTrack struct {
+	codecID string
+	number  uint64
+}
+
+// webmReader reads the frames of the first VP8 video track in a WebM file.
+type webmReader struct {
+	clusterTimecode int64
+	firstFrame      bool
+	input           io.ReadCloser
+	origin          int64
+	reader          *bufio.Reader
+	timecodeScale   uint64
+	track           *webmTrack
+	videoTrack      uint64
+}
+
+func newWebmReader(input io.ReadCloser) *webmReader {
+	return &webmReader{
+		firstFrame:    true,
+		input:         input,
+		reader:        bufio.NewReader(input),
+		timecodeScale: defaultTimecodeScale,
+	}
+}
+
+// readVint reads a variable length integer. IDs keep their length marker,
+// while sizes do not. The second result reports whether every value bit is
+// set, which marks a size as unknown.
+func (reader *webmReader) readVint(keepMarker bool, maxLength int) (uint64, bool, error) {
+	first, err := reader.reader.ReadByte()
+	if err != nil {
+		return 0, false, err
+	}
+
+	length := bits.LeadingZeros8(first) + 1
+	if length > maxLength {
+		return 0, false, fmt.Errorf("invalid ebml length marker 0x%02x", first)
+	}
+
+	value := uint64(first)
+	if !keepMarker {
+		value &= 0xff >> length
+	}
+	allOnes := value == 0xff>>length
+
+	for i := 1; i < length; i++ {
+		next, err := reader.reader.ReadByte()
+		if err != nil {
+			return 0, false, io.ErrUnexpectedEOF
+		}
+		value = value<<8 | uint64(next)
+		allOnes = allOnes && next == 0xff
+	}
+
+	return value, allOnes, nil
+}
+
+func (reader *webmReader) readElementHeader() (uint32, uint64, bool, error) {
+	id, _, err := reader.readVint(true, 4)
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	size, unknown, err := reader.readVint(false, 8)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, 0, false, err
+	}
+
+	return uint32(id), size, unknown, nil
+}
+
+// NextFrame reads the next block in the video track. Timestamps are relative
+// to the first block.
+func (reader *webmReader) NextFrame() (Frame, error) {
+	for {
+		id, size, unknown, err := reader.readElementHeader()
+		if errors.Is(err, io.EOF) {
+			return Frame{}, io.EOF
+		}
+		if err != nil {
+			return Frame{}, fmt.Errorf("read webm element: %w", err)
+		}
+
+		switch id {
+		case segmentID, infoID, tracksID, clusterID, blockGroupID:
+			continue
+		case trackEntryID:
+			reader.track = &webmTrack{}
+			continue
+		}
+
+		if unknown {
+			return Frame{}, fmt.Errorf("read webm element 0x%x: %w", id, errUnknownSize)
+		}
+
+		switch id {
+		case timecodeScaleID, trackNumberID, codecID, timecodeID, simpleBlockID, blockID:
+		default:
+			if _, err := reader.reader.Discard(int(size)); err != nil {
+				return Frame{}, fmt.Errorf("skip webm element 0x%x: %w", id, err)
+			}
+			continue
+		}
+
+		if size > maxWebmElementSize {
+			return Frame{}, fmt.Errorf("read webm element 0x%x: size %d is too large", id, size)
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(reader.reader, data); err != nil {
+			return Frame{}, fmt.Errorf("read webm element 0x%x: %w", id, err)
+		}
+
+		frame, ok, err := reader.handleElement(id, data)
+		if err != nil {
+			return Frame{}, err
+		}
+		if ok {
+			return frame, nil
+		}
+	}
+}
+
+func (reader *webmReader) handleElement(id uint32, data []byte) (Frame, bool, error) {
+	switch id {
+	case timecodeScaleID:
+		reader.timecodeScale = ebmlUint(data)
+	case trackNumberID:
+		if reader.track != nil {
+			reader.track.number = ebmlUint(data)
+			reader.resolveVideoTrack()
+		}
+	case codecID:
+		if reader.track != nil {
+			reader.track.codecID = strings.TrimRight(string(data), "\x00")
+			reader.resolveVideoTrack()
+		}
+	case timecodeID:
+		reader.clusterTimecode = int64(ebmlUint(data))
+	case simpleBlockID, blockID:
+		return reader.parseBlock(data)
+	}
+
+	return Frame{}, false, nil
+}
+
+// resolveVideoTrack selects the current track entry, if it is the first VP8
+// video track.
+func (reader *webmReader) resolveVideoTrack() {
+	if reader.videoTrack == 0 && reader.track.number != 0 && reader.track.codecID == vp8CodecID {
+		reader.videoTrack = reader.track.number
+	}
+}
+
+// parseBlock returns the frame in a block, if the block belongs to the video
+// track. Blocks start with a track number, a timecode relative to the cluster
+// and a byte of flags.
+func (reader *webmReader) parseBlock(data []byte) (Frame, bool, error) {
+	if reader.videoTrack == 0 {
+		return Frame{}, false, errors.New("read webm block: file has no vp8 video track")
+	}
+
+	if len(data) == 0 {
+		return Frame{}, false, errors.New("read webm block: block is empty")
+	}
+	length := bits.LeadingZeros8(data[0]) + 1
+	if length > 8 || len(data) < length+3 {
+		return Frame{}, false, errors.New("read webm block: block is too short")
+	}
+
+	trackNumber := ebmlUint(data[:length]) &^ (1 << (7 * length))
+	if trackNumber != reader.videoTrack {
+		return Frame{}, false, nil
+	}
+
+	flags := data[length+2]
+	if flags&0x06 != 0 {
+		return Frame{}, false, errors.New("read webm block: laced blocks are not supported")
+	}
+
+	timecode := reader.clusterTimecode + int64(int16(binary.BigEndian.Uint16(data[length:])))
+	if reader.firstFrame {
+		reader.firstFrame = false
+		reader.origin = timecode
+	}
+
+	frame := data[length+3:]
+	return Frame{
+		Data:      frame,
+		Keyframe:  vp8Keyframe(frame),
+		Timestamp: time.Duration(timecode-reader.origin) * time.Duration(reader.timecodeScale),
+	}, true, nil
+}
+
+func (reader *webmReader) Close() error {
+	return reader.input.Close()
+}
+
+// ebmlUint decodes a big-endian unsigned integer element.
+func ebmlUint(data []byte) uint64 {
+	var value uint64
+	for _, b := range data {
+		value = value<<8 | uint64(b)
+	}
+	return value
+}
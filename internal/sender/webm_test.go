@@ -0,0 +1,93 @@
+package sender
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+// unknownSize is the one byte encoding of an unknown element size.
+const unknownSize = 0xff
+
+func testWebmElement(id []byte, data ...byte) []byte {
+	return append(append(id, 0x80|byte(len(data))), data...)
+}
+
+func testWebmFile(codec string, flags byte) []byte {
+	var file []byte
+	file = append(file, testWebmElement(webmSignature, 0x42, 0x82, 0x84, 'w', 'e', 'b', 'm')...)
+	file = append(file, 0x18, 0x53, 0x80, 0x67, unknownSize)
+
+	track := testWebmElement([]byte{0xd7}, 1)
+	track = append(track, testWebmElement([]byte{0x86}, []byte(codec)...)...)
+	file = append(file, testWebmElement([]byte{0x16, 0x54, 0xae, 0x6b}, testWebmElement([]byte{0xae}, track...)...)...)
+
+	// a cluster of unknown size, at 1000ms, with a block 40ms after it
+	file = append(file, 0x1f, 0x43, 0xb6, 0x75, unknownSize)
+	file = append(file, testWebmElement([]byte{0xe7}, 0x03, 0xe8)...)
+	file = append(file, testWebmElement([]byte{0xa3}, 0x81, 0x00, 0x00, flags, 0x10, 0x00, 0x00)...)
+	file = append(file, testWebmElement([]byte{0xa3}, 0x81, 0x00, 0x28, flags, 0x11, 0x00, 0x00)...)
+
+	return file
+}
+
+func TestWebmReaderHandlesUnknownSizes(t *testing.T) {
+	reader := newWebmReader(io.NopCloser(bytes.NewReader(testWebmFile(vp8CodecID, 0x80))))
+	frames := readFrames(t, reader)
+
+	if len(frames) != 2 {
+		t.Fatalf("read %d frames, want 2", len(frames))
+	}
+	if !frames[0].Keyframe || frames[0].Timestamp != 0 {
+		t.Fatalf("first frame is %+v, want a keyframe at 0", frames[0])
+	}
+	if frames[1].Keyframe || frames[1].Timestamp != 40*time.Millisecond {
+		t.Fatalf("second frame is %+v, want a delta frame at 40ms", frames[1])
+	}
+}
+
+func TestWebmReaderSkipsOtherTracks(t *testing.T) {
+	var file []byte
+	file = append(file, testWebmElement(webmSignature, 0x42, 0x82, 0x84, 'w', 'e', 'b', 'm')...)
+	file = append(file, 0x18, 0x53, 0x80, 0x67, unknownSize)
+
+	// an audio track comes before the video track
+	audio := testWebmElement([]byte{0xd7}, 1)
+	audio = append(audio, testWebmElement([]byte{0x86}, []byte("A_OPUS")...)...)
+	video := testWebmElement([]byte{0xd7}, 2)
+	video = append(video, testWebmElement([]byte{0x86}, []byte(vp8CodecID)...)...)
+	tracks := append(testWebmElement([]byte{0xae}, audio...), testWebmElement([]byte{0xae}, video...)...)
+	file = append(file, testWebmElement([]byte{0x16, 0x54, 0xae, 0x6b}, tracks...)...)
+
+	file = append(file, 0x1f, 0x43, 0xb6, 0x75, unknownSize)
+	file = append(file, testWebmElement([]byte{0xe7}, 0x00)...)
+	file = append(file, testWebmElement([]byte{0xa3}, 0x81, 0x00, 0x00, 0x80, 0xfc)...)
+	file = append(file, testWebmElement([]byte{0xa3}, 0x82, 0x00, 0x00, 0x80, 0x10, 0x00, 0x00)...)
+	file = append(file, testWebmElement([]byte{0xa3}, 0x81, 0x00, 0x14, 0x80, 0xfc)...)
+	file = append(file, testWebmElement([]byte{0xa3}, 0x82, 0x00, 0x28, 0x00, 0x11, 0x00, 0x00)...)
+
+	frames := readFrames(t, newWebmReader(io.NopCloser(bytes.NewReader(file))))
+	if len(frames) != 2 {
+		t.Fatalf("read %d frames, want the 2 video frames", len(frames))
+	}
+	if !frames[0].Keyframe || frames[1].Keyframe || frames[1].Timestamp != 40*time.Millisecond {
+		t.Fatalf("unexpected frames %+v", frames)
+	}
+}
+
+func TestWebmReaderRejectsUnsupportedFiles(t *testing.T) {
+	tests := map[string][]byte{
+		"codec":  testWebmFile("V_VP9", 0x80),
+		"lacing": testWebmFile(vp8CodecID, 0x82),
+	}
+
+	for name, file := range tests {
+		t.Run(name, func(t *testing.T) {
+			reader := newWebmReader(io.NopCloser(bytes.NewReader(file)))
+			if _, err := reader.NextFrame(); err == nil || err == io.EOF {
+				t.Fatalf("reading a block returned %v", err)
+			}
+		})
+	}
+}
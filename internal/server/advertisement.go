@@ -4,6 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
+	"net"
+	"strings"
 	"sync"
 
 	"github.com/brutella/dnssd"
@@ -27,39 +30,81 @@ func (advertisement *Advertisement) Stop() {
 	})
 }
 
-// NewAdvertisement starts advertising a Cast device over mDNS.
-func NewAdvertisement(device *Device, port int, interfaceNames []string) (*Advertisement, error) {
+// responder publishes a service over mDNS while Respond runs, and withdraws
+// it when ctx is cancelled. UpdateText replaces the service's TXT record, and
+// announces the change.
+type responder interface {
+	Respond(ctx context.Context) error
+	UpdateText(text map[string]string) error
+}
+
+// newResponder creates the responder that publishes service.
+type newResponder func(service dnssd.Service) (responder, error)
+
+// NewAdvertisement starts advertising a Cast device over mDNS. The
+// advertisement follows the device, so that its friendly name and the status
+// of its running application are kept up to date. If another service already
+// has the device's instance name, a number is appended to it.
+//
+// The device is advertised on the given interfaces, or on every multicast
+// interface if there are none, with A and AAAA records for the given IPv4 and
+// IPv6 addresses. Without any addresses, every address of each interface is
+// advertised.
+func NewAdvertisement(device *Device, port int, interfaceNames []string, ips []net.IP) (*Advertisement, error) {
+	return newAdvertisement(device, port, interfaceNames, ips, newDNSSDResponder)
+}
+
+func newAdvertisement(device *Device, port int, interfaceNames []string, ips []net.IP, newResponder newResponder) (*Advertisement, error) {
 	var log = common.NewLogger("advertisement")
 
 	log.Info("starting mdns...")
 
-	cfg := advertisementConfig(device, port, interfaceNames)
+	cfg := advertisementConfig(device, port, interfaceNames, ips)
 
 	service, err := dnssd.NewService(cfg)
 	if err != nil {
 		return nil, fmt.Errorf("create discovery service: %w", err)
 	}
 
-	responder, err := dnssd.NewResponder()
+	responder, err := newResponder(service)
 	if err != nil {
-		return nil, fmt.Errorf("create discovery responder: %w", err)
+		return nil, err
 	}
 
-	_, err = responder.Add(service)
-	if err != nil {
-		return nil, fmt.Errorf("add discovery service: %w", err)
-	}
+	log.Info("starting", "name", cfg.Name, "interfaces", interfaceNames, "ips", ips)
 
-	log.Info("starting", "interfaces", interfaceNames)
+	changes, stopWatching := device.watchChanges()
 
 	ctx, cancel := context.WithCancel(context.Background())
 	done := make(chan struct{})
+	responded := make(chan error, 1)
+	go func() {
+		responded <- responder.Respond(ctx)
+	}()
 	go func() {
 		defer cancel()
 		defer close(done)
-		err := responder.Respond(ctx)
-		if err != nil && !errors.Is(err, context.Canceled) {
-			log.Error("failed to start responder", "err", err)
+		defer stopWatching()
+
+		text := cfg.Text
+		for {
+			select {
+			case err := <-responded:
+				if err != nil && !errors.Is(err, context.Canceled) {
+					log.Error("failed to start responder", "err", err)
+				}
+				return
+			case <-changes:
+				updated := advertisementText(device)
+				if maps.Equal(updated, text) {
+					continue
+				}
+				text = updated
+				log.Info("updating", "name", text["fn"], "status", text["rs"])
+				if err := responder.UpdateText(text); err != nil {
+					log.Warn("failed to update advertisement", "err", err)
+				}
+			}
 		}
 	}()
 
@@ -70,8 +115,56 @@ func NewAdvertisement(device *Device, port int, interfaceNames []string) (*Adver
 	}, nil
 }
 
-func advertisementConfig(device *Device, port int, interfaceNames []string) dnssd.Config {
-	info := map[string]string{
+// dnssdResponder is a dnssd.Responder, which updates the service it publishes
+// through the service's handle.
+type dnssdResponder struct {
+	dnssd.Responder
+	handle dnssd.ServiceHandle
+}
+
+func (responder *dnssdResponder) UpdateText(text map[string]string) error {
+	responder.handle.UpdateText(text, responder.Responder)
+	return nil
+}
+
+// newDNSSDResponder publishes service on the host's multicast interfaces. The
+// responder probes for the service's name before announcing it, and renames
+// the service if the name is taken.
+func newDNSSDResponder(service dnssd.Service) (responder, error) {
+	published, err := dnssd.NewResponder()
+	if err != nil {
+		return nil, fmt.Errorf("create discovery responder: %w", err)
+	}
+
+	handle, err := published.Add(service)
+	if err != nil {
+		return nil, fmt.Errorf("add discovery service: %w", err)
+	}
+
+	return &dnssdResponder{Responder: published, handle: handle}, nil
+}
+
+// maxInstanceName is the longest label that a DNS name can contain.
+const maxInstanceName = 63
+
+// instanceName returns a service instance name that is unique to the device,
+// in the form that Chromecasts use, such as "Chromecast-Ultra-<id>".
+func instanceName(model string, id string) string {
+	id = strings.ReplaceAll(id, "-", "")
+	model = strings.Join(strings.Fields(model), "-")
+	if model == "" {
+		return id
+	}
+	if len(model)+1+len(id) > maxInstanceName {
+		model = model[:max(0, maxInstanceName-1-len(id))]
+	}
+	return strings.TrimSuffix(model, "-") + "-" + id
+}
+
+// advertisementText returns the TXT record for the device as it is now. While
+// an application is running, st is set, and rs is the application's status.
+func advertisementText(device *Device) map[string]string {
+	text := map[string]string{
 		"ve": "02",
 		"st": "0",
 		"nf": "1",
@@ -79,16 +172,29 @@ func advertisementConfig(device *Device, port int, interfaceNames []string) dnss
 		"ic": "/setup/icon.png",
 		"md": device.DeviceModel,
 		"id": device.Id,
-		"fn": device.FriendlyName,
+		"fn": device.friendlyName(),
+	}
+
+	if sessions := device.ActiveSessions(); len(sessions) > 0 {
+		text["st"] = "1"
+		text["rs"] = sessions[0].StatusText
+		if text["rs"] == "" {
+			text["rs"] = sessions[0].DisplayName
+		}
 	}
 
+	return text
+}
+
+func advertisementConfig(device *Device, port int, interfaceNames []string, ips []net.IP) dnssd.Config {
 	return dnssd.Config{
-		Name:   "GoCast",
+		Name:   instanceName(device.DeviceModel, device.Id),
 		Type:   "_googlecast._tcp",
 		Domain: "local",
 		Host:   "",
+		IPs:    append([]net.IP(nil), ips...),
 		Port:   port,
-		Text:   info,
+		Text:   advertisementText(device),
 		Ifaces: append([]string(nil), interfaceNames...),
 	}
 }
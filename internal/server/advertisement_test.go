@@ -2,10 +2,18 @@ package server
 
 import (
 	"context"
+	"net"
 	"testing"
 	"time"
 
+	"github.com/brutella/dnssd"
 	"github.com/hashicorp/go-hclog"
+	"github.com/miekg/dns"
+
+	"github.com/tristanpenman/go-cast/internal/discovery"
+	"github.com/tristanpenman/go-cast/internal/mdnsnet"
+	"github.com/tristanpenman/go-cast/internal/media"
+	"github.com/tristanpenman/go-cast/internal/session"
 )
 
 func TestAdvertisementStopWaitsForResponder(t *testing.T) {
@@ -36,17 +44,216 @@ func TestAdvertisementStopWaitsForResponder(t *testing.T) {
 	advertisement.Stop()
 }
 
+func TestAdvertisementConfigUsesUniqueInstanceName(t *testing.T) {
+	tests := []struct {
+		model string
+		id    string
+		want  string
+	}{
+		{"go-cast", "3d1f6a2e-8b4c-4e5a-9f7d-2c1b0a9e8d7f", "go-cast-3d1f6a2e8b4c4e5a9f7d2c1b0a9e8d7f"},
+		{"Chromecast Ultra", "8d8ea6ac7c0b0b9a1c9cf4f7e4e7ff3e", "Chromecast-Ultra-8d8ea6ac7c0b0b9a1c9cf4f7e4e7ff3e"},
+		{"", "device-id", "deviceid"},
+		{"A Very Long Model Name That Does Not Fit", "3d1f6a2e8b4c4e5a9f7d2c1b0a9e8d7f", "A-Very-Long-Model-Name-That-Do-3d1f6a2e8b4c4e5a9f7d2c1b0a9e8d7f"},
+	}
+	for _, test := range tests {
+		config := advertisementConfig(&Device{DeviceModel: test.model, Id: test.id}, 8009, nil, nil)
+		if config.Name != test.want {
+			t.Errorf("instance name for %q, %q is %q, want %q", test.model, test.id, config.Name, test.want)
+		}
+		if len(config.Name) > maxInstanceName {
+			t.Errorf("instance name %q is longer than a DNS label", config.Name)
+		}
+	}
+}
+
 func TestAdvertisementConfigUsesListenerInterfaces(t *testing.T) {
 	device := &Device{DeviceModel: "go-cast", FriendlyName: "Living Room", Id: "device-id"}
 	interfaces := []string{"en0"}
-	config := advertisementConfig(device, 8009, interfaces)
+	ips := []net.IP{net.ParseIP("192.0.2.10"), net.ParseIP("2001:db8::10")}
+	config := advertisementConfig(device, 8009, interfaces, ips)
 
 	if len(config.Ifaces) != 1 || config.Ifaces[0] != "en0" {
 		t.Fatalf("unexpected advertisement interfaces: %v", config.Ifaces)
 	}
+	if len(config.IPs) != 2 || !config.IPs[0].Equal(ips[0]) || !config.IPs[1].Equal(ips[1]) {
+		t.Fatalf("unexpected advertisement addresses: %v", config.IPs)
+	}
 
 	interfaces[0] = "utun0"
-	if config.Ifaces[0] != "en0" {
-		t.Fatal("advertisement config retained caller's mutable interface slice")
+	ips[0] = net.ParseIP("192.0.2.11")
+	if config.Ifaces[0] != "en0" || !config.IPs[0].Equal(net.ParseIP("192.0.2.10")) {
+		t.Fatal("advertisement config retained caller's mutable slices")
+	}
+}
+
+func TestAdvertisementPublishesIPv4AndIPv6Addresses(t *testing.T) {
+	network := mdnsnet.NewNetwork()
+	sniffer := network.Join()
+	t.Cleanup(func() { _ = sniffer.Close() })
+
+	device := &Device{DeviceModel: "go-cast", FriendlyName: "Living Room", Id: "device-id"}
+	advertiseOn(t, network, device, 8009, net.ParseIP("192.0.2.10"), net.ParseIP("2001:db8::10"))
+
+	// the probe comes first, and then the announcement
+	buffer := make([]byte, 9000)
+	for {
+		n, _, err := sniffer.Read(buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg := new(dns.Msg)
+		if err := msg.Unpack(buffer[:n]); err != nil {
+			t.Fatal(err)
+		}
+		if !msg.Response {
+			continue
+		}
+
+		var addresses []string
+		for _, record := range msg.Extra {
+			switch record := record.(type) {
+			case *dns.A:
+				addresses = append(addresses, record.A.String())
+			case *dns.AAAA:
+				addresses = append(addresses, record.AAAA.String())
+			}
+		}
+		if len(addresses) != 2 || addresses[0] != "192.0.2.10" || addresses[1] != "2001:db8::10" {
+			t.Fatalf("announced addresses %v, want 192.0.2.10 and 2001:db8::10", addresses)
+		}
+		return
+	}
+}
+
+// advertiseOn starts advertising device on an in-memory network, from ips, or
+// from 192.0.2.10 if there are none.
+func advertiseOn(t *testing.T, network *mdnsnet.Network, device *Device, port int, ips ...net.IP) *Advertisement {
+	t.Helper()
+
+	if len(ips) == 0 {
+		ips = []net.IP{net.ParseIP("192.0.2.10")}
+	}
+	advertisement, err := newAdvertisement(device, port, nil, ips, func(service dnssd.Service) (responder, error) {
+		return mdnsnet.NewResponder(network.Join(), service), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(advertisement.Stop)
+	return advertisement
+}
+
+// watchNetwork browses network, and returns a function that waits for the
+// next event, which must be of the given type.
+func watchNetwork(t *testing.T, network *mdnsnet.Network) func(want discovery.EventType) discovery.Event {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	events, err := discovery.Watch(ctx, discovery.Options{Listen: func() ([]mdnsnet.Conn, error) {
+		return []mdnsnet.Conn{network.Join()}, nil
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return func(want discovery.EventType) discovery.Event {
+		t.Helper()
+		select {
+		case event := <-events:
+			if event.Type != want {
+				t.Fatalf("got event %+v, want %v", event, want)
+			}
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no %v event", want)
+			return discovery.Event{}
+		}
+	}
+}
+
+func TestAdvertisementIsDiscovered(t *testing.T) {
+	network := mdnsnet.NewNetwork()
+	watched := watchNetwork(t, network)
+	next := func(want discovery.EventType) discovery.Device {
+		t.Helper()
+		return watched(want).Device
+	}
+
+	device := &Device{DeviceModel: "go-cast", FriendlyName: "Living Room", Id: "device-id"}
+	advertisement := advertiseOn(t, network, device, 8009)
+
+	got := next(discovery.Added)
+	want := discovery.Device{
+		ID: "device-id", Name: "Living Room", Model: "go-cast",
+		Host: "192.0.2.10", Port: 8009, ConnectionState: "discovered",
+		Capabilities: discovery.Capabilities(4101), IconPath: "/setup/icon.png", ProtocolVersion: 2,
+	}
+	if got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	// stopping sends a goodbye, and a renamed device is advertised afresh
+	advertisement.Stop()
+	if got := next(discovery.Removed); got.Name != "Living Room" {
+		t.Fatalf("unexpected removed device %+v", got)
+	}
+
+	device.FriendlyName = "Kitchen"
+	advertiseOn(t, network, device, 8009)
+	if got := next(discovery.Added); got.Name != "Kitchen" || got.ID != "device-id" {
+		t.Fatalf("unexpected renamed device %+v", got)
+	}
+}
+
+func TestAdvertisementFollowsDevice(t *testing.T) {
+	network := mdnsnet.NewNetwork()
+	next := watchNetwork(t, network)
+
+	device := NewDevice(session.CaptureConfig{}, "go-cast", media.FanOut{}, "Living Room", "device-id", session.RecordingConfig{}, session.PortRange{}, "udn")
+	advertiseOn(t, network, device, 8009)
+	if got := next(discovery.Added).Device; got.Busy || got.StatusText != "" {
+		t.Fatalf("idle device advertised as %+v", got)
+	}
+
+	device.SetFriendlyName("Kitchen")
+	if got := next(discovery.Updated).Device; got.Name != "Kitchen" {
+		t.Fatalf("renamed device advertised as %+v", got)
+	}
+
+	if err := device.startApplication(chromeMirroringAppId, 0, "127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	sessions := device.ActiveSessions()
+	if got := next(discovery.Updated).Device; !got.Busy || got.StatusText != "Chrome Mirroring" {
+		t.Fatalf("busy device advertised as %+v", got)
+	}
+
+	if err := device.stopApplication(sessions[0].SessionId); err != nil {
+		t.Fatal(err)
+	}
+	if got := next(discovery.Updated).Device; got.Busy || got.StatusText != "" {
+		t.Fatalf("device advertised as %+v after its app stopped", got)
+	}
+}
+
+func TestAdvertisementResolvesInstanceNameConflicts(t *testing.T) {
+	network := mdnsnet.NewNetwork()
+	next := watchNetwork(t, network)
+
+	// receivers that share a device ID also share an instance name, so the
+	// second is renamed
+	device := &Device{DeviceModel: "go-cast", FriendlyName: "Living Room", Id: "device-id"}
+	advertiseOn(t, network, device, 8009)
+	first := next(discovery.Added)
+	advertiseOn(t, network, device, 8010)
+	second := next(discovery.Added)
+
+	firstName, secondName := mdnsnet.Unescape(first.Instance), mdnsnet.Unescape(second.Instance)
+	if firstName != "go-cast-deviceid._googlecast._tcp.local." || secondName != "go-cast-deviceid 2._googlecast._tcp.local." {
+		t.Fatalf("unexpected instances %q and %q", firstName, secondName)
+	}
+	if second.Device.Port != 8010 {
+		t.Fatalf("renamed instance advertised as %+v", second.Device)
 	}
 }
@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"encoding/pem"
 	"fmt"
+	"maps"
 	"net"
+	"sync"
 
 	// third-party
 	"github.com/hashicorp/go-hclog"
@@ -17,12 +19,66 @@ import (
 	"github.com/tristanpenman/go-cast/internal/transport"
 )
 
+// ConnectionStats counts the Cast messages sent and received on a client
+// connection, by namespace.
+type ConnectionStats struct {
+	ID               int
+	MessagesReceived map[string]uint64
+	MessagesSent     map[string]uint64
+	RemoteAddr       string
+}
+
 type ClientConnection struct {
-	castChannel transport.CastChannel
-	conn        net.Conn
-	device      *Device
-	id          int
-	log         hclog.Logger
+	mu sync.Mutex
+
+	// sendMu keeps messages that are sent from different goroutines, such as
+	// status updates for DIAL launches, from interleaving on the connection
+	sendMu sync.Mutex
+
+	castChannel      transport.CastChannel
+	conn             net.Conn
+	device           *Device
+	done             chan struct{}
+	id               int
+	log              hclog.Logger
+	messagesReceived map[string]uint64
+	messagesSent     map[string]uint64
+}
+
+func (clientConnection *ClientConnection) countMessage(counts map[string]uint64, namespace string) {
+	clientConnection.mu.Lock()
+	defer clientConnection.mu.Unlock()
+
+	counts[namespace]++
+}
+
+// closed returns a channel that is closed once the sender has disconnected,
+// and the connection has been unsubscribed from the device's transports.
+func (clientConnection *ClientConnection) closed() <-chan struct{} {
+	return clientConnection.done
+}
+
+// isClosed reports whether the sender has disconnected.
+func (clientConnection *ClientConnection) isClosed() bool {
+	select {
+	case <-clientConnection.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Stats returns a copy of the connection's message counts.
+func (clientConnection *ClientConnection) Stats() ConnectionStats {
+	clientConnection.mu.Lock()
+	defer clientConnection.mu.Unlock()
+
+	return ConnectionStats{
+		ID:               clientConnection.id,
+		MessagesReceived: maps.Clone(clientConnection.messagesReceived),
+		MessagesSent:     maps.Clone(clientConnection.messagesSent),
+		RemoteAddr:       clientConnection.conn.RemoteAddr().String(),
+	}
 }
 
 func (clientConnection *ClientConnection) sendBinary(namespace string, payloadBinary []byte, sourceId string, destinationId string) {
@@ -47,7 +103,7 @@ func (clientConnection *ClientConnection) sendBinary(namespace string, payloadBi
 			"payloadType", "BINARY")
 	}
 
-	clientConnection.castChannel.Send(&castMessage)
+	clientConnection.send(namespace, &castMessage)
 }
 
 func (clientConnection *ClientConnection) sendUtf8(namespace string, payloadUtf8 *string, sourceId string, destinationId string) {
@@ -73,7 +129,16 @@ func (clientConnection *ClientConnection) sendUtf8(namespace string, payloadUtf8
 			"payloadUtf8", *castMessage.PayloadUtf8)
 	}
 
-	clientConnection.castChannel.Send(&castMessage)
+	clientConnection.send(namespace, &castMessage)
+}
+
+func (clientConnection *ClientConnection) send(namespace string, castMessage *channel.CastMessage) {
+	clientConnection.countMessage(clientConnection.messagesSent, namespace)
+
+	clientConnection.sendMu.Lock()
+	defer clientConnection.sendMu.Unlock()
+
+	clientConnection.castChannel.Send(castMessage)
 }
 
 type connectRequest struct {
@@ -154,31 +219,40 @@ func NewClientConnection(
 	conn net.Conn,
 	id int,
 	manifest map[string]string,
+	sessionHost string,
 ) *ClientConnection {
 	log := common.NewLogger(fmt.Sprintf("client-connection (%d)", id))
 
 	castChannel := transport.NewCastChannel(conn, log)
 
 	clientConnection := ClientConnection{
-		castChannel: castChannel,
-		conn:        conn,
-		device:      device,
-		id:          id,
-		log:         log,
+		castChannel:      castChannel,
+		conn:             conn,
+		device:           device,
+		done:             make(chan struct{}),
+		id:               id,
+		log:              log,
+		messagesReceived: make(map[string]uint64),
+		messagesSent:     make(map[string]uint64),
 	}
 
-	receiver := NewReceiver(device, "receiver-0", clientConnection.id)
+	receiver := NewReceiver(device, receiverTransportId, clientConnection.id, sessionHost)
 	device.registerTransport(receiver)
-	device.registerSubscription(&clientConnection, "sender-0", "receiver-0")
+	device.registerSubscription(&clientConnection, "sender-0", receiverTransportId)
 
 	go func() {
 		defer func() {
 			_ = conn.Close()
 			log.Info("connection closed")
+
+			device.unregisterSubscriptions(&clientConnection)
+			close(clientConnection.done)
 		}()
 
 		for castMessage := range castChannel.Messages {
 			if castMessage != nil {
+				clientConnection.countMessage(clientConnection.messagesReceived, *castMessage.Namespace)
+
 				if log.IsDebug() {
 					log.Debug("received", "message", castMessage.String())
 				} else if *castMessage.PayloadType == channel.CastMessage_BINARY {
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	// third-party
+	"google.golang.org/protobuf/proto"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/channel"
+	"github.com/tristanpenman/go-cast/internal/common"
+	"github.com/tristanpenman/go-cast/internal/media"
+	"github.com/tristanpenman/go-cast/internal/session"
+)
+
+func writeCastMessage(t *testing.T, conn net.Conn, namespace string, payloadUtf8 string) {
+	if _, err := conn.Write(castMessageFrame(t, namespace, payloadUtf8)); err != nil {
+		t.Fatalf("write cast message: %v", err)
+	}
+}
+
+// castMessageFrame encodes a message from sender-0 to receiver-0, with its
+// length prefix.
+func castMessageFrame(t *testing.T, namespace string, payloadUtf8 string) []byte {
+	payloadType := channel.CastMessage_STRING
+	protocolVersion := channel.CastMessage_CASTV2_1_0
+	sourceId := "sender-0"
+	destinationId := "receiver-0"
+
+	data, err := proto.Marshal(&channel.CastMessage{
+		DestinationId:   &destinationId,
+		Namespace:       &namespace,
+		PayloadType:     &payloadType,
+		PayloadUtf8:     &payloadUtf8,
+		ProtocolVersion: &protocolVersion,
+		SourceId:        &sourceId,
+	})
+	if err != nil {
+		t.Fatalf("marshal cast message: %v", err)
+	}
+
+	frame := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	return append(frame, data...)
+}
+
+func TestClientConnectionCountsMessagesByNamespace(t *testing.T) {
+	device := NewDevice(session.CaptureConfig{}, "go-cast", media.FanOut{}, "Test", "device-id", session.RecordingConfig{}, session.PortRange{}, "udn")
+	senderConn, receiverConn := net.Pipe()
+	defer func() {
+		_ = senderConn.Close()
+	}()
+
+	// discard responses, so that the receiver never blocks on a send
+	go func() {
+		_, _ = io.Copy(io.Discard, senderConn)
+	}()
+
+	clientConnection := NewClientConnection(device, receiverConn, 7, nil, "")
+	writeCastMessage(t, senderConn, common.ConnectionNamespace, `{"type":"CONNECT"}`)
+	writeCastMessage(t, senderConn, common.ReceiverNamespace, `{"type":"GET_STATUS","requestId":1}`)
+	writeCastMessage(t, senderConn, common.ReceiverNamespace, `{"type":"GET_STATUS","requestId":2}`)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		stats := clientConnection.Stats()
+		if stats.MessagesSent[common.ReceiverNamespace] == 2 {
+			if stats.ID != 7 {
+				t.Fatalf("expected connection ID 7, got %d", stats.ID)
+			}
+			if stats.MessagesReceived[common.ConnectionNamespace] != 1 || stats.MessagesReceived[common.ReceiverNamespace] != 2 {
+				t.Fatalf("unexpected received counts %v", stats.MessagesReceived)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for responses, stats %+v", stats)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	_ = senderConn.Close()
+	for !clientConnection.isClosed() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the connection to close")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
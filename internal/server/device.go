@@ -3,7 +3,9 @@ package server
 import (
 	"errors"
 	"fmt"
-	"image"
+	"slices"
+	"sort"
+	"sync"
 
 	// third-party
 	"github.com/google/uuid"
@@ -12,6 +14,7 @@ import (
 	// internal
 	"github.com/tristanpenman/go-cast/internal/channel"
 	"github.com/tristanpenman/go-cast/internal/common"
+	"github.com/tristanpenman/go-cast/internal/media"
 	"github.com/tristanpenman/go-cast/internal/session"
 	"github.com/tristanpenman/go-cast/internal/transport"
 )
@@ -19,6 +22,11 @@ import (
 const androidMirroringAppId = "674A0243"
 const chromeMirroringAppId = "0F5096E8"
 
+var (
+	errApplicationStarted = errors.New("application already started")
+	errUnsupportedApp     = errors.New("unsupported app")
+)
+
 type Subscription struct {
 	clientConnection *ClientConnection
 	remoteId         string
@@ -38,33 +46,55 @@ type Device struct {
 	Udn           string
 
 	// implementation
-	images     chan *image.RGBA
-	jpegOutput bool
-	log        hclog.Logger
-	nextPid    int
-	transports map[string]*Transport
+	capture      session.CaptureConfig
+	changes      []chan struct{}
+	mu           sync.Mutex
+	frames       media.FrameSink
+	log          hclog.Logger
+	nextPid      int
+	recording    session.RecordingConfig
+	sessionPorts session.PortRange
+	transports   map[string]*Transport
 }
 
 func (device *Device) forwardCastMessage(castMessage *channel.CastMessage) {
-	transport := device.transports[*castMessage.DestinationId]
-	if transport == nil {
+	castTransport, _, ok := device.lookupTransport(*castMessage.DestinationId)
+	if !ok {
 		device.log.Error("message destination does not exist", "destinationId", *castMessage.DestinationId)
 		return
 	}
 
-	transport.castTransport.HandleCastMessage(castMessage)
+	castTransport.HandleCastMessage(castMessage)
 }
 
 //
 // Functions to register transports and subscribe to their broadcasts
 //
 
+// lookupTransport returns the transport that is registered under id, and a copy
+// of its subscriptions, so that messages can be sent to them without holding
+// the lock.
+func (device *Device) lookupTransport(id string) (transport.CastTransport, []Subscription, bool) {
+	device.mu.Lock()
+	defer device.mu.Unlock()
+
+	registered := device.transports[id]
+	if registered == nil {
+		return nil, nil, false
+	}
+
+	return registered.castTransport, slices.Clone(registered.subscriptions), true
+}
+
 func (device *Device) registerSubscription(clientConnection *ClientConnection, remoteId string, localId string) {
 	// localId should be a valid transport
 	// remoteId can be anything really
 	// clientConnection is just how we get to the remote
 	// from this, we can construct a Peer
 
+	device.mu.Lock()
+	defer device.mu.Unlock()
+
 	transport := device.transports[localId]
 	if transport == nil {
 		device.log.Error("attempt to register subscription for non-existent local transport ID", "localId", localId)
@@ -79,7 +109,27 @@ func (device *Device) registerSubscription(clientConnection *ClientConnection, r
 	transport.subscriptions = append(transport.subscriptions, subscription)
 }
 
+// unregisterSubscriptions removes the subscriptions of a client connection,
+// once it has closed.
+func (device *Device) unregisterSubscriptions(clientConnection *ClientConnection) {
+	device.mu.Lock()
+	defer device.mu.Unlock()
+
+	for _, registered := range device.transports {
+		registered.subscriptions = slices.DeleteFunc(registered.subscriptions, func(subscription Subscription) bool {
+			return subscription.clientConnection == clientConnection
+		})
+	}
+}
+
 func (device *Device) registerTransport(castTransport transport.CastTransport) {
+	device.mu.Lock()
+	defer device.mu.Unlock()
+
+	device.registerTransportLocked(castTransport)
+}
+
+func (device *Device) registerTransportLocked(castTransport transport.CastTransport) {
 	device.transports[castTransport.TransportID()] = &Transport{
 		castTransport: castTransport,
 		subscriptions: make([]Subscription, 0),
@@ -91,14 +141,14 @@ func (device *Device) registerTransport(castTransport transport.CastTransport) {
 //
 
 func (device *Device) broadcastUtf8(namespace string, payloadUtf8 *string, sourceId string) {
-	transport := device.transports[sourceId]
-	if transport == nil {
+	_, subscriptions, ok := device.lookupTransport(sourceId)
+	if !ok {
 		device.log.Error("source transport is not registered", "sourceId", sourceId)
 		return
 	}
 
 	var clientConnections = map[*ClientConnection]bool{}
-	for _, subscription := range transport.subscriptions {
+	for _, subscription := range subscriptions {
 		clientConnections[subscription.clientConnection] = true
 	}
 
@@ -108,14 +158,14 @@ func (device *Device) broadcastUtf8(namespace string, payloadUtf8 *string, sourc
 }
 
 func (device *Device) SendUTF8(namespace string, payloadUtf8 *string, sourceId string, destinationId string) {
-	transport := device.transports[sourceId]
-	if transport == nil {
+	_, subscriptions, ok := device.lookupTransport(sourceId)
+	if !ok {
 		device.log.Error("attempt to send from unregistered transport", "sourceId", sourceId)
 		return
 	}
 
 	var clientConnections = map[*ClientConnection]bool{}
-	for _, subscription := range transport.subscriptions {
+	for _, subscription := range subscriptions {
 		if subscription.remoteId == destinationId {
 			clientConnections[subscription.clientConnection] = true
 		}
@@ -126,63 +176,136 @@ func (device *Device) SendUTF8(namespace string, payloadUtf8 *string, sourceId s
 	}
 }
 
-func (device *Device) startAndroidMirroringSession(clientId int) {
+func (device *Device) startMirroringSession(appId string, clientId int, displayName string, sessionHost string) error {
 	transportId := fmt.Sprintf("pid-%d", device.nextPid)
-	device.nextPid++
 
-	activeSession := session.NewSession(androidMirroringAppId, clientId, device, "Android Mirroring", device.jpegOutput, uuid.New().String(), transportId)
+	listen := session.ListenConfig{Host: sessionHost, Ports: device.sessionPorts}
+	activeSession, err := session.NewSession(appId, device.capture, clientId, device, displayName, device.frames, listen, device.recording, uuid.New().String(), transportId)
+	if err != nil {
+		return err
+	}
+
+	device.nextPid++
 	activeSession.Start()
 
 	device.Sessions[activeSession.SessionId] = activeSession
-	device.registerTransport(activeSession)
+	device.registerTransportLocked(activeSession)
+	device.changedLocked()
+	return nil
 }
 
-func (device *Device) startChromeMirroringSession(clientId int) {
-	transportId := fmt.Sprintf("pid-%d", device.nextPid)
-	device.nextPid++
+// SetFriendlyName renames the device. The new name is reported to senders,
+// and advertised, straight away. It is safe to call from any goroutine.
+func (device *Device) SetFriendlyName(name string) {
+	device.mu.Lock()
+	defer device.mu.Unlock()
 
-	activeSession := session.NewSession(chromeMirroringAppId, clientId, device, "Chrome Mirroring", device.jpegOutput, uuid.New().String(), transportId)
-	activeSession.Start()
+	if device.FriendlyName == name {
+		return
+	}
+	device.FriendlyName = name
+	device.changedLocked()
+}
 
-	device.Sessions[activeSession.SessionId] = activeSession
-	device.registerTransport(activeSession)
+func (device *Device) friendlyName() string {
+	device.mu.Lock()
+	defer device.mu.Unlock()
+
+	return device.FriendlyName
+}
+
+// watchChanges returns a channel that receives a value after the device is
+// renamed, or an application is started or stopped, and a function that stops
+// watching. Changes that are made before the last one has been received are
+// only reported once.
+func (device *Device) watchChanges() (<-chan struct{}, func()) {
+	changes := make(chan struct{}, 1)
+
+	device.mu.Lock()
+	device.changes = append(device.changes, changes)
+	device.mu.Unlock()
+
+	return changes, func() {
+		device.mu.Lock()
+		defer device.mu.Unlock()
+
+		for i, watched := range device.changes {
+			if watched == changes {
+				device.changes = append(device.changes[:i], device.changes[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func (device *Device) changedLocked() {
+	for _, changes := range device.changes {
+		select {
+		case changes <- struct{}{}:
+		default:
+		}
+	}
 }
 
-func (device *Device) startApplication(appId string, clientId int) error {
+// ActiveSessions returns the sessions that are currently running, ordered by
+// session ID. It is safe to call from any goroutine.
+func (device *Device) ActiveSessions() []*session.Session {
+	device.mu.Lock()
+	defer device.mu.Unlock()
+
+	sessions := make([]*session.Session, 0, len(device.Sessions))
+	for _, activeSession := range device.Sessions {
+		sessions = append(sessions, activeSession)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].SessionId < sessions[j].SessionId
+	})
+
+	return sessions
+}
+
+// startApplication starts an application for a client. Sessions listen on
+// sessionHost, so that the sender reaches them through the same interface as
+// the Cast listener that it connected to.
+func (device *Device) startApplication(appId string, clientId int, sessionHost string) error {
+	device.mu.Lock()
+	defer device.mu.Unlock()
+
 	for _, session := range device.Sessions {
 		if session.AppId == appId {
-			return errors.New("application already started")
+			return errApplicationStarted
 		}
 	}
 
 	switch appId {
 	case androidMirroringAppId:
-		device.startAndroidMirroringSession(clientId)
+		return device.startMirroringSession(appId, clientId, "Android Mirroring", sessionHost)
 	case chromeMirroringAppId:
-		device.startChromeMirroringSession(clientId)
+		return device.startMirroringSession(appId, clientId, "Chrome Mirroring", sessionHost)
 	default:
-		return errors.New("unsupported app")
+		return errUnsupportedApp
 	}
-
-	return nil
 }
 
 func (device *Device) stopApplication(sessionId string) error {
+	device.mu.Lock()
 	session := device.Sessions[sessionId]
 	if session == nil {
+		device.mu.Unlock()
 		return errors.New("session does not exist")
 	}
 
 	delete(device.Sessions, sessionId)
+	delete(device.transports, session.TransportID())
+	device.changedLocked()
+	device.mu.Unlock()
+
 	session.Stop()
 	return nil
 }
 
-func (device *Device) DisplayImage(image *image.RGBA) {
-	device.images <- image
-}
-
-func NewDevice(images chan *image.RGBA, deviceModel string, friendlyName string, id string, jpegOutput bool, udn string) *Device {
+func NewDevice(capture session.CaptureConfig, deviceModel string, frames media.FrameSink, friendlyName string, id string, recording session.RecordingConfig, sessionPorts session.PortRange, udn string) *Device {
 	log := common.NewLogger(fmt.Sprintf("device (%s)", id))
 
 	// Allow clients to start Android or Chrome mirroring apps
@@ -199,11 +322,13 @@ func NewDevice(images chan *image.RGBA, deviceModel string, friendlyName string,
 		Udn:           udn,
 
 		// implementation
-		images:     images,
-		jpegOutput: jpegOutput,
-		log:        log,
-		nextPid:    1,
-		transports: make(map[string]*Transport),
+		capture:      capture,
+		frames:       frames,
+		log:          log,
+		nextPid:      1,
+		recording:    recording,
+		sessionPorts: sessionPorts,
+		transports:   make(map[string]*Transport),
 	}
 
 	return &device
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/common"
+	"github.com/tristanpenman/go-cast/internal/media"
+	"github.com/tristanpenman/go-cast/internal/session"
+)
+
+func TestDeviceStartsSessionsOnSeparatePorts(t *testing.T) {
+	device := NewDevice(session.CaptureConfig{}, "go-cast", media.FanOut{}, "Test", "device-id", session.RecordingConfig{}, session.PortRange{}, "udn")
+
+	if err := device.startApplication(androidMirroringAppId, 0, "127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := device.startApplication(chromeMirroringAppId, 0, "127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions := device.ActiveSessions()
+	defer func() {
+		for _, activeSession := range sessions {
+			activeSession.Stop()
+		}
+	}()
+
+	if len(sessions) != 2 {
+		t.Fatalf("%d sessions started, want 2", len(sessions))
+	}
+	if sessions[0].GetPort() == sessions[1].GetPort() {
+		t.Fatalf("sessions share port %d", sessions[0].GetPort())
+	}
+	if err := device.startApplication(chromeMirroringAppId, 0, "127.0.0.1"); !errors.Is(err, errApplicationStarted) {
+		t.Fatalf("relaunch returned %v, want %v", err, errApplicationStarted)
+	}
+}
+
+func TestDeviceReportsSessionPortExhaustion(t *testing.T) {
+	taken, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = taken.Close()
+	}()
+
+	port := common.GetPort(taken.LocalAddr())
+	device := NewDevice(session.CaptureConfig{}, "go-cast", media.FanOut{}, "Test", "device-id", session.RecordingConfig{}, session.PortRange{Max: port, Min: port}, "udn")
+
+	err = device.startApplication(chromeMirroringAppId, 0, "127.0.0.1")
+	if err == nil {
+		t.Fatal("expected an error when no session port is free")
+	}
+	if reason := launchErrorReason(err); reason != "RECEIVER_UNAVAILABLE" {
+		t.Fatalf("launch error reason %q, want RECEIVER_UNAVAILABLE", reason)
+	}
+	if sessions := device.ActiveSessions(); len(sessions) != 0 {
+		t.Fatalf("%d sessions registered after failed launch", len(sessions))
+	}
+	if reason := launchErrorReason(device.startApplication("ABCDEF01", 0, "127.0.0.1")); reason != "NOT_FOUND" {
+		t.Fatalf("unsupported app reason %q, want NOT_FOUND", reason)
+	}
+}
+
+func TestDeviceStopApplicationRemovesTransport(t *testing.T) {
+	device := NewDevice(session.CaptureConfig{}, "go-cast", media.FanOut{}, "Test", "device-id", session.RecordingConfig{}, session.PortRange{}, "udn")
+
+	if err := device.startApplication(chromeMirroringAppId, 0, "127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	activeSession := device.ActiveSessions()[0]
+	if _, _, ok := device.lookupTransport(activeSession.TransportID()); !ok {
+		t.Fatal("session transport was not registered")
+	}
+
+	if err := device.stopApplication(activeSession.SessionId); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := device.lookupTransport(activeSession.TransportID()); ok {
+		t.Fatal("session transport is still registered after stop")
+	}
+}
@@ -0,0 +1,384 @@
+package server
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	// third-party
+	"github.com/hashicorp/go-hclog"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/common"
+)
+
+const (
+	dialDeviceType  = "urn:dial-multiscreen-org:device:dial:1"
+	dialServiceType = "urn:dial-multiscreen-org:service:dial:1"
+
+	// dialClientID identifies sessions that were launched over DIAL, rather
+	// than by a Cast client connection, in logs.
+	dialClientID = -1
+
+	// maxDIALPayload is the largest launch payload that is accepted. Payloads
+	// are not passed on to the apps.
+	maxDIALPayload = 4096
+)
+
+// dialApps maps DIAL app names onto the Cast apps that the receiver runs. The
+// Cast app IDs can also be used as names.
+var dialApps = map[string]string{
+	"AndroidMirroring": androidMirroringAppId,
+	"ChromeMirroring":  chromeMirroringAppId,
+}
+
+// dialOrigins are the web origins that are allowed to launch and stop apps.
+// Requests from other web pages are refused, so that they cannot control the
+// receiver, but requests from native senders, which send no Origin or a
+// non-web one, are accepted.
+var dialOrigins = []string{
+	"https://www.youtube.com",
+}
+
+// DIALServer serves DIAL, which some senders use to find the receiver and to
+// launch apps on it, instead of Cast. It serves a UPnP device description, and
+// an app resource for each app that the receiver runs, and answers SSDP
+// searches for the device.
+type DIALServer struct {
+	device     *Device
+	httpServer *http.Server
+	listener   net.Listener
+	log        hclog.Logger
+	ssdp       *ssdpResponder
+}
+
+// NewDIALServer starts serving DIAL over HTTP on addr, and answering SSDP
+// searches on the given interfaces, or on every multicast interface if there
+// are none. Paths:
+//
+//	/ssdp/device-desc.xml  device description
+//	/apps/<name>           app status, and launching the app
+//	/apps/<name>/run       the running app, which can be stopped
+func NewDIALServer(device *Device, addr string, interfaceNames []string) (*DIALServer, error) {
+	server, err := newDIALServer(device, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := listenSSDP(interfaceNames)
+	if err != nil {
+		_ = server.Close()
+		return nil, err
+	}
+	server.ssdp = newSSDPResponder(conn, device, common.GetPort(server.listener.Addr()), server.log)
+	go server.ssdp.serve()
+
+	return server, nil
+}
+
+// newDIALServer serves DIAL over HTTP, without answering SSDP searches.
+func newDIALServer(device *Device, addr string) (*DIALServer, error) {
+	var log = common.NewLogger("dial")
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen for dial: %w", err)
+	}
+
+	server := &DIALServer{
+		device:   device,
+		listener: listener,
+		log:      log,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ssdp/device-desc.xml", server.handleDeviceDescription)
+	mux.HandleFunc("/apps/", server.handleApp)
+	server.httpServer = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	log.Info("serving dial", "addr", listener.Addr())
+
+	go func() {
+		if err := server.httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("dial server stopped", "err", err)
+		}
+	}()
+
+	return server, nil
+}
+
+// Addr returns the address that DIAL is served on.
+func (server *DIALServer) Addr() net.Addr {
+	return server.listener.Addr()
+}
+
+// Close stops answering SSDP searches, and serving DIAL.
+func (server *DIALServer) Close() error {
+	var errs []error
+	if server.ssdp != nil {
+		if err := server.ssdp.close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := server.httpServer.Shutdown(ctx); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("stop dial: %w", err)
+	}
+	return nil
+}
+
+type dialSpecVersion struct {
+	Major int `xml:"major"`
+	Minor int `xml:"minor"`
+}
+
+type dialService struct {
+	ServiceType string `xml:"serviceType"`
+	ServiceID   string `xml:"serviceId"`
+	ControlURL  string `xml:"controlURL"`
+	EventSubURL string `xml:"eventSubURL"`
+	SCPDURL     string `xml:"SCPDURL"`
+}
+
+type dialDevice struct {
+	DeviceType   string        `xml:"deviceType"`
+	FriendlyName string        `xml:"friendlyName"`
+	Manufacturer string        `xml:"manufacturer"`
+	ModelName    string        `xml:"modelName"`
+	UDN          string        `xml:"UDN"`
+	Services     []dialService `xml:"serviceList>service"`
+}
+
+type dialDeviceDescription struct {
+	XMLName     xml.Name        `xml:"urn:schemas-upnp-org:device-1-0 root"`
+	SpecVersion dialSpecVersion `xml:"specVersion"`
+	URLBase     string          `xml:"URLBase"`
+	Device      dialDevice      `xml:"device"`
+}
+
+// handleDeviceDescription describes the device, and gives the URL of its app
+// resources in the Application-URL header, which is how DIAL senders find
+// them.
+func (server *DIALServer) handleDeviceDescription(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	base := baseURL(r)
+	description := dialDeviceDescription{
+		SpecVersion: dialSpecVersion{Major: 1, Minor: 0},
+		URLBase:     base,
+		Device: dialDevice{
+			DeviceType:   dialDeviceType,
+			FriendlyName: server.device.friendlyName(),
+			Manufacturer: "GoCast",
+			ModelName:    server.device.DeviceModel,
+			UDN:          "uuid:" + server.device.Udn,
+			Services: []dialService{{
+				ServiceType: dialServiceType,
+				ServiceID:   "urn:dial-multiscreen-org:serviceId:dial",
+				ControlURL:  "/ssdp/notfound",
+				EventSubURL: "/ssdp/notfound",
+				SCPDURL:     "/ssdp/notfound",
+			}},
+		},
+	}
+
+	w.Header().Set("Application-URL", base+"/apps/")
+	writeXML(w, http.StatusOK, description, server.log)
+}
+
+type dialOptions struct {
+	AllowStop bool `xml:"allowStop,attr"`
+}
+
+type dialLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+}
+
+type dialAppStatus struct {
+	XMLName xml.Name    `xml:"urn:dial-multiscreen-org:schemas:dial service"`
+	DialVer string      `xml:"dialVer,attr"`
+	Name    string      `xml:"name"`
+	Options dialOptions `xml:"options"`
+	State   string      `xml:"state"`
+	Link    *dialLink   `xml:"link,omitempty"`
+}
+
+// handleApp serves /apps/<name>, whose status is read with GET, and which is
+// launched with POST, and /apps/<name>/run, which is stopped with DELETE.
+// DELETE is also accepted on /apps/<name>.
+func (server *DIALServer) handleApp(w http.ResponseWriter, r *http.Request) {
+	name, instance, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/apps/"), "/")
+	appID, ok := server.appID(name)
+	if !ok || (instance != "" && instance != "run") {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && !allowedDIALOrigin(r.Header.Get("Origin")) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && instance == "":
+		server.handleAppStatus(w, name, appID)
+	case r.Method == http.MethodPost && instance == "":
+		server.handleLaunch(w, r, name, appID)
+	case r.Method == http.MethodDelete:
+		server.handleStop(w, r, appID)
+	case instance == "":
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	default:
+		w.Header().Set("Allow", http.MethodDelete)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (server *DIALServer) handleAppStatus(w http.ResponseWriter, name string, appID string) {
+	status := dialAppStatus{
+		DialVer: "2.1",
+		Name:    name,
+		Options: dialOptions{AllowStop: true},
+		State:   "stopped",
+	}
+	if server.runningSessionID(appID) != "" {
+		status.State = "running"
+		status.Link = &dialLink{Rel: "run", Href: "run"}
+	}
+
+	writeXML(w, http.StatusOK, status, server.log)
+}
+
+func (server *DIALServer) handleLaunch(w http.ResponseWriter, r *http.Request, name string, appID string) {
+	if _, err := io.Copy(io.Discard, http.MaxBytesReader(w, r.Body, maxDIALPayload)); err != nil {
+		http.Error(w, "payload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	status := http.StatusCreated
+	err := server.device.startApplication(appID, dialClientID, sessionHost(server.listener.Addr()))
+	switch {
+	case errors.Is(err, errApplicationStarted):
+		status = http.StatusOK
+	case errors.Is(err, errUnsupportedApp):
+		http.NotFound(w, r)
+		return
+	case err != nil:
+		server.log.Error("failed to start application", "appId", appID, "err", err)
+		http.Error(w, "failed to start application", http.StatusServiceUnavailable)
+		return
+	default:
+		server.log.Info("started application", "name", name, "appId", appID)
+		server.notifyStatus()
+	}
+
+	w.Header().Set("Location", baseURL(r)+"/apps/"+url.PathEscape(name)+"/run")
+	w.WriteHeader(status)
+}
+
+func (server *DIALServer) handleStop(w http.ResponseWriter, r *http.Request, appID string) {
+	sessionID := server.runningSessionID(appID)
+	if sessionID == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if err := server.device.stopApplication(sessionID); err != nil {
+		// the app stopped in the meantime
+		http.NotFound(w, r)
+		return
+	}
+
+	server.log.Info("stopped application", "appId", appID, "sessionId", sessionID)
+	server.notifyStatus()
+	w.WriteHeader(http.StatusOK)
+}
+
+// notifyStatus tells connected Cast senders that an app was started or stopped
+// over DIAL, as they would be told if it was started or stopped by a sender.
+func (server *DIALServer) notifyStatus() {
+	if _, _, ok := server.device.lookupTransport(receiverTransportId); ok {
+		server.device.broadcastReceiverStatus(receiverTransportId, 0)
+	}
+}
+
+// appID returns the Cast app that a DIAL app name refers to.
+func (server *DIALServer) appID(name string) (string, bool) {
+	if appID, ok := dialApps[name]; ok {
+		return appID, true
+	}
+	for _, appID := range server.device.AvailableApps {
+		if appID == name {
+			return appID, true
+		}
+	}
+	return "", false
+}
+
+// runningSessionID returns the ID of the session that is running the app, or
+// an empty string if it is not running.
+func (server *DIALServer) runningSessionID(appID string) string {
+	for _, activeSession := range server.device.ActiveSessions() {
+		if activeSession.AppId == appID {
+			return activeSession.SessionId
+		}
+	}
+	return ""
+}
+
+func allowedDIALOrigin(origin string) bool {
+	if origin == "" {
+		return true
+	}
+	if !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
+		return true
+	}
+	for _, allowed := range dialOrigins {
+		if origin == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// baseURL returns the URL of the DIAL server, at the local address that the
+// request arrived on, so that the sender is given an address that it can
+// reach.
+func baseURL(r *http.Request) string {
+	if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		return "http://" + addr.String()
+	}
+	return "http://" + r.Host
+}
+
+func writeXML(w http.ResponseWriter, status int, value any, log hclog.Logger) {
+	body, err := xml.MarshalIndent(value, "", "  ")
+	if err != nil {
+		log.Error("failed to encode xml", "err", err)
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	w.WriteHeader(status)
+	_, _ = io.WriteString(w, xml.Header)
+	_, _ = w.Write(body)
+	_, _ = io.WriteString(w, "\n")
+}
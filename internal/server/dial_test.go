@@ -0,0 +1,275 @@
+package server
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	// third-party
+	"google.golang.org/protobuf/proto"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/channel"
+	"github.com/tristanpenman/go-cast/internal/common"
+	"github.com/tristanpenman/go-cast/internal/media"
+	"github.com/tristanpenman/go-cast/internal/session"
+)
+
+func newTestDIALServer(t *testing.T) (*DIALServer, *Device, string) {
+	t.Helper()
+
+	device := NewDevice(session.CaptureConfig{}, "go-cast", media.FanOut{}, "Living Room", "device-id", session.RecordingConfig{}, session.PortRange{}, "device-udn")
+	server, err := newDIALServer(device, "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = server.Close()
+		for _, activeSession := range device.ActiveSessions() {
+			activeSession.Stop()
+		}
+	})
+	return server, device, "http://" + server.Addr().String()
+}
+
+func dialRequest(t *testing.T, method string, url string, origin string) *http.Response {
+	t.Helper()
+
+	request, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if origin != "" {
+		request.Header.Set("Origin", origin)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = response.Body.Close()
+	})
+	return response
+}
+
+func TestDIALServerDescribesDevice(t *testing.T) {
+	_, _, base := newTestDIALServer(t)
+
+	response := dialRequest(t, http.MethodGet, base+"/ssdp/device-desc.xml", "")
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("device description status %d", response.StatusCode)
+	}
+	if got := response.Header.Get("Application-URL"); got != base+"/apps/" {
+		t.Fatalf("Application-URL %q, want %q", got, base+"/apps/")
+	}
+
+	var description dialDeviceDescription
+	if err := xml.NewDecoder(response.Body).Decode(&description); err != nil {
+		t.Fatal(err)
+	}
+	if description.Device.DeviceType != dialDeviceType || description.Device.FriendlyName != "Living Room" ||
+		description.Device.UDN != "uuid:device-udn" || description.Device.ModelName != "go-cast" {
+		t.Fatalf("unexpected device %+v", description.Device)
+	}
+}
+
+func TestDIALServerLaunchesAndStopsApps(t *testing.T) {
+	_, device, base := newTestDIALServer(t)
+	appURL := base + "/apps/ChromeMirroring"
+
+	status := func() dialAppStatus {
+		t.Helper()
+		response := dialRequest(t, http.MethodGet, appURL, "")
+		if response.StatusCode != http.StatusOK {
+			t.Fatalf("app status %d", response.StatusCode)
+		}
+		var status dialAppStatus
+		if err := xml.NewDecoder(response.Body).Decode(&status); err != nil {
+			t.Fatal(err)
+		}
+		return status
+	}
+
+	if got := status(); got.State != "stopped" || got.Link != nil || !got.Options.AllowStop {
+		t.Fatalf("idle app status %+v", got)
+	}
+
+	response := dialRequest(t, http.MethodPost, appURL, "")
+	if response.StatusCode != http.StatusCreated {
+		t.Fatalf("launch status %d, want %d", response.StatusCode, http.StatusCreated)
+	}
+	if got := response.Header.Get("Location"); got != appURL+"/run" {
+		t.Fatalf("launch location %q, want %q", got, appURL+"/run")
+	}
+	sessions := device.ActiveSessions()
+	if len(sessions) != 1 || sessions[0].AppId != chromeMirroringAppId {
+		t.Fatalf("launch started sessions %v", sessions)
+	}
+	if got := status(); got.State != "running" || got.Link == nil || got.Link.Rel != "run" {
+		t.Fatalf("running app status %+v", got)
+	}
+
+	// launching a running app leaves it running
+	if response := dialRequest(t, http.MethodPost, appURL, ""); response.StatusCode != http.StatusOK {
+		t.Fatalf("relaunch status %d, want %d", response.StatusCode, http.StatusOK)
+	}
+
+	if response := dialRequest(t, http.MethodDelete, appURL+"/run", ""); response.StatusCode != http.StatusOK {
+		t.Fatalf("stop status %d, want %d", response.StatusCode, http.StatusOK)
+	}
+	if sessions := device.ActiveSessions(); len(sessions) != 0 {
+		t.Fatalf("sessions %v still running after stop", sessions)
+	}
+	if response := dialRequest(t, http.MethodDelete, appURL+"/run", ""); response.StatusCode != http.StatusNotFound {
+		t.Fatalf("stopping a stopped app returned %d, want %d", response.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestDIALServerRefusesRequests(t *testing.T) {
+	_, device, base := newTestDIALServer(t)
+
+	tests := []struct {
+		method string
+		path   string
+		origin string
+		want   int
+	}{
+		{http.MethodGet, "/apps/YouTube", "", http.StatusNotFound},
+		{http.MethodGet, "/apps/ChromeMirroring/other", "", http.StatusNotFound},
+		{http.MethodPost, "/apps/ChromeMirroring", "https://example.com", http.StatusForbidden},
+		{http.MethodDelete, "/apps/ChromeMirroring/run", "http://example.com", http.StatusForbidden},
+		{http.MethodPut, "/apps/ChromeMirroring", "", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/apps/ChromeMirroring/run", "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/ssdp/device-desc.xml", "", http.StatusMethodNotAllowed},
+	}
+	for _, test := range tests {
+		response := dialRequest(t, test.method, base+test.path, test.origin)
+		if response.StatusCode != test.want {
+			t.Errorf("%s %s from %q returned %d, want %d", test.method, test.path, test.origin, response.StatusCode, test.want)
+		}
+	}
+	if sessions := device.ActiveSessions(); len(sessions) != 0 {
+		t.Fatalf("refused requests started sessions %v", sessions)
+	}
+
+	// native senders and allowed web origins can launch apps
+	for _, origin := range []string{"package:com.example.sender", "https://www.youtube.com"} {
+		response := dialRequest(t, http.MethodPost, base+"/apps/"+androidMirroringAppId, origin)
+		if response.StatusCode != http.StatusCreated && response.StatusCode != http.StatusOK {
+			t.Errorf("launch from %q returned %d", origin, response.StatusCode)
+		}
+	}
+}
+
+func TestDIALServerRefusesLargePayloads(t *testing.T) {
+	_, device, base := newTestDIALServer(t)
+
+	response, err := http.Post(base+"/apps/ChromeMirroring", "text/plain", strings.NewReader(strings.Repeat("x", maxDIALPayload+1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	_, _ = io.Copy(io.Discard, response.Body)
+
+	if response.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("large payload returned %d, want %d", response.StatusCode, http.StatusRequestEntityTooLarge)
+	}
+	if sessions := device.ActiveSessions(); len(sessions) != 0 {
+		t.Fatalf("refused launch started sessions %v", sessions)
+	}
+}
+
+// readReceiverStatuses reads Cast messages from conn until it is closed, and
+// passes on unsolicited RECEIVER_STATUS messages.
+func readReceiverStatuses(conn net.Conn, statuses chan<- GetStatusResponse) {
+	for {
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		data := make([]byte, binary.BigEndian.Uint32(header))
+		if _, err := io.ReadFull(conn, data); err != nil {
+			return
+		}
+
+		var castMessage channel.CastMessage
+		if err := proto.Unmarshal(data, &castMessage); err != nil || castMessage.GetNamespace() != common.ReceiverNamespace {
+			continue
+		}
+		var status GetStatusResponse
+		if err := json.Unmarshal([]byte(castMessage.GetPayloadUtf8()), &status); err != nil || status.Type != "RECEIVER_STATUS" || status.RequestId != 0 {
+			continue
+		}
+		statuses <- status
+	}
+}
+
+func TestDIALServerLaunchesWhileCastSenderIsConnected(t *testing.T) {
+	_, device, base := newTestDIALServer(t)
+	appURL := base + "/apps/ChromeMirroring"
+
+	senderConn, receiverConn := net.Pipe()
+	t.Cleanup(func() {
+		_ = senderConn.Close()
+	})
+	NewClientConnection(device, receiverConn, 0, nil, "127.0.0.1")
+
+	statuses := make(chan GetStatusResponse, 16)
+	go readReceiverStatuses(senderConn, statuses)
+
+	// the sender keeps asking for status while apps are launched and stopped
+	// over DIAL
+	getStatus := castMessageFrame(t, common.ReceiverNamespace, `{"type":"GET_STATUS","requestId":1}`)
+	done := make(chan struct{})
+	sending := make(chan struct{})
+	go func() {
+		defer close(sending)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if _, err := senderConn.Write(getStatus); err != nil {
+				return
+			}
+		}
+	}()
+	t.Cleanup(func() {
+		close(done)
+		<-sending
+	})
+
+	nextStatus := func() Status {
+		t.Helper()
+		select {
+		case status := <-statuses:
+			return status.Status
+		case <-time.After(5 * time.Second):
+			t.Fatal("no status update")
+			return Status{}
+		}
+	}
+
+	if response := dialRequest(t, http.MethodPost, appURL, ""); response.StatusCode != http.StatusCreated {
+		t.Fatalf("launch status %d, want %d", response.StatusCode, http.StatusCreated)
+	}
+	if status := nextStatus(); len(status.Applications) != 1 || status.Applications[0].AppId != chromeMirroringAppId {
+		t.Fatalf("sender told of applications %+v after launch", status.Applications)
+	}
+
+	if response := dialRequest(t, http.MethodDelete, appURL+"/run", ""); response.StatusCode != http.StatusOK {
+		t.Fatalf("stop status %d, want %d", response.StatusCode, http.StatusOK)
+	}
+	if status := nextStatus(); len(status.Applications) != 0 {
+		t.Fatalf("sender told of applications %+v after stop", status.Applications)
+	}
+}
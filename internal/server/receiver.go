@@ -2,6 +2,7 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/hashicorp/go-hclog"
@@ -11,11 +12,16 @@ import (
 	"github.com/tristanpenman/go-cast/internal/session"
 )
 
+// receiverTransportId is the transport that senders connect to, to control the
+// receiver.
+const receiverTransportId = "receiver-0"
+
 type Receiver struct {
-	clientId int
-	device   *Device
-	id       string
-	log      hclog.Logger
+	clientId    int
+	device      *Device
+	id          string
+	log         hclog.Logger
+	sessionHost string
 }
 
 // ================================================================================================
@@ -30,6 +36,7 @@ type Receiver struct {
 //
 // Outgoing:
 //   - GET_APP_AVAILABILITY
+//   - LAUNCH_ERROR
 //   - RECEIVER_STATUS
 //
 
@@ -130,10 +137,9 @@ func marshallNamespaces(namespaces []string) []Namespace {
 	return marshalled
 }
 
-func marshallApplicationStatuses(sessions map[string]*session.Session) []Application {
+func marshallApplicationStatuses(sessions []*session.Session) []Application {
 	marshalled := make([]Application, len(sessions))
-	var index = 0
-	for _, session := range sessions {
+	for index, session := range sessions {
 		marshalled[index] = Application{
 			AppId:        session.AppId,
 			DisplayName:  session.DisplayName,
@@ -143,20 +149,26 @@ func marshallApplicationStatuses(sessions map[string]*session.Session) []Applica
 			StatusText:   session.StatusText,
 			TransportId:  session.TransportID(),
 		}
-		index++
 	}
 
 	return marshalled
 }
 
 func (receiver *Receiver) handleGetStatus(requestId int) {
+	receiver.device.broadcastReceiverStatus(receiver.id, requestId)
+}
+
+// broadcastReceiverStatus sends RECEIVER_STATUS from a receiver transport to
+// all of its subscribers. Updates that were not requested by a sender have a
+// requestId of 0.
+func (device *Device) broadcastReceiverStatus(sourceId string, requestId int) {
 	response := GetStatusResponse{
 		ReceiverMessage: &ReceiverMessage{
 			RequestId: requestId,
 			Type:      "RECEIVER_STATUS",
 		},
 		Status: Status{
-			Applications:  marshallApplicationStatuses(receiver.device.Sessions),
+			Applications:  marshallApplicationStatuses(device.ActiveSessions()),
 			IsActiveInput: true,
 			Volume: Volume{
 				Level: 1.0,
@@ -167,12 +179,12 @@ func (receiver *Receiver) handleGetStatus(requestId int) {
 
 	bytes, err := json.Marshal(response)
 	if err != nil {
-		receiver.log.Error("failed to marshall RECEIVER_STATUS message")
+		device.log.Error("failed to marshall RECEIVER_STATUS message")
 		return
 	}
 
 	payloadUtf8 := string(bytes)
-	receiver.device.broadcastUtf8(common.ReceiverNamespace, &payloadUtf8, receiver.id)
+	device.broadcastUtf8(common.ReceiverNamespace, &payloadUtf8, sourceId)
 }
 
 type launchRequest struct {
@@ -181,22 +193,62 @@ type launchRequest struct {
 	AppId string `json:"appId"`
 }
 
-func (receiver *Receiver) handleLaunch(data string) {
+type launchErrorResponse struct {
+	*ReceiverMessage
+
+	Reason string `json:"reason"`
+}
+
+// launchErrorReason maps a failure to start an application to the reason that
+// is reported to the sender.
+func launchErrorReason(err error) string {
+	if errors.Is(err, errUnsupportedApp) {
+		return "NOT_FOUND"
+	}
+
+	return "RECEIVER_UNAVAILABLE"
+}
+
+func (receiver *Receiver) handleLaunch(castMessage *channel.CastMessage) {
 	var request launchRequest
-	var err = json.Unmarshal([]byte(data), &request)
+	var err = json.Unmarshal([]byte(*castMessage.PayloadUtf8), &request)
 	if err != nil {
 		receiver.log.Error("failed to unmarshall launch request", "err", err)
 		return
 	}
 
-	err = receiver.device.startApplication(request.AppId, receiver.clientId)
-	if err != nil {
-		receiver.log.Error("failed to start application", "err", err)
+	err = receiver.device.startApplication(request.AppId, receiver.clientId, receiver.sessionHost)
+	if errors.Is(err, errApplicationStarted) {
+		// the sender joins the running application
+		receiver.log.Info("application already started", "appId", request.AppId)
+	} else if err != nil {
+		receiver.log.Error("failed to start application", "appId", request.AppId, "err", err)
+		receiver.sendLaunchError(castMessage, request.RequestId, launchErrorReason(err))
+		return
 	}
 
 	receiver.handleGetStatus(request.RequestId)
 }
 
+func (receiver *Receiver) sendLaunchError(castMessage *channel.CastMessage, requestId int, reason string) {
+	response := launchErrorResponse{
+		ReceiverMessage: &ReceiverMessage{
+			RequestId: requestId,
+			Type:      "LAUNCH_ERROR",
+		},
+		Reason: reason,
+	}
+
+	bytes, err := json.Marshal(response)
+	if err != nil {
+		receiver.log.Error("failed to marshall LAUNCH_ERROR message")
+		return
+	}
+
+	payloadUtf8 := string(bytes)
+	receiver.device.SendUTF8(common.ReceiverNamespace, &payloadUtf8, *castMessage.DestinationId, *castMessage.SourceId)
+}
+
 type stopRequest struct {
 	*ReceiverMessage
 
@@ -231,7 +283,7 @@ func (receiver *Receiver) handleReceiverMessage(castMessage *channel.CastMessage
 	case "GET_STATUS":
 		receiver.handleGetStatus(parsed.RequestId)
 	case "LAUNCH":
-		receiver.handleLaunch(*castMessage.PayloadUtf8)
+		receiver.handleLaunch(castMessage)
 	case "STOP":
 		receiver.handleStop(*castMessage.PayloadUtf8)
 	default:
@@ -291,7 +343,7 @@ func (receiver *Receiver) handleDiscoveryMessage(castMessage *channel.CastMessag
 		DeviceIconUrl:        "",
 		DeviceId:             receiver.device.Id,
 		DeviceModel:          receiver.device.DeviceModel,
-		FriendlyName:         receiver.device.FriendlyName,
+		FriendlyName:         receiver.device.friendlyName(),
 		ReceiverMetricsId:    "",
 		WifiProximityId:      "",
 	}
@@ -402,7 +454,7 @@ func (receiver *Receiver) handleSetupMessage(castMessage *channel.CastMessage) {
 			DeviceInfo: SetupDeviceInfo{
 				SsdpUdn: receiver.device.Udn,
 			},
-			Name:    receiver.device.FriendlyName,
+			Name:    receiver.device.friendlyName(),
 			Version: 8,
 		},
 		ResponseCode:   200,
@@ -453,13 +505,17 @@ func (receiver *Receiver) TransportID() string {
 // Constructor
 //
 
-func NewReceiver(device *Device, id string, clientId int) *Receiver {
+// NewReceiver returns the receiver for one client connection. Sessions that it
+// starts listen on sessionHost, which is the address of the Cast listener that
+// accepted the connection, or empty to listen on every interface.
+func NewReceiver(device *Device, id string, clientId int, sessionHost string) *Receiver {
 	log := common.NewLogger(fmt.Sprintf("receiver (%d) [%s]", clientId, id))
 
 	return &Receiver{
-		clientId: clientId,
-		device:   device,
-		id:       id,
-		log:      log,
+		clientId:    clientId,
+		device:      device,
+		id:          id,
+		log:         log,
+		sessionHost: sessionHost,
 	}
 }
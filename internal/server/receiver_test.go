@@ -0,0 +1,103 @@
+package server
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/discovery"
+	"github.com/tristanpenman/go-cast/internal/media"
+	"github.com/tristanpenman/go-cast/internal/session"
+)
+
+// testManifest returns a manifest whose device certificate is self-signed, so
+// that the receiver can answer device-auth challenges that senders then
+// reject.
+func testManifest(t *testing.T) map[string]string {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "go-cast test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := sha256.Sum256(der)
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certificate := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	return map[string]string{
+		"cpu": certificate,
+		"ica": certificate,
+		"pr":  string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+		"pu":  certificate,
+		"sig": base64.StdEncoding.EncodeToString(sig),
+	}
+}
+
+// serveReceiver starts a receiver on loopback, and returns the port that it
+// listens on.
+func serveReceiver(t *testing.T) int {
+	t.Helper()
+
+	device := NewDevice(session.CaptureConfig{}, "GoCast", media.FanOut{}, "Study", "3d1f6a2e8b4c4e5a9f7d2c1b0a9e8d7f", session.RecordingConfig{}, session.PortRange{}, "3d1f6a2e-8b4c-4e5a-9f7d-2c1b0a9e8d7f")
+	castServer, err := NewServer(device, testManifest(t), nil, []string{"127.0.0.1"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = castServer.StopListening() })
+
+	return castServer.listeners[0].Addr().(*net.TCPAddr).Port
+}
+
+func TestEnrichQueriesReceiver(t *testing.T) {
+	port := serveReceiver(t)
+	// as if it had been added by address, without an ID
+	advertised := discovery.Device{Name: "Stu", Host: "127.0.0.1", Port: port, ConnectionState: "discovered"}
+
+	enriched := discovery.Enrich([]discovery.Device{advertised}, discovery.EnrichOptions{Timeout: 5 * time.Second})
+	if len(enriched) != 1 {
+		t.Fatalf("enriched %d devices, want 1", len(enriched))
+	}
+	got := enriched[0]
+	if got.ConnectionState != "reachable" || got.ConnectLatency <= 0 || got.AuthLatency != 0 {
+		t.Fatalf("unexpected connection: %+v", got)
+	}
+	if got.ID != "3d1f6a2e8b4c4e5a9f7d2c1b0a9e8d7f" || got.Name != "Study" || got.Model != "GoCast" || got.Capabilities != discovery.Capabilities(4101) {
+		t.Fatalf("device info not applied: %+v", got)
+	}
+	if got.UDN != "3d1f6a2e-8b4c-4e5a-9f7d-2c1b0a9e8d7f" {
+		t.Fatalf("eureka info not applied: %+v", got)
+	}
+}
+
+func TestEnrichReportsFailedDeviceAuth(t *testing.T) {
+	port := serveReceiver(t)
+	advertised := discovery.Device{Name: "Study", Host: "127.0.0.1", Port: port}
+
+	enriched := discovery.Enrich([]discovery.Device{advertised}, discovery.EnrichOptions{DeviceAuth: true, Timeout: 5 * time.Second})
+	if enriched[0].ConnectionState != "auth-failed" {
+		t.Fatalf("connection state %q, want auth-failed", enriched[0].ConnectionState)
+	}
+}
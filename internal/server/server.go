@@ -5,8 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 
 	// third-party
 	"github.com/hashicorp/go-hclog"
@@ -15,19 +17,25 @@ import (
 )
 
 type Server struct {
+	mu sync.Mutex
+
 	clientConnections []*ClientConnection
-	listener          net.Listener
+	listeners         []net.Listener
 	interfaceNames    []string
 	log               hclog.Logger
 	nextClientId      int
 }
 
-// NewServer starts a TLS listener for Cast client connections.
+// NewServer starts TLS listeners for Cast client connections. Each of the
+// interfaces is a network interface name, whose IPv4 and IPv6 addresses are
+// all listened on, or a local address or hostname. Without any interfaces, the
+// server listens on every interface, over both IPv4 and IPv6. If port is 0,
+// one port is chosen for all of the listeners.
 func NewServer(
 	device *Device,
 	manifest map[string]string,
 	clientPrefix *string,
-	iface *string,
+	interfaces []string,
 	port int,
 ) (*Server, error) {
 	var log = common.NewLogger("server")
@@ -38,120 +46,246 @@ func NewServer(
 	}
 
 	cfg := &tls.Config{Certificates: []tls.Certificate{cert}}
-	listenHost, err := resolveListenHost(iface)
+	addresses, err := resolveListenAddresses(interfaces)
 	if err != nil {
 		return nil, fmt.Errorf("resolve Cast listener interface: %w", err)
 	}
-	addr := net.JoinHostPort(listenHost, strconv.Itoa(port))
-	listener, err := tls.Listen("tcp", addr, cfg)
-	if err != nil {
-		return nil, fmt.Errorf("listen for Cast connections: %w", err)
-	}
-	interfaceNames, err := listenerInterfaceNames(listener.Addr())
-	if err != nil {
-		_ = listener.Close()
-		return nil, fmt.Errorf("resolve Cast listener network interface: %w", err)
-	}
-
-	log.Info("listening", "addr", listener.Addr(), "interfaces", interfaceNames)
 
 	server := Server{
 		clientConnections: make([]*ClientConnection, 0),
-		listener:          listener,
-		interfaceNames:    interfaceNames,
 		log:               log,
 		nextClientId:      0,
 	}
 
-	go func() {
-		for {
-			conn, err := listener.Accept()
-			if err != nil {
-				if errors.Is(err, net.ErrClosed) {
-					break
-				}
-				log.Error("server accept failed", "err", err)
-				continue
-			}
+	for _, address := range addresses {
+		listener, err := tls.Listen("tcp", net.JoinHostPort(address.host, strconv.Itoa(port)), cfg)
+		if err != nil && address.optional {
+			log.Warn("failed to listen on interface address", "addr", address.host, "err", err)
+			continue
+		}
+		if err != nil {
+			_ = server.StopListening()
+			return nil, fmt.Errorf("listen for Cast connections: %w", err)
+		}
+		port = listener.Addr().(*net.TCPAddr).Port
+		server.listeners = append(server.listeners, listener)
+	}
+	if len(server.listeners) == 0 {
+		return nil, fmt.Errorf("listen for Cast connections: no address could be listened on")
+	}
+
+	server.interfaceNames, err = listenersInterfaceNames(server.listeners)
+	if err != nil {
+		_ = server.StopListening()
+		return nil, fmt.Errorf("resolve Cast listener network interface: %w", err)
+	}
+
+	for _, listener := range server.listeners {
+		log.Info("listening", "addr", listener.Addr(), "interfaces", server.interfaceNames)
+		go server.accept(listener, device, manifest, clientPrefix)
+	}
 
-			if clientPrefix == nil || strings.HasPrefix(conn.RemoteAddr().String(), *clientPrefix) {
-				log.Info("accepted connection", "remote addr", conn.RemoteAddr())
-				id := server.nextClientId
-				clientConnection := NewClientConnection(device, conn, id, manifest)
-				server.nextClientId++
-				server.clientConnections = append(server.clientConnections, clientConnection)
-			} else {
-				log.Debug("ignored connection", "remote addr", conn.RemoteAddr())
-				_ = conn.Close()
+	return &server, nil
+}
+
+// accept serves the connections that arrive on listener. Sessions that are
+// started over a connection listen on the same address as the listener.
+func (server *Server) accept(listener net.Listener, device *Device, manifest map[string]string, clientPrefix *string) {
+	host := sessionHost(listener.Addr())
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				break
 			}
+			server.log.Error("server accept failed", "err", err)
+			continue
 		}
-	}()
 
-	return &server, nil
+		if clientPrefix == nil || strings.HasPrefix(conn.RemoteAddr().String(), *clientPrefix) {
+			server.log.Info("accepted connection", "remote addr", conn.RemoteAddr())
+
+			server.mu.Lock()
+			id := server.nextClientId
+			server.nextClientId++
+			server.mu.Unlock()
+
+			clientConnection := NewClientConnection(device, conn, id, manifest, host)
+
+			server.mu.Lock()
+			server.clientConnections = append(server.clientConnections, clientConnection)
+			server.mu.Unlock()
+
+			go server.removeWhenClosed(clientConnection)
+		} else {
+			server.log.Debug("ignored connection", "remote addr", conn.RemoteAddr())
+			_ = conn.Close()
+		}
+	}
+}
+
+// removeWhenClosed forgets a client connection once the sender disconnects.
+func (server *Server) removeWhenClosed(clientConnection *ClientConnection) {
+	<-clientConnection.closed()
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	server.clientConnections = slices.DeleteFunc(server.clientConnections, func(open *ClientConnection) bool {
+		return open == clientConnection
+	})
+}
+
+// ConnectionStats returns message counts for each open client connection,
+// ordered by connection ID.
+func (server *Server) ConnectionStats() []ConnectionStats {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	stats := make([]ConnectionStats, 0, len(server.clientConnections))
+	for _, clientConnection := range server.clientConnections {
+		stats = append(stats, clientConnection.Stats())
+	}
+
+	return stats
 }
 
-// InterfaceNames returns the network interfaces on which the listener accepts
-// Cast connections. An empty result means the listener is bound to all local
+// InterfaceNames returns the network interfaces on which the listeners accept
+// Cast connections. An empty result means that the server listens on all local
 // interfaces.
 func (server *Server) InterfaceNames() []string {
 	return append([]string(nil), server.interfaceNames...)
 }
 
+// ListenIPs returns the addresses that the listeners are bound to, which are
+// those that the receiver can be reached at. An empty result means that the
+// server listens on every address.
+func (server *Server) ListenIPs() []net.IP {
+	var ips []net.IP
+	for _, listener := range server.listeners {
+		tcpAddr := listener.Addr().(*net.TCPAddr)
+		if tcpAddr.IP.IsUnspecified() {
+			return nil
+		}
+		ips = append(ips, tcpAddr.IP)
+	}
+	return ips
+}
+
 // StopListening stops the server from accepting new connections.
 func (server *Server) StopListening() error {
-	if err := server.listener.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+	var errs []error
+	for _, listener := range server.listeners {
+		if err := listener.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
 		return fmt.Errorf("stop listening: %w", err)
 	}
 	return nil
 }
 
-func resolveListenHost(interfaceValue *string) (string, error) {
-	if interfaceValue == nil || strings.TrimSpace(*interfaceValue) == "" {
-		return "", nil
-	}
-	value := strings.TrimSpace(*interfaceValue)
-	if ip := net.ParseIP(value); ip != nil {
-		return ip.String(), nil
-	}
+// listenAddress is a host to listen on. The addresses of a named interface are
+// optional, so that one that cannot be bound, such as an IPv6 address that is
+// still tentative, does not stop the server listening on the others.
+type listenAddress struct {
+	host     string
+	optional bool
+}
 
-	iface, err := net.InterfaceByName(value)
-	if err != nil {
-		// Preserve support for hostnames while allowing --iface to use the
-		// interface-name behavior described by the flag.
-		return value, nil
+// resolveListenAddresses returns the hosts to listen on for each interface
+// name or address. Addresses that are listed more than once are only listened
+// on once. Without any interfaces, the server listens on the wildcard address,
+// which accepts connections over both IPv4 and IPv6.
+func resolveListenAddresses(interfaces []string) ([]listenAddress, error) {
+	var addresses []listenAddress
+	seen := make(map[string]bool)
+	add := func(address listenAddress) {
+		if !seen[address.host] {
+			seen[address.host] = true
+			addresses = append(addresses, address)
+		}
 	}
-	ip, err := preferredInterfaceIP(iface)
-	if err != nil {
-		return "", err
+
+	for _, value := range interfaces {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if ip := net.ParseIP(value); ip != nil {
+			add(listenAddress{host: ip.String()})
+			continue
+		}
+
+		iface, err := net.InterfaceByName(value)
+		if err != nil {
+			// Preserve support for hostnames while allowing --iface to use the
+			// interface-name behavior described by the flag.
+			add(listenAddress{host: value})
+			continue
+		}
+		hosts, err := interfaceHosts(iface)
+		if err != nil {
+			return nil, err
+		}
+		for _, host := range hosts {
+			add(listenAddress{host: host, optional: true})
+		}
 	}
-	if ip.To4() == nil && ip.IsLinkLocalUnicast() {
-		return ip.String() + "%" + iface.Name, nil
+
+	if len(addresses) == 0 {
+		return []listenAddress{{host: ""}}, nil
 	}
-	return ip.String(), nil
+	return addresses, nil
 }
 
-func preferredInterfaceIP(iface *net.Interface) (net.IP, error) {
+// interfaceHosts returns the IPv4 and IPv6 addresses of an interface, IPv4
+// first. Link-local IPv6 addresses are qualified with the interface's name.
+func interfaceHosts(iface *net.Interface) ([]string, error) {
 	addrs, err := iface.Addrs()
 	if err != nil {
 		return nil, fmt.Errorf("list addresses for interface %s: %w", iface.Name, err)
 	}
-	var ipv6 net.IP
+	var ipv4, ipv6 []string
 	for _, addr := range addrs {
 		ip := ipFromAddr(addr)
-		if ip == nil {
-			continue
+		switch {
+		case ip == nil:
+		case ip.To4() != nil:
+			ipv4 = append(ipv4, ip.String())
+		case ip.IsLinkLocalUnicast():
+			ipv6 = append(ipv6, ip.String()+"%"+iface.Name)
+		default:
+			ipv6 = append(ipv6, ip.String())
+		}
+	}
+	if len(ipv4) == 0 && len(ipv6) == 0 {
+		return nil, fmt.Errorf("interface %s has no IP address", iface.Name)
+	}
+	return append(ipv4, ipv6...), nil
+}
+
+// listenersInterfaceNames returns the interfaces that own the listeners'
+// addresses, in the order of the listeners, or nil if any listener is bound to
+// every interface.
+func listenersInterfaceNames(listeners []net.Listener) ([]string, error) {
+	var names []string
+	for _, listener := range listeners {
+		listenerNames, err := listenerInterfaceNames(listener.Addr())
+		if err != nil {
+			return nil, err
 		}
-		if ip.To4() != nil {
-			return ip, nil
+		if listenerNames == nil {
+			return nil, nil
 		}
-		if ipv6 == nil {
-			ipv6 = ip
+		for _, name := range listenerNames {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
 		}
 	}
-	if ipv6 != nil {
-		return ipv6, nil
-	}
-	return nil, fmt.Errorf("interface %s has no IP address", iface.Name)
+	return names, nil
 }
 
 func listenerInterfaceNames(addr net.Addr) ([]string, error) {
@@ -181,6 +315,20 @@ func listenerInterfaceNames(addr net.Addr) ([]string, error) {
 	return nil, fmt.Errorf("no network interface owns listener address %s", tcpAddr.IP)
 }
 
+// sessionHost is the address that sessions should listen on, so that they
+// share an interface with the Cast listener. A wildcard listener is reported as
+// an empty host, so that sessions also listen on every interface.
+func sessionHost(addr net.Addr) string {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok || tcpAddr.IP == nil || tcpAddr.IP.IsUnspecified() {
+		return ""
+	}
+	if tcpAddr.Zone != "" {
+		return tcpAddr.IP.String() + "%" + tcpAddr.Zone
+	}
+	return tcpAddr.IP.String()
+}
+
 func ipFromAddr(addr net.Addr) net.IP {
 	switch value := addr.(type) {
 	case *net.IPNet:
@@ -1,8 +1,17 @@
 package server
 
 import (
+	"crypto/tls"
 	"net"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/media"
+	"github.com/tristanpenman/go-cast/internal/session"
 )
 
 func TestListenerInterfaceNamesWildcardMeansAllInterfaces(t *testing.T) {
@@ -24,13 +33,165 @@ func TestServerInterfaceNamesReturnsCopy(t *testing.T) {
 	}
 }
 
-func TestResolveListenHostAcceptsIPAddress(t *testing.T) {
-	value := "192.0.2.10"
-	got, err := resolveListenHost(&value)
+func TestResolveListenAddressesAcceptsList(t *testing.T) {
+	got, err := resolveListenAddresses([]string{"192.0.2.10", " 2001:db8::10 ", "", "192.0.2.10", "receiver.local"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []listenAddress{{host: "192.0.2.10"}, {host: "2001:db8::10"}, {host: "receiver.local"}}
+	if !slices.Equal(got, want) {
+		t.Fatalf("listen addresses %v, want %v", got, want)
+	}
+
+	got, err = resolveListenAddresses(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, []listenAddress{{host: ""}}) {
+		t.Fatalf("listen addresses without interfaces %v, want the wildcard address", got)
+	}
+}
+
+func TestInterfaceHostsListsIPv4First(t *testing.T) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, iface := range interfaces {
+		hosts, err := interfaceHosts(&iface)
+		if err != nil {
+			continue
+		}
+		seenIPv6 := false
+		for _, host := range hosts {
+			ip := net.ParseIP(strings.SplitN(host, "%", 2)[0])
+			if ip == nil {
+				t.Fatalf("interface %s has invalid host %q", iface.Name, host)
+			}
+			if ip.To4() != nil && seenIPv6 {
+				t.Fatalf("interface %s lists IPv4 after IPv6: %v", iface.Name, hosts)
+			}
+			if ip.To4() == nil {
+				seenIPv6 = true
+				if ip.IsLinkLocalUnicast() != strings.Contains(host, "%") {
+					t.Fatalf("interface %s has unexpected zone in %q", iface.Name, host)
+				}
+			}
+		}
+	}
+}
+
+func TestServerListensOnIPv4AndIPv6(t *testing.T) {
+	probe, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skip("IPv6 loopback is not available:", err)
+	}
+	_ = probe.Close()
+
+	device := NewDevice(session.CaptureConfig{}, "go-cast", media.FanOut{}, "Test", "device-id", session.RecordingConfig{}, session.PortRange{}, "udn")
+	castServer, err := NewServer(device, testManifest(t), nil, []string{"127.0.0.1", "::1"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = castServer.StopListening() })
+
+	ips := castServer.ListenIPs()
+	if len(ips) != 2 || !ips[0].Equal(net.IPv4(127, 0, 0, 1)) || !ips[1].Equal(net.IPv6loopback) {
+		t.Fatalf("listening on %v, want 127.0.0.1 and ::1", ips)
+	}
+
+	// the listeners share the port that was chosen for the first
+	port := castServer.listeners[0].Addr().(*net.TCPAddr).Port
+	for _, host := range []string{"127.0.0.1", "::1"} {
+		conn, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+		if err != nil {
+			t.Fatalf("connect over %s: %v", host, err)
+		}
+		_ = conn.Close()
+	}
+
+	names := castServer.InterfaceNames()
+	if len(names) != 1 {
+		t.Fatalf("loopback listeners are on interfaces %v, want one", names)
+	}
+
+	if err := castServer.StopListening(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := net.Dial("tcp", net.JoinHostPort("::1", strconv.Itoa(port))); err == nil {
+		t.Fatal("IPv6 listener accepted a connection after StopListening")
+	}
+}
+
+func TestServerWildcardListenerReportsAllInterfaces(t *testing.T) {
+	device := NewDevice(session.CaptureConfig{}, "go-cast", media.FanOut{}, "Test", "device-id", session.RecordingConfig{}, session.PortRange{}, "udn")
+	castServer, err := NewServer(device, testManifest(t), nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = castServer.StopListening() })
+
+	if ips := castServer.ListenIPs(); ips != nil {
+		t.Fatalf("wildcard listener reported addresses %v", ips)
+	}
+	if names := castServer.InterfaceNames(); names != nil {
+		t.Fatalf("wildcard listener reported interfaces %v", names)
+	}
+	port := castServer.listeners[0].Addr().(*net.TCPAddr).Port
+	conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+}
+
+func TestSessionHostFollowsListenerAddress(t *testing.T) {
+	tests := []struct {
+		addr net.Addr
+		want string
+	}{
+		{addr: &net.TCPAddr{IP: net.IPv4zero, Port: 8009}, want: ""},
+		{addr: &net.TCPAddr{IP: net.IPv6unspecified, Port: 8009}, want: ""},
+		{addr: &net.TCPAddr{IP: net.IPv4(192, 0, 2, 10), Port: 8009}, want: "192.0.2.10"},
+		{addr: &net.TCPAddr{IP: net.ParseIP("fe80::1"), Port: 8009, Zone: "en0"}, want: "fe80::1%en0"},
+	}
+
+	for _, test := range tests {
+		if got := sessionHost(test.addr); got != test.want {
+			t.Fatalf("sessionHost(%v) = %q, want %q", test.addr, got, test.want)
+		}
+	}
+}
+
+func TestServerForgetsClosedConnections(t *testing.T) {
+	device := NewDevice(session.CaptureConfig{}, "go-cast", media.FanOut{}, "Test", "device-id", session.RecordingConfig{}, session.PortRange{}, "udn")
+	castServer, err := NewServer(device, testManifest(t), nil, []string{"127.0.0.1"}, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if got != value {
-		t.Fatalf("listen host %q, want %q", got, value)
+	t.Cleanup(func() { _ = castServer.StopListening() })
+
+	waitForConnections := func(want int) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for len(castServer.ConnectionStats()) != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("server has connections %+v, want %d", castServer.ConnectionStats(), want)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	conn, err := tls.Dial("tcp", castServer.listeners[0].Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForConnections(1)
+
+	_ = conn.Close()
+	waitForConnections(0)
+
+	if _, subscriptions, _ := device.lookupTransport(receiverTransportId); len(subscriptions) != 0 {
+		t.Fatalf("closed connection is still subscribed: %+v", subscriptions)
 	}
 }
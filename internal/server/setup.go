@@ -0,0 +1,48 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// setEurekaInfoRequest is the part of a set_eureka_info request that go-cast
+// applies.
+type setEurekaInfoRequest struct {
+	Name *string `json:"name"`
+}
+
+// NewSetupHandler returns a handler for POST /setup/set_eureka_info, which
+// renames the device in the same way that Chromecasts are renamed, with a body
+// such as {"name": "Kitchen"}. Other settings are ignored. Like DIAL launches,
+// requests from web pages other than those in dialOrigins are refused.
+func NewSetupHandler(device *Device) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !allowedDIALOrigin(r.Header.Get("Origin")) {
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+
+		var request setEurekaInfoRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&request); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		if request.Name != nil {
+			name := strings.TrimSpace(*request.Name)
+			if name == "" {
+				http.Error(w, "name must not be empty", http.StatusBadRequest)
+				return
+			}
+			device.log.Info("renaming device", "name", name)
+			device.SetFriendlyName(name)
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	// third-party
+	"github.com/hashicorp/go-hclog"
+)
+
+func TestSetupHandlerRenamesDevice(t *testing.T) {
+	device := &Device{FriendlyName: "Living Room", log: hclog.NewNullLogger()}
+	changes, stopWatching := device.watchChanges()
+	defer stopWatching()
+
+	handler := NewSetupHandler(device)
+	post := func(body string) int {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/setup/set_eureka_info", strings.NewReader(body)))
+		return recorder.Code
+	}
+
+	if code := post(`{"name":" Kitchen "}`); code != http.StatusOK {
+		t.Fatalf("rename returned %d", code)
+	}
+	if name := device.friendlyName(); name != "Kitchen" {
+		t.Fatalf("device is named %q, want Kitchen", name)
+	}
+	select {
+	case <-changes:
+	default:
+		t.Fatal("rename was not reported")
+	}
+
+	// settings other than the name are accepted, and leave the name alone
+	if code := post(`{"opt_in":{"stats":false}}`); code != http.StatusOK {
+		t.Fatalf("other settings returned %d", code)
+	}
+	for _, body := range []string{`{"name":""}`, `not json`} {
+		if code := post(body); code != http.StatusBadRequest {
+			t.Fatalf("%s returned %d, want %d", body, code, http.StatusBadRequest)
+		}
+	}
+	if name := device.friendlyName(); name != "Kitchen" {
+		t.Fatalf("device is named %q after invalid requests", name)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/setup/set_eureka_info", nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET returned %d", recorder.Code)
+	}
+}
+
+func TestSetupHandlerRefusesForeignOrigins(t *testing.T) {
+	device := &Device{FriendlyName: "Living Room", log: hclog.NewNullLogger()}
+	handler := NewSetupHandler(device)
+
+	for _, origin := range []string{"https://example.com", "http://192.168.1.10"} {
+		request := httptest.NewRequest(http.MethodPost, "/setup/set_eureka_info", strings.NewReader(`{"name":"Pwned"}`))
+		request.Header.Set("Origin", origin)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		if recorder.Code != http.StatusForbidden {
+			t.Fatalf("rename from %q returned %d, want %d", origin, recorder.Code, http.StatusForbidden)
+		}
+	}
+	if name := device.friendlyName(); name != "Living Room" {
+		t.Fatalf("device was renamed to %q from a foreign origin", name)
+	}
+
+	// native senders send a non-web origin
+	request := httptest.NewRequest(http.MethodPost, "/setup/set_eureka_info", strings.NewReader(`{"name":"Kitchen"}`))
+	request.Header.Set("Origin", "package:com.example.sender")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusOK || device.friendlyName() != "Kitchen" {
+		t.Fatalf("rename from a native sender returned %d, device is named %q", recorder.Code, device.friendlyName())
+	}
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	// third-party
+	"github.com/hashicorp/go-hclog"
+	"golang.org/x/net/ipv4"
+)
+
+var ssdpGroup = &net.UDPAddr{IP: net.IPv4(239, 255, 255, 250), Port: 1900}
+
+// maxSSDPDelay caps the delay that a search asks responses to be spread over,
+// as UPnP 1.1 does.
+const maxSSDPDelay = 5 * time.Second
+
+// ssdpResponder answers SSDP searches for the DIAL device, with the location
+// of its device description.
+type ssdpResponder struct {
+	conn     net.PacketConn
+	device   *Device
+	httpPort int
+	log      hclog.Logger
+
+	// delay returns how long to wait before responding to a search whose MX
+	// header allows responses to be spread over up to mx.
+	delay func(mx time.Duration) time.Duration
+}
+
+func newSSDPResponder(conn net.PacketConn, device *Device, httpPort int, log hclog.Logger) *ssdpResponder {
+	return &ssdpResponder{
+		conn:     conn,
+		device:   device,
+		httpPort: httpPort,
+		log:      log,
+		delay: func(mx time.Duration) time.Duration {
+			if mx <= 0 {
+				return 0
+			}
+			return rand.N(mx)
+		},
+	}
+}
+
+// listenSSDP opens a socket that is joined to the SSDP group on the given
+// interfaces, or on every multicast interface if there are none. Interfaces
+// that cannot join are skipped, but it is an error if none can.
+func listenSSDP(interfaceNames []string) (net.PacketConn, error) {
+	var interfaces []net.Interface
+	if len(interfaceNames) == 0 {
+		all, err := net.Interfaces()
+		if err != nil {
+			return nil, fmt.Errorf("list network interfaces: %w", err)
+		}
+		for _, iface := range all {
+			if iface.Flags&net.FlagUp != 0 && iface.Flags&net.FlagMulticast != 0 {
+				interfaces = append(interfaces, iface)
+			}
+		}
+	} else {
+		for _, name := range interfaceNames {
+			iface, err := net.InterfaceByName(name)
+			if err != nil {
+				return nil, fmt.Errorf("find interface %s: %w", name, err)
+			}
+			interfaces = append(interfaces, *iface)
+		}
+	}
+
+	// listening on the group address binds the wildcard address, and allows
+	// the port to be shared with other SSDP responders
+	packetConn, err := net.ListenPacket("udp4", ssdpGroup.String())
+	if err != nil {
+		return nil, fmt.Errorf("listen for ssdp: %w", err)
+	}
+
+	conn := ipv4.NewPacketConn(packetConn)
+	joined := 0
+	for i := range interfaces {
+		if err := conn.JoinGroup(&interfaces[i], ssdpGroup); err == nil {
+			joined++
+		}
+	}
+	if joined == 0 {
+		_ = packetConn.Close()
+		return nil, errors.New("join ssdp group: no interface could join")
+	}
+
+	return packetConn, nil
+}
+
+// serve answers searches until the responder is closed.
+func (responder *ssdpResponder) serve() {
+	buffer := make([]byte, 9000)
+	for {
+		n, remote, err := responder.conn.ReadFrom(buffer)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				responder.log.Error("failed to read ssdp message", "err", err)
+			}
+			return
+		}
+
+		targets, mx, ok := responder.parseSearch(buffer[:n])
+		if !ok {
+			continue
+		}
+		time.AfterFunc(responder.delay(mx), func() {
+			responder.respond(remote, targets)
+		})
+	}
+}
+
+func (responder *ssdpResponder) close() error {
+	return responder.conn.Close()
+}
+
+// parseSearch returns the search targets that an M-SEARCH asks for, which the
+// device matches, and the MX delay that responses may be spread over. It
+// reports false for other messages, and for searches that the device does not
+// match.
+func (responder *ssdpResponder) parseSearch(message []byte) ([]string, time.Duration, bool) {
+	request, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(message)))
+	if err != nil || request.Method != "M-SEARCH" || request.Header.Get("MAN") != `"ssdp:discover"` {
+		return nil, 0, false
+	}
+
+	uuid := "uuid:" + responder.device.Udn
+	var targets []string
+	switch st := request.Header.Get("ST"); st {
+	case "ssdp:all":
+		targets = []string{"upnp:rootdevice", uuid, dialDeviceType, dialServiceType}
+	case "upnp:rootdevice", uuid, dialDeviceType, dialServiceType:
+		targets = []string{st}
+	default:
+		return nil, 0, false
+	}
+
+	mx, _ := strconv.Atoi(request.Header.Get("MX"))
+	delay := min(time.Duration(max(mx, 0))*time.Second, maxSSDPDelay)
+	return targets, delay, true
+}
+
+// respond sends a response for each search target to the searcher.
+func (responder *ssdpResponder) respond(remote net.Addr, targets []string) {
+	location := fmt.Sprintf("http://%s/ssdp/device-desc.xml", net.JoinHostPort(localIPFor(remote).String(), strconv.Itoa(responder.httpPort)))
+	uuid := "uuid:" + responder.device.Udn
+
+	for _, target := range targets {
+		usn := uuid
+		if target != uuid {
+			usn += "::" + target
+		}
+
+		var response strings.Builder
+		response.WriteString("HTTP/1.1 200 OK\r\n")
+		response.WriteString("CACHE-CONTROL: max-age=1800\r\n")
+		response.WriteString("DATE: " + time.Now().UTC().Format(http.TimeFormat) + "\r\n")
+		response.WriteString("EXT:\r\n")
+		response.WriteString("LOCATION: " + location + "\r\n")
+		response.WriteString("SERVER: " + runtime.GOOS + "/1.0 UPnP/1.1 GoCast/1.0\r\n")
+		response.WriteString("ST: " + target + "\r\n")
+		response.WriteString("USN: " + usn + "\r\n")
+		response.WriteString("BOOTID.UPNP.ORG: 1\r\n")
+		response.WriteString("CONFIGID.UPNP.ORG: 1\r\n")
+		response.WriteString("\r\n")
+
+		if _, err := responder.conn.WriteTo([]byte(response.String()), remote); err != nil {
+			responder.log.Warn("failed to send ssdp response", "remote", remote, "err", err)
+			return
+		}
+	}
+}
+
+// localIPFor returns the local address that traffic to remote is sent from,
+// which is the address that the searcher can reach the device at. No packets
+// are sent to find it.
+func localIPFor(remote net.Addr) net.IP {
+	udpAddr, ok := remote.(*net.UDPAddr)
+	if !ok {
+		return net.IPv4zero
+	}
+	conn, err := net.DialUDP("udp", nil, udpAddr)
+	if err != nil {
+		return net.IPv4zero
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	return conn.LocalAddr().(*net.UDPAddr).IP
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	// third-party
+	"github.com/hashicorp/go-hclog"
+)
+
+// searchLoopback sends an M-SEARCH for st to a responder on a loopback socket,
+// and returns the responses that arrive before the timeout.
+func searchLoopback(t *testing.T, st string, want int) []*http.Response {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	responder := newSSDPResponder(conn, &Device{Udn: "device-udn"}, 8008, hclog.NewNullLogger())
+	responder.delay = func(time.Duration) time.Duration { return 0 }
+	go responder.serve()
+	t.Cleanup(func() {
+		_ = responder.close()
+	})
+
+	searcher, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = searcher.Close()
+	})
+
+	search := "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: 239.255.255.250:1900\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: 1\r\n" +
+		"ST: " + st + "\r\n\r\n"
+	if _, err := searcher.WriteTo([]byte(search), conn.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+
+	var responses []*http.Response
+	buffer := make([]byte, 9000)
+	_ = searcher.SetReadDeadline(time.Now().Add(250 * time.Millisecond))
+	for len(responses) < want+1 {
+		n, _, err := searcher.ReadFrom(buffer)
+		if err != nil {
+			break
+		}
+		response, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buffer[:n])), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		responses = append(responses, response)
+	}
+	if len(responses) != want {
+		t.Fatalf("search for %q got %d responses, want %d", st, len(responses), want)
+	}
+	return responses
+}
+
+func TestSSDPResponderAnswersDIALSearch(t *testing.T) {
+	responses := searchLoopback(t, dialDeviceType, 1)
+
+	header := responses[0].Header
+	if got := header.Get("ST"); got != dialDeviceType {
+		t.Errorf("ST %q, want %q", got, dialDeviceType)
+	}
+	if got, want := header.Get("USN"), "uuid:device-udn::"+dialDeviceType; got != want {
+		t.Errorf("USN %q, want %q", got, want)
+	}
+	if got, want := header.Get("LOCATION"), "http://127.0.0.1:8008/ssdp/device-desc.xml"; got != want {
+		t.Errorf("LOCATION %q, want %q", got, want)
+	}
+}
+
+func TestSSDPResponderAnswersEachTargetOfSearchForAll(t *testing.T) {
+	responses := searchLoopback(t, "ssdp:all", 4)
+
+	usns := make(map[string]string)
+	for _, response := range responses {
+		usns[response.Header.Get("ST")] = response.Header.Get("USN")
+	}
+	want := map[string]string{
+		"upnp:rootdevice": "uuid:device-udn::upnp:rootdevice",
+		"uuid:device-udn": "uuid:device-udn",
+		dialDeviceType:    "uuid:device-udn::" + dialDeviceType,
+		dialServiceType:   "uuid:device-udn::" + dialServiceType,
+	}
+	for st, usn := range want {
+		if usns[st] != usn {
+			t.Errorf("USN for %q is %q, want %q", st, usns[st], usn)
+		}
+	}
+}
+
+func TestSSDPResponderIgnoresOtherSearches(t *testing.T) {
+	searchLoopback(t, "urn:schemas-upnp-org:device:MediaRenderer:1", 0)
+	searchLoopback(t, "uuid:other-udn", 0)
+}
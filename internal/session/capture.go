@@ -0,0 +1,219 @@
+package session
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Session Capture File:
+//
+//	bytes 0-3    signature: 'GCAP'
+//	bytes 4-7    version (currently 1)
+//
+// The header is followed by records, each of which starts with a 13-byte
+// header:
+//
+//	byte  0      record type (1 = offer, 2 = datagram)
+//	bytes 1-8    time since the capture started, in nanoseconds
+//	bytes 9-12   length of the record data
+//
+// Offer records contain the complete UTF-8 payload of a WebRTC OFFER message,
+// including the AES key and IV mask needed to decrypt the stream. Datagram
+// records contain a raw UDP datagram, exactly as it was read from the socket.
+// All integers are big endian.
+
+const (
+	captureSignature     = "GCAP"
+	captureVersion       = 1
+	captureHeaderLength  = 8
+	captureRecordHeader  = 13
+	maxCaptureRecordSize = 1 << 20
+)
+
+// CaptureRecordType identifies the contents of a capture record.
+type CaptureRecordType uint8
+
+const (
+	OfferRecord    CaptureRecordType = 1
+	DatagramRecord CaptureRecordType = 2
+)
+
+// CaptureRecord is an offer or datagram received during a session.
+type CaptureRecord struct {
+	At   time.Duration
+	Data []byte
+	Type CaptureRecordType
+}
+
+// Capture is the complete contents of a capture file.
+type Capture struct {
+	Records []CaptureRecord
+}
+
+// ErrNoOffer is returned when a capture cannot be replayed because it does not
+// contain an offer.
+var ErrNoOffer = errors.New("capture does not contain an offer")
+
+// Validate checks that a capture starts with an offer, without which none of
+// its datagrams can be decrypted.
+func (capture *Capture) Validate() error {
+	if len(capture.Records) == 0 || capture.Records[0].Type != OfferRecord {
+		return ErrNoOffer
+	}
+
+	return nil
+}
+
+// ReadCapture reads every record from a capture file.
+func ReadCapture(input io.Reader) (*Capture, error) {
+	reader := bufio.NewReader(input)
+
+	header := make([]byte, captureHeaderLength)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, fmt.Errorf("read capture header: %w", err)
+	}
+	if string(header[:4]) != captureSignature {
+		return nil, errors.New("read capture header: not a capture file")
+	}
+	if version := binary.BigEndian.Uint32(header[4:]); version != captureVersion {
+		return nil, fmt.Errorf("read capture header: unsupported version %d", version)
+	}
+
+	capture := &Capture{}
+	recordHeader := make([]byte, captureRecordHeader)
+	for {
+		if _, err := io.ReadFull(reader, recordHeader); err == io.EOF {
+			return capture, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("read capture record: %w", err)
+		}
+
+		recordType := CaptureRecordType(recordHeader[0])
+		if recordType != OfferRecord && recordType != DatagramRecord {
+			return nil, fmt.Errorf("read capture record: unknown record type %d", recordType)
+		}
+
+		length := binary.BigEndian.Uint32(recordHeader[9:])
+		if length > maxCaptureRecordSize {
+			return nil, fmt.Errorf("read capture record: record too large (%d bytes)", length)
+		}
+
+		data := make([]byte, length)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, fmt.Errorf("read capture record: %w", err)
+		}
+
+		capture.Records = append(capture.Records, CaptureRecord{
+			At:   time.Duration(binary.BigEndian.Uint64(recordHeader[1:])),
+			Data: data,
+			Type: recordType,
+		})
+	}
+}
+
+// LoadCapture reads a capture file from disk.
+func LoadCapture(capturePath string) (*Capture, error) {
+	file, err := os.Open(capturePath)
+	if err != nil {
+		return nil, fmt.Errorf("load capture: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	return ReadCapture(file)
+}
+
+// CaptureWriter writes offers and datagrams to a capture file as they are
+// received. It is safe for concurrent use.
+type CaptureWriter struct {
+	mu sync.Mutex
+
+	output    io.WriteCloser
+	startedAt time.Time
+}
+
+// NewCaptureWriter writes a capture header to output. Record timestamps are
+// relative to startedAt.
+func NewCaptureWriter(output io.WriteCloser, startedAt time.Time) (*CaptureWriter, error) {
+	header := make([]byte, captureHeaderLength)
+	copy(header, captureSignature)
+	binary.BigEndian.PutUint32(header[4:], captureVersion)
+
+	if _, err := output.Write(header); err != nil {
+		return nil, fmt.Errorf("write capture header: %w", err)
+	}
+
+	return &CaptureWriter{output: output, startedAt: startedAt}, nil
+}
+
+// CaptureConfig controls where the offers and packets of sessions are
+// captured. Capture is disabled when Dir is empty.
+type CaptureConfig struct {
+	Dir string
+}
+
+// CreateCapture creates a capture file in dir, named after the session and the
+// time that the capture started.
+func CreateCapture(dir string, sessionId string) (*CaptureWriter, string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, "", fmt.Errorf("create capture directory: %w", err)
+	}
+
+	now := time.Now()
+	capturePath := filepath.Join(dir, fmt.Sprintf("%s-%s.gcap", now.Format("20060102-150405"), sessionId))
+
+	file, err := os.Create(capturePath)
+	if err != nil {
+		return nil, "", fmt.Errorf("create capture: %w", err)
+	}
+
+	writer, err := NewCaptureWriter(file, now)
+	if err != nil {
+		_ = file.Close()
+		_ = os.Remove(capturePath)
+		return nil, "", err
+	}
+
+	return writer, capturePath, nil
+}
+
+// WriteRecord appends a record, timestamped relative to the start of the
+// capture.
+func (writer *CaptureWriter) WriteRecord(recordType CaptureRecordType, data []byte, at time.Time) error {
+	header := make([]byte, captureRecordHeader)
+	header[0] = byte(recordType)
+	binary.BigEndian.PutUint64(header[1:], uint64(at.Sub(writer.startedAt)))
+	binary.BigEndian.PutUint32(header[9:], uint32(len(data)))
+
+	writer.mu.Lock()
+	defer writer.mu.Unlock()
+
+	if _, err := writer.output.Write(header); err != nil {
+		return fmt.Errorf("write capture record: %w", err)
+	}
+	if _, err := writer.output.Write(data); err != nil {
+		return fmt.Errorf("write capture record: %w", err)
+	}
+
+	return nil
+}
+
+// Close closes the capture file.
+func (writer *CaptureWriter) Close() error {
+	writer.mu.Lock()
+	defer writer.mu.Unlock()
+
+	if err := writer.output.Close(); err != nil {
+		return fmt.Errorf("close capture: %w", err)
+	}
+
+	return nil
+}
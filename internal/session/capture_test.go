@@ -0,0 +1,86 @@
+package session
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCaptureRoundTrip(t *testing.T) {
+	output := &nopWriteCloser{}
+	start := time.Now()
+
+	writer, err := NewCaptureWriter(output, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.WriteRecord(OfferRecord, []byte(`{"type":"OFFER"}`), start); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.WriteRecord(DatagramRecord, []byte{0x80, 0x60}, start.Add(20*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	capture, err := ReadCapture(bytes.NewReader(output.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []CaptureRecord{
+		{At: 0, Data: []byte(`{"type":"OFFER"}`), Type: OfferRecord},
+		{At: 20 * time.Millisecond, Data: []byte{0x80, 0x60}, Type: DatagramRecord},
+	}
+	if !reflect.DeepEqual(capture.Records, want) {
+		t.Fatalf("records %+v, want %+v", capture.Records, want)
+	}
+	if err := capture.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadCaptureRejectsMalformedFiles(t *testing.T) {
+	header := []byte("GCAP\x00\x00\x00\x01")
+	record := func(recordType byte, length uint32, data []byte) []byte {
+		recordHeader := make([]byte, captureRecordHeader)
+		recordHeader[0] = recordType
+		binary.BigEndian.PutUint32(recordHeader[9:], length)
+		return append(recordHeader, data...)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr string
+	}{
+		{name: "empty", data: nil, wantErr: "read capture header"},
+		{name: "wrong signature", data: []byte("RIFF\x00\x00\x00\x01"), wantErr: "not a capture file"},
+		{name: "unsupported version", data: []byte("GCAP\x00\x00\x00\x02"), wantErr: "unsupported version"},
+		{name: "truncated record header", data: append(header, 1, 0), wantErr: "read capture record"},
+		{name: "unknown record type", data: append(header, record(9, 0, nil)...), wantErr: "unknown record type"},
+		{name: "truncated record", data: append(header, record(2, 4, []byte{1})...), wantErr: "read capture record"},
+		{name: "oversized record", data: append(header, record(2, maxCaptureRecordSize+1, nil)...), wantErr: "too large"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ReadCapture(bytes.NewReader(test.data))
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Fatalf("ReadCapture() error = %v, want %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestCaptureValidateRequiresLeadingOffer(t *testing.T) {
+	capture := &Capture{Records: []CaptureRecord{{Type: DatagramRecord}, {Type: OfferRecord}}}
+	if err := capture.Validate(); !errors.Is(err, ErrNoOffer) {
+		t.Fatalf("Validate() = %v, want %v", err, ErrNoOffer)
+	}
+}
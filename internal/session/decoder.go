@@ -0,0 +1,55 @@
+package session
+
+import (
+	"fmt"
+	"image"
+
+	// third-party
+	"github.com/xlab/libvpx-go/vpx"
+)
+
+// videoDecoder decodes the VP8 frames of a single stream. Each stream has its
+// own decoder, so that a renegotiated stream starts from a clean state rather
+// than inheriting reference frames from the stream that it replaced.
+type videoDecoder struct {
+	ctx *vpx.CodecCtx
+}
+
+func newVideoDecoder() (*videoDecoder, error) {
+	ctx := vpx.NewCodecCtx()
+	if err := vpx.Error(vpx.CodecDecInitVer(ctx, vpx.DecoderIfaceVP8(), nil, 0, vpx.DecoderABIVersion)); err != nil {
+		return nil, fmt.Errorf("initialise vp8 decoder: %w", err)
+	}
+
+	return &videoDecoder{ctx: ctx}, nil
+}
+
+// decode decodes a frame. When render is set, the images that it produced are
+// copied out of the decoder and returned.
+func (decoder *videoDecoder) decode(payload []byte, render bool) ([]*image.YCbCr, error) {
+	if err := vpx.Error(vpx.CodecDecode(decoder.ctx, string(payload), uint32(len(payload)), nil, 0)); err != nil {
+		return nil, fmt.Errorf("decode frame: %w", err)
+	}
+
+	if !render {
+		return nil, nil
+	}
+
+	var images []*image.YCbCr
+	var iter vpx.CodecIter
+	for img := vpx.CodecGetFrame(decoder.ctx, &iter); img != nil; img = vpx.CodecGetFrame(decoder.ctx, &iter) {
+		img.Deref()
+		images = append(images, img.ImageYCbCr())
+	}
+
+	return images, nil
+}
+
+// close releases the decoder. It must not be used afterwards.
+func (decoder *videoDecoder) close() error {
+	if err := vpx.Error(vpx.CodecDestroy(decoder.ctx)); err != nil {
+		return fmt.Errorf("destroy vp8 decoder: %w", err)
+	}
+
+	return nil
+}
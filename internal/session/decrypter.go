@@ -1,51 +1,98 @@
 package session
 
 import (
-	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"encoding/binary"
+	"errors"
+	"fmt"
 )
 
-type Decrypter struct {
+// Cast Streaming encrypts each frame with AES-128 in counter mode. The initial
+// counter block for a frame is the session's IV mask, with bytes 8-11 XORed
+// with the big endian frame ID, so that no two frames share a key stream.
+
+const (
+	aesKeyLength    = 16
+	aesIvMaskLength = aes.BlockSize
+)
+
+var (
+	// ErrInvalidAesKey is returned when an offer's AES key is not 16 bytes.
+	ErrInvalidAesKey = errors.New("aes key must be 16 bytes")
+
+	// ErrInvalidAesIvMask is returned when an offer's IV mask is not 16 bytes.
+	ErrInvalidAesIvMask = errors.New("aes iv mask must be 16 bytes")
+)
+
+// frameCipher holds the state shared by the encrypter and decrypter. Counter
+// mode is symmetric, so both apply the same key stream.
+type frameCipher struct {
 	aesIvMask []byte
 	block     cipher.Block
 	stream    cipher.Stream
 }
 
-func (decrypter *Decrypter) Decrypt(payload []byte, output []byte) int {
-	decrypter.stream.XORKeyStream(output, payload)
-	return len(output)
-}
+func newFrameCipher(aesKey []byte, aesIvMask []byte) (frameCipher, error) {
+	if len(aesKey) != aesKeyLength {
+		return frameCipher{}, ErrInvalidAesKey
+	}
+	if len(aesIvMask) != aesIvMaskLength {
+		return frameCipher{}, ErrInvalidAesIvMask
+	}
 
-func (decrypter *Decrypter) Reset(frameNumber int) {
-	left := new(bytes.Buffer)
-	err := binary.Write(left, binary.BigEndian, uint32(frameNumber))
+	block, err := aes.NewCipher(aesKey)
 	if err != nil {
-		return
+		return frameCipher{}, fmt.Errorf("create aes cipher: %w", err)
 	}
 
-	iv := new(bytes.Buffer)
-	iv.Write(decrypter.aesIvMask)
+	aesIvMask = append([]byte(nil), aesIvMask...)
+
+	return frameCipher{
+		aesIvMask: aesIvMask,
+		block:     block,
+		stream:    cipher.NewCTR(block, aesIvMask),
+	}, nil
+}
+
+// frameIv returns the initial counter block for a frame.
+func frameIv(aesIvMask []byte, frameNumber int) []byte {
+	iv := append([]byte(nil), aesIvMask...)
 
-	for i := range left.Bytes() {
-		iv.Bytes()[i+8] = left.Bytes()[i] ^ iv.Bytes()[8+i]
+	var frameBytes [4]byte
+	binary.BigEndian.PutUint32(frameBytes[:], uint32(frameNumber))
+	for i, b := range frameBytes {
+		iv[8+i] ^= b
 	}
 
-	decrypter.stream = cipher.NewCTR(decrypter.block, iv.Bytes())
+	return iv
 }
 
-func NewDecrypter(aesKey []byte, aesIvMask []byte) *Decrypter {
-	block, err := aes.NewCipher(aesKey)
-	if err != nil {
-		return nil
-	}
+func (state *frameCipher) reset(frameNumber int) {
+	state.stream = cipher.NewCTR(state.block, frameIv(state.aesIvMask, frameNumber))
+}
 
-	stream := cipher.NewCTR(block, aesIvMask)
+// Decrypter decrypts the frames of a Cast Streaming stream.
+type Decrypter struct {
+	frameCipher
+}
 
-	return &Decrypter{
-		aesIvMask: aesIvMask,
-		block:     block,
-		stream:    stream,
+func (decrypter *Decrypter) Decrypt(payload []byte, output []byte) int {
+	decrypter.stream.XORKeyStream(output, payload)
+	return len(output)
+}
+
+// Reset prepares the decrypter for the start of a frame.
+func (decrypter *Decrypter) Reset(frameNumber int) {
+	decrypter.reset(frameNumber)
+}
+
+// NewDecrypter creates a decrypter from the AES key and IV mask in an offer.
+func NewDecrypter(aesKey []byte, aesIvMask []byte) (*Decrypter, error) {
+	state, err := newFrameCipher(aesKey, aesIvMask)
+	if err != nil {
+		return nil, fmt.Errorf("create decrypter: %w", err)
 	}
+
+	return &Decrypter{frameCipher: state}, nil
 }
@@ -5,13 +5,31 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"encoding/binary"
+	"errors"
 	"testing"
 )
 
 func TestNewDecrypterInvalidKeyLength(t *testing.T) {
-	if decrypter := NewDecrypter([]byte{0x00, 0x01, 0x02}, make([]byte, aes.BlockSize)); decrypter != nil {
+	decrypter, err := NewDecrypter([]byte{0x00, 0x01, 0x02}, make([]byte, aes.BlockSize))
+	if !errors.Is(err, ErrInvalidAesKey) {
+		t.Fatalf("expected invalid key error, got %v", err)
+	}
+	if decrypter != nil {
 		t.Fatalf("expected nil decrypter for invalid key length, got %#v", decrypter)
 	}
+
+	// Cast Streaming only uses AES-128, even though longer keys are valid AES
+	if _, err := NewDecrypter(make([]byte, 32), make([]byte, aes.BlockSize)); !errors.Is(err, ErrInvalidAesKey) {
+		t.Fatalf("expected invalid key error for 256-bit key, got %v", err)
+	}
+}
+
+func TestNewDecrypterInvalidIvMaskLength(t *testing.T) {
+	for _, length := range []int{0, 8, 12, 32} {
+		if _, err := NewDecrypter(make([]byte, 16), make([]byte, length)); !errors.Is(err, ErrInvalidAesIvMask) {
+			t.Fatalf("expected invalid iv mask error for %d bytes, got %v", length, err)
+		}
+	}
 }
 
 func TestDecryptWithoutReset(t *testing.T) {
@@ -34,9 +52,9 @@ func TestDecryptWithoutReset(t *testing.T) {
 	reference := cipher.NewCTR(block, ivMask)
 	reference.XORKeyStream(ciphertext, plaintext)
 
-	decrypter := NewDecrypter(key, ivMask)
-	if decrypter == nil {
-		t.Fatal("expected valid decrypter")
+	decrypter, err := NewDecrypter(key, ivMask)
+	if err != nil {
+		t.Fatalf("expected valid decrypter: %v", err)
 	}
 
 	output := make([]byte, len(ciphertext))
@@ -78,9 +96,9 @@ func TestDecryptAfterReset(t *testing.T) {
 	reference := cipher.NewCTR(block, iv)
 	reference.XORKeyStream(ciphertext, plaintext)
 
-	decrypter := NewDecrypter(key, ivMask)
-	if decrypter == nil {
-		t.Fatal("expected valid decrypter")
+	decrypter, err := NewDecrypter(key, ivMask)
+	if err != nil {
+		t.Fatalf("expected valid decrypter: %v", err)
 	}
 
 	decrypter.Reset(frameNumber)
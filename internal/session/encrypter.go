@@ -0,0 +1,33 @@
+package session
+
+import (
+	"fmt"
+)
+
+// Encrypter encrypts frames for a Cast Streaming stream, as a sender would. It
+// is the counterpart of Decrypter.
+type Encrypter struct {
+	frameCipher
+}
+
+// Encrypt encrypts the next part of the current frame.
+func (encrypter *Encrypter) Encrypt(payload []byte, output []byte) int {
+	encrypter.stream.XORKeyStream(output, payload)
+	return len(output)
+}
+
+// Reset prepares the encrypter for the start of a frame.
+func (encrypter *Encrypter) Reset(frameNumber int) {
+	encrypter.reset(frameNumber)
+}
+
+// NewEncrypter creates an encrypter from the AES key and IV mask that are
+// sent to the receiver in an offer.
+func NewEncrypter(aesKey []byte, aesIvMask []byte) (*Encrypter, error) {
+	state, err := newFrameCipher(aesKey, aesIvMask)
+	if err != nil {
+		return nil, fmt.Errorf("create encrypter: %w", err)
+	}
+
+	return &Encrypter{frameCipher: state}, nil
+}
@@ -0,0 +1,112 @@
+package session
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"testing/quick"
+)
+
+// frameKeys are the encryption parameters for a property test. Arrays are used
+// so that testing/quick generates keys and masks of the right length.
+type frameKeys struct {
+	AesIvMask [aesIvMaskLength]byte
+	AesKey    [aesKeyLength]byte
+}
+
+func (keys frameKeys) ciphers(t *testing.T) (*Encrypter, *Decrypter) {
+	t.Helper()
+
+	encrypter, err := NewEncrypter(keys.AesKey[:], keys.AesIvMask[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypter, err := NewDecrypter(keys.AesKey[:], keys.AesIvMask[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return encrypter, decrypter
+}
+
+func TestEncrypterRoundTrip(t *testing.T) {
+	roundTrip := func(keys frameKeys, frameNumber uint32, plaintext []byte) bool {
+		encrypter, decrypter := keys.ciphers(t)
+
+		ciphertext := make([]byte, len(plaintext))
+		encrypter.Reset(int(frameNumber))
+		encrypter.Encrypt(plaintext, ciphertext)
+
+		output := make([]byte, len(ciphertext))
+		decrypter.Reset(int(frameNumber))
+		decrypter.Decrypt(ciphertext, output)
+
+		return bytes.Equal(output, plaintext)
+	}
+
+	if err := quick.Check(roundTrip, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEncrypterRoundTripInParts(t *testing.T) {
+	// frames are decrypted as a whole, but senders may encrypt them in parts
+	roundTrip := func(keys frameKeys, frameNumber uint32, plaintext []byte, split uint16) bool {
+		encrypter, decrypter := keys.ciphers(t)
+
+		at := 0
+		if len(plaintext) > 0 {
+			at = int(split) % len(plaintext)
+		}
+
+		ciphertext := make([]byte, len(plaintext))
+		encrypter.Reset(int(frameNumber))
+		encrypter.Encrypt(plaintext[:at], ciphertext[:at])
+		encrypter.Encrypt(plaintext[at:], ciphertext[at:])
+
+		output := make([]byte, len(ciphertext))
+		decrypter.Reset(int(frameNumber))
+		decrypter.Decrypt(ciphertext, output)
+
+		return bytes.Equal(output, plaintext)
+	}
+
+	if err := quick.Check(roundTrip, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEncrypterUsesKeyStreamPerFrame(t *testing.T) {
+	distinct := func(keys frameKeys, first uint32, second uint32) bool {
+		if first == second {
+			return true
+		}
+
+		encrypter, decrypter := keys.ciphers(t)
+		plaintext := bytes.Repeat([]byte{0x5a}, 32)
+
+		ciphertext := make([]byte, len(plaintext))
+		encrypter.Reset(int(first))
+		encrypter.Encrypt(plaintext, ciphertext)
+
+		// a frame decrypted with the wrong frame ID is garbage
+		output := make([]byte, len(ciphertext))
+		decrypter.Reset(int(second))
+		decrypter.Decrypt(ciphertext, output)
+
+		return !bytes.Equal(output, plaintext)
+	}
+
+	if err := quick.Check(distinct, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewEncrypterValidatesKeys(t *testing.T) {
+	if _, err := NewEncrypter(make([]byte, 24), make([]byte, aesIvMaskLength)); !errors.Is(err, ErrInvalidAesKey) {
+		t.Fatalf("expected invalid key error, got %v", err)
+	}
+	if _, err := NewEncrypter(make([]byte, aesKeyLength), make([]byte, 4)); !errors.Is(err, ErrInvalidAesIvMask) {
+		t.Fatalf("expected invalid iv mask error, got %v", err)
+	}
+}
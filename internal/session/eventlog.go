@@ -0,0 +1,237 @@
+package session
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+
+	// third-party
+	"github.com/pion/rtcp"
+)
+
+// Cast Receiver Log (RTCP APP packet, subtype 2):
+//
+//  0                   1                   2                   3
+//  0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |V=2|P| Subtype |   PT=APP=204  |             Length            |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |                       SSRC of Receiver                        |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |               Unique identifier 'C' 'A' 'S' 'T'               |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |                    RTP Timestamp of Frame                     |  (repeated
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+   per frame)
+// | # Events - 1  |        Event Timestamp Base (msec)            |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |  Delay Delta or Packet ID     | Type  | Timestamp Delta (msec)|  (repeated
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+   per event)
+//
+// Event timestamps are relative to the same wall clock as the receiver
+// reference time reports, which senders use to estimate the clock offset.
+
+// ReceiverEventType identifies an event in a receiver log.
+type ReceiverEventType uint8
+
+// Receiver events report the progress of each frame through the receiver. The
+// playout event is logged when a frame leaves the jitter buffer to be decoded,
+// and its delay is how far ahead of its playout time that happened.
+const (
+	FrameAckSentEvent   ReceiverEventType = 11
+	FramePlayoutEvent   ReceiverEventType = 12
+	FrameDecodedEvent   ReceiverEventType = 13
+	PacketReceivedEvent ReceiverEventType = 14
+)
+
+const (
+	receiverLogSubtype        = 2
+	maxEventsPerFrame         = 16
+	maxEventTimestampDelta    = 0xfff
+	maxPendingReceiverEvents  = 256
+	maxReceiverEventsPerRtcp  = 64
+	receiverLogFrameHeaderLen = 8
+	receiverLogEventLen       = 4
+)
+
+// ReceiverEvent is an event in a receiver log. Delay is only meaningful for
+// playout events, and PacketID for packet events.
+type ReceiverEvent struct {
+	At           time.Time
+	Delay        time.Duration
+	PacketID     uint16
+	RTPTimestamp uint32
+	Type         ReceiverEventType
+}
+
+// receiverEventLog queues receiver events until they can be reported to the
+// sender in an RTCP compound packet.
+type receiverEventLog struct {
+	events []ReceiverEvent
+}
+
+func (eventLog *receiverEventLog) add(event ReceiverEvent) {
+	if len(eventLog.events) >= maxPendingReceiverEvents {
+		eventLog.events = eventLog.events[1:]
+	}
+	eventLog.events = append(eventLog.events, event)
+}
+
+// marshal removes up to maxReceiverEventsPerRtcp events from the queue and
+// encodes them as a Cast receiver log. It returns nil if there are no events.
+func (eventLog *receiverEventLog) marshal(receiverSsrc uint32) ([]byte, error) {
+	count := len(eventLog.events)
+	if count == 0 {
+		return nil, nil
+	}
+	if count > maxReceiverEventsPerRtcp {
+		count = maxReceiverEventsPerRtcp
+	}
+
+	events := eventLog.events[:count]
+	eventLog.events = eventLog.events[count:]
+
+	body := make([]byte, 8, 8+count*(receiverLogFrameHeaderLen+receiverLogEventLen))
+	binary.BigEndian.PutUint32(body, receiverSsrc)
+	copy(body[4:], "CAST")
+
+	for _, group := range groupReceiverEvents(events) {
+		base := group[0].At.UnixMilli()
+
+		frameHeader := make([]byte, receiverLogFrameHeaderLen)
+		binary.BigEndian.PutUint32(frameHeader, group[0].RTPTimestamp)
+		binary.BigEndian.PutUint32(frameHeader[4:], uint32(base)&0xffffff)
+		frameHeader[4] = uint8(len(group) - 1)
+		body = append(body, frameHeader...)
+
+		for _, event := range group {
+			eventBytes := make([]byte, receiverLogEventLen)
+			if event.Type == PacketReceivedEvent {
+				binary.BigEndian.PutUint16(eventBytes, event.PacketID)
+			} else {
+				binary.BigEndian.PutUint16(eventBytes, uint16(int16(event.Delay.Milliseconds())))
+			}
+			delta := uint16(event.At.UnixMilli() - base)
+			binary.BigEndian.PutUint16(eventBytes[2:], uint16(event.Type)<<12|delta)
+			body = append(body, eventBytes...)
+		}
+	}
+
+	header := rtcp.Header{
+		Count:  receiverLogSubtype,
+		Type:   rtcp.TypeApplicationDefined,
+		Length: uint16((headerLength+len(body))/4 - 1),
+	}
+	headerBytes, err := header.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	return append(headerBytes, body...), nil
+}
+
+// ReceiverLog is a Cast receiver log, as sent to a sender.
+type ReceiverLog struct {
+	ReceiverSSRC uint32
+	Events       []ReceiverEvent
+}
+
+// Unmarshal parses a receiver log. Event timestamps only carry the low 24 bits
+// of the receiver's wall clock in milliseconds, so each is expanded to the
+// time nearest to reference.
+func (receiverLog *ReceiverLog) Unmarshal(rawPacket []byte, reference time.Time) error {
+	if len(rawPacket) < headerLength+8 {
+		return errors.New("rtcp: packet too short")
+	}
+
+	var h rtcp.Header
+	if err := h.Unmarshal(rawPacket); err != nil {
+		return err
+	}
+	if h.Type != rtcp.TypeApplicationDefined || h.Count != receiverLogSubtype {
+		return errors.New("rtcp: wrong packet type")
+	}
+
+	body := rawPacket[headerLength:]
+	if string(body[4:8]) != "CAST" {
+		return errors.New("rtcp: missing CAST identifier")
+	}
+
+	receiverLog.ReceiverSSRC = binary.BigEndian.Uint32(body)
+	receiverLog.Events = nil
+
+	body = body[8:]
+	for len(body) > 0 {
+		if len(body) < receiverLogFrameHeaderLen {
+			return errors.New("rtcp: receiver log frame too short")
+		}
+
+		rtpTimestamp := binary.BigEndian.Uint32(body)
+		eventCount := int(body[4]) + 1
+		base := expandEventTimestamp(binary.BigEndian.Uint32(body[4:])&0xffffff, reference)
+		body = body[receiverLogFrameHeaderLen:]
+
+		if len(body) < eventCount*receiverLogEventLen {
+			return errors.New("rtcp: receiver log frame too short for events")
+		}
+
+		for i := 0; i < eventCount; i++ {
+			value := binary.BigEndian.Uint16(body)
+			typeAndDelta := binary.BigEndian.Uint16(body[2:])
+			body = body[receiverLogEventLen:]
+
+			event := ReceiverEvent{
+				At:           base.Add(time.Duration(typeAndDelta&maxEventTimestampDelta) * time.Millisecond),
+				RTPTimestamp: rtpTimestamp,
+				Type:         ReceiverEventType(typeAndDelta >> 12),
+			}
+			if event.Type == PacketReceivedEvent {
+				event.PacketID = value
+			} else {
+				event.Delay = time.Duration(int16(value)) * time.Millisecond
+			}
+			receiverLog.Events = append(receiverLog.Events, event)
+		}
+	}
+
+	return nil
+}
+
+// expandEventTimestamp recovers a wall clock time from the low 24 bits of its
+// value in milliseconds, choosing the candidate nearest to reference.
+func expandEventTimestamp(truncated uint32, reference time.Time) time.Time {
+	const wrap = 1 << 24
+
+	referenceMilli := reference.UnixMilli()
+	milli := referenceMilli&^(wrap-1) | int64(truncated)
+	if milli-referenceMilli > wrap/2 {
+		milli -= wrap
+	} else if referenceMilli-milli > wrap/2 {
+		milli += wrap
+	}
+
+	return time.UnixMilli(milli)
+}
+
+// groupReceiverEvents groups consecutive events by frame, starting a new group
+// whenever the event count or timestamp delta would overflow its field.
+func groupReceiverEvents(events []ReceiverEvent) [][]ReceiverEvent {
+	var groups [][]ReceiverEvent
+	byTimestamp := make(map[uint32]int)
+
+	for _, event := range events {
+		index, ok := byTimestamp[event.RTPTimestamp]
+		if ok {
+			group := groups[index]
+			delta := event.At.UnixMilli() - group[0].At.UnixMilli()
+			if len(group) < maxEventsPerFrame && delta >= 0 && delta <= maxEventTimestampDelta {
+				groups[index] = append(group, event)
+				continue
+			}
+		}
+
+		byTimestamp[event.RTPTimestamp] = len(groups)
+		groups = append(groups, []ReceiverEvent{event})
+	}
+
+	return groups
+}
@@ -0,0 +1,168 @@
+package session
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/pion/rtcp"
+)
+
+func TestReceiverEventLogMarshal(t *testing.T) {
+	base := time.UnixMilli(0x12345678)
+
+	var eventLog receiverEventLog
+	eventLog.add(ReceiverEvent{At: base, Type: PacketReceivedEvent, PacketID: 3, RTPTimestamp: 1000})
+	eventLog.add(ReceiverEvent{At: base.Add(5 * time.Millisecond), Type: FramePlayoutEvent, Delay: -20 * time.Millisecond, RTPTimestamp: 1000})
+	eventLog.add(ReceiverEvent{At: base.Add(7 * time.Millisecond), Type: FrameAckSentEvent, RTPTimestamp: 4000})
+
+	raw, err := eventLog.marshal(0xaabbccdd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(eventLog.events) != 0 {
+		t.Fatalf("%d events remain queued", len(eventLog.events))
+	}
+
+	var header rtcp.Header
+	if err := header.Unmarshal(raw); err != nil {
+		t.Fatal(err)
+	}
+	if header.Type != rtcp.TypeApplicationDefined || header.Count != receiverLogSubtype {
+		t.Fatalf("unexpected header %+v", header)
+	}
+	if (int(header.Length)+1)*4 != len(raw) {
+		t.Fatalf("header length %d does not match %d byte packet", header.Length, len(raw))
+	}
+
+	body := raw[headerLength:]
+	if binary.BigEndian.Uint32(body) != 0xaabbccdd || string(body[4:8]) != "CAST" {
+		t.Fatalf("unexpected receiver log header %x", body[:8])
+	}
+
+	// first frame: two events
+	frame := body[8:]
+	if binary.BigEndian.Uint32(frame) != 1000 || frame[4] != 1 {
+		t.Fatalf("unexpected frame header %x", frame[:8])
+	}
+	if timestampBase := binary.BigEndian.Uint32(frame[4:]) & 0xffffff; timestampBase != 0x345678 {
+		t.Fatalf("timestamp base %x, want 345678", timestampBase)
+	}
+	if binary.BigEndian.Uint16(frame[8:]) != 3 || binary.BigEndian.Uint16(frame[10:]) != uint16(PacketReceivedEvent)<<12 {
+		t.Fatalf("unexpected packet event %x", frame[8:12])
+	}
+	if int16(binary.BigEndian.Uint16(frame[12:])) != -20 || binary.BigEndian.Uint16(frame[14:]) != uint16(FramePlayoutEvent)<<12|5 {
+		t.Fatalf("unexpected playout event %x", frame[12:16])
+	}
+
+	// second frame: one event
+	frame = frame[16:]
+	if binary.BigEndian.Uint32(frame) != 4000 || frame[4] != 0 || len(frame) != 12 {
+		t.Fatalf("unexpected second frame %x", frame)
+	}
+}
+
+func TestReceiverEventLogMarshalWithoutEvents(t *testing.T) {
+	var eventLog receiverEventLog
+	raw, err := eventLog.marshal(1)
+	if err != nil || raw != nil {
+		t.Fatalf("marshal() = %x, %v; want nil, nil", raw, err)
+	}
+}
+
+func TestReceiverEventLogLimitsEventsPerPacket(t *testing.T) {
+	var eventLog receiverEventLog
+	now := time.Now()
+	for i := 0; i < maxReceiverEventsPerRtcp+10; i++ {
+		eventLog.add(ReceiverEvent{At: now, Type: PacketReceivedEvent, PacketID: uint16(i), RTPTimestamp: 1})
+	}
+
+	if _, err := eventLog.marshal(1); err != nil {
+		t.Fatal(err)
+	}
+	if len(eventLog.events) != 10 {
+		t.Fatalf("%d events remain queued, want 10", len(eventLog.events))
+	}
+}
+
+func TestGroupReceiverEventsSplitsLargeDeltas(t *testing.T) {
+	now := time.Now()
+	groups := groupReceiverEvents([]ReceiverEvent{
+		{At: now, RTPTimestamp: 1},
+		{At: now.Add(time.Duration(maxEventTimestampDelta+1) * time.Millisecond), RTPTimestamp: 1},
+	})
+	if len(groups) != 2 {
+		t.Fatalf("%d groups, want 2", len(groups))
+	}
+}
+
+func TestReceiverLogRoundTrip(t *testing.T) {
+	base := time.UnixMilli(0x12345678)
+
+	var eventLog receiverEventLog
+	events := []ReceiverEvent{
+		{At: base, PacketID: 3, RTPTimestamp: 1000, Type: PacketReceivedEvent},
+		{At: base.Add(5 * time.Millisecond), Delay: -20 * time.Millisecond, RTPTimestamp: 1000, Type: FramePlayoutEvent},
+		{At: base.Add(7 * time.Millisecond), RTPTimestamp: 1000, Type: FrameDecodedEvent},
+		{At: base.Add(9 * time.Millisecond), RTPTimestamp: 4000, Type: FrameAckSentEvent},
+	}
+	for _, event := range events {
+		eventLog.add(event)
+	}
+
+	raw, err := eventLog.marshal(0xaabbccdd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var receiverLog ReceiverLog
+	if err := receiverLog.Unmarshal(raw, base.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if receiverLog.ReceiverSSRC != 0xaabbccdd {
+		t.Fatalf("receiver SSRC %x, want aabbccdd", receiverLog.ReceiverSSRC)
+	}
+	if len(receiverLog.Events) != len(events) {
+		t.Fatalf("%d events, want %d", len(receiverLog.Events), len(events))
+	}
+	for i, event := range receiverLog.Events {
+		if event != events[i] {
+			t.Fatalf("event %d = %+v, want %+v", i, event, events[i])
+		}
+	}
+}
+
+func TestReceiverLogRejectsMalformedPackets(t *testing.T) {
+	var eventLog receiverEventLog
+	eventLog.add(ReceiverEvent{At: time.Now(), RTPTimestamp: 1, Type: FrameAckSentEvent})
+	raw, err := eventLog.marshal(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var receiverLog ReceiverLog
+	if err := receiverLog.Unmarshal(raw[:len(raw)-4], time.Now()); err == nil {
+		t.Fatal("expected an error for a truncated receiver log")
+	}
+
+	feedback := CastFeedback{ReceiverSSRC: 1, SenderSSRC: 2}
+	rawFeedback, err := feedback.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := receiverLog.Unmarshal(rawFeedback, time.Now()); err == nil {
+		t.Fatal("expected an error for cast feedback")
+	}
+}
+
+func TestExpandEventTimestampAcrossWrap(t *testing.T) {
+	reference := time.UnixMilli(0x13000002)
+
+	// shortly before the reference, but before the low 24 bits wrapped
+	if got := expandEventTimestamp(0xfffffe, reference); !got.Equal(time.UnixMilli(0x12fffffe)) {
+		t.Fatalf("expandEventTimestamp() = %x, want 12fffffe", got.UnixMilli())
+	}
+	if got := expandEventTimestamp(0x000004, reference); !got.Equal(time.UnixMilli(0x13000004)) {
+		t.Fatalf("expandEventTimestamp() = %x, want 13000004", got.UnixMilli())
+	}
+}
@@ -22,22 +22,49 @@ import (
 // +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
 // | CkPt Frame ID | # Loss Fields | Current Playout Delay (msec)  |
 // +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |    Frame ID   |       Lost Packet ID          | PID Bitmask   |  (repeated)
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//
+// Each loss field reports a lost packet, and bit N of the bitmask reports the
+// loss of the packet with ID (Lost Packet ID + N + 1). A Lost Packet ID of
+// AllPacketsLost reports that every packet in the frame was lost.
+
+// AllPacketsLost is the packet ID used in a loss field to report that none of
+// the packets in a frame were received.
+const AllPacketsLost uint16 = 0xffff
+
+// CastLossField identifies lost packets within a single frame.
+type CastLossField struct {
+	FrameId  uint8
+	PacketId uint16
+	Bitmask  uint8
+}
 
 type CastFeedback struct {
-	ReceiverSSRC        uint32
-	SenderSSRC          uint32
-	CkPtFrameId         uint8
-	LossFields          uint8
-	CurrentPlayoutDelay uint16
+	ReceiverSSRC uint32
+	SenderSSRC   uint32
+	CkPtFrameId  uint8
+	LossFields   []CastLossField
+
+	// TargetPlayoutDelay is the playout delay, in milliseconds, that the
+	// receiver is aiming for. It is not a measurement of how late frames are
+	// actually played.
+	TargetPlayoutDelay uint16
 }
 
 const (
-	headerLength = 4
-	bodyLength   = 4 // TODO: loss increases size
-	ssrcLength   = 4
+	headerLength    = 4
+	bodyLength      = 16
+	lossFieldLength = 4
+	maxLossFields   = 255
+	ssrcLength      = 4
 )
 
 func (feedback *CastFeedback) Marshal() ([]byte, error) {
+	if len(feedback.LossFields) > maxLossFields {
+		return nil, fmt.Errorf("rtcp: too many loss fields (%d)", len(feedback.LossFields))
+	}
+
 	rawPacket := make([]byte, feedback.len())
 	packetBody := rawPacket[headerLength:]
 
@@ -50,9 +77,17 @@ func (feedback *CastFeedback) Marshal() ([]byte, error) {
 	packetBody[ssrcLength*2+3] = 'T'
 
 	packetBody[ssrcLength*2+4] = feedback.CkPtFrameId
-	packetBody[ssrcLength*2+5] = feedback.LossFields
+	packetBody[ssrcLength*2+5] = uint8(len(feedback.LossFields))
+
+	binary.BigEndian.PutUint16(packetBody[ssrcLength*2+6:], feedback.TargetPlayoutDelay)
 
-	binary.BigEndian.PutUint16(packetBody[ssrcLength*2+6:], feedback.CurrentPlayoutDelay)
+	lossBody := packetBody[bodyLength:]
+	for i, field := range feedback.LossFields {
+		offset := i * lossFieldLength
+		lossBody[offset] = field.FrameId
+		binary.BigEndian.PutUint16(lossBody[offset+1:], field.PacketId)
+		lossBody[offset+3] = field.Bitmask
+	}
 
 	h := feedback.Header()
 	hData, err := h.Marshal()
@@ -78,8 +113,31 @@ func (feedback *CastFeedback) Unmarshal(rawPacket []byte) error {
 		return errors.New("rtcp: wrong packet type")
 	}
 
-	feedback.ReceiverSSRC = binary.BigEndian.Uint32(rawPacket[headerLength:])
-	feedback.SenderSSRC = binary.BigEndian.Uint32(rawPacket[headerLength+ssrcLength:])
+	packetBody := rawPacket[headerLength:]
+	if string(packetBody[ssrcLength*2:ssrcLength*2+4]) != "CAST" {
+		return errors.New("rtcp: missing CAST identifier")
+	}
+
+	lossFieldCount := int(packetBody[ssrcLength*2+5])
+	if len(packetBody) < bodyLength+lossFieldCount*lossFieldLength {
+		return errors.New("rtcp: packet too short for loss fields")
+	}
+
+	feedback.ReceiverSSRC = binary.BigEndian.Uint32(packetBody)
+	feedback.SenderSSRC = binary.BigEndian.Uint32(packetBody[ssrcLength:])
+	feedback.CkPtFrameId = packetBody[ssrcLength*2+4]
+	feedback.TargetPlayoutDelay = binary.BigEndian.Uint16(packetBody[ssrcLength*2+6:])
+
+	feedback.LossFields = make([]CastLossField, lossFieldCount)
+	lossBody := packetBody[bodyLength:]
+	for i := range feedback.LossFields {
+		offset := i * lossFieldLength
+		feedback.LossFields[i] = CastLossField{
+			FrameId:  lossBody[offset],
+			PacketId: binary.BigEndian.Uint16(lossBody[offset+1:]),
+			Bitmask:  lossBody[offset+3],
+		}
+	}
 
 	return nil
 }
@@ -88,14 +146,14 @@ func (feedback *CastFeedback) Header() rtcp.Header {
 	return rtcp.Header{
 		Count:  rtcp.FormatREMB,
 		Type:   rtcp.TypePayloadSpecificFeedback,
-		Length: bodyLength,
+		Length: uint16(feedback.len()/4 - 1),
 	}
 }
 
 func (feedback *CastFeedback) len() int {
-	return headerLength + 16
+	return headerLength + bodyLength + len(feedback.LossFields)*lossFieldLength
 }
 
 func (feedback *CastFeedback) String() string {
-	return fmt.Sprintf("CastFeedback %x %x", feedback.ReceiverSSRC, feedback.SenderSSRC)
+	return fmt.Sprintf("CastFeedback %x %x ckpt=%d losses=%d", feedback.ReceiverSSRC, feedback.SenderSSRC, feedback.CkPtFrameId, len(feedback.LossFields))
 }
@@ -0,0 +1,67 @@
+package session
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pion/rtcp"
+)
+
+func TestCastFeedbackRoundTripWithLossFields(t *testing.T) {
+	feedback := CastFeedback{
+		ReceiverSSRC:       0x11223344,
+		SenderSSRC:         0x55667788,
+		CkPtFrameId:        7,
+		TargetPlayoutDelay: 400,
+		LossFields: []CastLossField{
+			{FrameId: 8, PacketId: 2, Bitmask: 0x05},
+			{FrameId: 9, PacketId: AllPacketsLost},
+		},
+	}
+
+	raw, err := feedback.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(raw) != 28 {
+		t.Fatalf("marshalled %d bytes, want 28", len(raw))
+	}
+
+	var header rtcp.Header
+	if err := header.Unmarshal(raw); err != nil {
+		t.Fatal(err)
+	}
+	if int(header.Length) != len(raw)/4-1 {
+		t.Fatalf("header length %d does not match %d byte packet", header.Length, len(raw))
+	}
+
+	var decoded CastFeedback
+	if err := decoded.Unmarshal(raw); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, feedback) {
+		t.Fatalf("decoded %+v, want %+v", decoded, feedback)
+	}
+}
+
+func TestCastFeedbackRejectsTooManyLossFields(t *testing.T) {
+	feedback := CastFeedback{LossFields: make([]CastLossField, maxLossFields+1)}
+	if _, err := feedback.Marshal(); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestCastFeedbackUnmarshalRejectsTruncatedPackets(t *testing.T) {
+	feedback := CastFeedback{LossFields: []CastLossField{{FrameId: 1, PacketId: 3}}}
+	raw, err := feedback.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, length := range []int{8, headerLength + bodyLength - 1, len(raw) - 1} {
+		var decoded CastFeedback
+		if err := decoded.Unmarshal(raw[:length]); err == nil {
+			t.Fatalf("expected an error for %d byte packet", length)
+		}
+	}
+}
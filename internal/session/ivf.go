@@ -0,0 +1,95 @@
+package session
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// IVF File Header:
+//
+//	bytes 0-3    signature: 'DKIF'
+//	bytes 4-5    version (should be 0)
+//	bytes 6-7    length of header in bytes
+//	bytes 8-11   codec FourCC (e.g., 'VP80')
+//	bytes 12-13  width in pixels
+//	bytes 14-15  height in pixels
+//	bytes 16-19  time base denominator
+//	bytes 20-23  time base numerator
+//	bytes 24-27  number of frames in file
+//	bytes 28-31  unused
+//
+// Each frame is preceded by a 12-byte header containing the frame size and a
+// 64-bit timestamp, in units of the time base.
+
+const (
+	ivfHeaderLength      = 32
+	ivfFrameHeaderLength = 12
+	ivfFrameCountOffset  = 24
+)
+
+// ivfWriter writes VP8 frames to an IVF file, using the RTP clock as its time
+// base so that timestamps can be written without conversion.
+type ivfWriter struct {
+	frameCount uint32
+	output     io.Writer
+}
+
+func newIvfWriter(output io.Writer, width int, height int, clockRate uint32) (*ivfWriter, error) {
+	header := make([]byte, ivfHeaderLength)
+	copy(header, "DKIF")
+	binary.LittleEndian.PutUint16(header[4:], 0)
+	binary.LittleEndian.PutUint16(header[6:], ivfHeaderLength)
+	copy(header[8:], "VP80")
+	binary.LittleEndian.PutUint16(header[12:], uint16(width))
+	binary.LittleEndian.PutUint16(header[14:], uint16(height))
+	binary.LittleEndian.PutUint32(header[16:], clockRate)
+	binary.LittleEndian.PutUint32(header[20:], 1)
+
+	if _, err := output.Write(header); err != nil {
+		return nil, fmt.Errorf("write ivf header: %w", err)
+	}
+
+	return &ivfWriter{output: output}, nil
+}
+
+func (writer *ivfWriter) writeFrame(data []byte, timestamp int64) error {
+	header := make([]byte, ivfFrameHeaderLength)
+	binary.LittleEndian.PutUint32(header, uint32(len(data)))
+	binary.LittleEndian.PutUint64(header[4:], uint64(timestamp))
+
+	if _, err := writer.output.Write(header); err != nil {
+		return fmt.Errorf("write ivf frame header: %w", err)
+	}
+	if _, err := writer.output.Write(data); err != nil {
+		return fmt.Errorf("write ivf frame: %w", err)
+	}
+
+	writer.frameCount++
+	return nil
+}
+
+// close fills in the frame count, if the output supports seeking. Players do
+// not rely on the frame count, so it is left as zero otherwise.
+func (writer *ivfWriter) close() error {
+	seeker, ok := writer.output.(io.WriteSeeker)
+	if !ok {
+		return nil
+	}
+
+	if _, err := seeker.Seek(ivfFrameCountOffset, io.SeekStart); err != nil {
+		return fmt.Errorf("seek to ivf frame count: %w", err)
+	}
+
+	frameCount := make([]byte, 4)
+	binary.LittleEndian.PutUint32(frameCount, writer.frameCount)
+	if _, err := seeker.Write(frameCount); err != nil {
+		return fmt.Errorf("write ivf frame count: %w", err)
+	}
+
+	if _, err := seeker.Seek(0, io.SeekEnd); err != nil {
+		return fmt.Errorf("seek to end of ivf file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// PortRange is an inclusive range of UDP ports for sessions to listen on. The
+// zero value selects an ephemeral port, chosen by the operating system.
+type PortRange struct {
+	Max int
+	Min int
+}
+
+// ParsePortRange parses a single port (e.g. "50000") or an inclusive range of
+// ports (e.g. "50000-50099"). An empty string selects an ephemeral port.
+func ParsePortRange(value string) (PortRange, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return PortRange{}, nil
+	}
+
+	minValue, maxValue, isRange := strings.Cut(value, "-")
+	if !isRange {
+		maxValue = minValue
+	}
+
+	minPort, err := parsePort(minValue)
+	if err != nil {
+		return PortRange{}, fmt.Errorf("invalid port range %q: %w", value, err)
+	}
+	maxPort, err := parsePort(maxValue)
+	if err != nil {
+		return PortRange{}, fmt.Errorf("invalid port range %q: %w", value, err)
+	}
+	if minPort > maxPort {
+		return PortRange{}, fmt.Errorf("invalid port range %q: first port is greater than last port", value)
+	}
+
+	return PortRange{Max: maxPort, Min: minPort}, nil
+}
+
+func parsePort(value string) (int, error) {
+	port, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || port < 1 || port > 65535 {
+		return 0, errors.New("ports must be between 1 and 65535")
+	}
+
+	return port, nil
+}
+
+func (ports PortRange) String() string {
+	if ports.Min == 0 {
+		return "ephemeral"
+	}
+
+	return fmt.Sprintf("%d-%d", ports.Min, ports.Max)
+}
+
+// ListenConfig controls where sessions listen for RTP and RTCP.
+type ListenConfig struct {
+	// Host is the local address to listen on, which is normally the address
+	// of the Cast listener. Sessions listen on every interface when it is
+	// empty.
+	Host  string
+	Ports PortRange
+}
+
+// listenUDP listens on the first free port in the configured range.
+func listenUDP(config ListenConfig) (net.PacketConn, error) {
+	if config.Ports.Min == 0 {
+		packetConn, err := net.ListenPacket("udp", net.JoinHostPort(config.Host, "0"))
+		if err != nil {
+			return nil, fmt.Errorf("listen for session packets: %w", err)
+		}
+
+		return packetConn, nil
+	}
+
+	var lastErr error
+	for port := config.Ports.Min; port <= config.Ports.Max; port++ {
+		packetConn, err := net.ListenPacket("udp", net.JoinHostPort(config.Host, strconv.Itoa(port)))
+		if err == nil {
+			return packetConn, nil
+		}
+		lastErr = err
+	}
+
+	return nil, fmt.Errorf("listen for session packets: no free port in range %s: %w", config.Ports, lastErr)
+}
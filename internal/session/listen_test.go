@@ -0,0 +1,102 @@
+package session
+
+import (
+	"net"
+	"testing"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/common"
+)
+
+func TestParsePortRange(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    PortRange
+		wantErr bool
+	}{
+		{value: "", want: PortRange{}},
+		{value: "50000", want: PortRange{Max: 50000, Min: 50000}},
+		{value: "50000-50099", want: PortRange{Max: 50099, Min: 50000}},
+		{value: " 50000 - 50099 ", want: PortRange{Max: 50099, Min: 50000}},
+		{value: "50099-50000", wantErr: true},
+		{value: "0-10", wantErr: true},
+		{value: "50000-70000", wantErr: true},
+		{value: "fifty", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, err := ParsePortRange(test.value)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("ParsePortRange(%q) = %+v, want error", test.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Fatalf("ParsePortRange(%q) = %+v, want %+v", test.value, got, test.want)
+			}
+		})
+	}
+}
+
+func TestListenUDPUsesEphemeralPorts(t *testing.T) {
+	config := ListenConfig{Host: "127.0.0.1"}
+
+	first, err := listenUDP(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = first.Close()
+	}()
+
+	second, err := listenUDP(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = second.Close()
+	}()
+
+	if common.GetPort(first.LocalAddr()) == common.GetPort(second.LocalAddr()) {
+		t.Fatal("sessions share a port")
+	}
+}
+
+func TestListenUDPSkipsPortsInUse(t *testing.T) {
+	taken, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = taken.Close()
+	}()
+
+	port := common.GetPort(taken.LocalAddr())
+	if port == 65535 {
+		t.Skip("no port above the one in use")
+	}
+
+	config := ListenConfig{Host: "127.0.0.1", Ports: PortRange{Max: port + 1, Min: port}}
+	packetConn, err := listenUDP(config)
+	if err != nil {
+		// the next port may belong to another process
+		t.Skipf("port %d unavailable: %v", port+1, err)
+	}
+	defer func() {
+		_ = packetConn.Close()
+	}()
+
+	if got := common.GetPort(packetConn.LocalAddr()); got != port+1 {
+		t.Fatalf("listening on port %d, want %d", got, port+1)
+	}
+
+	config.Ports = PortRange{Max: port, Min: port}
+	if _, err := listenUDP(config); err == nil {
+		t.Fatal("expected an error when every port in the range is in use")
+	}
+}
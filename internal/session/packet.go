@@ -0,0 +1,93 @@
+package session
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Cast RTP Payload Header:
+//
+//  0                   1                   2                   3
+//  0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |K|R| EXT Count |    Frame ID   |           Packet ID           |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |         Max Packet ID         | Ref Frame ID  | Extensions... |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//
+// The reference frame ID is only present when R is set. Each extension starts
+// with a 6-bit type and 10-bit size, followed by that many bytes of data.
+
+const (
+	castHeaderLength       = 6
+	adaptiveLatencyExtType = 1
+)
+
+type castPacketHeader struct {
+	keyframe         bool
+	hasReference     bool
+	frameId          uint8
+	packetId         uint16
+	maxPacketId      uint16
+	referenceFrameId uint8
+
+	// newPlayoutDelay is the playout delay requested by the adaptive latency
+	// extension, or zero if the extension was not present.
+	newPlayoutDelay uint16
+}
+
+// parseCastPacket validates the Cast header at the start of an RTP payload and
+// returns it, along with the frame data that follows it.
+func parseCastPacket(payload []byte) (castPacketHeader, []byte, error) {
+	var header castPacketHeader
+	if len(payload) < castHeaderLength {
+		return header, nil, fmt.Errorf("cast payload too short (%d bytes)", len(payload))
+	}
+
+	header.keyframe = payload[0]&0x80 != 0
+	header.hasReference = payload[0]&0x40 != 0
+	numExt := int(payload[0] & 0x3f)
+	header.frameId = payload[1]
+	header.packetId = binary.BigEndian.Uint16(payload[2:])
+	header.maxPacketId = binary.BigEndian.Uint16(payload[4:])
+
+	if header.packetId > header.maxPacketId {
+		return header, nil, fmt.Errorf("packet id %d exceeds max packet id %d", header.packetId, header.maxPacketId)
+	}
+
+	offset := castHeaderLength
+	if header.hasReference {
+		if len(payload) < offset+1 {
+			return header, nil, errors.New("cast payload too short for reference frame id")
+		}
+		header.referenceFrameId = payload[offset]
+		offset++
+	}
+
+	for i := 0; i < numExt; i++ {
+		if len(payload) < offset+2 {
+			return header, nil, errors.New("cast payload too short for extension header")
+		}
+		typeAndSize := int(binary.BigEndian.Uint16(payload[offset:]))
+		dataType := typeAndSize >> 10
+		dataSize := typeAndSize & 0x3ff
+		offset += 2
+
+		if len(payload) < offset+dataSize {
+			return header, nil, fmt.Errorf("cast payload too short for extension type %d", dataType)
+		}
+		if dataType == adaptiveLatencyExtType && dataSize >= 2 {
+			header.newPlayoutDelay = binary.BigEndian.Uint16(payload[offset:])
+		}
+		offset += dataSize
+	}
+
+	return header, payload[offset:], nil
+}
+
+// expandFrameId recovers a full frame ID from the truncated 8-bit value that
+// appears on the wire, choosing the candidate nearest to expected.
+func expandFrameId(truncated uint8, expected int64) int64 {
+	return expected + int64(int8(truncated-uint8(expected)))
+}
@@ -0,0 +1,72 @@
+package session
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseCastPacketSkipsReferenceAndExtensions(t *testing.T) {
+	payload := []byte{
+		0xc2, 0x05, 0x00, 0x01, 0x00, 0x02, // keyframe, reference, two extensions
+		0x04,                   // reference frame ID
+		0x04, 0x02, 0x01, 0x90, // adaptive latency: 400ms
+		0x08, 0x01, 0xff, // unknown extension
+		'd', 'a', 't', 'a',
+	}
+
+	header, data, err := parseCastPacket(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !header.keyframe || !header.hasReference || header.referenceFrameId != 4 {
+		t.Fatalf("unexpected header flags: %+v", header)
+	}
+	if header.frameId != 5 || header.packetId != 1 || header.maxPacketId != 2 {
+		t.Fatalf("unexpected packet ids: %+v", header)
+	}
+	if header.newPlayoutDelay != 400 {
+		t.Fatalf("playout delay %d, want 400", header.newPlayoutDelay)
+	}
+	if !bytes.Equal(data, []byte("data")) {
+		t.Fatalf("unexpected frame data %q", data)
+	}
+}
+
+func TestParseCastPacketRejectsMalformedPayloads(t *testing.T) {
+	tests := map[string][]byte{
+		"empty":                 {},
+		"short header":          {0x00, 0x00, 0x00},
+		"missing reference":     {0x40, 0x00, 0x00, 0x00, 0x00, 0x00},
+		"missing extension":     {0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x04},
+		"truncated extension":   {0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x04, 0x02, 0x01},
+		"packet id exceeds max": {0x00, 0x00, 0x00, 0x02, 0x00, 0x01},
+	}
+
+	for name, payload := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, _, err := parseCastPacket(payload); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestExpandFrameIdHandlesWrapAround(t *testing.T) {
+	tests := []struct {
+		truncated uint8
+		expected  int64
+		want      int64
+	}{
+		{truncated: 0, expected: 0, want: 0},
+		{truncated: 2, expected: 255, want: 258},
+		{truncated: 254, expected: 256, want: 254},
+		{truncated: 255, expected: 0, want: -1},
+		{truncated: 10, expected: 1000, want: 1034},
+	}
+
+	for _, test := range tests {
+		if got := expandFrameId(test.truncated, test.expected); got != test.want {
+			t.Fatalf("expandFrameId(%d, %d) = %d, want %d", test.truncated, test.expected, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package session
+
+import (
+	"time"
+)
+
+const (
+	// defaultTargetDelay is the playout delay used when an offer does not
+	// specify a target delay.
+	defaultTargetDelay = 400 * time.Millisecond
+
+	// maxPlayoutLateness is how far past its playout time a frame can be
+	// rendered. Later frames are still decoded, since subsequent frames may
+	// depend on them, but they are not displayed.
+	maxPlayoutLateness = 50 * time.Millisecond
+
+	// playoutCheckInterval is how often streams are checked for frames that
+	// are due to be played out.
+	playoutCheckInterval = 5 * time.Millisecond
+)
+
+// playoutClock maps RTP timestamps to local time. Until a sender report
+// arrives, the first frame is assumed to have been captured when its first
+// packet arrived. Sender reports then anchor the mapping to the sender's
+// wall clock, offset by an estimate of the difference between the two clocks.
+type playoutClock struct {
+	clockRate     uint32
+	hasOffset     bool
+	hasReference  bool
+	offset        time.Duration
+	referenceRtp  uint32
+	referenceTime time.Time
+}
+
+// observeFrame anchors the clock to the first frame, if nothing better is
+// available.
+func (clock *playoutClock) observeFrame(rtpTimestamp uint32, arrival time.Time) {
+	if clock.hasReference {
+		return
+	}
+
+	clock.hasReference = true
+	clock.referenceRtp = rtpTimestamp
+	clock.referenceTime = arrival
+}
+
+// observeSenderReport anchors the clock to a sender report's NTP/RTP pair.
+// The clock offset includes the network delay, so the smallest sample is
+// preferred, but larger samples are slowly followed to account for drift.
+func (clock *playoutClock) observeSenderReport(ntpTime uint64, rtpTimestamp uint32, arrival time.Time) {
+	senderTime := fromNtpTime(ntpTime)
+	sample := arrival.Sub(senderTime)
+	if !clock.hasOffset || sample < clock.offset {
+		clock.offset = sample
+	} else {
+		clock.offset += (sample - clock.offset) / 8
+	}
+
+	clock.hasOffset = true
+	clock.hasReference = true
+	clock.referenceRtp = rtpTimestamp
+	clock.referenceTime = senderTime.Add(clock.offset)
+}
+
+// localTime returns the local time at which the frame with the given RTP
+// timestamp was captured.
+func (clock *playoutClock) localTime(rtpTimestamp uint32) time.Time {
+	ticks := int64(int32(rtpTimestamp - clock.referenceRtp))
+	return clock.referenceTime.Add(rtpDuration(ticks, clock.clockRate))
+}
+
+// rtpDuration converts a number of RTP clock ticks to a duration, without
+// overflowing for long-running streams.
+func rtpDuration(ticks int64, clockRate uint32) time.Duration {
+	rate := int64(clockRate)
+	return time.Duration(ticks/rate)*time.Second + time.Duration(ticks%rate)*time.Second/time.Duration(rate)
+}
@@ -0,0 +1,54 @@
+package session
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPlayoutClockUsesFirstFrame(t *testing.T) {
+	clock := playoutClock{clockRate: videoClockRate}
+	now := time.Now()
+
+	clock.observeFrame(1000, now)
+	clock.observeFrame(4000, now.Add(time.Second))
+
+	if got := clock.localTime(4000); !got.Equal(now.Add(time.Second / 30)) {
+		t.Fatalf("localTime() = %v, want %v", got, now.Add(time.Second/30))
+	}
+}
+
+func TestPlayoutClockHandlesTimestampWrapAround(t *testing.T) {
+	clock := playoutClock{clockRate: videoClockRate}
+	now := time.Now()
+
+	clock.observeFrame(0xffffffff-2999, now)
+	if got := clock.localTime(0); !got.Equal(now.Add(time.Second / 30)) {
+		t.Fatalf("localTime() = %v, want %v", got, now.Add(time.Second/30))
+	}
+	if got := clock.localTime(0xffffffff - 5999); !got.Equal(now.Add(-time.Second / 30)) {
+		t.Fatalf("localTime() = %v, want %v", got, now.Add(-time.Second/30))
+	}
+}
+
+func TestPlayoutClockPrefersSmallestOffset(t *testing.T) {
+	clock := playoutClock{clockRate: videoClockRate}
+	senderTime := time.Unix(1700000000, 0)
+	now := senderTime.Add(time.Hour)
+
+	clock.observeSenderReport(toNtpTime(senderTime), 0, now.Add(20*time.Millisecond))
+	clock.observeSenderReport(toNtpTime(senderTime.Add(time.Second)), 90000, now.Add(time.Second+10*time.Millisecond))
+	if clock.offset != time.Hour+10*time.Millisecond {
+		t.Fatalf("offset %v, want %v", clock.offset, time.Hour+10*time.Millisecond)
+	}
+
+	// larger samples are followed gradually
+	clock.observeSenderReport(toNtpTime(senderTime.Add(2*time.Second)), 180000, now.Add(2*time.Second+90*time.Millisecond))
+	if clock.offset != time.Hour+20*time.Millisecond {
+		t.Fatalf("offset %v, want %v", clock.offset, time.Hour+20*time.Millisecond)
+	}
+
+	want := now.Add(3*time.Second + 20*time.Millisecond)
+	if got := clock.localTime(270000); got.Sub(want).Abs() > time.Microsecond {
+		t.Fatalf("localTime() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,177 @@
+package session
+
+import (
+	"time"
+
+	// third-party
+	"github.com/pion/rtcp"
+)
+
+// ntpEpochOffset is the number of seconds between the NTP epoch (1900) and the
+// Unix epoch (1970).
+const ntpEpochOffset = 2208988800
+
+// toNtpTime converts a wall clock time to a 64-bit NTP timestamp.
+func toNtpTime(t time.Time) uint64 {
+	seconds := uint64(t.Unix() + ntpEpochOffset)
+	fraction := (uint64(t.Nanosecond()) << 32) / uint64(time.Second)
+	return seconds<<32 | fraction
+}
+
+// fromNtpTime converts a 64-bit NTP timestamp to a wall clock time.
+func fromNtpTime(ntpTime uint64) time.Time {
+	seconds := int64(ntpTime>>32) - ntpEpochOffset
+	nanoseconds := (ntpTime & 0xffffffff) * uint64(time.Second) >> 32
+	return time.Unix(seconds, int64(nanoseconds))
+}
+
+// isRtcpPacket distinguishes RTCP from RTP on a multiplexed port, using the
+// packet type ranges described in RFC 5761, section 4.
+func isRtcpPacket(data []byte) bool {
+	if len(data) < 2 || data[0]>>6 != 2 {
+		return false
+	}
+
+	return data[1] >= 192 && data[1] <= 223
+}
+
+// receptionStats tracks the RTP sequence numbers and arrival times needed to
+// fill in a reception report block, following RFC 3550, appendix A.
+type receptionStats struct {
+	baseSeq       uint32
+	clockRate     uint32
+	cycles        uint32
+	expectedPrior uint32
+	hasTransit    bool
+	initialized   bool
+	jitter        float64
+	lastTransit   int64
+	maxSeq        uint16
+	received      uint32
+	receivedPrior uint32
+	start         time.Time
+}
+
+func (stats *receptionStats) update(seq uint16, rtpTimestamp uint32, arrival time.Time) {
+	if !stats.initialized {
+		stats.baseSeq = uint32(seq)
+		stats.initialized = true
+		stats.maxSeq = seq
+		stats.start = arrival
+	} else if delta := seq - stats.maxSeq; delta != 0 && delta < 0x8000 {
+		if seq < stats.maxSeq {
+			stats.cycles += 1 << 16
+		}
+		stats.maxSeq = seq
+	}
+
+	stats.received++
+
+	// interarrival jitter is measured in RTP timestamp units
+	arrivalUnits := arrival.Sub(stats.start).Nanoseconds() * int64(stats.clockRate) / int64(time.Second)
+	transit := arrivalUnits - int64(rtpTimestamp)
+	if stats.hasTransit {
+		d := transit - stats.lastTransit
+		if d < 0 {
+			d = -d
+		}
+		stats.jitter += (float64(d) - stats.jitter) / 16
+	}
+	stats.hasTransit = true
+	stats.lastTransit = transit
+}
+
+func (stats *receptionStats) extendedHighestSeq() uint32 {
+	return stats.cycles + uint32(stats.maxSeq)
+}
+
+// lost returns the cumulative number of packets lost, which can be negative
+// when duplicates have been received.
+func (stats *receptionStats) lost() int64 {
+	if !stats.initialized {
+		return 0
+	}
+
+	expected := int64(stats.extendedHighestSeq()) - int64(stats.baseSeq) + 1
+	return expected - int64(stats.received)
+}
+
+// report fills in a reception report block, and starts a new interval for the
+// fraction lost calculation.
+func (stats *receptionStats) report(ssrc uint32) rtcp.ReceptionReport {
+	report := rtcp.ReceptionReport{SSRC: ssrc}
+	if !stats.initialized {
+		return report
+	}
+
+	expected := stats.extendedHighestSeq() - stats.baseSeq + 1
+	expectedInterval := expected - stats.expectedPrior
+	receivedInterval := stats.received - stats.receivedPrior
+	stats.expectedPrior = expected
+	stats.receivedPrior = stats.received
+
+	lostInterval := int64(expectedInterval) - int64(receivedInterval)
+	if expectedInterval != 0 && lostInterval > 0 {
+		report.FractionLost = uint8((lostInterval << 8) / int64(expectedInterval))
+	}
+
+	// the cumulative loss is a 24-bit signed value, but pion only accepts
+	// non-negative values
+	lost := stats.lost()
+	if lost < 0 {
+		lost = 0
+	} else if lost > 0x7fffff {
+		lost = 0x7fffff
+	}
+
+	report.TotalLost = uint32(lost)
+	report.LastSequenceNumber = stats.extendedHighestSeq()
+	report.Jitter = uint32(stats.jitter)
+
+	return report
+}
+
+// bitrateInterval is the length of the interval over which bitrate is
+// measured.
+const bitrateInterval = time.Second
+
+// bitrateMeter measures the rate at which data arrives, over consecutive
+// fixed-length intervals.
+type bitrateMeter struct {
+	bitrate       float64
+	bytes         uint64
+	intervalStart time.Time
+}
+
+func (meter *bitrateMeter) add(bytes int, now time.Time) {
+	if meter.intervalStart.IsZero() {
+		meter.intervalStart = now
+	}
+
+	meter.finishInterval(now)
+	meter.bytes += uint64(bytes)
+}
+
+// finishInterval updates the bitrate once the current interval has elapsed.
+func (meter *bitrateMeter) finishInterval(now time.Time) {
+	elapsed := now.Sub(meter.intervalStart)
+	if elapsed < bitrateInterval {
+		return
+	}
+
+	meter.bitrate = float64(meter.bytes*8) / elapsed.Seconds()
+	meter.bytes = 0
+	meter.intervalStart = now
+}
+
+// rate returns the bitrate measured over the most recent complete interval,
+// in bits per second.
+func (meter *bitrateMeter) rate(now time.Time) float64 {
+	if meter.intervalStart.IsZero() {
+		return 0
+	}
+
+	// an interval that ends without any data arriving still counts
+	meter.finishInterval(now)
+	return meter.bitrate
+}
@@ -0,0 +1,124 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+)
+
+func TestIsRtcpPacket(t *testing.T) {
+	senderReport, err := (&rtcp.SenderReport{SSRC: 1}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rtpPacket, err := (&rtp.Packet{Header: rtp.Header{Version: 2, PayloadType: 96, Marker: true}}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{name: "sender report", data: senderReport, want: true},
+		{name: "rtp with marker", data: rtpPacket, want: false},
+		{name: "too short", data: senderReport[:1], want: false},
+		{name: "wrong version", data: []byte{0x40, 200}, want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := isRtcpPacket(test.data); got != test.want {
+				t.Fatalf("isRtcpPacket() = %t, want %t", got, test.want)
+			}
+		})
+	}
+}
+
+func TestToNtpTime(t *testing.T) {
+	got := toNtpTime(time.Unix(1, int64(time.Second/2)))
+	want := uint64(ntpEpochOffset+1)<<32 | 1<<31
+	if got != want {
+		t.Fatalf("toNtpTime() = %x, want %x", got, want)
+	}
+}
+
+func TestReceptionStatsReportsLoss(t *testing.T) {
+	stats := receptionStats{clockRate: videoClockRate}
+	now := time.Now()
+	for _, seq := range []uint16{10, 11, 13, 14} {
+		stats.update(seq, 0, now)
+	}
+
+	report := stats.report(7)
+	if report.SSRC != 7 || report.TotalLost != 1 || report.LastSequenceNumber != 14 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if report.FractionLost != 256/5 {
+		t.Fatalf("fraction lost %d, want %d", report.FractionLost, 256/5)
+	}
+
+	// the fraction lost only covers the interval since the previous report
+	stats.update(15, 0, now)
+	report = stats.report(7)
+	if report.FractionLost != 0 || report.TotalLost != 1 {
+		t.Fatalf("unexpected second report %+v", report)
+	}
+}
+
+func TestReceptionStatsHandlesSequenceWrapAround(t *testing.T) {
+	stats := receptionStats{clockRate: videoClockRate}
+	now := time.Now()
+	for _, seq := range []uint16{65534, 65535, 0, 1} {
+		stats.update(seq, 0, now)
+	}
+
+	report := stats.report(1)
+	if report.LastSequenceNumber != 1<<16|1 || report.TotalLost != 0 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+}
+
+func TestReceptionStatsClampsDuplicateLoss(t *testing.T) {
+	stats := receptionStats{clockRate: videoClockRate}
+	now := time.Now()
+	for _, seq := range []uint16{1, 1, 2} {
+		stats.update(seq, 0, now)
+	}
+
+	if report := stats.report(1); report.TotalLost != 0 {
+		t.Fatalf("total lost %d, want 0", report.TotalLost)
+	}
+}
+
+func TestReceptionStatsMeasuresJitter(t *testing.T) {
+	stats := receptionStats{clockRate: videoClockRate}
+	start := time.Now()
+
+	// frames are sent every 3000 ticks (33ms), but alternate between arriving
+	// on time and 10ms late
+	for i := 0; i < 64; i++ {
+		arrival := start.Add(time.Duration(i) * time.Second / 30)
+		if i%2 == 1 {
+			arrival = arrival.Add(10 * time.Millisecond)
+		}
+		stats.update(uint16(i), uint32(i*3000), arrival)
+	}
+
+	// a constant 900 tick (10ms) variation converges on a jitter of 900
+	report := stats.report(1)
+	if report.Jitter < 850 || report.Jitter > 900 {
+		t.Fatalf("jitter %d, want approximately 900", report.Jitter)
+	}
+}
+
+func TestFromNtpTime(t *testing.T) {
+	want := time.Unix(1700000000, 123456000)
+	got := fromNtpTime(toNtpTime(want))
+	if diff := got.Sub(want); diff < -time.Microsecond || diff > time.Microsecond {
+		t.Fatalf("fromNtpTime() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,247 @@
+package session
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// RecordingFormat identifies the container used for session recordings.
+type RecordingFormat string
+
+const (
+	// IVFFormat records video only, in the simple container used by the
+	// libvpx tools.
+	IVFFormat RecordingFormat = "ivf"
+
+	// WebMFormat records video and, when available, Opus audio, in a file
+	// that most browsers and media players can open.
+	WebMFormat RecordingFormat = "webm"
+)
+
+// ParseRecordingFormat validates a recording format name.
+func ParseRecordingFormat(name string) (RecordingFormat, error) {
+	switch format := RecordingFormat(name); format {
+	case IVFFormat, WebMFormat:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unsupported recording format %q", name)
+	}
+}
+
+// RecordingConfig controls where and how sessions are recorded. Recording is
+// disabled when Dir is empty.
+type RecordingConfig struct {
+	Dir    string
+	Format RecordingFormat
+}
+
+// AudioTrack describes an Opus audio track in a recording.
+type AudioTrack struct {
+	Channels  int
+	ClockRate uint32
+}
+
+// rtpTimeline converts wrapping 32-bit RTP timestamps to a monotonic timeline
+// that starts at the first timestamp it sees.
+type rtpTimeline struct {
+	extended     int64
+	initialized  bool
+	lastRtpStamp uint32
+}
+
+func (timeline *rtpTimeline) extend(rtpTimestamp uint32) int64 {
+	if !timeline.initialized {
+		timeline.initialized = true
+	} else {
+		timeline.extended += int64(int32(rtpTimestamp - timeline.lastRtpStamp))
+	}
+
+	timeline.lastRtpStamp = rtpTimestamp
+	return timeline.extended
+}
+
+// vp8FrameSize reads the dimensions from the uncompressed header of a VP8
+// keyframe, as described in RFC 6386, section 9.1.
+func vp8FrameSize(frame []byte) (int, int, bool) {
+	if len(frame) < 10 || frame[0]&0x01 != 0 {
+		return 0, 0, false
+	}
+	if frame[3] != 0x9d || frame[4] != 0x01 || frame[5] != 0x2a {
+		return 0, 0, false
+	}
+
+	width := int(binary.LittleEndian.Uint16(frame[6:]) & 0x3fff)
+	height := int(binary.LittleEndian.Uint16(frame[8:]) & 0x3fff)
+	return width, height, true
+}
+
+// Recorder writes decrypted, compressed frames to a container file without
+// re-encoding them. Nothing is written until the first video keyframe, since
+// earlier frames cannot be decoded, and timestamps are taken from RTP time
+// relative to that keyframe. Audio and video have independent RTP clocks, so
+// the audio timeline is aligned with the video using the playout times of
+// their first frames, which both streams derive from sender reports.
+type Recorder struct {
+	mu sync.Mutex
+
+	audio          *AudioTrack
+	audioOffset    time.Duration
+	audioTimeline  rtpTimeline
+	closed         bool
+	format         RecordingFormat
+	ivf            *ivfWriter
+	output         io.WriteCloser
+	startedAt      time.Time
+	videoClockRate uint32
+	videoTimeline  rtpTimeline
+	webm           *webmWriter
+}
+
+// NewRecorder creates a recorder that writes to output. IVF recordings cannot
+// contain audio, so audio must be nil for the IVF format.
+func NewRecorder(output io.WriteCloser, format RecordingFormat, videoClockRate uint32, audio *AudioTrack) (*Recorder, error) {
+	if _, err := ParseRecordingFormat(string(format)); err != nil {
+		return nil, fmt.Errorf("create recorder: %w", err)
+	}
+	if format == IVFFormat && audio != nil {
+		return nil, errors.New("create recorder: ivf recordings cannot contain audio")
+	}
+
+	return &Recorder{
+		audio:          audio,
+		format:         format,
+		output:         output,
+		videoClockRate: videoClockRate,
+	}, nil
+}
+
+// CreateRecording creates a recording file in the configured directory, named
+// after the session and the time that recording started. A session that is
+// renegotiated within the same second is numbered, rather than overwriting
+// its earlier recording.
+func CreateRecording(config RecordingConfig, sessionId string, videoClockRate uint32, audio *AudioTrack) (*Recorder, string, error) {
+	if err := os.MkdirAll(config.Dir, 0o755); err != nil {
+		return nil, "", fmt.Errorf("create recording directory: %w", err)
+	}
+
+	base := fmt.Sprintf("%s-%s", time.Now().Format("20060102-150405"), sessionId)
+	name := fmt.Sprintf("%s.%s", base, config.Format)
+	recordingPath := filepath.Join(config.Dir, name)
+
+	file, err := os.OpenFile(recordingPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o666)
+	for n := 2; errors.Is(err, fs.ErrExist); n++ {
+		recordingPath = filepath.Join(config.Dir, fmt.Sprintf("%s-%d.%s", base, n, config.Format))
+		file, err = os.OpenFile(recordingPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o666)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("create recording: %w", err)
+	}
+
+	recorder, err := NewRecorder(file, config.Format, videoClockRate, audio)
+	if err != nil {
+		_ = file.Close()
+		_ = os.Remove(recordingPath)
+		return nil, "", err
+	}
+
+	return recorder, recordingPath, nil
+}
+
+func (recorder *Recorder) started() bool {
+	return recorder.ivf != nil || recorder.webm != nil
+}
+
+// WriteVideoFrame records a VP8 frame, which is due to be played at playoutAt.
+// Frames that precede the first keyframe, or that are written after the
+// recording is closed, are discarded.
+func (recorder *Recorder) WriteVideoFrame(frame []byte, keyframe bool, rtpTimestamp uint32, playoutAt time.Time) error {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+
+	if recorder.closed {
+		return nil
+	}
+
+	if !recorder.started() {
+		if !keyframe {
+			return nil
+		}
+
+		width, height, ok := vp8FrameSize(frame)
+		if !ok {
+			return errors.New("record video frame: keyframe is missing a vp8 header")
+		}
+
+		var err error
+		if recorder.format == IVFFormat {
+			recorder.ivf, err = newIvfWriter(recorder.output, width, height, recorder.videoClockRate)
+		} else {
+			recorder.webm, err = newWebmWriter(recorder.output, width, height, recorder.audio)
+		}
+		if err != nil {
+			return fmt.Errorf("record video frame: %w", err)
+		}
+
+		recorder.startedAt = playoutAt
+	}
+
+	timestamp := recorder.videoTimeline.extend(rtpTimestamp)
+	if recorder.ivf != nil {
+		return recorder.ivf.writeFrame(frame, timestamp)
+	}
+
+	timecode := timestamp * 1000 / int64(recorder.videoClockRate)
+	return recorder.webm.writeBlock(videoTrackNumber, frame, keyframe, timecode)
+}
+
+// WriteAudioFrame records an Opus frame, which is due to be played at
+// playoutAt. Frames that arrive before the video has started are discarded,
+// as are all frames when the recording has no audio track.
+func (recorder *Recorder) WriteAudioFrame(frame []byte, rtpTimestamp uint32, playoutAt time.Time) error {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+
+	if recorder.closed || recorder.webm == nil || recorder.audio == nil {
+		return nil
+	}
+
+	if !recorder.audioTimeline.initialized {
+		recorder.audioOffset = playoutAt.Sub(recorder.startedAt)
+	}
+
+	timestamp := recorder.audioTimeline.extend(rtpTimestamp)
+	timecode := timestamp*1000/int64(recorder.audio.ClockRate) + recorder.audioOffset.Milliseconds()
+	return recorder.webm.writeBlock(audioTrackNumber, frame, true, timecode)
+}
+
+// Close finishes the recording and closes its output. Closing a recording
+// more than once has no effect.
+func (recorder *Recorder) Close() error {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+
+	if recorder.closed {
+		return nil
+	}
+	recorder.closed = true
+
+	var err error
+	if recorder.ivf != nil {
+		err = recorder.ivf.close()
+	} else if recorder.webm != nil {
+		err = recorder.webm.close()
+	}
+
+	if closeErr := recorder.output.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("close recording: %w", closeErr)
+	}
+
+	return err
+}
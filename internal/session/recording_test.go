@@ -0,0 +1,529 @@
+package session
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testVP8Keyframe builds the uncompressed header of a VP8 keyframe.
+func testVP8Keyframe(width int, height int) []byte {
+	frame := []byte{0x10, 0x00, 0x00, 0x9d, 0x01, 0x2a, 0, 0, 0, 0, 0xaa}
+	binary.LittleEndian.PutUint16(frame[6:], uint16(width))
+	binary.LittleEndian.PutUint16(frame[8:], uint16(height))
+	return frame
+}
+
+var testVP8Interframe = []byte{0x11, 0x00, 0x00, 0xbb}
+
+type nopWriteCloser struct {
+	bytes.Buffer
+}
+
+func (writer *nopWriteCloser) Close() error {
+	return nil
+}
+
+func TestParseRecordingFormat(t *testing.T) {
+	for _, name := range []string{"ivf", "webm"} {
+		if format, err := ParseRecordingFormat(name); err != nil || string(format) != name {
+			t.Fatalf("ParseRecordingFormat(%q) = %q, %v", name, format, err)
+		}
+	}
+
+	if _, err := ParseRecordingFormat("mp4"); err == nil {
+		t.Fatal("expected an error for an unsupported format")
+	}
+}
+
+func TestVP8FrameSize(t *testing.T) {
+	width, height, ok := vp8FrameSize(testVP8Keyframe(1280, 720))
+	if !ok || width != 1280 || height != 720 {
+		t.Fatalf("vp8FrameSize() = %d, %d, %t", width, height, ok)
+	}
+
+	if _, _, ok := vp8FrameSize(testVP8Interframe); ok {
+		t.Fatal("read frame size from an interframe")
+	}
+}
+
+func TestRtpTimelineHandlesWrapAround(t *testing.T) {
+	var timeline rtpTimeline
+	var got []int64
+	for _, rtpTimestamp := range []uint32{0xffffffff - 2999, 0, 3000} {
+		got = append(got, timeline.extend(rtpTimestamp))
+	}
+
+	if want := []int64{0, 3000, 6000}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("extended timestamps %v, want %v", got, want)
+	}
+}
+
+func TestRecorderWritesIvfFromFirstKeyframe(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "recording.ivf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder, err := NewRecorder(file, IVFFormat, videoClockRate, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyframe := testVP8Keyframe(640, 360)
+	frames := []struct {
+		data         []byte
+		keyframe     bool
+		rtpTimestamp uint32
+	}{
+		{data: testVP8Interframe, rtpTimestamp: 1000},
+		{data: keyframe, keyframe: true, rtpTimestamp: 4000},
+		{data: testVP8Interframe, rtpTimestamp: 7000},
+	}
+	for _, frame := range frames {
+		if err := recorder.WriteVideoFrame(frame.data, frame.keyframe, frame.rtpTimestamp, time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := os.ReadFile(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(raw[:4]) != "DKIF" || string(raw[8:12]) != "VP80" {
+		t.Fatalf("unexpected ivf header %x", raw[:ivfHeaderLength])
+	}
+	if binary.LittleEndian.Uint16(raw[12:]) != 640 || binary.LittleEndian.Uint16(raw[14:]) != 360 {
+		t.Fatalf("unexpected dimensions in ivf header %x", raw[12:16])
+	}
+	if binary.LittleEndian.Uint32(raw[16:]) != videoClockRate || binary.LittleEndian.Uint32(raw[20:]) != 1 {
+		t.Fatalf("unexpected time base in ivf header %x", raw[16:24])
+	}
+	if binary.LittleEndian.Uint32(raw[ivfFrameCountOffset:]) != 2 {
+		t.Fatalf("frame count %d, want 2", binary.LittleEndian.Uint32(raw[ivfFrameCountOffset:]))
+	}
+
+	// the interframe before the keyframe is discarded
+	data, timestamps := readIvfFrames(t, raw)
+	if !reflect.DeepEqual(timestamps, []uint64{0, 3000}) {
+		t.Fatalf("timestamps %v, want [0 3000]", timestamps)
+	}
+	if !bytes.Equal(data[0], keyframe) || !bytes.Equal(data[1], testVP8Interframe) {
+		t.Fatalf("unexpected frame data %x", data)
+	}
+}
+
+// readIvfFrames returns the frames and timestamps from an IVF file.
+func readIvfFrames(t *testing.T, raw []byte) ([][]byte, []uint64) {
+	t.Helper()
+
+	if len(raw) < ivfHeaderLength || string(raw[:4]) != "DKIF" {
+		t.Fatalf("not an ivf file: %x", raw)
+	}
+
+	var frames [][]byte
+	var timestamps []uint64
+	for body := raw[ivfHeaderLength:]; len(body) > 0; {
+		if len(body) < ivfFrameHeaderLength {
+			t.Fatalf("truncated ivf frame header %x", body)
+		}
+		size := int(binary.LittleEndian.Uint32(body))
+		if len(body) < ivfFrameHeaderLength+size {
+			t.Fatalf("truncated ivf frame %x", body)
+		}
+		timestamps = append(timestamps, binary.LittleEndian.Uint64(body[4:]))
+		frames = append(frames, body[ivfFrameHeaderLength:ivfFrameHeaderLength+size])
+		body = body[ivfFrameHeaderLength+size:]
+	}
+
+	return frames, timestamps
+}
+
+func TestRecorderRejectsKeyframeWithoutHeader(t *testing.T) {
+	recorder, err := NewRecorder(&nopWriteCloser{}, IVFFormat, videoClockRate, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := recorder.WriteVideoFrame(testVP8Interframe, true, 0, time.Time{}); err == nil {
+		t.Fatal("expected an error for a keyframe without a vp8 header")
+	}
+}
+
+// ebmlChild is an element found while walking an EBML document.
+type ebmlChild struct {
+	id   uint32
+	data []byte
+}
+
+// readEbmlVint decodes a variable length integer. IDs keep their length
+// marker, while sizes do not.
+func readEbmlVint(t *testing.T, data []byte, keepMarker bool) (uint64, int) {
+	t.Helper()
+
+	length := 1
+	for length <= 8 && data[0]&(0x80>>(length-1)) == 0 {
+		length++
+	}
+	if length > 8 || len(data) < length {
+		t.Fatalf("invalid vint %x", data)
+	}
+
+	value := uint64(data[0])
+	if !keepMarker {
+		value &= uint64(0xff >> length)
+	}
+	for _, b := range data[1:length] {
+		value = value<<8 | uint64(b)
+	}
+
+	return value, length
+}
+
+func readEbmlChildren(t *testing.T, data []byte) []ebmlChild {
+	t.Helper()
+
+	var children []ebmlChild
+	for len(data) > 0 {
+		id, idLength := readEbmlVint(t, data, true)
+		size, sizeLength := readEbmlVint(t, data[idLength:], false)
+		data = data[idLength+sizeLength:]
+
+		// an unknown size extends to the end of the parent
+		if size == 1<<(7*uint(sizeLength))-1 || size > uint64(len(data)) {
+			size = uint64(len(data))
+		}
+
+		children = append(children, ebmlChild{id: uint32(id), data: data[:size]})
+		data = data[size:]
+	}
+
+	return children
+}
+
+func findEbmlChildren(t *testing.T, data []byte, id uint32) []ebmlChild {
+	t.Helper()
+
+	var found []ebmlChild
+	for _, child := range readEbmlChildren(t, data) {
+		if child.id == id {
+			found = append(found, child)
+		}
+	}
+
+	return found
+}
+
+func TestRecorderWritesWebm(t *testing.T) {
+	output := &nopWriteCloser{}
+	recorder, err := NewRecorder(output, WebMFormat, videoClockRate, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyframe := testVP8Keyframe(320, 240)
+
+	// frames before the first keyframe are discarded
+	if err := recorder.WriteVideoFrame(testVP8Interframe, false, 87000, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.WriteVideoFrame(keyframe, true, 90000, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.WriteVideoFrame(testVP8Interframe, false, 93000, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.WriteVideoFrame(keyframe, true, 90000+2*90000, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	top := readEbmlChildren(t, output.Bytes())
+	if len(top) != 2 || top[0].id != ebmlHeaderId || top[1].id != segmentId {
+		t.Fatalf("unexpected top level elements %+v", top)
+	}
+
+	docType := findEbmlChildren(t, top[0].data, ebmlDocTypeId)
+	if len(docType) != 1 || string(docType[0].data) != "webm" {
+		t.Fatalf("unexpected doc type %+v", docType)
+	}
+
+	segment := top[1].data
+	tracks := findEbmlChildren(t, segment, tracksId)
+	if len(tracks) != 1 {
+		t.Fatalf("%d tracks elements, want 1", len(tracks))
+	}
+	var codecs []string
+	for _, entry := range findEbmlChildren(t, tracks[0].data, trackEntryId) {
+		for _, codec := range findEbmlChildren(t, entry.data, codecId) {
+			codecs = append(codecs, string(codec.data))
+		}
+	}
+	if !reflect.DeepEqual(codecs, []string{"V_VP8"}) {
+		t.Fatalf("codecs %v, want [V_VP8]", codecs)
+	}
+
+	// each keyframe starts a new cluster
+	blocks, clusters := readWebmBlocks(t, segment)
+	if clusters != 2 {
+		t.Fatalf("%d clusters, want 2", clusters)
+	}
+	want := []webmBlock{
+		{track: videoTrackNumber, timecode: 0, keyframe: true, data: 0xaa},
+		{track: videoTrackNumber, timecode: 33, keyframe: false, data: 0xbb},
+		{track: videoTrackNumber, timecode: 2000, keyframe: true, data: 0xaa},
+	}
+	if !reflect.DeepEqual(blocks, want) {
+		t.Fatalf("blocks %+v, want %+v", blocks, want)
+	}
+}
+
+func TestRecorderWritesWebmWithAudio(t *testing.T) {
+	output := &nopWriteCloser{}
+	recorder, err := NewRecorder(output, WebMFormat, videoClockRate, &AudioTrack{Channels: 2, ClockRate: 48000})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	audio := []byte{0xfc, 0xff, 0xcc}
+
+	// audio before the video starts is discarded, and later audio is aligned
+	// by playout time, although its RTP clock starts somewhere else entirely
+	if err := recorder.WriteAudioFrame(audio, 1000, start.Add(-20*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.WriteVideoFrame(testVP8Keyframe(320, 240), true, 90000, start); err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.WriteAudioFrame(audio, 500000, start.Add(10*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.WriteAudioFrame(audio, 500000+960, start.Add(30*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.WriteVideoFrame(testVP8Interframe, false, 93000, start.Add(33*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	top := readEbmlChildren(t, output.Bytes())
+	segment := top[1].data
+	var codecs []string
+	for _, entry := range findEbmlChildren(t, findEbmlChildren(t, segment, tracksId)[0].data, trackEntryId) {
+		for _, codec := range findEbmlChildren(t, entry.data, codecId) {
+			codecs = append(codecs, string(codec.data))
+		}
+		if private := findEbmlChildren(t, entry.data, codecPrivateId); len(private) == 1 && !bytes.Equal(private[0].data, opusHead(2, 48000)) {
+			t.Fatalf("unexpected opus header %x", private[0].data)
+		}
+	}
+	if !reflect.DeepEqual(codecs, []string{"V_VP8", "A_OPUS"}) {
+		t.Fatalf("codecs %v, want [V_VP8 A_OPUS]", codecs)
+	}
+
+	// audio blocks are keyframes, but only video keyframes start clusters
+	blocks, clusters := readWebmBlocks(t, segment)
+	if clusters != 1 {
+		t.Fatalf("%d clusters, want 1", clusters)
+	}
+	want := []webmBlock{
+		{track: videoTrackNumber, timecode: 0, keyframe: true, data: 0xaa},
+		{track: audioTrackNumber, timecode: 10, keyframe: true, data: 0xcc},
+		{track: audioTrackNumber, timecode: 30, keyframe: true, data: 0xcc},
+		{track: videoTrackNumber, timecode: 33, keyframe: false, data: 0xbb},
+	}
+	if !reflect.DeepEqual(blocks, want) {
+		t.Fatalf("blocks %+v, want %+v", blocks, want)
+	}
+}
+
+func TestRecorderRejectsAudioInIvf(t *testing.T) {
+	if _, err := NewRecorder(&nopWriteCloser{}, IVFFormat, videoClockRate, &AudioTrack{Channels: 2, ClockRate: 48000}); err == nil {
+		t.Fatal("expected an error for an ivf recording with audio")
+	}
+}
+
+func TestRecorderIgnoresFramesAfterClose(t *testing.T) {
+	output := &nopWriteCloser{}
+	recorder, err := NewRecorder(output, WebMFormat, videoClockRate, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// frames from streams that are still being retired
+	if err := recorder.WriteVideoFrame(testVP8Keyframe(320, 240), true, 0, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if output.Len() != 0 {
+		t.Fatalf("wrote %d bytes after close", output.Len())
+	}
+}
+
+func TestRecorderWritesEachWebmFrameImmediately(t *testing.T) {
+	output := &nopWriteCloser{}
+	recorder, err := NewRecorder(output, WebMFormat, videoClockRate, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// senders rarely send keyframes, so a recording may be a single long
+	// cluster, and every frame must reach the file without waiting for the
+	// next one
+	if err := recorder.WriteVideoFrame(testVP8Keyframe(320, 240), true, 0, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 30; i++ {
+		if err := recorder.WriteVideoFrame(testVP8Interframe, false, uint32(i*3000), time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the receiver exits without closing the recording, part way through
+	// writing a frame
+	written := output.Bytes()
+	truncated := written[:len(written)-2]
+
+	top := readEbmlChildren(t, truncated)
+	if len(top) != 2 || top[1].id != segmentId {
+		t.Fatalf("unexpected top level elements %+v", top)
+	}
+	blocks, clusters := readWebmBlocks(t, top[1].data)
+	if clusters != 1 || len(blocks) != 30 {
+		t.Fatalf("read %d blocks in %d clusters from a truncated recording, want 30 in 1", len(blocks), clusters)
+	}
+	if last := blocks[len(blocks)-1]; last.timecode != 29*3000*1000/videoClockRate {
+		t.Fatalf("last complete block at %dms", last.timecode)
+	}
+}
+
+// webmBlock is a simple block read from a recording, with its absolute
+// timecode and the last byte of its frame.
+type webmBlock struct {
+	track    uint8
+	timecode int64
+	keyframe bool
+	data     byte
+}
+
+// readWebmBlocks returns the blocks in a segment, and the number of clusters
+// that they are in. Clusters have an unknown size, so each one ends where the
+// next begins. Reading stops at an element that has been cut short.
+func readWebmBlocks(t *testing.T, segment []byte) ([]webmBlock, int) {
+	t.Helper()
+
+	var blocks []webmBlock
+	var clusterTimecode int64
+	clusters := 0
+	for len(segment) > 0 {
+		id, idLength := readEbmlVint(t, segment, true)
+		size, sizeLength := readEbmlVint(t, segment[idLength:], false)
+		segment = segment[idLength+sizeLength:]
+
+		if uint32(id) == clusterId && size == 1<<(7*uint(sizeLength))-1 {
+			clusters++
+			continue
+		}
+		if size > uint64(len(segment)) {
+			break
+		}
+
+		data := segment[:size]
+		segment = segment[size:]
+		switch uint32(id) {
+		case timecodeId:
+			clusterTimecode = 0
+			for _, b := range data {
+				clusterTimecode = clusterTimecode<<8 | int64(b)
+			}
+		case simpleBlockId:
+			blocks = append(blocks, webmBlock{
+				track:    data[0] & 0x7f,
+				timecode: clusterTimecode + int64(int16(binary.BigEndian.Uint16(data[1:]))),
+				keyframe: data[3]&0x80 != 0,
+				data:     data[len(data)-1],
+			})
+		}
+	}
+
+	return blocks, clusters
+}
+
+func TestEbmlSizeUsesShortestEncoding(t *testing.T) {
+	tests := []struct {
+		size uint64
+		want []byte
+	}{
+		{size: 0, want: []byte{0x80}},
+		{size: 126, want: []byte{0xfe}},
+		{size: 127, want: []byte{0x40, 0x7f}},
+		{size: 300, want: []byte{0x41, 0x2c}},
+	}
+
+	for _, test := range tests {
+		if got := ebmlSize(test.size); !bytes.Equal(got, test.want) {
+			t.Fatalf("ebmlSize(%d) = %x, want %x", test.size, got, test.want)
+		}
+	}
+}
+
+func TestCreateRecordingNamesFileAfterSession(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "recordings")
+	recorder, recordingPath, err := CreateRecording(RecordingConfig{Dir: dir, Format: IVFFormat}, "session-1", videoClockRate, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if filepath.Dir(recordingPath) != dir || filepath.Ext(recordingPath) != ".ivf" {
+		t.Fatalf("unexpected recording path %q", recordingPath)
+	}
+	if matched, _ := filepath.Match("*-session-1.ivf", filepath.Base(recordingPath)); !matched {
+		t.Fatalf("recording %q is not named after the session", recordingPath)
+	}
+}
+
+func TestCreateRecordingKeepsEarlierRecordings(t *testing.T) {
+	dir := t.TempDir()
+	config := RecordingConfig{Dir: dir, Format: IVFFormat}
+
+	var paths []string
+	for range 3 {
+		recorder, recordingPath, err := CreateRecording(config, "session-1", videoClockRate, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := recorder.Close(); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, recordingPath)
+	}
+
+	if paths[0] == paths[1] || paths[1] == paths[2] || paths[0] == paths[2] {
+		t.Fatalf("recordings share paths %v", paths)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("recording directory has %d files, want 3 (%v)", len(entries), err)
+	}
+}
@@ -0,0 +1,471 @@
+package session
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	// third-party
+	"github.com/hashicorp/go-hclog"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/remoting"
+)
+
+const (
+	audioSourceStreamType = "audio_source"
+	remoteAudioCodecName  = "REMOTE_AUDIO"
+	remoteVideoCodecName  = "REMOTE_VIDEO"
+	remotingCastMode      = "remoting"
+	remotingRPCType       = "RPC"
+
+	// remotingVersion is the version of the remoting RPCs that the receiver
+	// reports in its capabilities.
+	remotingVersion = 2
+
+	// remotingReadAhead is how many frames the renderer asks each demuxer
+	// stream for at a time. More are requested when fewer than half of them
+	// remain outstanding, which must cover the frames held in the jitter buffer.
+	remotingReadAhead = 60
+
+	// remotingTimeUpdateInterval is how much media time passes between the
+	// time updates that are sent to the sender.
+	remotingTimeUpdateInterval = 250 * time.Millisecond
+)
+
+// remotingMessage is the JSON envelope of messages on the remoting namespace.
+// The RPC is a serialized remoting.Message, which encoding/json base64 encodes.
+type remotingMessage struct {
+	RPC  []byte `json:"rpc"`
+	Type string `json:"type"`
+}
+
+// selectRemotingStreams returns the first remoted audio stream and the first
+// remoted video stream in an offer, if present.
+func selectRemotingStreams(streams []SupportedStream) []*SupportedStream {
+	var audio, video *SupportedStream
+	for i := range streams {
+		stream := &streams[i]
+		switch {
+		case audio == nil && stream.Type == audioSourceStreamType && strings.EqualFold(stream.CodecName, remoteAudioCodecName):
+			audio = stream
+		case video == nil && stream.Type == videoSourceStreamType && strings.EqualFold(stream.CodecName, remoteVideoCodecName):
+			video = stream
+		}
+	}
+
+	selected := make([]*SupportedStream, 0, 2)
+	for _, stream := range []*SupportedStream{audio, video} {
+		if stream != nil {
+			selected = append(selected, stream)
+		}
+	}
+
+	return selected
+}
+
+// remoteDemuxer tracks a demuxer stream on the sender, which the renderer
+// pulls frames from. Counts are the total number of frames read from the
+// stream since it was initialized.
+type remoteDemuxer struct {
+	audio          *remoting.AudioDecoderConfig
+	callbackHandle int32
+	delivered      uint32
+	discardUntil   uint32
+	ended          bool
+	handle         int32
+	initialized    bool
+	readPending    bool
+	requested      uint32
+	video          *remoting.VideoDecoderConfig
+}
+
+// remotingRenderer plays remoted media on behalf of a sender. It implements
+// the receiver side of the renderer RPCs, and pulls frames from the sender's
+// demuxer streams. Frames themselves arrive over Cast Streaming, and are
+// passed to the renderer by the session's streams.
+type remotingRenderer struct {
+	mu                 sync.Mutex
+	bufferingReported  bool
+	callbacks          map[int32]*remoteDemuxer
+	clientHandle       int32
+	demuxers           map[int32]*remoteDemuxer
+	destinationID      string
+	handle             int32
+	initCallbackHandle int32
+	log                hclog.Logger
+	mediaTime          time.Duration
+	nextHandle         int32
+	sourceID           string
+	started            bool
+	timeUpdated        bool
+	timeUpdatedAt      time.Duration
+}
+
+func newRemotingRenderer(log hclog.Logger) *remotingRenderer {
+	renderer := &remotingRenderer{log: log}
+	renderer.reset()
+	return renderer
+}
+
+// reset discards the state of a previously acquired renderer.
+func (renderer *remotingRenderer) reset() {
+	renderer.bufferingReported = false
+	renderer.callbacks = make(map[int32]*remoteDemuxer)
+	renderer.clientHandle = remoting.InvalidHandle
+	renderer.demuxers = make(map[int32]*remoteDemuxer)
+	renderer.handle = remoting.InvalidHandle
+	renderer.initCallbackHandle = remoting.InvalidHandle
+	renderer.mediaTime = 0
+	renderer.started = false
+	renderer.timeUpdated = false
+	renderer.timeUpdatedAt = 0
+}
+
+func (renderer *remotingRenderer) allocateHandle() int32 {
+	if renderer.nextHandle < remoting.FirstHandle {
+		renderer.nextHandle = remoting.FirstHandle
+	}
+
+	handle := renderer.nextHandle
+	renderer.nextHandle++
+	return handle
+}
+
+// setPeer records the transport that remoting messages are exchanged with.
+func (renderer *remotingRenderer) setPeer(sourceID string, destinationID string) {
+	renderer.mu.Lock()
+	defer renderer.mu.Unlock()
+
+	renderer.destinationID = destinationID
+	renderer.sourceID = sourceID
+}
+
+// peer returns the source and destination IDs for messages to the sender.
+func (renderer *remotingRenderer) peer() (string, string, bool) {
+	renderer.mu.Lock()
+	defer renderer.mu.Unlock()
+
+	return renderer.sourceID, renderer.destinationID, renderer.destinationID != ""
+}
+
+// handleMessage processes an RPC message from the sender, and returns the
+// messages to send in response.
+func (renderer *remotingRenderer) handleMessage(message *remoting.Message) []remoting.Message {
+	renderer.mu.Lock()
+	defer renderer.mu.Unlock()
+
+	if message.Proc == remoting.ProcAcquireRenderer {
+		return renderer.acquire(message)
+	}
+
+	if message.Handle == renderer.handle && renderer.handle != remoting.InvalidHandle {
+		return renderer.handleRendererMessage(message)
+	}
+
+	if demuxer := renderer.callbacks[message.Handle]; demuxer != nil {
+		return renderer.handleDemuxerMessage(demuxer, message)
+	}
+
+	renderer.log.Warn("rpc message for unknown handle", "handle", message.Handle, "proc", message.Proc)
+	return nil
+}
+
+// acquire creates a renderer, replacing any that the sender acquired before.
+func (renderer *remotingRenderer) acquire(message *remoting.Message) []remoting.Message {
+	renderer.reset()
+	renderer.handle = renderer.allocateHandle()
+	renderer.log.Info("acquired remoting renderer", "handle", renderer.handle, "senderHandle", message.Integer)
+
+	return []remoting.Message{{
+		Handle:  message.Integer,
+		Integer: renderer.handle,
+		Proc:    remoting.ProcAcquireRendererDone,
+	}}
+}
+
+func (renderer *remotingRenderer) handleRendererMessage(message *remoting.Message) []remoting.Message {
+	switch message.Proc {
+	case remoting.ProcRendererInitialize:
+		return renderer.initialize(message.Initialize)
+	case remoting.ProcRendererFlushUntil:
+		return renderer.flushUntil(message.FlushUntil)
+	case remoting.ProcRendererStartPlayingFrom:
+		return renderer.startPlayingFrom(time.Duration(message.Integer64) * time.Microsecond)
+	case remoting.ProcRendererSetPlaybackRate:
+		renderer.log.Info("ignoring playback rate", "rate", message.Double)
+	case remoting.ProcRendererSetVolume:
+		renderer.log.Info("ignoring volume", "volume", message.Double)
+	default:
+		renderer.log.Warn("unsupported renderer rpc", "proc", message.Proc)
+	}
+
+	return nil
+}
+
+// initialize asks each of the sender's demuxer streams to describe itself.
+// The sender is told that the renderer is initialized once they all have.
+func (renderer *remotingRenderer) initialize(initialize *remoting.RendererInitialize) []remoting.Message {
+	if initialize == nil {
+		renderer.log.Error("renderer initialize rpc is missing its arguments")
+		return nil
+	}
+
+	renderer.clientHandle = initialize.ClientHandle
+	renderer.initCallbackHandle = initialize.CallbackHandle
+
+	var messages []remoting.Message
+	demuxerHandles := map[int32]int32{
+		remoting.StreamTypeAudio: initialize.AudioDemuxerHandle,
+		remoting.StreamTypeVideo: initialize.VideoDemuxerHandle,
+	}
+	for _, streamType := range []int32{remoting.StreamTypeAudio, remoting.StreamTypeVideo} {
+		handle := demuxerHandles[streamType]
+		if handle < remoting.FirstHandle {
+			continue
+		}
+
+		demuxer := &remoteDemuxer{
+			callbackHandle: renderer.allocateHandle(),
+			handle:         handle,
+		}
+		renderer.callbacks[demuxer.callbackHandle] = demuxer
+		renderer.demuxers[streamType] = demuxer
+
+		messages = append(messages, remoting.Message{
+			Handle:  handle,
+			Integer: demuxer.callbackHandle,
+			Proc:    remoting.ProcDemuxerStreamInitialize,
+		})
+	}
+
+	if len(messages) == 0 {
+		renderer.log.Error("renderer initialized without demuxer streams")
+		return []remoting.Message{renderer.initialized(false)}
+	}
+
+	return messages
+}
+
+// initialized reports the outcome of initialization to the sender.
+func (renderer *remotingRenderer) initialized(success bool) remoting.Message {
+	message := remoting.Message{
+		Boolean: success,
+		Handle:  renderer.initCallbackHandle,
+		Proc:    remoting.ProcRendererInitializeCallback,
+	}
+	renderer.initCallbackHandle = remoting.InvalidHandle
+
+	return message
+}
+
+// flushUntil discards the frames that the sender has read so far, which
+// happens when it seeks.
+func (renderer *remotingRenderer) flushUntil(flush *remoting.FlushUntil) []remoting.Message {
+	if flush == nil {
+		renderer.log.Error("renderer flush rpc is missing its arguments")
+		return nil
+	}
+
+	counts := map[int32]uint32{
+		remoting.StreamTypeAudio: flush.AudioCount,
+		remoting.StreamTypeVideo: flush.VideoCount,
+	}
+	for streamType, demuxer := range renderer.demuxers {
+		demuxer.discardUntil = counts[streamType]
+		demuxer.ended = false
+	}
+	renderer.started = false
+
+	return []remoting.Message{{
+		Handle: flush.CallbackHandle,
+		Proc:   remoting.ProcRendererFlushUntilCallback,
+	}}
+}
+
+func (renderer *remotingRenderer) startPlayingFrom(mediaTime time.Duration) []remoting.Message {
+	renderer.log.Info("starting remoting playback", "time", mediaTime)
+	renderer.bufferingReported = false
+	renderer.mediaTime = mediaTime
+	renderer.started = true
+	renderer.timeUpdated = false
+
+	var messages []remoting.Message
+	for _, demuxer := range renderer.demuxers {
+		if message, ok := renderer.read(demuxer); ok {
+			messages = append(messages, message)
+		}
+	}
+
+	return messages
+}
+
+// read asks a demuxer stream for more frames, when playback has started and
+// few of the frames requested so far remain outstanding.
+func (renderer *remotingRenderer) read(demuxer *remoteDemuxer) (remoting.Message, bool) {
+	if !renderer.started || !demuxer.initialized || demuxer.ended || demuxer.readPending {
+		return remoting.Message{}, false
+	}
+
+	requested := max(demuxer.requested, demuxer.delivered, demuxer.discardUntil)
+	if requested-demuxer.delivered > remotingReadAhead/2 {
+		return remoting.Message{}, false
+	}
+
+	demuxer.readPending = true
+	demuxer.requested = requested + remotingReadAhead
+
+	return remoting.Message{
+		Handle: demuxer.handle,
+		Proc:   remoting.ProcDemuxerStreamReadUntil,
+		ReadUntil: &remoting.ReadUntil{
+			CallbackHandle: demuxer.callbackHandle,
+			Count:          demuxer.requested,
+		},
+	}, true
+}
+
+func (renderer *remotingRenderer) handleDemuxerMessage(demuxer *remoteDemuxer, message *remoting.Message) []remoting.Message {
+	switch message.Proc {
+	case remoting.ProcDemuxerStreamInitializeCallback:
+		if message.InitializeCallback == nil {
+			renderer.log.Error("demuxer initialize callback is missing its arguments")
+			return nil
+		}
+		demuxer.audio = message.InitializeCallback.Audio
+		demuxer.video = message.InitializeCallback.Video
+		demuxer.initialized = true
+
+		if renderer.initCallbackHandle == remoting.InvalidHandle {
+			return nil
+		}
+		for _, other := range renderer.demuxers {
+			if !other.initialized {
+				return nil
+			}
+		}
+		return []remoting.Message{renderer.initialized(true)}
+
+	case remoting.ProcDemuxerStreamReadUntilCallback:
+		callback := message.ReadUntilCallback
+		if callback == nil {
+			renderer.log.Error("demuxer read callback is missing its arguments")
+			return nil
+		}
+		demuxer.readPending = false
+		demuxer.requested = callback.Count
+
+		switch callback.Status {
+		case remoting.ReadStatusConfigChanged:
+			if callback.Audio != nil {
+				demuxer.audio = callback.Audio
+			}
+			if callback.Video != nil {
+				demuxer.video = callback.Video
+			}
+		case remoting.ReadStatusAborted:
+			return nil
+		case remoting.ReadStatusError:
+			renderer.log.Error("demuxer stream read failed", "handle", demuxer.handle)
+			return nil
+		}
+
+		if message, ok := renderer.read(demuxer); ok {
+			return []remoting.Message{message}
+		}
+
+	case remoting.ProcDemuxerStreamOnError:
+		renderer.log.Error("demuxer stream failed", "handle", demuxer.handle)
+
+	default:
+		renderer.log.Warn("unsupported demuxer rpc", "proc", message.Proc)
+	}
+
+	return nil
+}
+
+// deliver accounts for a frame read from a demuxer stream, and returns the
+// messages to send to the sender as a result. Frames that were flushed, or
+// that arrive for a stream that the renderer does not know about, should be
+// dropped rather than played.
+func (renderer *remotingRenderer) deliver(streamType int32, buffer *remoting.DecoderBuffer) ([]remoting.Message, bool) {
+	renderer.mu.Lock()
+	defer renderer.mu.Unlock()
+
+	demuxer := renderer.demuxers[streamType]
+	if demuxer == nil {
+		return nil, false
+	}
+
+	demuxer.delivered++
+	if demuxer.delivered <= demuxer.discardUntil {
+		return nil, false
+	}
+
+	var messages []remoting.Message
+	if buffer.EndOfStream {
+		demuxer.ended = true
+		for _, other := range renderer.demuxers {
+			if !other.ended {
+				return nil, false
+			}
+		}
+		return []remoting.Message{renderer.clientMessage(remoting.ProcRendererClientOnEnded)}, false
+	}
+
+	if !renderer.bufferingReported {
+		renderer.bufferingReported = true
+		message := renderer.clientMessage(remoting.ProcRendererClientOnBufferingStateChange)
+		message.BufferingState = &remoting.BufferingState{State: remoting.BufferingHaveEnough}
+		messages = append(messages, message)
+	}
+
+	renderer.mediaTime = max(renderer.mediaTime, buffer.Timestamp)
+	if !renderer.timeUpdated || renderer.mediaTime-renderer.timeUpdatedAt >= remotingTimeUpdateInterval {
+		renderer.timeUpdated = true
+		renderer.timeUpdatedAt = renderer.mediaTime
+		message := renderer.clientMessage(remoting.ProcRendererClientOnTimeUpdate)
+		message.TimeUpdate = &remoting.TimeUpdate{
+			MaxTimeUsec: renderer.mediaTime.Microseconds(),
+			TimeUsec:    renderer.mediaTime.Microseconds(),
+		}
+		messages = append(messages, message)
+	}
+
+	if message, ok := renderer.read(demuxer); ok {
+		messages = append(messages, message)
+	}
+
+	return messages, true
+}
+
+// clientMessage creates a message for the sender's renderer client.
+func (renderer *remotingRenderer) clientMessage(proc remoting.Proc) remoting.Message {
+	return remoting.Message{Handle: renderer.clientHandle, Proc: proc}
+}
+
+// audioCodec returns the codec of the audio demuxer stream, as last reported
+// by the sender.
+func (renderer *remotingRenderer) audioCodec() int32 {
+	renderer.mu.Lock()
+	defer renderer.mu.Unlock()
+
+	if demuxer := renderer.demuxers[remoting.StreamTypeAudio]; demuxer != nil && demuxer.audio != nil {
+		return demuxer.audio.Codec
+	}
+
+	return 0
+}
+
+// videoCodec returns the codec of the video demuxer stream, as last reported
+// by the sender.
+func (renderer *remotingRenderer) videoCodec() int32 {
+	renderer.mu.Lock()
+	defer renderer.mu.Unlock()
+
+	if demuxer := renderer.demuxers[remoting.StreamTypeVideo]; demuxer != nil && demuxer.video != nil {
+		return demuxer.video.Codec
+	}
+
+	return 0
+}
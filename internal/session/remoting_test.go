@@ -0,0 +1,347 @@
+package session
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/common"
+	"github.com/tristanpenman/go-cast/internal/remoting"
+)
+
+const (
+	testClientHandle       = 201
+	testInitCallbackHandle = 202
+	testAudioDemuxerHandle = 203
+	testVideoDemuxerHandle = 204
+)
+
+func TestSelectRemotingStreams(t *testing.T) {
+	streams := []SupportedStream{
+		{Index: 0, Type: videoSourceStreamType, CodecName: vp8CodecName, Ssrc: 10},
+		{Index: 1, Type: audioSourceStreamType, CodecName: "remote_audio", Ssrc: 20},
+		{Index: 2, Type: videoSourceStreamType, CodecName: remoteVideoCodecName, Ssrc: 30},
+		{Index: 3, Type: audioSourceStreamType, CodecName: remoteAudioCodecName, Ssrc: 40},
+		{Index: 4, Type: audioSourceStreamType, CodecName: remoteVideoCodecName, Ssrc: 50},
+	}
+
+	selected := selectRemotingStreams(streams)
+	if len(selected) != 2 || selected[0].Index != 1 || selected[1].Index != 2 {
+		t.Fatalf("selected %+v, want streams 1 and 2", selected)
+	}
+
+	if selected := selectRemotingStreams(streams[:1]); len(selected) != 0 {
+		t.Fatalf("selected %+v from a mirroring offer", selected)
+	}
+}
+
+// initializeTestRenderer acquires and initializes a renderer with audio and
+// video demuxer streams, and starts playback.
+func initializeTestRenderer(t *testing.T, renderer *remotingRenderer) (audio *remoteDemuxer, video *remoteDemuxer) {
+	t.Helper()
+
+	replies := renderer.handleMessage(&remoting.Message{Handle: remoting.AcquireRendererHandle, Proc: remoting.ProcAcquireRenderer, Integer: 7})
+	if len(replies) != 1 || replies[0].Proc != remoting.ProcAcquireRendererDone || replies[0].Handle != 7 {
+		t.Fatalf("unexpected acquire replies %+v", replies)
+	}
+	rendererHandle := replies[0].Integer
+
+	replies = renderer.handleMessage(&remoting.Message{Handle: rendererHandle, Proc: remoting.ProcRendererInitialize, Initialize: &remoting.RendererInitialize{
+		AudioDemuxerHandle: testAudioDemuxerHandle,
+		CallbackHandle:     testInitCallbackHandle,
+		ClientHandle:       testClientHandle,
+		VideoDemuxerHandle: testVideoDemuxerHandle,
+	}})
+	if len(replies) != 2 {
+		t.Fatalf("got %d replies to initialize, want one per demuxer stream", len(replies))
+	}
+	for _, reply := range replies {
+		if reply.Proc != remoting.ProcDemuxerStreamInitialize {
+			t.Fatalf("unexpected initialize reply %+v", reply)
+		}
+	}
+
+	audio = renderer.callbacks[replies[0].Integer]
+	video = renderer.callbacks[replies[1].Integer]
+	if audio == nil || audio.handle != testAudioDemuxerHandle || video == nil || video.handle != testVideoDemuxerHandle {
+		t.Fatalf("demuxer callbacks not registered: %+v", renderer.callbacks)
+	}
+
+	replies = renderer.handleMessage(&remoting.Message{Handle: audio.callbackHandle, Proc: remoting.ProcDemuxerStreamInitializeCallback, InitializeCallback: &remoting.InitializeCallback{
+		Audio: &remoting.AudioDecoderConfig{Codec: remoting.AudioCodecOpus},
+		Type:  remoting.StreamTypeAudio,
+	}})
+	if len(replies) != 0 {
+		t.Fatalf("renderer initialized before all demuxer streams: %+v", replies)
+	}
+
+	replies = renderer.handleMessage(&remoting.Message{Handle: video.callbackHandle, Proc: remoting.ProcDemuxerStreamInitializeCallback, InitializeCallback: &remoting.InitializeCallback{
+		Type:  remoting.StreamTypeVideo,
+		Video: &remoting.VideoDecoderConfig{Codec: remoting.VideoCodecVP8},
+	}})
+	want := []remoting.Message{{Boolean: true, Handle: testInitCallbackHandle, Proc: remoting.ProcRendererInitializeCallback}}
+	if !reflect.DeepEqual(replies, want) {
+		t.Fatalf("initialize callback replies %+v, want %+v", replies, want)
+	}
+
+	replies = renderer.handleMessage(&remoting.Message{Handle: rendererHandle, Proc: remoting.ProcRendererStartPlayingFrom, Integer64: 0})
+	if len(replies) != 2 {
+		t.Fatalf("got %d replies to start playing, want a read for each demuxer stream", len(replies))
+	}
+	for _, reply := range replies {
+		if reply.Proc != remoting.ProcDemuxerStreamReadUntil || reply.ReadUntil == nil || reply.ReadUntil.Count != remotingReadAhead {
+			t.Fatalf("unexpected read %+v", reply)
+		}
+	}
+
+	return audio, video
+}
+
+func TestRemotingRendererInitializesAndReads(t *testing.T) {
+	renderer := newRemotingRenderer(common.NewLogger("remoting"))
+	audio, video := initializeTestRenderer(t, renderer)
+
+	if renderer.videoCodec() != remoting.VideoCodecVP8 {
+		t.Fatalf("video codec %d, want vp8", renderer.videoCodec())
+	}
+
+	// the first frame reports buffering and the media time
+	messages, play := renderer.deliver(remoting.StreamTypeVideo, &remoting.DecoderBuffer{Data: []byte{1}, Timestamp: time.Second})
+	if !play || len(messages) != 2 {
+		t.Fatalf("first frame: play %v, messages %+v", play, messages)
+	}
+	if messages[0].Proc != remoting.ProcRendererClientOnBufferingStateChange || messages[0].Handle != testClientHandle ||
+		messages[0].BufferingState.State != remoting.BufferingHaveEnough {
+		t.Fatalf("unexpected buffering message %+v", messages[0])
+	}
+	if messages[1].Proc != remoting.ProcRendererClientOnTimeUpdate || messages[1].TimeUpdate.TimeUsec != 1000000 {
+		t.Fatalf("unexpected time update %+v", messages[1])
+	}
+
+	// time updates are rate limited
+	messages, _ = renderer.deliver(remoting.StreamTypeAudio, &remoting.DecoderBuffer{Data: []byte{1}, Timestamp: time.Second + 20*time.Millisecond})
+	if len(messages) != 0 {
+		t.Fatalf("unexpected messages %+v", messages)
+	}
+
+	// more frames are requested once the sender has sent the ones asked for,
+	// and half of them have been received
+	messages = renderer.handleMessage(&remoting.Message{Handle: video.callbackHandle, Proc: remoting.ProcDemuxerStreamReadUntilCallback, ReadUntilCallback: &remoting.ReadUntilCallback{Count: remotingReadAhead}})
+	if len(messages) != 0 {
+		t.Fatalf("read again with %d frames outstanding", remotingReadAhead-video.delivered)
+	}
+	var read []remoting.Message
+	for i := 0; i < remotingReadAhead/2 && len(read) == 0; i++ {
+		read, _ = renderer.deliver(remoting.StreamTypeVideo, &remoting.DecoderBuffer{Data: []byte{1}, Timestamp: time.Second})
+	}
+	if len(read) != 1 || read[0].ReadUntil == nil || read[0].ReadUntil.Count != 2*remotingReadAhead {
+		t.Fatalf("unexpected read %+v after %d frames", read, video.delivered)
+	}
+
+	// a config change replaces the decoder config
+	renderer.handleMessage(&remoting.Message{Handle: video.callbackHandle, Proc: remoting.ProcDemuxerStreamReadUntilCallback, ReadUntilCallback: &remoting.ReadUntilCallback{
+		Count:  video.delivered,
+		Status: remoting.ReadStatusConfigChanged,
+		Video:  &remoting.VideoDecoderConfig{Codec: 2},
+	}})
+	if renderer.videoCodec() != 2 {
+		t.Fatalf("video codec %d after config change, want 2", renderer.videoCodec())
+	}
+
+	// the sender is told that playback ended once every stream has ended
+	if messages, _ := renderer.deliver(remoting.StreamTypeAudio, &remoting.DecoderBuffer{EndOfStream: true}); len(messages) != 0 {
+		t.Fatalf("ended before video: %+v", messages)
+	}
+	messages, play = renderer.deliver(remoting.StreamTypeVideo, &remoting.DecoderBuffer{EndOfStream: true})
+	if play || len(messages) != 1 || messages[0].Proc != remoting.ProcRendererClientOnEnded || messages[0].Handle != testClientHandle {
+		t.Fatalf("end of stream: play %v, messages %+v", play, messages)
+	}
+	if !audio.ended || !video.ended {
+		t.Fatal("demuxer streams not ended")
+	}
+}
+
+func TestRemotingRendererFlushDiscardsFrames(t *testing.T) {
+	renderer := newRemotingRenderer(common.NewLogger("remoting"))
+	_, video := initializeTestRenderer(t, renderer)
+
+	messages := renderer.handleMessage(&remoting.Message{Handle: renderer.handle, Proc: remoting.ProcRendererFlushUntil, FlushUntil: &remoting.FlushUntil{
+		CallbackHandle: 300,
+		VideoCount:     2,
+	}})
+	want := []remoting.Message{{Handle: 300, Proc: remoting.ProcRendererFlushUntilCallback}}
+	if !reflect.DeepEqual(messages, want) {
+		t.Fatalf("flush replies %+v, want %+v", messages, want)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, play := renderer.deliver(remoting.StreamTypeVideo, &remoting.DecoderBuffer{Data: []byte{1}}); play {
+			t.Fatalf("flushed frame %d was played", i)
+		}
+	}
+	if _, play := renderer.deliver(remoting.StreamTypeVideo, &remoting.DecoderBuffer{Data: []byte{1}}); !play {
+		t.Fatal("frame after flush was not played")
+	}
+	if video.delivered != 3 {
+		t.Fatalf("delivered %d frames, want 3", video.delivered)
+	}
+}
+
+func TestRemotingRendererIgnoresUnknownHandles(t *testing.T) {
+	renderer := newRemotingRenderer(common.NewLogger("remoting"))
+	if messages := renderer.handleMessage(&remoting.Message{Handle: 500, Proc: remoting.ProcRendererStartPlayingFrom}); len(messages) != 0 {
+		t.Fatalf("unexpected replies %+v", messages)
+	}
+	if _, play := renderer.deliver(remoting.StreamTypeVideo, &remoting.DecoderBuffer{Data: []byte{1}}); play {
+		t.Fatal("played a frame before the renderer was initialized")
+	}
+}
+
+func testRemotingOffer(t *testing.T) []byte {
+	t.Helper()
+
+	stream := SupportedStream{
+		AesIvMask:      hex.EncodeToString(syntheticAesIvMask),
+		AesKey:         hex.EncodeToString(syntheticAesKey),
+		RtpPayloadType: syntheticPayloadType,
+		TargetDelay:    20,
+	}
+	audio, video := stream, stream
+	audio.CodecName, audio.Index, audio.Ssrc, audio.TimeBase, audio.Type = remoteAudioCodecName, 0, 500, "1/48000", audioSourceStreamType
+	video.CodecName, video.Index, video.Ssrc, video.TimeBase, video.Type = remoteVideoCodecName, 1, syntheticSsrc, "1/90000", videoSourceStreamType
+
+	offer := webrtcOfferMessage{
+		WebrtcMessage: &WebrtcMessage{SeqNum: 1, Type: "OFFER"},
+		Offer:         Offer{CastMode: remotingCastMode, SupportedStreams: []SupportedStream{audio, video}},
+	}
+
+	payload, err := json.Marshal(&offer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return payload
+}
+
+// sendRemotingRPC delivers an RPC to the session on the remoting namespace,
+// and returns the RPCs that the session sent in response.
+func sendRemotingRPC(t *testing.T, session *Session, device *testDevice, message remoting.Message) []remoting.Message {
+	t.Helper()
+
+	device.mu.Lock()
+	sent := len(device.answers)
+	device.mu.Unlock()
+
+	payload, err := json.Marshal(&remotingMessage{RPC: message.Marshal(), Type: remotingRPCType})
+	if err != nil {
+		t.Fatal(err)
+	}
+	castMessage := replayOffer(session, payload)
+	namespace := common.RemotingNamespace
+	castMessage.Namespace = &namespace
+	session.HandleCastMessage(castMessage)
+
+	return device.remotingMessages(t, sent)
+}
+
+// remotingMessages decodes the RPCs that the session has sent, starting from
+// the given message.
+func (device *testDevice) remotingMessages(t *testing.T, from int) []remoting.Message {
+	t.Helper()
+
+	device.mu.Lock()
+	defer device.mu.Unlock()
+
+	var messages []remoting.Message
+	for _, answer := range device.answers[from:] {
+		var envelope remotingMessage
+		if err := json.Unmarshal([]byte(answer), &envelope); err != nil {
+			t.Fatal(err)
+		}
+		if envelope.Type != remotingRPCType {
+			continue
+		}
+
+		var message remoting.Message
+		if err := message.Unmarshal(envelope.RPC); err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, message)
+	}
+
+	return messages
+}
+
+func TestSessionPlaysRemotingStreams(t *testing.T) {
+	session, device := newLoopbackSession(t, CaptureConfig{}, RecordingConfig{})
+	defer session.Stop()
+
+	session.HandleCastMessage(replayOffer(session, testRemotingOffer(t)))
+	var answer webrtcAnswerMessage
+	device.lastAnswer(t, &answer)
+	if answer.Answer.CastMode != remotingCastMode || !reflect.DeepEqual(answer.Answer.SendIndexes, []int{0, 1}) ||
+		!reflect.DeepEqual(answer.Answer.Ssrcs, []uint32{501, syntheticSsrc + 1}) {
+		t.Fatalf("unexpected answer %+v", answer.Answer)
+	}
+	if len(session.decoders) != 1 || session.decoders[syntheticSsrc] == nil {
+		t.Fatalf("session has %d decoders, want one for the video stream", len(session.decoders))
+	}
+
+	replies := sendRemotingRPC(t, session, device, remoting.Message{Handle: remoting.AcquireRendererHandle, Proc: remoting.ProcAcquireRenderer, Integer: 7})
+	if len(replies) != 1 || replies[0].Proc != remoting.ProcAcquireRendererDone {
+		t.Fatalf("unexpected acquire replies %+v", replies)
+	}
+	rendererHandle := replies[0].Integer
+
+	replies = sendRemotingRPC(t, session, device, remoting.Message{Handle: rendererHandle, Proc: remoting.ProcRendererInitialize, Initialize: &remoting.RendererInitialize{
+		AudioDemuxerHandle: remoting.InvalidHandle,
+		CallbackHandle:     testInitCallbackHandle,
+		ClientHandle:       testClientHandle,
+		VideoDemuxerHandle: testVideoDemuxerHandle,
+	}})
+	if len(replies) != 1 || replies[0].Handle != testVideoDemuxerHandle {
+		t.Fatalf("unexpected initialize replies %+v", replies)
+	}
+	callbackHandle := replies[0].Integer
+
+	replies = sendRemotingRPC(t, session, device, remoting.Message{Handle: callbackHandle, Proc: remoting.ProcDemuxerStreamInitializeCallback, InitializeCallback: &remoting.InitializeCallback{
+		Type:  remoting.StreamTypeVideo,
+		Video: &remoting.VideoDecoderConfig{Codec: remoting.VideoCodecVP8},
+	}})
+	if len(replies) != 1 || replies[0].Proc != remoting.ProcRendererInitializeCallback || !replies[0].Boolean {
+		t.Fatalf("unexpected initialize callback replies %+v", replies)
+	}
+
+	replies = sendRemotingRPC(t, session, device, remoting.Message{Handle: rendererHandle, Proc: remoting.ProcRendererStartPlayingFrom})
+	if len(replies) != 1 || replies[0].Proc != remoting.ProcDemuxerStreamReadUntil {
+		t.Fatalf("unexpected start replies %+v", replies)
+	}
+
+	// frames are serialized decoder buffers, sent as video frames would be
+	var frames [][]byte
+	for i, frame := range syntheticFrames(3) {
+		buffer := remoting.DecoderBuffer{Data: frame, Keyframe: i == 0, Timestamp: time.Duration(i) * time.Second / 30}
+		frames = append(frames, buffer.Marshal())
+	}
+	capture := syntheticCapture{frames: frames, packetSize: 64}.build(t)
+
+	device.mu.Lock()
+	sent := len(device.answers)
+	device.mu.Unlock()
+
+	if err := Replay(&Capture{Records: capture.Records[1:]}, session, ReplayOptions{Timeout: 5 * time.Second}); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := device.remotingMessages(t, sent)
+	if len(messages) < 2 || messages[0].Proc != remoting.ProcRendererClientOnBufferingStateChange ||
+		messages[1].Proc != remoting.ProcRendererClientOnTimeUpdate {
+		t.Fatalf("unexpected messages during playback %+v", messages)
+	}
+	if stats := session.StreamStats(); len(stats) != 2 {
+		t.Fatalf("session streams %+v, want audio and video", stats)
+	}
+}
@@ -0,0 +1,107 @@
+package session
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/channel"
+	"github.com/tristanpenman/go-cast/internal/common"
+)
+
+const (
+	replaySourceId = "sender-replay"
+
+	// replayPollInterval is how often a replay checks whether the session has
+	// finished processing the datagrams sent to it.
+	replayPollInterval = 5 * time.Millisecond
+)
+
+// ReplayOptions controls the pacing of a replay.
+type ReplayOptions struct {
+	// Speed scales the delay between records. Zero replays records as quickly
+	// as possible, and one replays them in real time.
+	Speed float64
+
+	// Timeout limits how long to wait for the session to play out the frames
+	// it has received, after the last record has been sent.
+	Timeout time.Duration
+}
+
+// Replay feeds a capture into a started session. Offers are delivered as
+// WebRTC messages, and datagrams are sent to the session's UDP port from a
+// loopback socket. Replay returns once the session has received every
+// datagram and played out every complete frame.
+func Replay(capture *Capture, session *Session, options ReplayOptions) error {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("replay capture: %w", err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	// discard the session's RTCP feedback
+	go func() {
+		buffer := make([]byte, 2048)
+		for {
+			if _, _, err := conn.ReadFrom(buffer); err != nil {
+				return
+			}
+		}
+	}()
+
+	target := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: session.GetPort()}
+	start := time.Now()
+	initialPackets := session.packetsReceived.Load()
+	var sent uint64
+
+	for _, record := range capture.Records {
+		if options.Speed > 0 {
+			due := start.Add(time.Duration(float64(record.At) / options.Speed))
+			time.Sleep(time.Until(due))
+		}
+
+		switch record.Type {
+		case OfferRecord:
+			session.HandleCastMessage(replayOffer(session, record.Data))
+		case DatagramRecord:
+			if _, err := conn.WriteTo(record.Data, target); err != nil {
+				return fmt.Errorf("replay datagram: %w", err)
+			}
+			sent++
+		}
+	}
+
+	deadline := time.Now().Add(options.Timeout)
+	for session.packetsReceived.Load()-initialPackets < sent || !session.idle() {
+		if time.Now().After(deadline) {
+			received := session.packetsReceived.Load() - initialPackets
+			return fmt.Errorf("replay capture: timed out after session received %d of %d datagrams", received, sent)
+		}
+		time.Sleep(replayPollInterval)
+	}
+
+	return nil
+}
+
+// replayOffer wraps a captured offer in a WebRTC message addressed to the
+// session.
+func replayOffer(session *Session, payload []byte) *channel.CastMessage {
+	namespace := common.WebRTCNamespace
+	payloadType := channel.CastMessage_STRING
+	payloadUtf8 := string(payload)
+	protocolVersion := channel.CastMessage_CASTV2_1_0
+	sourceId := replaySourceId
+	transportId := session.TransportID()
+
+	return &channel.CastMessage{
+		DestinationId:   &transportId,
+		Namespace:       &namespace,
+		PayloadType:     &payloadType,
+		PayloadUtf8:     &payloadUtf8,
+		ProtocolVersion: &protocolVersion,
+		SourceId:        &sourceId,
+	}
+}
@@ -0,0 +1,286 @@
+package session
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	// third-party
+	"github.com/pion/rtp"
+
+	// internal
+	"github.com/tristanpenman/go-cast/internal/media"
+)
+
+const (
+	syntheticSsrc        = 1000
+	syntheticPayloadType = 96
+)
+
+var (
+	syntheticAesKey    = []byte("0123456789abcdef")
+	syntheticAesIvMask = []byte("fedcba9876543210")
+)
+
+type testDevice struct {
+	mu      sync.Mutex
+	answers []string
+}
+
+func (device *testDevice) SendUTF8(_ string, payloadUtf8 *string, _ string, _ string) {
+	device.mu.Lock()
+	defer device.mu.Unlock()
+	device.answers = append(device.answers, *payloadUtf8)
+}
+
+// syntheticCapture describes a mirroring session that can be generated without
+// a sender.
+type syntheticCapture struct {
+	frames     [][]byte
+	packetSize int
+
+	// reorder, if set, changes the order in which the packets of each frame are
+	// sent.
+	reorder func([]*rtp.Packet) []*rtp.Packet
+}
+
+func (synthetic syntheticCapture) offer(t *testing.T) []byte {
+	t.Helper()
+
+	offer := webrtcOfferMessage{
+		WebrtcMessage: &WebrtcMessage{SeqNum: 1, Type: "OFFER"},
+		Offer: Offer{
+			CastMode: "mirroring",
+			SupportedStreams: []SupportedStream{{
+				AesIvMask:      hex.EncodeToString(syntheticAesIvMask),
+				AesKey:         hex.EncodeToString(syntheticAesKey),
+				CodecName:      vp8CodecName,
+				RtpPayloadType: syntheticPayloadType,
+				Ssrc:           syntheticSsrc,
+				TargetDelay:    20,
+				TimeBase:       "1/90000",
+				Type:           videoSourceStreamType,
+			}},
+		},
+	}
+
+	payload, err := json.Marshal(&offer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return payload
+}
+
+// build encrypts and packetizes each frame the way a Cast sender would, with
+// the first frame marked as a keyframe.
+func (synthetic syntheticCapture) build(t *testing.T) *Capture {
+	t.Helper()
+
+	capture := &Capture{Records: []CaptureRecord{{Type: OfferRecord, Data: synthetic.offer(t)}}}
+	encrypter, err := NewEncrypter(syntheticAesKey, syntheticAesIvMask)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq := uint16(0)
+
+	for frameId, frame := range synthetic.frames {
+		ciphertext := make([]byte, len(frame))
+		encrypter.Reset(frameId)
+		encrypter.Encrypt(frame, ciphertext)
+
+		var packets []*rtp.Packet
+		maxPacketId := (len(ciphertext) - 1) / synthetic.packetSize
+		for packetId := 0; packetId <= maxPacketId; packetId++ {
+			chunk := ciphertext[packetId*synthetic.packetSize : min((packetId+1)*synthetic.packetSize, len(ciphertext))]
+
+			header := make([]byte, castHeaderLength)
+			if frameId == 0 {
+				header[0] = 0x80
+			}
+			header[1] = uint8(frameId)
+			binary.BigEndian.PutUint16(header[2:], uint16(packetId))
+			binary.BigEndian.PutUint16(header[4:], uint16(maxPacketId))
+
+			packets = append(packets, &rtp.Packet{
+				Header: rtp.Header{
+					Version:        2,
+					Marker:         packetId == maxPacketId,
+					PayloadType:    syntheticPayloadType,
+					SequenceNumber: seq,
+					Timestamp:      uint32(frameId * 3000),
+					SSRC:           syntheticSsrc,
+				},
+				Payload: append(header, chunk...),
+			})
+			seq++
+		}
+
+		if synthetic.reorder != nil {
+			packets = synthetic.reorder(packets)
+		}
+
+		for _, packet := range packets {
+			datagram, err := packet.Marshal()
+			if err != nil {
+				t.Fatal(err)
+			}
+			capture.Records = append(capture.Records, CaptureRecord{
+				At:   time.Duration(frameId) * time.Second / 30,
+				Data: datagram,
+				Type: DatagramRecord,
+			})
+		}
+	}
+
+	return capture
+}
+
+func syntheticFrames(count int) [][]byte {
+	frames := [][]byte{append(testVP8Keyframe(320, 240), bytes.Repeat([]byte{0x5a}, 40)...)}
+	for i := 1; i < count; i++ {
+		frames = append(frames, append([]byte{0x11, 0x00, 0x00}, bytes.Repeat([]byte{byte(i)}, 30+i)...))
+	}
+	return frames
+}
+
+// newLoopbackSession starts a session on an ephemeral loopback port.
+func newLoopbackSession(t *testing.T, capture CaptureConfig, recording RecordingConfig) (*Session, *testDevice) {
+	t.Helper()
+
+	packetConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	device := &testDevice{}
+	session := newSession("0F5096E8", capture, 1, device, "Chrome Mirroring", media.FanOut{}, packetConn, recording, "session-1", "pid-1")
+	session.Start()
+
+	return session, device
+}
+
+func replayIntoIvf(t *testing.T, capture *Capture) ([][]byte, []uint64, *testDevice) {
+	t.Helper()
+
+	dir := t.TempDir()
+	session, device := newLoopbackSession(t, CaptureConfig{}, RecordingConfig{Dir: dir, Format: IVFFormat})
+
+	err := Replay(capture, session, ReplayOptions{Timeout: 5 * time.Second})
+	session.Stop()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recordings, err := filepath.Glob(filepath.Join(dir, "*.ivf"))
+	if err != nil || len(recordings) != 1 {
+		t.Fatalf("found recordings %v, err %v", recordings, err)
+	}
+
+	raw, err := os.ReadFile(recordings[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frames, timestamps := readIvfFrames(t, raw)
+	return frames, timestamps, device
+}
+
+func TestReplayDecryptsFrames(t *testing.T) {
+	frames := syntheticFrames(3)
+	capture := syntheticCapture{frames: frames, packetSize: 16}.build(t)
+
+	recorded, timestamps, device := replayIntoIvf(t, capture)
+
+	if !reflect.DeepEqual(recorded, frames) {
+		t.Fatalf("recorded frames %x, want %x", recorded, frames)
+	}
+	if !reflect.DeepEqual(timestamps, []uint64{0, 3000, 6000}) {
+		t.Fatalf("timestamps %v, want [0 3000 6000]", timestamps)
+	}
+
+	if len(device.answers) != 1 {
+		t.Fatalf("%d answers sent, want 1", len(device.answers))
+	}
+	var answer webrtcAnswerMessage
+	if err := json.Unmarshal([]byte(device.answers[0]), &answer); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(answer.Answer.Ssrcs, []uint32{syntheticSsrc + 1}) {
+		t.Fatalf("answer ssrcs %v, want [%d]", answer.Answer.Ssrcs, syntheticSsrc+1)
+	}
+}
+
+func TestReplayReassemblesReorderedPackets(t *testing.T) {
+	frames := syntheticFrames(4)
+	reverse := func(packets []*rtp.Packet) []*rtp.Packet {
+		reversed := make([]*rtp.Packet, 0, len(packets))
+		for i := len(packets) - 1; i >= 0; i-- {
+			reversed = append(reversed, packets[i])
+		}
+		return reversed
+	}
+	capture := syntheticCapture{frames: frames, packetSize: 8, reorder: reverse}.build(t)
+
+	recorded, _, _ := replayIntoIvf(t, capture)
+	if !reflect.DeepEqual(recorded, frames) {
+		t.Fatalf("recorded frames %x, want %x", recorded, frames)
+	}
+}
+
+func TestReplayIgnoresDuplicatePackets(t *testing.T) {
+	frames := syntheticFrames(2)
+	duplicate := func(packets []*rtp.Packet) []*rtp.Packet {
+		return append(packets, packets...)
+	}
+	capture := syntheticCapture{frames: frames, packetSize: 12, reorder: duplicate}.build(t)
+
+	recorded, _, _ := replayIntoIvf(t, capture)
+	if !reflect.DeepEqual(recorded, frames) {
+		t.Fatalf("recorded frames %x, want %x", recorded, frames)
+	}
+}
+
+func TestSessionCaptureCanBeReplayed(t *testing.T) {
+	dir := t.TempDir()
+	session, _ := newLoopbackSession(t, CaptureConfig{Dir: dir}, RecordingConfig{})
+
+	original := syntheticCapture{frames: syntheticFrames(2), packetSize: 16}.build(t)
+	err := Replay(original, session, ReplayOptions{Timeout: 5 * time.Second})
+	session.Stop()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	captures, err := filepath.Glob(filepath.Join(dir, "*-session-1.gcap"))
+	if err != nil || len(captures) != 1 {
+		t.Fatalf("found captures %v, err %v", captures, err)
+	}
+
+	captured, err := LoadCapture(captures[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(captured.Records) != len(original.Records) {
+		t.Fatalf("captured %d records, want %d", len(captured.Records), len(original.Records))
+	}
+	for i, record := range captured.Records {
+		if record.Type != original.Records[i].Type || !bytes.Equal(record.Data, original.Records[i].Data) {
+			t.Fatalf("record %d is %+v, want %+v", i, record, original.Records[i])
+		}
+	}
+
+	// the capture can itself be replayed
+	recorded, _, _ := replayIntoIvf(t, captured)
+	if !reflect.DeepEqual(recorded, syntheticFrames(2)) {
+		t.Fatalf("recorded frames %x from replayed capture", recorded)
+	}
+}
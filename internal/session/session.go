@@ -1,29 +1,30 @@
 package session
 
 import (
-	"bytes"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"image"
-	"image/jpeg"
 	"net"
-	"os"
-	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	// third-party
 	"github.com/hashicorp/go-hclog"
 	"github.com/pion/rtcp"
 	"github.com/pion/rtp"
-	"github.com/xlab/libvpx-go/vpx"
 
 	// internal
 	"github.com/tristanpenman/go-cast/internal/channel"
 	"github.com/tristanpenman/go-cast/internal/common"
+	"github.com/tristanpenman/go-cast/internal/media"
+	"github.com/tristanpenman/go-cast/internal/remoting"
 )
 
 type Device interface {
-	DisplayImage(*image.RGBA)
 	SendUTF8(namespace string, payloadUTF8 *string, sourceID string, destinationID string)
 }
 
@@ -34,17 +35,23 @@ type Session struct {
 	StatusText  string
 
 	// implementation
-	device      Device
-	frameCount  int
-	jpegOutput  bool
-	log         hclog.Logger
-	packetConn  net.PacketConn
-	streams     map[uint32]*Stream
-	stop        chan struct{}
-	stopping    bool
-	transportId string
-	vpxCtx      *vpx.CodecCtx
-	vpxIface    *vpx.CodecIface
+	mu              sync.Mutex
+	capture         *CaptureWriter
+	captureConfig   CaptureConfig
+	capturing       atomic.Bool
+	decoders        map[uint32]*videoDecoder
+	device          Device
+	frames          media.FrameSink
+	log             hclog.Logger
+	packetConn      net.PacketConn
+	packetsReceived atomic.Uint64
+	recorder        *Recorder
+	recording       RecordingConfig
+	remoting        *remotingRenderer
+	streams         map[uint32]*Stream
+	stop            chan struct{}
+	stopping        bool
+	transportId     string
 }
 
 func (session *Session) GetPort() int {
@@ -59,18 +66,79 @@ type WebrtcMessage struct {
 type SupportedStream struct {
 	AesIvMask      string `json:"aesIvMask"`
 	AesKey         string `json:"aesKey"`
+	Channels       int    `json:"channels"`
 	CodecName      string `json:"codecName"`
 	Index          int    `json:"index"`
 	RtpPayloadType int    `json:"rtpPayloadType"`
 	Ssrc           uint32 `json:"ssrc"`
+	TargetDelay    int    `json:"targetDelay"`
+	TimeBase       string `json:"timeBase"`
 	Type           string `json:"type"`
 }
 
 const (
+	opusCodecName         = "opus"
 	videoSourceStreamType = "video_source"
+	videoClockRate        = 90000
 	vp8CodecName          = "vp8"
+
+	// defaultAudioChannels is assumed for audio streams whose offer does not
+	// give a channel count.
+	defaultAudioChannels = 2
 )
 
+// clockRate parses the RTP clock rate from a time base such as "1/90000",
+// falling back to the standard video clock rate.
+func (stream *SupportedStream) clockRate() uint32 {
+	numerator, denominator, ok := strings.Cut(stream.TimeBase, "/")
+	if !ok || strings.TrimSpace(numerator) != "1" {
+		return videoClockRate
+	}
+
+	rate, err := strconv.ParseUint(strings.TrimSpace(denominator), 10, 32)
+	if err != nil || rate == 0 {
+		return videoClockRate
+	}
+
+	return uint32(rate)
+}
+
+// audioTrack describes the recording track for an audio stream.
+func (stream *SupportedStream) audioTrack() *AudioTrack {
+	channels := stream.Channels
+	if channels <= 0 {
+		channels = defaultAudioChannels
+	}
+
+	return &AudioTrack{Channels: channels, ClockRate: stream.clockRate()}
+}
+
+// targetDelay returns the requested playout delay, which the offer specifies
+// in milliseconds.
+func (stream *SupportedStream) targetDelay() time.Duration {
+	if stream.TargetDelay <= 0 {
+		return defaultTargetDelay
+	}
+
+	return time.Duration(stream.TargetDelay) * time.Millisecond
+}
+
+// decrypter creates a decrypter from the stream's hex encoded AES key and IV
+// mask.
+func (stream *SupportedStream) decrypter() (*Decrypter, error) {
+	key, err := hex.DecodeString(stream.AesKey)
+	if err != nil {
+		return nil, fmt.Errorf("decode aes key: %w", err)
+	}
+
+	iv, err := hex.DecodeString(stream.AesIvMask)
+	if err != nil {
+		return nil, fmt.Errorf("decode aes iv mask: %w", err)
+	}
+
+	return NewDecrypter(key, iv)
+}
+
 func selectVP8VideoStream(streams []SupportedStream) *SupportedStream {
 	for i := range streams {
 		stream := &streams[i]
@@ -82,6 +150,17 @@ func selectVP8VideoStream(streams []SupportedStream) *SupportedStream {
 	return nil
 }
 
+func selectOpusAudioStream(streams []SupportedStream) *SupportedStream {
+	for i := range streams {
+		stream := &streams[i]
+		if stream.Type == audioSourceStreamType && stream.CodecName == opusCodecName {
+			return stream
+		}
+	}
+
+	return nil
+}
+
 type Offer struct {
 	CastMode          string            `json:"castMode"`
 	ReceiverGetStatus bool              `json:"receiverGetStatus"`
@@ -140,7 +219,16 @@ type webrtcAnswerMessage struct {
 	Result string `json:"result"`
 }
 
+// handleWebrtcOffer negotiates the session's streams. Senders make a new offer
+// whenever the stream changes, such as when the mirrored tab is switched or
+// the resolution changes, so each offer retires the streams negotiated by the
+// previous one. The UDP port is unchanged, and the answer lists the SSRCs of
+// the new streams.
 func (session *Session) handleWebrtcOffer(castMessage *channel.CastMessage) {
+	if session.capturing.Load() {
+		session.captureRecord(OfferRecord, []byte(*castMessage.PayloadUtf8), time.Now())
+	}
+
 	var request webrtcOfferMessage
 	err := json.Unmarshal([]byte(*castMessage.PayloadUtf8), &request)
 	if err != nil {
@@ -148,45 +236,25 @@ func (session *Session) handleWebrtcOffer(castMessage *channel.CastMessage) {
 		return
 	}
 
-	receiverRtcpEventLog := make([]int, 0, 1)
-	sendIndexes := make([]int, 0, 1)
-	ssrcs := make([]uint32, 0, 1)
-
-	supportedStream := selectVP8VideoStream(request.Offer.SupportedStreams)
-	if supportedStream != nil {
-		senderSsrc := supportedStream.Ssrc
-		receiverSsrc := supportedStream.Ssrc + 1
-
-		receiverRtcpEventLog = append(receiverRtcpEventLog, supportedStream.Index)
-		sendIndexes = append(sendIndexes, supportedStream.Index)
-		ssrcs = append(ssrcs, receiverSsrc)
-
-		key, _ := hex.DecodeString(supportedStream.AesKey)
-		iv, _ := hex.DecodeString(supportedStream.AesIvMask)
-
-		decrypter := NewDecrypter(key, iv)
-
-		decode := func(buffer []byte, frameId int) {
-			plaintext := make([]byte, len(buffer))
-			session.log.Info(fmt.Sprintf("decrypting %d bytes", len(buffer)), "frame id", frameId)
-			n := decrypter.Decrypt(buffer, plaintext)
-			session.log.Info(fmt.Sprintf("decrypted %d bytes", n))
-			session.decodeBuffer(plaintext)
-			decrypter.Reset(frameId + 1)
-		}
-
-		sendRtcp := func(buffer []byte, addr net.Addr) {
-			if _, err := session.packetConn.WriteTo(buffer, addr); err != nil {
-				session.log.Warn("failed to write rtcp packet", "err", err)
-			}
-		}
-
-		logger := common.NewLogger(fmt.Sprintf("stream (%d)", supportedStream.Ssrc))
-		session.streams[senderSsrc] = NewStream(decode, logger, sendRtcp, receiverSsrc, senderSsrc)
+	var accepted []acceptedStream
+	var recorder *Recorder
+	if request.Offer.CastMode == remotingCastMode {
+		accepted, recorder = session.acceptRemotingStreams(request.Offer.SupportedStreams)
 	} else {
-		session.log.Warn("offer contains no supported VP8 video stream")
+		accepted, recorder = session.acceptMirroringStreams(request.Offer.SupportedStreams)
 	}
 
+	receiverRtcpEventLog := make([]int, 0, len(accepted))
+	sendIndexes := make([]int, 0, len(accepted))
+	ssrcs := make([]uint32, 0, len(accepted))
+	for _, stream := range accepted {
+		receiverRtcpEventLog = append(receiverRtcpEventLog, stream.index)
+		sendIndexes = append(sendIndexes, stream.index)
+		ssrcs = append(ssrcs, stream.stream.receiverSsrc)
+	}
+
+	session.replaceStreams(accepted, recorder)
+
 	response := webrtcAnswerMessage{
 		WebrtcMessage: &WebrtcMessage{
 			SeqNum: request.SeqNum,
@@ -203,9 +271,238 @@ func (session *Session) handleWebrtcOffer(castMessage *channel.CastMessage) {
 		Result: "ok",
 	}
 
-	bytes, err := json.Marshal(&response)
+	session.sendWebrtcResponse(castMessage, &response)
+}
+
+// acceptedStream is a stream that the session has agreed to receive, and
+// the index of the offered stream that it was created from. Audio streams
+// have no decoder.
+type acceptedStream struct {
+	decoder *videoDecoder
+	index   int
+	stream  *Stream
+}
+
+// acceptMirroringStreams creates a stream for the first VP8 video stream in a
+// mirroring offer, and a recording for the streams if recording is enabled.
+// Audio cannot be played, so the first Opus audio stream is only accepted when
+// it can be recorded.
+func (session *Session) acceptMirroringStreams(offered []SupportedStream) ([]acceptedStream, *Recorder) {
+	supportedStream := selectVP8VideoStream(offered)
+	if supportedStream == nil {
+		session.log.Warn("offer contains no supported VP8 video stream")
+		return nil, nil
+	}
+
+	decrypter, err := supportedStream.decrypter()
+	if err != nil {
+		session.log.Error("rejecting stream with invalid encryption parameters", "ssrc", supportedStream.Ssrc, "err", err)
+		return nil, nil
+	}
+
+	decoder, err := newVideoDecoder()
+	if err != nil {
+		session.log.Error("failed to create decoder", "err", err)
+		return nil, nil
+	}
+
+	var audioStream *SupportedStream
+	var audioDecrypter *Decrypter
+	if session.recording.Dir != "" && session.recording.Format == WebMFormat {
+		if audioStream = selectOpusAudioStream(offered); audioStream != nil {
+			if audioDecrypter, err = audioStream.decrypter(); err != nil {
+				session.log.Error("rejecting stream with invalid encryption parameters", "ssrc", audioStream.Ssrc, "err", err)
+				audioStream = nil
+			}
+		}
+	}
+	recorder := session.newRecorder(supportedStream, audioStream)
+
+	info := media.StreamInfo{
+		CodecName: supportedStream.CodecName,
+		SessionID: session.SessionId,
+		SSRC:      supportedStream.Ssrc,
+	}
+
+	stream := session.newOfferedStream(supportedStream, decrypter, func(plaintext []byte, frame encodedFrame) error {
+		session.recordVideoFrame(recorder, plaintext, frame)
+		return session.decodeBuffer(decoder, plaintext, frame, info)
+	})
+	accepted := []acceptedStream{{decoder: decoder, index: supportedStream.Index, stream: stream}}
+
+	if audioStream != nil {
+		stream := session.newOfferedStream(audioStream, audioDecrypter, func(plaintext []byte, frame encodedFrame) error {
+			session.recordAudioFrame(recorder, plaintext, frame)
+			return nil
+		})
+		accepted = append(accepted, acceptedStream{index: audioStream.Index, stream: stream})
+	}
+
+	return accepted, recorder
+}
+
+// acceptRemotingStreams creates streams for the remoted audio and video in a
+// remoting offer, and a recording for the streams if recording is enabled.
+// Their frames are passed to the session's remoting renderer.
+func (session *Session) acceptRemotingStreams(offered []SupportedStream) ([]acceptedStream, *Recorder) {
+	supportedStreams := selectRemotingStreams(offered)
+	if len(supportedStreams) == 0 {
+		session.log.Warn("offer contains no remoting streams")
+		return nil, nil
+	}
+
+	var audioStream, videoStream *SupportedStream
+	for _, supportedStream := range supportedStreams {
+		if supportedStream.Type == videoSourceStreamType {
+			videoStream = supportedStream
+		} else if session.recording.Format == WebMFormat {
+			audioStream = supportedStream
+		}
+	}
+	var recorder *Recorder
+	if videoStream != nil {
+		recorder = session.newRecorder(videoStream, audioStream)
+	}
+
+	accepted := make([]acceptedStream, 0, len(supportedStreams))
+	for _, supportedStream := range supportedStreams {
+		decrypter, err := supportedStream.decrypter()
+		if err != nil {
+			session.log.Error("rejecting stream with invalid encryption parameters", "ssrc", supportedStream.Ssrc, "err", err)
+			continue
+		}
+
+		streamType := remoting.StreamTypeAudio
+		var decoder *videoDecoder
+		if supportedStream.Type == videoSourceStreamType {
+			streamType = remoting.StreamTypeVideo
+			if decoder, err = newVideoDecoder(); err != nil {
+				session.log.Error("failed to create decoder", "err", err)
+				continue
+			}
+		}
+
+		info := media.StreamInfo{
+			CodecName: vp8CodecName,
+			SessionID: session.SessionId,
+			SSRC:      supportedStream.Ssrc,
+		}
+
+		stream := session.newOfferedStream(supportedStream, decrypter, func(plaintext []byte, frame encodedFrame) error {
+			return session.playRemotingBuffer(streamType, decoder, recorder, plaintext, frame, info)
+		})
+
+		accepted = append(accepted, acceptedStream{decoder: decoder, index: supportedStream.Index, stream: stream})
+	}
+
+	return accepted, recorder
+}
+
+// newOfferedStream creates a stream for an offered stream, which decrypts
+// each frame before passing it to decode.
+func (session *Session) newOfferedStream(supportedStream *SupportedStream, decrypter *Decrypter, decode func(plaintext []byte, frame encodedFrame) error) *Stream {
+	decrypt := func(frame encodedFrame) error {
+		plaintext := make([]byte, len(frame.data))
+		session.log.Info(fmt.Sprintf("decrypting %d bytes", len(frame.data)), "frame id", frame.frameId)
+		decrypter.Reset(int(frame.frameId))
+		n := decrypter.Decrypt(frame.data, plaintext)
+		session.log.Info(fmt.Sprintf("decrypted %d bytes", n))
+		return decode(plaintext, frame)
+	}
+
+	sendRtcp := func(buffer []byte, addr net.Addr) {
+		if _, err := session.packetConn.WriteTo(buffer, addr); err != nil {
+			session.log.Warn("failed to write rtcp packet", "err", err)
+		}
+	}
+
+	senderSsrc := supportedStream.Ssrc
+	receiverSsrc := supportedStream.Ssrc + 1
+
+	logger := common.NewLogger(fmt.Sprintf("stream (%d)", senderSsrc))
+	return NewStream(decrypt, logger, sendRtcp, receiverSsrc, senderSsrc, supportedStream.clockRate(), supportedStream.targetDelay())
+}
+
+type webrtcStatusMessage struct {
+	*WebrtcMessage
+
+	Result string       `json:"result"`
+	Status webrtcStatus `json:"status"`
+}
+
+// webrtcStatus is the body of a STATUS_RESPONSE. Its fields, which report
+// wifi signal quality, are all optional, and are omitted since the receiver
+// does not know which interface the sender is connected through.
+type webrtcStatus struct{}
+
+type webrtcCapabilitiesMessage struct {
+	*WebrtcMessage
+
+	Capabilities webrtcCapabilities `json:"capabilities"`
+	Result       string             `json:"result"`
+}
+
+type webrtcCapabilities struct {
+	MediaCaps       []string `json:"mediaCaps"`
+	RemotingVersion int      `json:"remoting,omitempty"`
+}
+
+// webrtcKeyFrameRequest asks the receiver to request a keyframe for a stream.
+// Requests without an SSRC apply to every stream.
+type webrtcKeyFrameRequest struct {
+	*WebrtcMessage
+
+	Ssrc uint32 `json:"ssrc"`
+}
+
+func (session *Session) handleWebrtcGetStatus(castMessage *channel.CastMessage, request *WebrtcMessage) {
+	response := webrtcStatusMessage{
+		WebrtcMessage: &WebrtcMessage{
+			SeqNum: request.SeqNum,
+			Type:   "STATUS_RESPONSE",
+		},
+		Result: "ok",
+		Status: webrtcStatus{},
+	}
+
+	session.sendWebrtcResponse(castMessage, &response)
+}
+
+func (session *Session) handleWebrtcGetCapabilities(castMessage *channel.CastMessage, request *WebrtcMessage) {
+	response := webrtcCapabilitiesMessage{
+		WebrtcMessage: &WebrtcMessage{
+			SeqNum: request.SeqNum,
+			Type:   "CAPABILITIES_RESPONSE",
+		},
+		Capabilities: webrtcCapabilities{
+			MediaCaps:       []string{"video", vp8CodecName},
+			RemotingVersion: remotingVersion,
+		},
+		Result: "ok",
+	}
+
+	session.sendWebrtcResponse(castMessage, &response)
+}
+
+func (session *Session) handleWebrtcKeyFrameRequest(castMessage *channel.CastMessage) {
+	var request webrtcKeyFrameRequest
+	if err := json.Unmarshal([]byte(*castMessage.PayloadUtf8), &request); err != nil {
+		session.log.Error("failed to unmarshall keyframe request", "err", err)
+		return
+	}
+
+	now := time.Now()
+	for _, stream := range session.activeStreams() {
+		if request.Ssrc == 0 || request.Ssrc == stream.senderSsrc {
+			stream.requestKeyframe(now)
+		}
+	}
+}
+
+func (session *Session) sendWebrtcResponse(castMessage *channel.CastMessage, response any) {
+	bytes, err := json.Marshal(response)
 	if err != nil {
-		session.log.Error("failed to marshall webrtc answer", "err", err)
+		session.log.Error("failed to marshall webrtc response", "err", err)
 		return
 	}
 
@@ -224,16 +521,71 @@ func (session *Session) handleWebrtcMessage(castMessage *channel.CastMessage) {
 	switch request.Type {
 	case "OFFER":
 		session.handleWebrtcOffer(castMessage)
+	case "GET_STATUS":
+		session.handleWebrtcGetStatus(castMessage, &request)
+	case "GET_CAPABILITIES":
+		session.handleWebrtcGetCapabilities(castMessage, &request)
+	case "KEY_FRAME_REQUEST":
+		session.handleWebrtcKeyFrameRequest(castMessage)
 	default:
 		session.log.Error("unrecognised webrtc request type", "type", request.Type)
 	}
 }
 
+func (session *Session) handleRemotingMessage(castMessage *channel.CastMessage) {
+	var envelope remotingMessage
+	if err := json.Unmarshal([]byte(*castMessage.PayloadUtf8), &envelope); err != nil {
+		session.log.Error("failed to unmarshall remoting message", "err", err)
+		return
+	}
+
+	if envelope.Type != remotingRPCType {
+		session.log.Error("unrecognised remoting message type", "type", envelope.Type)
+		return
+	}
+
+	var message remoting.Message
+	if err := message.Unmarshal(envelope.RPC); err != nil {
+		session.log.Error("failed to unmarshall remoting rpc", "err", err)
+		return
+	}
+
+	session.log.Info("received remoting rpc", "handle", message.Handle, "proc", message.Proc)
+	session.remoting.setPeer(*castMessage.DestinationId, *castMessage.SourceId)
+	session.sendRemotingMessages(session.remoting.handleMessage(&message))
+}
+
+// sendRemotingMessages sends RPC messages to the sender that the remoting
+// renderer last heard from.
+func (session *Session) sendRemotingMessages(messages []remoting.Message) {
+	if len(messages) == 0 {
+		return
+	}
+
+	sourceID, destinationID, ok := session.remoting.peer()
+	if !ok {
+		session.log.Warn("dropping remoting rpcs before the sender is known", "count", len(messages))
+		return
+	}
+
+	for i := range messages {
+		bytes, err := json.Marshal(&remotingMessage{RPC: messages[i].Marshal(), Type: remotingRPCType})
+		if err != nil {
+			session.log.Error("failed to marshall remoting message", "err", err)
+			return
+		}
+
+		payloadUtf8 := string(bytes)
+		session.device.SendUTF8(common.RemotingNamespace, &payloadUtf8, sourceID, destinationID)
+	}
+}
+
 func (session *Session) HandleCastMessage(castMessage *channel.CastMessage) {
 	switch *castMessage.Namespace {
 	case common.DebugNamespace:
 	case common.MediaNamespace:
 	case common.RemotingNamespace:
+		session.handleRemotingMessage(castMessage)
 	case common.WebRTCNamespace:
 		session.handleWebrtcMessage(castMessage)
 	default:
@@ -252,6 +604,164 @@ func (session *Session) Namespaces() []string {
 	return namespaces
 }
 
+func (session *Session) stream(ssrc uint32) *Stream {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return session.streams[ssrc]
+}
+
+// replaceStreams retires the session's streams, and releases their decoders.
+// The replacement streams, and the recording that they write to, take their
+// place. The retired recording is closed after its streams, so that each
+// negotiation is recorded to its own file, starting at its first keyframe.
+// Streams are closed without holding the session lock, since streams take it
+// while decoding.
+func (session *Session) replaceStreams(replacements []acceptedStream, recorder *Recorder) {
+	session.mu.Lock()
+	retired := session.streams
+	retiredDecoders := session.decoders
+	retiredRecorders := []*Recorder{session.recorder}
+	session.streams = make(map[uint32]*Stream)
+	session.decoders = make(map[uint32]*videoDecoder)
+	session.recorder = recorder
+	if session.stopping {
+		retiredRecorders = append(retiredRecorders, recorder)
+		session.recorder = nil
+	}
+	for _, replacement := range replacements {
+		streams, decoders := session.streams, session.decoders
+		if session.stopping {
+			streams, decoders = retired, retiredDecoders
+		}
+
+		ssrc := replacement.stream.senderSsrc
+		streams[ssrc] = replacement.stream
+		if replacement.decoder != nil {
+			decoders[ssrc] = replacement.decoder
+		}
+	}
+	session.mu.Unlock()
+
+	for ssrc, stream := range retired {
+		session.log.Info("retiring stream", "ssrc", ssrc)
+		stream.close()
+		if decoder := retiredDecoders[ssrc]; decoder != nil {
+			if err := decoder.close(); err != nil {
+				session.log.Warn("failed to close decoder", "ssrc", ssrc, "err", err)
+			}
+		}
+	}
+
+	for _, retiredRecorder := range retiredRecorders {
+		if retiredRecorder == nil {
+			continue
+		}
+		if err := retiredRecorder.Close(); err != nil {
+			session.log.Warn("failed to close recording", "err", err)
+		}
+	}
+}
+
+func (session *Session) activeStreams() []*Stream {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	streams := make([]*Stream, 0, len(session.streams))
+	for _, stream := range session.streams {
+		streams = append(streams, stream)
+	}
+
+	return streams
+}
+
+// StreamStats returns statistics for each of the session's streams, ordered by
+// SSRC.
+func (session *Session) StreamStats() []StreamStats {
+	now := time.Now()
+	streams := session.activeStreams()
+
+	stats := make([]StreamStats, 0, len(streams))
+	for _, stream := range streams {
+		stats = append(stats, stream.stats(now))
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].SSRC < stats[j].SSRC
+	})
+
+	return stats
+}
+
+// rtcpSenderSsrc identifies the sender of an RTCP packet, which is also the
+// SSRC of the stream that the packet relates to.
+func rtcpSenderSsrc(packet rtcp.Packet) (uint32, bool) {
+	switch p := packet.(type) {
+	case *rtcp.SenderReport:
+		return p.SSRC, true
+	case *rtcp.ExtendedReport:
+		return p.SenderSSRC, true
+	case *rtcp.Goodbye:
+		if len(p.Sources) > 0 {
+			return p.Sources[0], true
+		}
+	}
+
+	return 0, false
+}
+
+func (session *Session) handleRtcp(data []byte, addr net.Addr, now time.Time) {
+	rtcpPackets, err := rtcp.Unmarshal(data)
+	if err != nil {
+		session.log.Warn("error while unmarshalling rtcp", "err", err)
+		return
+	}
+
+	for _, packet := range rtcpPackets {
+		ssrc, ok := rtcpSenderSsrc(packet)
+		if !ok {
+			continue
+		}
+
+		stream := session.stream(ssrc)
+		if stream == nil {
+			session.log.Warn("stream not found", "ssrc", ssrc)
+			return
+		}
+
+		stream.handleRtcpPackets(rtcpPackets, addr, now)
+		return
+	}
+
+	session.log.Warn("rtcp packet missing sender ssrc")
+}
+
+func (session *Session) handleRtp(data []byte, addr net.Addr, now time.Time) {
+	packet := &rtp.Packet{}
+	if err := packet.Unmarshal(data); err != nil {
+		session.log.Warn("error while unmarshalling rtp", "err", err)
+		return
+	}
+
+	stream := session.stream(packet.SSRC)
+	if stream == nil {
+		session.log.Warn("stream not found", "ssrc", packet.SSRC, "seq", packet.SequenceNumber, "type", packet.PayloadType)
+		return
+	}
+
+	// clone packets before collecting them, to ensure they are not altered
+	stream.handleDataPacket(packet.Clone(), addr, now)
+}
+
+// handlePacket demultiplexes RTP and RTCP packets received on the session's
+// UDP port.
+func (session *Session) handlePacket(data []byte, addr net.Addr, now time.Time) {
+	if isRtcpPacket(data) {
+		session.handleRtcp(data, addr, now)
+	} else {
+		session.handleRtp(data, addr, now)
+	}
+}
+
 func (session *Session) Start() {
 	go func() {
 		<-session.stop
@@ -260,6 +770,31 @@ func (session *Session) Start() {
 		}
 	}()
 
+	// periodically re-request lost packets, since retransmissions can be lost,
+	// and play out frames as they become due
+	go func() {
+		lossTicker := time.NewTicker(lossCheckInterval)
+		defer lossTicker.Stop()
+
+		playoutTicker := time.NewTicker(playoutCheckInterval)
+		defer playoutTicker.Stop()
+
+		for {
+			select {
+			case <-session.stop:
+				return
+			case now := <-lossTicker.C:
+				for _, stream := range session.activeStreams() {
+					stream.checkLoss(now)
+				}
+			case now := <-playoutTicker.C:
+				for _, stream := range session.activeStreams() {
+					stream.playFrames(now)
+				}
+			}
+		}
+	}()
+
 	session.log.Info("listening on port", "port", common.GetPort(session.packetConn.LocalAddr()))
 
 	go func() {
@@ -276,57 +811,12 @@ func (session *Session) Start() {
 			}
 
 			session.log.Info(fmt.Sprintf("read %d bytes", count))
-
-			packet := &rtp.Packet{}
-			err = packet.Unmarshal(data[:count])
-			if err != nil {
-				session.log.Warn("error while unmarshalling rtp")
-				return
-			}
-
-			if packet.PayloadType == 72 {
-				// rtcp
-				rtcpPackets, err := rtcp.Unmarshal(data[:count])
-				if err != nil {
-					session.log.Warn("error while unmarshalling rtcp")
-					return
-				}
-
-				if len(rtcpPackets) == 0 {
-					session.log.Warn("expected at least one rtcp packet")
-					continue
-				}
-
-				dest := rtcpPackets[0].DestinationSSRC()
-				if len(dest) == 0 {
-					session.log.Warn("rtcp packet missing destination ssrc")
-					continue
-				}
-
-				stream := session.streams[dest[0]]
-				if stream == nil {
-					session.log.Warn("stream not found", "ssrc", packet.SSRC, "seq", packet.SequenceNumber, "type", packet.PayloadType)
-					continue
-				}
-
-				stream.handleRtcpPackets(rtcpPackets, addr)
-			} else {
-				// data
-				stream := session.streams[packet.SSRC]
-				if stream == nil {
-					session.log.Warn("stream not found", "ssrc", packet.SSRC, "seq", packet.SequenceNumber, "type", packet.PayloadType)
-					continue
-				}
-
-				// clone packets before enqueueing, to ensure they are not altered
-				packet = packet.Clone()
-				stream.enqueuePacket(packet)
-				nextPacket := stream.nextPacket()
-				for nextPacket != nil {
-					stream.handleDataPacket(nextPacket, addr)
-					nextPacket = stream.nextPacket()
-				}
+			now := time.Now()
+			if session.capturing.Load() {
+				session.captureRecord(DatagramRecord, data[:count], now)
 			}
+			session.handlePacket(data[:count], addr, now)
+			session.packetsReceived.Add(1)
 		}
 
 		if err := session.packetConn.Close(); err != nil {
@@ -336,98 +826,225 @@ func (session *Session) Start() {
 }
 
 func (session *Session) Stop() {
+	session.mu.Lock()
+
 	session.stopping = true
 	close(session.stop)
+
+	if session.capture != nil {
+		if err := session.capture.Close(); err != nil {
+			session.log.Warn("failed to close capture", "err", err)
+		}
+		session.capture = nil
+	}
+
+	session.mu.Unlock()
+
+	session.replaceStreams(nil, nil)
 }
 
-func (session *Session) TransportID() string {
-	return session.transportId
+// captureRecord writes an offer or datagram to the session's capture file,
+// which is created when the first record arrives. Capturing stops after the
+// first write error.
+func (session *Session) captureRecord(recordType CaptureRecordType, data []byte, now time.Time) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	if session.stopping {
+		return
+	}
+
+	if session.capture == nil {
+		capture, capturePath, err := CreateCapture(session.captureConfig.Dir, session.SessionId)
+		if err != nil {
+			session.log.Error("failed to start capture", "err", err)
+			session.capturing.Store(false)
+			return
+		}
+
+		session.log.Info("capturing session", "path", capturePath)
+		session.capture = capture
+	}
+
+	if err := session.capture.WriteRecord(recordType, data, now); err != nil {
+		session.log.Error("failed to capture record, stopping capture", "err", err)
+		if err := session.capture.Close(); err != nil {
+			session.log.Warn("failed to close capture", "err", err)
+		}
+		session.capture = nil
+		session.capturing.Store(false)
+	}
+}
+
+// idle reports whether the session has played out every frame that it has
+// received.
+func (session *Session) idle() bool {
+	for _, stream := range session.activeStreams() {
+		if stream.hasReadyFrames() {
+			return false
+		}
+	}
+
+	return true
 }
 
-func (session *Session) decodeBuffer(payload []byte) {
-	err := vpx.Error(vpx.CodecDecode(session.vpxCtx, string(payload), uint32(len(payload)), nil, 0))
+// newRecorder creates a recording for the streams of an offer, or returns nil
+// if recording is disabled or the recording cannot be created. Audio is only
+// recorded to WebM files, and may be nil.
+func (session *Session) newRecorder(video *SupportedStream, audio *SupportedStream) *Recorder {
+	if session.recording.Dir == "" {
+		return nil
+	}
+
+	var audioTrack *AudioTrack
+	if audio != nil {
+		audioTrack = audio.audioTrack()
+	}
+
+	recorder, recordingPath, err := CreateRecording(session.recording, session.SessionId, video.clockRate(), audioTrack)
 	if err != nil {
-		session.log.Error("failed to decode buffer", "err", err)
+		session.log.Error("failed to start recording", "err", err)
+		return nil
+	}
+
+	session.log.Info("recording session", "path", recordingPath, "audio", audioTrack != nil)
+	return recorder
+}
+
+// recordVideoFrame adds a decrypted video frame to a recording. Recording
+// stops after the first write error, rather than logging an error for every
+// frame.
+func (session *Session) recordVideoFrame(recorder *Recorder, data []byte, frame encodedFrame) {
+	if recorder == nil {
 		return
 	}
 
-	var iter vpx.CodecIter
-	image := vpx.CodecGetFrame(session.vpxCtx, &iter)
-	for image != nil {
-		image.Deref()
-		session.frameCount++
+	if err := recorder.WriteVideoFrame(data, frame.keyframe, frame.rtpTimestamp, frame.playoutAt); err != nil {
+		session.stopRecording(recorder, frame, err)
+	}
+}
+
+// recordAudioFrame adds a decrypted audio frame to a recording.
+func (session *Session) recordAudioFrame(recorder *Recorder, data []byte, frame encodedFrame) {
+	if recorder == nil {
+		return
+	}
 
-		session.log.Info("image", "format", image.Fmt)
+	if err := recorder.WriteAudioFrame(data, frame.rtpTimestamp, frame.playoutAt); err != nil {
+		session.stopRecording(recorder, frame, err)
+	}
+}
 
-		session.device.DisplayImage(image.ImageRGBA())
+func (session *Session) stopRecording(recorder *Recorder, frame encodedFrame, err error) {
+	session.log.Error("failed to record frame, stopping recording", "frameId", frame.frameId, "err", err)
+	if err := recorder.Close(); err != nil {
+		session.log.Warn("failed to close recording", "err", err)
+	}
+}
 
-		if session.jpegOutput {
-			jpegBuffer := new(bytes.Buffer)
-			if err = jpeg.Encode(jpegBuffer, image.ImageYCbCr(), nil); err != nil {
-				session.log.Error("failed to encode jpeg", "err", err)
-				return
-			}
+func (session *Session) TransportID() string {
+	return session.transportId
+}
 
-			jpegPath := path.Join("tmp", fmt.Sprintf("%d%s", session.frameCount, ".jpg"))
-			fo, err := os.Create(jpegPath)
-			if err != nil {
-				session.log.Error("failed to create image", "err", err)
-				return
-			}
+// playRemotingBuffer passes a decrypted frame from a remoting stream to the
+// remoting renderer. Video frames are then recorded and decoded like mirrored
+// frames. Audio cannot be played, so audio frames are only recorded, and only
+// when the sender remotes Opus.
+func (session *Session) playRemotingBuffer(streamType int32, decoder *videoDecoder, recorder *Recorder, plaintext []byte, frame encodedFrame, info media.StreamInfo) error {
+	var buffer remoting.DecoderBuffer
+	if err := buffer.Unmarshal(plaintext); err != nil {
+		session.log.Error("failed to parse remoting buffer", "err", err)
+		return fmt.Errorf("parse remoting buffer: %w", err)
+	}
 
-			if _, err := fo.Write(jpegBuffer.Bytes()); err != nil {
-				session.log.Error("failed to write jpeg", "err", err)
-				return
-			}
+	messages, play := session.remoting.deliver(streamType, &buffer)
+	session.sendRemotingMessages(messages)
+	if !play {
+		return nil
+	}
 
-			err = fo.Close()
-			if err != nil {
-				session.log.Warn("failed to close file", "err", err)
-				return
-			}
+	if streamType == remoting.StreamTypeAudio {
+		if session.remoting.audioCodec() == remoting.AudioCodecOpus {
+			session.recordAudioFrame(recorder, buffer.Data, frame)
 		}
+		return nil
+	}
+	if decoder == nil {
+		return nil
+	}
 
-		image = vpx.CodecGetFrame(session.vpxCtx, &iter)
+	if codec := session.remoting.videoCodec(); codec != remoting.VideoCodecVP8 {
+		session.log.Error("unsupported remoting video codec", "codec", codec)
+		return fmt.Errorf("unsupported remoting video codec %d", codec)
 	}
-}
 
-func NewSession(appId string, clientId int, device Device, displayName string, jpegOutput bool, sessionId string, transportId string) *Session {
-	log := common.NewLogger(fmt.Sprintf("session (%d) [%s]", clientId, sessionId))
+	session.recordVideoFrame(recorder, buffer.Data, frame)
+	return session.decodeBuffer(decoder, buffer.Data, frame, info)
+}
 
-	packetConn, err := net.ListenPacket("udp", ":50000")
+// decodeBuffer decodes a frame, and passes the resulting image to the frame
+// sink unless the frame is too late to be rendered.
+func (session *Session) decodeBuffer(decoder *videoDecoder, payload []byte, frame encodedFrame, info media.StreamInfo) error {
+	images, err := decoder.decode(payload, frame.render)
 	if err != nil {
-		return nil
+		session.log.Error("failed to decode buffer", "err", err)
+		return err
 	}
 
-	stop := make(chan struct{})
+	for _, image := range images {
+		session.frames.WriteFrame(media.Frame{
+			FrameID:      frame.frameId,
+			Image:        image,
+			PlayoutTime:  frame.playoutAt,
+			RtpTimestamp: frame.rtpTimestamp,
+			Stream:       info,
+			Timestamp:    frame.timestamp,
+		})
+	}
 
-	vpxCtx := vpx.NewCodecCtx()
-	vpxIface := vpx.DecoderIfaceVP8()
+	return nil
+}
 
-	err = vpx.Error(vpx.CodecDecInitVer(vpxCtx, vpxIface, nil, 0, vpx.DecoderABIVersion))
+// NewSession creates a session that receives RTP and RTCP on a UDP port chosen
+// according to listen.
+func NewSession(appId string, capture CaptureConfig, clientId int, device Device, displayName string, frames media.FrameSink, listen ListenConfig, recording RecordingConfig, sessionId string, transportId string) (*Session, error) {
+	packetConn, err := listenUDP(listen)
 	if err != nil {
-		log.Error(err.Error())
+		return nil, fmt.Errorf("create session: %w", err)
 	}
 
-	session := Session{
+	return newSession(appId, capture, clientId, device, displayName, frames, packetConn, recording, sessionId, transportId), nil
+}
+
+// newSession creates a session that receives RTP and RTCP on packetConn.
+func newSession(appId string, capture CaptureConfig, clientId int, device Device, displayName string, frames media.FrameSink, packetConn net.PacketConn, recording RecordingConfig, sessionId string, transportId string) *Session {
+	log := common.NewLogger(fmt.Sprintf("session (%d) [%s]", clientId, sessionId))
+
+	stop := make(chan struct{})
+
+	session := &Session{
 		AppId:       appId,
 		DisplayName: displayName,
 		SessionId:   sessionId,
 		StatusText:  "",
 
 		// internal
-		device:      device,
-		frameCount:  0,
-		jpegOutput:  jpegOutput,
-		log:         log,
-		packetConn:  packetConn,
-		stop:        stop,
-		stopping:    false,
-		streams:     make(map[uint32]*Stream),
-		transportId: transportId,
-		vpxCtx:      vpxCtx,
-		vpxIface:    vpxIface,
-	}
-
-	return &session
+		captureConfig: capture,
+		decoders:      make(map[uint32]*videoDecoder),
+		device:        device,
+		frames:        frames,
+		log:           log,
+		packetConn:    packetConn,
+		recording:     recording,
+		remoting:      newRemotingRenderer(log.Named("remoting")),
+		stop:          stop,
+		stopping:      false,
+		streams:       make(map[uint32]*Stream),
+		transportId:   transportId,
+	}
+
+	// capturing is cleared if the capture file cannot be written
+	session.capturing.Store(capture.Dir != "")
+
+	return session
 }
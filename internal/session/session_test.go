@@ -1,6 +1,18 @@
 package session
 
-import "testing"
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	// third-party
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+)
 
 func TestSelectVP8VideoStreamSelectsFirstVP8VideoOffer(t *testing.T) {
 	streams := []SupportedStream{
@@ -54,3 +66,321 @@ func TestSelectVP8VideoStreamRejectsUnsupportedStreams(t *testing.T) {
 		})
 	}
 }
+
+func TestSupportedStreamTiming(t *testing.T) {
+	tests := []struct {
+		name            string
+		stream          SupportedStream
+		wantClockRate   uint32
+		wantTargetDelay time.Duration
+	}{
+		{name: "defaults", wantClockRate: videoClockRate, wantTargetDelay: defaultTargetDelay},
+		{name: "explicit", stream: SupportedStream{TimeBase: "1/48000", TargetDelay: 150}, wantClockRate: 48000, wantTargetDelay: 150 * time.Millisecond},
+		{name: "malformed time base", stream: SupportedStream{TimeBase: "2/90000"}, wantClockRate: videoClockRate, wantTargetDelay: defaultTargetDelay},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.stream.clockRate(); got != test.wantClockRate {
+				t.Fatalf("clockRate() = %d, want %d", got, test.wantClockRate)
+			}
+			if got := test.stream.targetDelay(); got != test.wantTargetDelay {
+				t.Fatalf("targetDelay() = %v, want %v", got, test.wantTargetDelay)
+			}
+		})
+	}
+}
+
+func testOffer(t *testing.T, seqNum uint32, ssrc uint32) []byte {
+	t.Helper()
+
+	return testOfferWithKeys(t, seqNum, ssrc, hex.EncodeToString(syntheticAesKey), hex.EncodeToString(syntheticAesIvMask))
+}
+
+func testOfferWithKeys(t *testing.T, seqNum uint32, ssrc uint32, aesKey string, aesIvMask string) []byte {
+	t.Helper()
+
+	offer := webrtcOfferMessage{
+		WebrtcMessage: &WebrtcMessage{SeqNum: seqNum, Type: "OFFER"},
+		Offer: Offer{
+			CastMode: "mirroring",
+			SupportedStreams: []SupportedStream{{
+				AesIvMask: aesIvMask,
+				AesKey:    aesKey,
+				CodecName: vp8CodecName,
+				Ssrc:      ssrc,
+				Type:      videoSourceStreamType,
+			}},
+		},
+	}
+
+	payload, err := json.Marshal(&offer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return payload
+}
+
+func (device *testDevice) lastAnswer(t *testing.T, response any) {
+	t.Helper()
+
+	device.mu.Lock()
+	defer device.mu.Unlock()
+
+	if len(device.answers) == 0 {
+		t.Fatal("no response sent")
+	}
+	if err := json.Unmarshal([]byte(device.answers[len(device.answers)-1]), response); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSessionRenegotiationReplacesStreams(t *testing.T) {
+	session, device := newLoopbackSession(t, CaptureConfig{}, RecordingConfig{})
+	defer session.Stop()
+
+	session.HandleCastMessage(replayOffer(session, testOffer(t, 1, 100)))
+	var first webrtcAnswerMessage
+	device.lastAnswer(t, &first)
+
+	previous := session.stream(100)
+	if previous == nil {
+		t.Fatal("first offer did not create a stream")
+	}
+
+	session.HandleCastMessage(replayOffer(session, testOffer(t, 2, 200)))
+	var second webrtcAnswerMessage
+	device.lastAnswer(t, &second)
+
+	if second.SeqNum != 2 || !reflect.DeepEqual(second.Answer.Ssrcs, []uint32{201}) {
+		t.Fatalf("second answer %+v, want seqNum 2 and ssrcs [201]", second)
+	}
+	if second.Answer.UdpPort != first.Answer.UdpPort || second.Answer.UdpPort != session.GetPort() {
+		t.Fatalf("answer ports %d and %d, want %d", first.Answer.UdpPort, second.Answer.UdpPort, session.GetPort())
+	}
+
+	stats := session.StreamStats()
+	if len(stats) != 1 || stats[0].SSRC != 200 {
+		t.Fatalf("session streams %+v, want only ssrc 200", stats)
+	}
+	if !previous.closed {
+		t.Fatal("replaced stream was not closed")
+	}
+	if len(session.decoders) != 1 || session.decoders[200] == nil {
+		t.Fatalf("session has %d decoders, want one for ssrc 200", len(session.decoders))
+	}
+}
+
+func TestSessionRenegotiationStartsNewRecording(t *testing.T) {
+	dir := t.TempDir()
+	session, _ := newLoopbackSession(t, CaptureConfig{}, RecordingConfig{Dir: dir, Format: IVFFormat})
+	defer session.Stop()
+
+	session.HandleCastMessage(replayOffer(session, testOffer(t, 1, 100)))
+	first := session.recorder
+	if first == nil {
+		t.Fatal("first offer did not start a recording")
+	}
+
+	// the new stream has its own RTP timestamps and may have a new
+	// resolution, so it is recorded to a new file
+	session.HandleCastMessage(replayOffer(session, testOffer(t, 2, 200)))
+	if session.recorder == nil || session.recorder == first {
+		t.Fatal("second offer did not start a new recording")
+	}
+	if !first.closed {
+		t.Fatal("first recording was not closed")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("recording directory has %d files, want 2 (%v)", len(entries), err)
+	}
+}
+
+func TestSessionAcceptsOpusAudioForWebmRecordings(t *testing.T) {
+	offer := webrtcOfferMessage{
+		WebrtcMessage: &WebrtcMessage{SeqNum: 1, Type: "OFFER"},
+		Offer: Offer{
+			CastMode: "mirroring",
+			SupportedStreams: []SupportedStream{
+				{
+					AesIvMask: hex.EncodeToString(syntheticAesIvMask),
+					AesKey:    hex.EncodeToString(syntheticAesKey),
+					Channels:  2,
+					CodecName: opusCodecName,
+					Index:     0,
+					Ssrc:      10,
+					TimeBase:  "1/48000",
+					Type:      audioSourceStreamType,
+				},
+				{
+					AesIvMask: hex.EncodeToString(syntheticAesIvMask),
+					AesKey:    hex.EncodeToString(syntheticAesKey),
+					CodecName: vp8CodecName,
+					Index:     1,
+					Ssrc:      20,
+					Type:      videoSourceStreamType,
+				},
+			},
+		},
+	}
+	payload, err := json.Marshal(&offer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		recording RecordingConfig
+		want      []uint32
+	}{
+		{name: "not recording", want: []uint32{21}},
+		{name: "ivf", recording: RecordingConfig{Dir: t.TempDir(), Format: IVFFormat}, want: []uint32{21}},
+		{name: "webm", recording: RecordingConfig{Dir: t.TempDir(), Format: WebMFormat}, want: []uint32{21, 11}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			session, device := newLoopbackSession(t, CaptureConfig{}, test.recording)
+			defer session.Stop()
+
+			session.HandleCastMessage(replayOffer(session, payload))
+			var answer webrtcAnswerMessage
+			device.lastAnswer(t, &answer)
+			if !reflect.DeepEqual(answer.Answer.Ssrcs, test.want) {
+				t.Fatalf("answer accepted ssrcs %v, want %v", answer.Answer.Ssrcs, test.want)
+			}
+			if test.recording.Format == WebMFormat && session.recorder.audio == nil {
+				t.Fatal("recording has no audio track")
+			}
+		})
+	}
+}
+
+func TestSessionRejectsStreamsWithInvalidKeys(t *testing.T) {
+	validKey := hex.EncodeToString(syntheticAesKey)
+	validIvMask := hex.EncodeToString(syntheticAesIvMask)
+
+	tests := []struct {
+		name      string
+		aesKey    string
+		aesIvMask string
+	}{
+		{name: "missing key", aesIvMask: validIvMask},
+		{name: "key is not hex", aesKey: "not hex", aesIvMask: validIvMask},
+		{name: "short key", aesKey: validKey[:16], aesIvMask: validIvMask},
+		{name: "short iv mask", aesKey: validKey, aesIvMask: validIvMask[:24]},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			session, device := newLoopbackSession(t, CaptureConfig{}, RecordingConfig{})
+			defer session.Stop()
+
+			session.HandleCastMessage(replayOffer(session, testOfferWithKeys(t, 1, 100, test.aesKey, test.aesIvMask)))
+
+			var answer webrtcAnswerMessage
+			device.lastAnswer(t, &answer)
+			if len(answer.Answer.Ssrcs) != 0 {
+				t.Fatalf("answer accepted ssrcs %v", answer.Answer.Ssrcs)
+			}
+			if stats := session.StreamStats(); len(stats) != 0 {
+				t.Fatalf("session has streams %+v", stats)
+			}
+		})
+	}
+}
+
+func TestSessionAnswersStatusAndCapabilityRequests(t *testing.T) {
+	session, device := newLoopbackSession(t, CaptureConfig{}, RecordingConfig{})
+	defer session.Stop()
+
+	session.HandleCastMessage(replayOffer(session, []byte(`{"type":"GET_STATUS","seqNum":7}`)))
+	var status webrtcStatusMessage
+	device.lastAnswer(t, &status)
+	if status.Type != "STATUS_RESPONSE" || status.SeqNum != 7 || status.Result != "ok" {
+		t.Fatalf("unexpected status response %+v", status)
+	}
+
+	session.HandleCastMessage(replayOffer(session, []byte(`{"type":"GET_CAPABILITIES","seqNum":8}`)))
+	var capabilities webrtcCapabilitiesMessage
+	device.lastAnswer(t, &capabilities)
+	if capabilities.Type != "CAPABILITIES_RESPONSE" || capabilities.SeqNum != 8 || capabilities.Result != "ok" {
+		t.Fatalf("unexpected capabilities response %+v", capabilities)
+	}
+	if !reflect.DeepEqual(capabilities.Capabilities.MediaCaps, []string{"video", vp8CodecName}) {
+		t.Fatalf("media caps %v, want [video vp8]", capabilities.Capabilities.MediaCaps)
+	}
+}
+
+func TestSessionKeyFrameRequestSendsPLI(t *testing.T) {
+	session, _ := newLoopbackSession(t, CaptureConfig{}, RecordingConfig{})
+	defer session.Stop()
+
+	session.HandleCastMessage(replayOffer(session, testOffer(t, 1, syntheticSsrc)))
+
+	sender, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = sender.Close()
+	}()
+
+	// the session learns the sender's address from its first packet
+	payload := make([]byte, castHeaderLength+1)
+	payload[0] = 0x80
+	packet := rtp.Packet{
+		Header:  rtp.Header{Version: 2, PayloadType: syntheticPayloadType, SSRC: syntheticSsrc},
+		Payload: payload,
+	}
+	datagram, err := packet.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: session.GetPort()}
+	if _, err := sender.WriteTo(datagram, target); err != nil {
+		t.Fatal(err)
+	}
+
+	request := `{"type":"KEY_FRAME_REQUEST","seqNum":3,"ssrc":1000}`
+	requested := false
+	buffer := make([]byte, 2048)
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		if !requested && session.packetsReceived.Load() > 0 {
+			session.HandleCastMessage(replayOffer(session, []byte(request)))
+			requested = true
+		}
+
+		_ = sender.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+		n, _, err := sender.ReadFrom(buffer)
+		if err != nil {
+			continue
+		}
+
+		// cast feedback cannot be parsed by rtcp.Unmarshal, so each packet in
+		// the compound packet is inspected individually
+		for raw := buffer[:n]; len(raw) > 0; {
+			var header rtcp.Header
+			if err := header.Unmarshal(raw); err != nil {
+				t.Fatal(err)
+			}
+			length := (int(header.Length) + 1) * 4
+			if requested && header.Type == rtcp.TypePayloadSpecificFeedback && header.Count == rtcp.FormatPLI {
+				var pli rtcp.PictureLossIndication
+				if err := pli.Unmarshal(raw[:length]); err != nil {
+					t.Fatal(err)
+				}
+				if pli.MediaSSRC != syntheticSsrc {
+					t.Fatalf("pli for ssrc %d, want %d", pli.MediaSSRC, syntheticSsrc)
+				}
+				return
+			}
+			raw = raw[length:]
+		}
+	}
+
+	t.Fatal("no pli sent after keyframe request")
+}
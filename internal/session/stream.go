@@ -1,11 +1,9 @@
 package session
 
 import (
-	"bytes"
-	"encoding/binary"
-	"fmt"
-	"io"
 	"net"
+	"sync"
+	"time"
 
 	// third-party
 	"github.com/hashicorp/go-hclog"
@@ -13,187 +11,582 @@ import (
 	"github.com/pion/rtp"
 )
 
-type Stream struct {
-	actualFrameId int
-	buffer        []byte
-	ckptFrameId   int
-	decode        func([]byte, int)
-	highestSeq    uint16
-	log           hclog.Logger
-	nextFrameId   uint8
-	nextPacketId  uint16
-	ntpTime       uint64
-	packetsQueue  map[uint16]*rtp.Packet
-	receiverSsrc  uint32
-	rtpTime       uint32
-	sendRtcp      func([]byte, net.Addr)
-	senderSsrc    uint32
+const (
+	// lossCheckInterval is how often streams are checked for packets that
+	// should be re-requested.
+	lossCheckInterval = 20 * time.Millisecond
+
+	// nackInterval is the minimum time between retransmission requests for
+	// the same frame.
+	nackInterval = 50 * time.Millisecond
+
+	// maxNackAttempts is the number of retransmission requests made for a
+	// frame before the stream gives up on it and resynchronises on the next
+	// keyframe.
+	maxNackAttempts = 5
+
+	// keyframeRequestInterval is the minimum time between keyframe requests
+	// while the stream is waiting for a keyframe.
+	keyframeRequestInterval = 250 * time.Millisecond
+)
+
+// pendingFrame collects the packets of a frame that has not yet been decoded.
+// A frame that is known to exist, but for which no packets have arrived, has
+// nil packets.
+type pendingFrame struct {
+	firstPacketAt   time.Time
+	highestPacketId uint16
+	keyframe        bool
+	lastNackAt      time.Time
+	maxPacketId     uint16
+	nackAttempts    int
+	packets         map[uint16][]byte
+	rtpTimestamp    uint32
 }
 
-func (stream *Stream) enqueuePacket(packet *rtp.Packet) {
-	frameId := int(packet.Payload[1])
+func (frame *pendingFrame) complete() bool {
+	return frame.packets != nil && len(frame.packets) == int(frame.maxPacketId)+1
+}
 
-	// skip repeated packets
-	// TODO: wrap around broken
-	if uint8(frameId) < stream.nextFrameId && stream.nextFrameId > 0 {
-		stream.log.Info("skipping packet", "frameId", frameId, "nextFrameId", stream.nextFrameId)
-		return
+func (frame *pendingFrame) data() []byte {
+	size := 0
+	for _, packet := range frame.packets {
+		size += len(packet)
 	}
 
-	if packet.SequenceNumber > stream.highestSeq {
-		stream.highestSeq = packet.SequenceNumber
+	data := make([]byte, 0, size)
+	for packetId := 0; packetId <= int(frame.maxPacketId); packetId++ {
+		data = append(data, frame.packets[uint16(packetId)]...)
 	}
 
-	stream.packetsQueue[packet.SequenceNumber] = packet
-	stream.log.Info("enqueued packet", "sequenceNumber", packet.SequenceNumber, "frameId", frameId)
+	return data
 }
 
-func (stream *Stream) nextPacket() *rtp.Packet {
-	for seq := range stream.packetsQueue {
-		packet := stream.packetsQueue[seq]
-		frameId := uint8(packet.Payload[1])
-
-		payloadReader := bytes.NewReader(packet.Payload)
-		packetIdBytes := make([]byte, 2)
-		if _, err := payloadReader.Seek(2, io.SeekStart); err != nil {
-			stream.log.Warn("failed to seek packet id", "err", err)
-			continue
-		}
-		if err := binary.Read(payloadReader, binary.BigEndian, packetIdBytes); err != nil {
-			stream.log.Warn("failed to read packet id", "err", err)
-			continue
-		}
-		packetId := binary.BigEndian.Uint16(packetIdBytes)
+// missingPacketIds lists the packets that have not been received. Unless
+// includeTail is set, packets after the highest received packet are assumed
+// to still be in flight.
+func (frame *pendingFrame) missingPacketIds(includeTail bool) []uint16 {
+	last := int(frame.highestPacketId)
+	if includeTail {
+		last = int(frame.maxPacketId)
+	}
 
-		maxPacketIdBytes := make([]byte, 2)
-		if _, err := payloadReader.Seek(4, io.SeekStart); err != nil {
-			stream.log.Warn("failed to seek max packet id", "err", err)
-			continue
-		}
-		if err := binary.Read(payloadReader, binary.BigEndian, maxPacketIdBytes); err != nil {
-			stream.log.Warn("failed to read max packet id", "err", err)
-			continue
-		}
-		maxPacketId := binary.BigEndian.Uint16(maxPacketIdBytes)
-
-		if packetId == stream.nextPacketId && frameId == stream.nextFrameId {
-			delete(stream.packetsQueue, seq)
-			if packetId == maxPacketId {
-				stream.nextPacketId = 0
-				stream.nextFrameId = stream.nextFrameId + 1
-			} else {
-				stream.nextPacketId++
-			}
-			return packet
+	var missing []uint16
+	for packetId := 0; packetId <= last; packetId++ {
+		if _, ok := frame.packets[uint16(packetId)]; !ok {
+			missing = append(missing, uint16(packetId))
 		}
 	}
 
-	return nil
+	return missing
 }
 
-func (stream *Stream) handleDataPacket(packet *rtp.Packet, addr net.Addr) {
-	bits := int(packet.Payload[0])
-	keyframe := (bits & 0x80) != 0
-	hasRef := (bits & 0x40) != 0
-	numExt := bits & 0x3f
-	frameId := int(packet.Payload[1])
+// encodedFrame is a complete, still encrypted frame that is ready to be
+// decoded. Frames that are too late to be rendered are still decoded, since
+// subsequent frames may depend on them.
+type encodedFrame struct {
+	data         []byte
+	frameId      int64
+	keyframe     bool
+	playoutAt    time.Time
+	render       bool
+	rtpTimestamp uint32
+	timestamp    time.Duration
+}
 
-	//	payload:     packet.Payload,
-	//	seq:         packet.SequenceNumber,
-	//	ssrc:        packet.SSRC,
+// readyFrame is a complete frame that is waiting for its playout time.
+type readyFrame struct {
+	completedAt time.Time
+	frame       *pendingFrame
+	frameId     int64
+}
 
-	payloadReader := bytes.NewReader(packet.Payload)
+type Stream struct {
+	mu sync.Mutex
+
+	addr                net.Addr
+	bitrate             bitrateMeter
+	bytesReceived       uint64
+	checkpointFrameId   int64
+	closed              bool
+	decode              func(encodedFrame) error
+	decodeErrors        uint64
+	eventLog            receiverEventLog
+	frames              map[int64]*pendingFrame
+	framesDecoded       uint64
+	framesDropped       uint64
+	framesRendered      uint64
+	keyframeRequested   bool
+	lastKeyframeRequest time.Time
+	lastSenderReportAt  time.Time
+	latestFrameId       int64
+	log                 hclog.Logger
+	ntpTime             uint64
+	packetsDuplicated   uint64
+	playout             playoutClock
+	playoutDelay        time.Duration
+	readyFrames         []readyFrame
+	reception           receptionStats
+	receiverSsrc        uint32
+	rtpTime             uint32
+	sendRtcp            func([]byte, net.Addr)
+	senderSsrc          uint32
+	timeline            rtpTimeline
+	unackedFrames       []uint32
+	waitingForKeyframe  bool
+}
+
+func (stream *Stream) handleDataPacket(packet *rtp.Packet, addr net.Addr, now time.Time) {
+	header, data, err := parseCastPacket(packet.Payload)
+	if err != nil {
+		stream.log.Warn("dropping malformed packet", "seq", packet.SequenceNumber, "err", err)
+		return
+	}
+
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
 
-	packetIdBytes := make([]byte, 2)
-	if _, err := payloadReader.Seek(2, io.SeekStart); err != nil {
-		stream.log.Warn("failed to seek packet id", "err", err)
+	if stream.closed {
 		return
 	}
-	if err := binary.Read(payloadReader, binary.BigEndian, packetIdBytes); err != nil {
-		stream.log.Warn("failed to read packet id", "err", err)
+
+	stream.addr = addr
+	stream.reception.update(packet.SequenceNumber, packet.Timestamp, now)
+	stream.bytesReceived += uint64(len(packet.Payload))
+	stream.bitrate.add(len(packet.Payload), now)
+
+	frameId := expandFrameId(header.frameId, stream.checkpointFrameId+1)
+	if frameId <= stream.checkpointFrameId {
+		stream.log.Info("skipping packet", "frameId", frameId, "checkpointFrameId", stream.checkpointFrameId)
+		stream.packetsDuplicated++
 		return
 	}
-	packetId := binary.BigEndian.Uint16(packetIdBytes)
 
-	maxPacketIdBytes := make([]byte, 2)
-	if _, err := payloadReader.Seek(4, io.SeekStart); err != nil {
-		stream.log.Warn("failed to seek max packet id", "err", err)
+	// frames between the latest frame and this one have been lost entirely
+	for missingFrameId := stream.latestFrameId + 1; missingFrameId < frameId; missingFrameId++ {
+		stream.frames[missingFrameId] = &pendingFrame{firstPacketAt: now}
+	}
+	if frameId > stream.latestFrameId {
+		stream.latestFrameId = frameId
+	}
+
+	frame := stream.frames[frameId]
+	if frame == nil {
+		frame = &pendingFrame{}
+		stream.frames[frameId] = frame
+	}
+
+	if frame.packets == nil {
+		frame.firstPacketAt = now
+		frame.keyframe = header.keyframe
+		frame.maxPacketId = header.maxPacketId
+		frame.packets = make(map[uint16][]byte)
+		frame.rtpTimestamp = packet.Timestamp
+		stream.playout.observeFrame(packet.Timestamp, now)
+	} else if frame.maxPacketId != header.maxPacketId {
+		stream.log.Warn("dropping packet with inconsistent max packet id",
+			"frameId", frameId,
+			"maxPacketId", header.maxPacketId,
+			"expectedMaxPacketId", frame.maxPacketId)
 		return
 	}
-	if err := binary.Read(payloadReader, binary.BigEndian, maxPacketIdBytes); err != nil {
-		stream.log.Warn("failed to read max packet id", "err", err)
+
+	if _, ok := frame.packets[header.packetId]; ok {
+		stream.log.Debug("skipping duplicate packet", "frameId", frameId, "packetId", header.packetId)
+		stream.packetsDuplicated++
+		return
+	}
+
+	frame.packets[header.packetId] = data
+	if header.packetId > frame.highestPacketId {
+		frame.highestPacketId = header.packetId
+	}
+
+	stream.eventLog.add(ReceiverEvent{
+		At:           now,
+		PacketID:     header.packetId,
+		RTPTimestamp: frame.rtpTimestamp,
+		Type:         PacketReceivedEvent,
+	})
+
+	stream.log.Info("enqueued packet",
+		"seq", packet.SequenceNumber,
+		"frameId", frameId,
+		"packetId", header.packetId,
+		"maxPacketId", header.maxPacketId)
+
+	if header.newPlayoutDelay != 0 {
+		playoutDelay := time.Duration(header.newPlayoutDelay) * time.Millisecond
+		if playoutDelay != stream.playoutDelay {
+			stream.log.Info("sender changed playout delay", "from", stream.playoutDelay, "to", playoutDelay)
+			stream.playoutDelay = playoutDelay
+		}
+	}
+
+	if !frame.complete() {
 		return
 	}
-	maxPacketId := binary.BigEndian.Uint16(maxPacketIdBytes)
 
 	stream.log.Info("frame",
-		"keyframe", keyframe,
-		"hasRef", hasRef,
-		"numExt", numExt,
-		"marker", packet.Marker,
+		"keyframe", frame.keyframe,
 		"frameId", frameId,
-		"ckptFrameId", stream.ckptFrameId,
-		"packetId", packetId,
-		"maxPacketId", maxPacketId,
-		"seq", packet.SequenceNumber)
-
-	offset := 6
-	if hasRef {
-		if _, err := payloadReader.Seek(int64(offset), io.SeekStart); err != nil {
-			stream.log.Warn("failed to seek reference id", "err", err)
-			return
+		"checkpointFrameId", stream.checkpointFrameId,
+		"packets", len(frame.packets))
+
+	stream.deliverFrames(now)
+	stream.sendFeedback(now, stream.collectLossFields(now))
+}
+
+// deliverFrames queues complete frames for playout in order, starting
+// immediately after the checkpoint. While waiting for a keyframe, frames that
+// precede the next complete keyframe are skipped.
+func (stream *Stream) deliverFrames(now time.Time) {
+	for {
+		if stream.waitingForKeyframe {
+			if keyframeId, ok := stream.nextCompleteKeyframe(); ok && keyframeId > stream.checkpointFrameId+1 {
+				stream.skipTo(keyframeId - 1)
+			}
+		}
+
+		frameId := stream.checkpointFrameId + 1
+		frame := stream.frames[frameId]
+		if frame == nil || !frame.complete() {
+			break
+		}
+
+		delete(stream.frames, frameId)
+		stream.checkpointFrameId = frameId
+		stream.unackedFrames = append(stream.unackedFrames, frame.rtpTimestamp)
+
+		if stream.waitingForKeyframe && !frame.keyframe {
+			stream.log.Info("dropping frame while waiting for keyframe", "frameId", frameId)
+			stream.framesDropped++
+			continue
 		}
-		refId, _ := payloadReader.ReadByte()
-		stream.log.Debug(fmt.Sprintf("ref id: %d", refId))
-		offset++
+
+		stream.waitingForKeyframe = false
+		stream.readyFrames = append(stream.readyFrames, readyFrame{completedAt: now, frame: frame, frameId: frameId})
+	}
+
+	stream.playDueFrames(now)
+}
+
+func (stream *Stream) hasReadyFrames() bool {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	return len(stream.readyFrames) > 0
+}
+
+// playFrames decodes any frames that are due to be played out.
+func (stream *Stream) playFrames(now time.Time) {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	if stream.closed {
+		return
 	}
 
-	for i := 0; i < numExt; i++ {
-		if _, err := payloadReader.Seek(int64(offset), io.SeekStart); err != nil {
-			stream.log.Warn("failed to seek extension header", "err", err)
+	stream.playDueFrames(now)
+}
+
+// playDueFrames decodes ready frames once their playout time arrives. Frames
+// that are too late are decoded without being rendered, so that the decoder
+// keeps its reference frames while the stream catches up.
+func (stream *Stream) playDueFrames(now time.Time) {
+	for len(stream.readyFrames) > 0 {
+		ready := stream.readyFrames[0]
+		playoutAt := stream.playoutTime(ready)
+		if now.Before(playoutAt) {
 			return
 		}
-		typeAndSizeBytes := make([]byte, 2)
-		if err := binary.Read(payloadReader, binary.BigEndian, typeAndSizeBytes); err != nil {
-			stream.log.Warn("failed to read extension header", "err", err)
-			return
+
+		stream.readyFrames = stream.readyFrames[1:]
+
+		render := now.Sub(playoutAt) <= maxPlayoutLateness
+		if render {
+			stream.log.Info("decoding frame", "frameId", ready.frameId, "keyframe", ready.frame.keyframe)
+		} else {
+			stream.log.Warn("decoding late frame without rendering", "frameId", ready.frameId, "late", now.Sub(playoutAt))
+		}
+		ticks := stream.timeline.extend(ready.frame.rtpTimestamp)
+		decodeStarted := time.Now()
+		err := stream.decode(encodedFrame{
+			data:         ready.frame.data(),
+			frameId:      ready.frameId,
+			keyframe:     ready.frame.keyframe,
+			playoutAt:    playoutAt,
+			render:       render,
+			rtpTimestamp: ready.frame.rtpTimestamp,
+			timestamp:    rtpDuration(ticks, stream.playout.clockRate),
+		})
+
+		stream.framesDecoded++
+		if err != nil {
+			stream.decodeErrors++
+			stream.framesDropped++
+		} else if render {
+			stream.framesRendered++
+		} else {
+			stream.framesDropped++
 		}
 
-		typeAndSize := int(binary.BigEndian.Uint16(typeAndSizeBytes))
-		dataType := typeAndSize >> 10
-		dataSize := typeAndSize & 0x3FF
+		rtpTimestamp := ready.frame.rtpTimestamp
+		stream.eventLog.add(ReceiverEvent{At: now, Delay: playoutAt.Sub(now), RTPTimestamp: rtpTimestamp, Type: FramePlayoutEvent})
+
+		// the decoded event is offset from now by the time taken to decode,
+		// so that senders can tell decoding apart from buffering
+		decodedAt := now.Add(time.Since(decodeStarted))
+		stream.eventLog.add(ReceiverEvent{At: decodedAt, RTPTimestamp: rtpTimestamp, Type: FrameDecodedEvent})
+	}
+}
+
+// StreamStats are the reception, decoding and playout counters for a stream.
+// Counters are cumulative for the life of the stream.
+type StreamStats struct {
+	// Bitrate is the rate at which payload data arrived during the most
+	// recent one second interval, in bits per second.
+	Bitrate       float64
+	BytesReceived uint64
+	DecodeErrors  uint64
+
+	// FramesDecoded includes frames that were decoded without being
+	// rendered, and frames that failed to decode.
+	FramesDecoded uint64
+
+	// FramesDropped counts frames that were not rendered, because they were
+	// late, failed to decode, or were abandoned while resyncing on a keyframe.
+	FramesDropped  uint64
+	FramesRendered uint64
+	Jitter         time.Duration
+
+	// PacketsDuplicated counts packets that repeat a packet that was already
+	// received, or that belong to a frame that has already been played out
+	// or abandoned.
+	PacketsDuplicated uint64
+
+	// PacketsLost is the number of packets expected less the number received,
+	// which can be negative if duplicates arrive before losses.
+	PacketsLost     int64
+	PacketsReceived uint64
+	PlayoutDelay    time.Duration
+	SSRC            uint32
+}
 
-		if dataType == 1 {
-			stream.log.Debug("ignored adaptive latency extension")
+func (stream *Stream) stats(now time.Time) StreamStats {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	return StreamStats{
+		Bitrate:           stream.bitrate.rate(now),
+		BytesReceived:     stream.bytesReceived,
+		DecodeErrors:      stream.decodeErrors,
+		FramesDecoded:     stream.framesDecoded,
+		FramesDropped:     stream.framesDropped,
+		FramesRendered:    stream.framesRendered,
+		Jitter:            rtpDuration(int64(stream.reception.jitter), stream.reception.clockRate),
+		PacketsDuplicated: stream.packetsDuplicated,
+		PacketsLost:       stream.reception.lost(),
+		PacketsReceived:   uint64(stream.reception.received),
+		PlayoutDelay:      stream.playoutDelay,
+		SSRC:              stream.senderSsrc,
+	}
+}
+
+// playoutTime is the time at which a frame should be played out. A frame is
+// never held for longer than the playout delay after it was completed, so that
+// a poor estimate of the sender's clock cannot stall the stream.
+func (stream *Stream) playoutTime(ready readyFrame) time.Time {
+	playoutAt := stream.playout.localTime(ready.frame.rtpTimestamp).Add(stream.playoutDelay)
+	if latest := ready.completedAt.Add(stream.playoutDelay); playoutAt.After(latest) {
+		return latest
+	}
+
+	return playoutAt
+}
+
+func (stream *Stream) nextCompleteKeyframe() (int64, bool) {
+	for frameId := stream.checkpointFrameId + 1; frameId <= stream.latestFrameId; frameId++ {
+		if frame := stream.frames[frameId]; frame != nil && frame.keyframe && frame.complete() {
+			return frameId, true
+		}
+	}
+
+	return 0, false
+}
+
+// skipTo abandons every frame up to and including frameId, and moves the
+// checkpoint so that the sender stops retransmitting them.
+func (stream *Stream) skipTo(frameId int64) {
+	stream.log.Warn("skipping frames", "from", stream.checkpointFrameId+1, "to", frameId)
+	for skippedFrameId := stream.checkpointFrameId + 1; skippedFrameId <= frameId; skippedFrameId++ {
+		delete(stream.frames, skippedFrameId)
+	}
+	stream.framesDropped += uint64(frameId - stream.checkpointFrameId)
+	stream.checkpointFrameId = frameId
+}
+
+// collectLossFields reports the packets that should be retransmitted, and
+// records the request against each frame so that retries can be rate-limited.
+func (stream *Stream) collectLossFields(now time.Time) []CastLossField {
+	var fields []CastLossField
+
+	for frameId := stream.checkpointFrameId + 1; frameId <= stream.latestFrameId; frameId++ {
+		frame := stream.frames[frameId]
+		if frame == nil || frame.complete() {
+			continue
+		}
+		if stream.waitingForKeyframe && !frame.keyframe {
+			continue
+		}
+		if frame.nackAttempts >= maxNackAttempts || now.Sub(frame.lastNackAt) < nackInterval {
+			continue
+		}
+
+		var frameFields []CastLossField
+		if frame.packets == nil {
+			frameFields = []CastLossField{{FrameId: uint8(frameId), PacketId: AllPacketsLost}}
 		} else {
-			stream.log.Info("ignoring unknown extension type", "dataType", dataType, "dataSize", dataSize)
+			includeTail := frameId < stream.latestFrameId || now.Sub(frame.firstPacketAt) >= nackInterval
+			frameFields = lossFieldsForPackets(uint8(frameId), frame.missingPacketIds(includeTail))
+		}
+
+		if len(frameFields) == 0 {
+			continue
+		}
+		if len(fields)+len(frameFields) > maxLossFields {
+			break
+		}
+
+		frame.lastNackAt = now
+		frame.nackAttempts++
+		fields = append(fields, frameFields...)
+	}
+
+	return fields
+}
+
+// lossFieldsForPackets packs a sorted list of missing packet IDs into loss
+// fields, using each field's bitmask to cover the eight packets that follow.
+func lossFieldsForPackets(frameId uint8, missing []uint16) []CastLossField {
+	var fields []CastLossField
+
+	for i := 0; i < len(missing); {
+		field := CastLossField{FrameId: frameId, PacketId: missing[i]}
+		i++
+		for i < len(missing) && missing[i]-field.PacketId <= 8 {
+			field.Bitmask |= 1 << (missing[i] - field.PacketId - 1)
+			i++
 		}
+		fields = append(fields, field)
+	}
+
+	return fields
+}
+
+// checkLoss re-requests overdue packets, and resynchronises on the next
+// keyframe once retransmission of the frame after the checkpoint has failed.
+func (stream *Stream) checkLoss(now time.Time) {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	if stream.closed || stream.addr == nil {
+		return
+	}
 
-		offset += dataSize + 2
+	checkpointFrameId := stream.checkpointFrameId
+	stalled := stream.frames[checkpointFrameId+1]
+	if stalled != nil && !stalled.complete() && !stream.waitingForKeyframe &&
+		stalled.nackAttempts >= maxNackAttempts && now.Sub(stalled.lastNackAt) >= nackInterval {
+		stream.log.Warn("failed to recover frame", "frameId", checkpointFrameId+1, "attempts", stalled.nackAttempts)
+		stream.waitingForKeyframe = true
+		stream.deliverFrames(now)
 	}
 
-	stream.buffer = append(stream.buffer, packet.Payload[offset:]...)
+	fields := stream.collectLossFields(now)
+	if len(fields) > 0 || stream.checkpointFrameId != checkpointFrameId || stream.keyframeRequestDue(now) {
+		stream.sendFeedback(now, fields)
+	}
+}
+
+func (stream *Stream) keyframeRequestDue(now time.Time) bool {
+	return stream.keyframeRequested ||
+		(stream.waitingForKeyframe && now.Sub(stream.lastKeyframeRequest) >= keyframeRequestInterval)
+}
+
+// requestKeyframe asks the sender for a keyframe, on behalf of a sender that
+// has lost its own state, or a consumer that needs a fresh picture. The
+// request is sent immediately once the sender's address is known, and
+// otherwise with the first feedback.
+func (stream *Stream) requestKeyframe(now time.Time) {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	if stream.closed {
+		return
+	}
+
+	stream.keyframeRequested = true
+	stream.sendFeedback(now, stream.collectLossFields(now))
+}
+
+// close retires the stream after it has been replaced by a renegotiation, or
+// its session has stopped. Frames that have not yet been played out are
+// discarded, and packets that arrive afterwards are ignored.
+func (stream *Stream) close() {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	stream.closed = true
+	stream.frames = make(map[int64]*pendingFrame)
+	stream.readyFrames = nil
+}
+
+// sendFeedback sends a compound RTCP packet containing a receiver report,
+// receiver reference time, optional keyframe request, Cast feedback and any
+// pending receiver events, in the order that Cast senders expect.
+func (stream *Stream) sendFeedback(now time.Time, lossFields []CastLossField) {
+	if stream.addr == nil {
+		return
+	}
+
+	stream.log.Info("sending psfb", "lossFields", len(lossFields))
+	payload := stream.prepareReceiverReport(now)
+	payload = append(payload, stream.prepareExtendedReport(now)...)
+
+	if stream.keyframeRequestDue(now) {
+		stream.log.Info("requesting keyframe")
+		stream.keyframeRequested = false
+		stream.lastKeyframeRequest = now
+		payload = append(payload, stream.preparePLI()...)
+	}
+
+	payload = append(payload, stream.preparePSFB(lossFields)...)
 
-	if packet.Marker {
-		// send payload-specific feedback
-		stream.log.Info("sending psfb")
-		extReportBytes := stream.prepareExtendedReport(stream.ntpTime)
-		psfbBytes := stream.preparePSFB()
-		payload := append(extReportBytes, psfbBytes...)
-		stream.sendRtcp(payload, addr)
+	for _, rtpTimestamp := range stream.unackedFrames {
+		stream.eventLog.add(ReceiverEvent{At: now, RTPTimestamp: rtpTimestamp, Type: FrameAckSentEvent})
+	}
+	stream.unackedFrames = stream.unackedFrames[:0]
 
-		// decode frame
-		stream.log.Info("decoding frame", "actualFrameId", stream.actualFrameId, "ckptFrameId", stream.ckptFrameId)
-		stream.decode(stream.buffer, stream.actualFrameId)
-		stream.buffer = make([]byte, 0)
-		stream.ckptFrameId = frameId
-		stream.actualFrameId++
+	receiverLog, err := stream.eventLog.marshal(stream.receiverSsrc)
+	if err != nil {
+		stream.log.Warn("failed to prepare receiver log", "err", err)
 	}
+	payload = append(payload, receiverLog...)
+
+	stream.sendRtcp(payload, stream.addr)
 }
 
-func (stream *Stream) handleRtcpPackets(packets []rtcp.Packet, addr net.Addr) {
+func (stream *Stream) handleRtcpPackets(packets []rtcp.Packet, addr net.Addr, now time.Time) {
 	stream.log.Info("received rtcp packets", "len", len(packets))
 
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	if stream.closed {
+		return
+	}
+
 	for _, packet := range packets {
 		switch p := packet.(type) {
 		case *rtcp.SenderReport:
@@ -202,31 +595,46 @@ func (stream *Stream) handleRtcpPackets(packets []rtcp.Packet, addr net.Addr) {
 			// update sender timestamps
 			stream.ntpTime = p.NTPTime
 			stream.rtpTime = p.RTPTime
+			stream.lastSenderReportAt = now
+			stream.playout.observeSenderReport(p.NTPTime, p.RTPTime, now)
 
 			// respond with a receiver report
-			go func() {
-				stream.log.Info("sending receiver report")
-				extReportBytes := stream.prepareExtendedReport(stream.ntpTime)
-				recvReportBytes := stream.prepareReceiverReport(stream.rtpTime)
-				payload := append(extReportBytes, recvReportBytes...)
-				stream.sendRtcp(payload, addr)
-			}()
+			stream.log.Info("sending receiver report")
+			if stream.addr == nil {
+				stream.addr = addr
+			}
+			stream.sendFeedback(now, stream.collectLossFields(now))
 		default:
 			stream.log.Info("skipping rtcp packet")
 		}
 	}
 }
 
-func (stream *Stream) preparePSFB() []byte {
+func (stream *Stream) preparePLI() []byte {
+	pli := rtcp.PictureLossIndication{
+		SenderSSRC: stream.receiverSsrc,
+		MediaSSRC:  stream.senderSsrc,
+	}
+
+	payload, err := pli.Marshal()
+	if err != nil {
+		stream.log.Warn("failed to prepare pli", "err", err)
+		return nil
+	}
+
+	return payload
+}
+
+func (stream *Stream) preparePSFB(lossFields []CastLossField) []byte {
 	feedback := CastFeedback{
-		ReceiverSSRC:        stream.receiverSsrc,
-		SenderSSRC:          stream.senderSsrc,
-		CkPtFrameId:         uint8(stream.ckptFrameId),
-		LossFields:          0,
-		CurrentPlayoutDelay: 400,
+		ReceiverSSRC:       stream.receiverSsrc,
+		SenderSSRC:         stream.senderSsrc,
+		CkPtFrameId:        uint8(stream.checkpointFrameId),
+		LossFields:         lossFields,
+		TargetPlayoutDelay: uint16(stream.playoutDelay.Milliseconds()),
 	}
 
-	stream.log.Debug("psfb", "psfb", feedback)
+	stream.log.Debug("psfb", "psfb", feedback.String())
 
 	payload, err := feedback.Marshal()
 	if err != nil {
@@ -237,11 +645,11 @@ func (stream *Stream) preparePSFB() []byte {
 	return payload
 }
 
-func (stream *Stream) prepareExtendedReport(time uint64) []byte {
+func (stream *Stream) prepareExtendedReport(now time.Time) []byte {
 	var reports []rtcp.ReportBlock
 
 	reports = append(reports, &rtcp.ReceiverReferenceTimeReportBlock{
-		NTPTimestamp: time,
+		NTPTimestamp: toNtpTime(now),
 	})
 
 	report := rtcp.ExtendedReport{
@@ -258,12 +666,16 @@ func (stream *Stream) prepareExtendedReport(time uint64) []byte {
 	return payload
 }
 
-func (stream *Stream) prepareReceiverReport(rtpTime uint32) []byte {
+func (stream *Stream) prepareReceiverReport(now time.Time) []byte {
 	reports := make([]rtcp.ReceptionReport, 1)
-	reports[0].SSRC = stream.senderSsrc
-	reports[0].LastSenderReport = rtpTime
-	reports[0].Delay = 2000
-	reports[0].LastSequenceNumber = uint32(stream.highestSeq)
+	reports[0] = stream.reception.report(stream.senderSsrc)
+
+	// LSR is the middle 32 bits of the sender report's NTP timestamp, and DLSR
+	// is the time since it arrived in units of 1/65536 seconds
+	if !stream.lastSenderReportAt.IsZero() {
+		reports[0].LastSenderReport = uint32(stream.ntpTime >> 16)
+		reports[0].Delay = uint32(now.Sub(stream.lastSenderReportAt) * 65536 / time.Second)
+	}
 
 	report := rtcp.ReceiverReport{
 		SSRC:              stream.receiverSsrc,
@@ -280,21 +692,20 @@ func (stream *Stream) prepareReceiverReport(rtpTime uint32) []byte {
 	return payload
 }
 
-func NewStream(decode func([]byte, int), log hclog.Logger, sendRtcp func([]byte, net.Addr), receiverSsrc uint32, senderSsrc uint32) *Stream {
+func NewStream(decode func(encodedFrame) error, log hclog.Logger, sendRtcp func([]byte, net.Addr), receiverSsrc uint32, senderSsrc uint32, clockRate uint32, targetDelay time.Duration) *Stream {
 	return &Stream{
-		actualFrameId: 0,
-		buffer:        make([]byte, 0),
-		ckptFrameId:   255,
-		decode:        decode,
-		highestSeq:    0,
-		log:           log,
-		nextFrameId:   0,
-		nextPacketId:  0,
-		ntpTime:       0,
-		packetsQueue:  make(map[uint16]*rtp.Packet),
-		receiverSsrc:  receiverSsrc,
-		rtpTime:       0,
-		sendRtcp:      sendRtcp,
-		senderSsrc:    senderSsrc,
+		checkpointFrameId: -1,
+		decode:            decode,
+		frames:            make(map[int64]*pendingFrame),
+		latestFrameId:     -1,
+		log:               log,
+		ntpTime:           0,
+		playout:           playoutClock{clockRate: clockRate},
+		playoutDelay:      targetDelay,
+		reception:         receptionStats{clockRate: clockRate},
+		receiverSsrc:      receiverSsrc,
+		rtpTime:           0,
+		sendRtcp:          sendRtcp,
+		senderSsrc:        senderSsrc,
 	}
 }
@@ -0,0 +1,554 @@
+package session
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+)
+
+type testStream struct {
+	*Stream
+
+	decodeErr error
+	decoded   []int
+	data      [][]byte
+	rendered  []int
+	sent      [][]byte
+}
+
+// newTestStream creates a stream without a playout delay, so that frames are
+// played out as soon as they are complete.
+func newTestStream() *testStream {
+	return newTestStreamWithDelay(0)
+}
+
+func newTestStreamWithDelay(targetDelay time.Duration) *testStream {
+	test := &testStream{}
+	decode := func(frame encodedFrame) error {
+		test.decoded = append(test.decoded, int(frame.frameId))
+		test.data = append(test.data, frame.data)
+		if frame.render {
+			test.rendered = append(test.rendered, int(frame.frameId))
+		}
+		return test.decodeErr
+	}
+	sendRtcp := func(buffer []byte, _ net.Addr) {
+		test.sent = append(test.sent, buffer)
+	}
+	test.Stream = NewStream(decode, hclog.NewNullLogger(), sendRtcp, 2, 1, videoClockRate, targetDelay)
+	return test
+}
+
+func (test *testStream) receive(seq uint16, frameId uint8, packetId uint16, maxPacketId uint16, keyframe bool, now time.Time) {
+	test.receiveWithTimestamp(seq, frameId, packetId, maxPacketId, keyframe, 0, now)
+}
+
+func (test *testStream) receiveWithTimestamp(seq uint16, frameId uint8, packetId uint16, maxPacketId uint16, keyframe bool, rtpTimestamp uint32, now time.Time) {
+	payload := make([]byte, castHeaderLength, castHeaderLength+1)
+	if keyframe {
+		payload[0] = 0x80
+	}
+	payload[1] = frameId
+	binary.BigEndian.PutUint16(payload[2:], packetId)
+	binary.BigEndian.PutUint16(payload[4:], maxPacketId)
+	payload = append(payload, byte(packetId))
+
+	packet := &rtp.Packet{
+		Header:  rtp.Header{SequenceNumber: seq, SSRC: 1, Timestamp: rtpTimestamp},
+		Payload: payload,
+	}
+	test.handleDataPacket(packet, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1234}, now)
+}
+
+// lastFeedback decodes the Cast feedback and any PLI from the most recently
+// sent compound RTCP packet.
+func (test *testStream) lastFeedback(t *testing.T) (CastFeedback, bool) {
+	t.Helper()
+	if len(test.sent) == 0 {
+		t.Fatal("no rtcp sent")
+	}
+
+	var feedback CastFeedback
+	found := false
+	pli := false
+	raw := test.sent[len(test.sent)-1]
+	for len(raw) > 0 {
+		var header rtcp.Header
+		if err := header.Unmarshal(raw); err != nil {
+			t.Fatal(err)
+		}
+		length := (int(header.Length) + 1) * 4
+		if header.Type == rtcp.TypePayloadSpecificFeedback && header.Count == rtcp.FormatREMB {
+			if err := feedback.Unmarshal(raw[:length]); err != nil {
+				t.Fatal(err)
+			}
+			found = true
+		}
+		if header.Type == rtcp.TypePayloadSpecificFeedback && header.Count == rtcp.FormatPLI {
+			pli = true
+		}
+		raw = raw[length:]
+	}
+	if !found {
+		t.Fatal("rtcp did not include cast feedback")
+	}
+
+	return feedback, pli
+}
+
+func TestStreamReassemblesOutOfOrderPackets(t *testing.T) {
+	test := newTestStream()
+	now := time.Now()
+
+	test.receive(2, 0, 2, 2, true, now)
+	test.receive(0, 0, 0, 2, true, now)
+	test.receive(1, 0, 1, 2, true, now)
+	test.receive(3, 1, 0, 0, false, now)
+
+	if !reflect.DeepEqual(test.decoded, []int{0, 1}) {
+		t.Fatalf("decoded frames %v, want [0 1]", test.decoded)
+	}
+	if string(test.data[0]) != "\x00\x01\x02" {
+		t.Fatalf("frame data %x was not assembled in packet order", test.data[0])
+	}
+
+	feedback, _ := test.lastFeedback(t)
+	if feedback.CkPtFrameId != 1 || len(feedback.LossFields) != 0 {
+		t.Fatalf("unexpected feedback %+v", feedback)
+	}
+}
+
+func TestStreamIgnoresDuplicateAndStalePackets(t *testing.T) {
+	test := newTestStream()
+	now := time.Now()
+
+	test.receive(0, 0, 0, 1, true, now)
+	test.receive(0, 0, 0, 1, true, now)
+	test.receive(1, 0, 1, 1, true, now)
+	test.receive(1, 0, 1, 1, true, now)
+
+	if !reflect.DeepEqual(test.decoded, []int{0}) {
+		t.Fatalf("decoded frames %v, want [0]", test.decoded)
+	}
+}
+
+func TestStreamRequestsMissingPackets(t *testing.T) {
+	test := newTestStream()
+	now := time.Now()
+
+	test.receive(0, 0, 0, 3, true, now)
+	test.receive(3, 0, 3, 3, true, now)
+
+	test.checkLoss(now)
+	feedback, _ := test.lastFeedback(t)
+	want := []CastLossField{{FrameId: 0, PacketId: 1, Bitmask: 0x01}}
+	if !reflect.DeepEqual(feedback.LossFields, want) {
+		t.Fatalf("loss fields %+v, want %+v", feedback.LossFields, want)
+	}
+	if feedback.CkPtFrameId != 255 {
+		t.Fatalf("checkpoint %d, want 255", feedback.CkPtFrameId)
+	}
+
+	// retries are rate-limited
+	sent := len(test.sent)
+	test.checkLoss(now.Add(nackInterval / 2))
+	if len(test.sent) != sent {
+		t.Fatal("re-requested packets before the nack interval elapsed")
+	}
+
+	test.checkLoss(now.Add(nackInterval))
+	if len(test.sent) != sent+1 {
+		t.Fatal("expected packets to be re-requested after the nack interval")
+	}
+
+	test.receive(1, 0, 1, 3, true, now)
+	test.receive(2, 0, 2, 3, true, now)
+	if !reflect.DeepEqual(test.decoded, []int{0}) {
+		t.Fatalf("decoded frames %v, want [0]", test.decoded)
+	}
+}
+
+func TestStreamRequestsMissingTailAfterTimeout(t *testing.T) {
+	test := newTestStream()
+	now := time.Now()
+
+	test.receive(0, 0, 0, 2, true, now)
+
+	test.checkLoss(now)
+	if len(test.sent) != 0 {
+		t.Fatal("requested packets that may still be in flight")
+	}
+
+	test.checkLoss(now.Add(nackInterval))
+	feedback, _ := test.lastFeedback(t)
+	want := []CastLossField{{FrameId: 0, PacketId: 1, Bitmask: 0x01}}
+	if !reflect.DeepEqual(feedback.LossFields, want) {
+		t.Fatalf("loss fields %+v, want %+v", feedback.LossFields, want)
+	}
+}
+
+func TestStreamReportsEntirelyLostFrames(t *testing.T) {
+	test := newTestStream()
+	now := time.Now()
+
+	test.receive(0, 0, 0, 0, true, now)
+	test.receive(3, 3, 0, 0, false, now)
+
+	test.checkLoss(now)
+	feedback, _ := test.lastFeedback(t)
+	want := []CastLossField{
+		{FrameId: 1, PacketId: AllPacketsLost},
+		{FrameId: 2, PacketId: AllPacketsLost},
+	}
+	if !reflect.DeepEqual(feedback.LossFields, want) {
+		t.Fatalf("loss fields %+v, want %+v", feedback.LossFields, want)
+	}
+	if feedback.CkPtFrameId != 0 {
+		t.Fatalf("checkpoint %d, want 0", feedback.CkPtFrameId)
+	}
+}
+
+func TestLossFieldsForPacketsUsesBitmask(t *testing.T) {
+	got := lossFieldsForPackets(4, []uint16{1, 2, 9, 10, 20})
+	want := []CastLossField{
+		{FrameId: 4, PacketId: 1, Bitmask: 0x81},
+		{FrameId: 4, PacketId: 10},
+		{FrameId: 4, PacketId: 20},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("loss fields %+v, want %+v", got, want)
+	}
+}
+
+// exhaustRetries advances time until the stream gives up on frames it cannot
+// recover.
+func (test *testStream) exhaustRetries(now time.Time) time.Time {
+	for i := 0; i <= maxNackAttempts; i++ {
+		now = now.Add(nackInterval)
+		test.checkLoss(now)
+	}
+	return now
+}
+
+func TestStreamSkipsToNextKeyframeWhenRecoveryFails(t *testing.T) {
+	test := newTestStream()
+	now := time.Now()
+
+	test.receive(0, 0, 0, 0, true, now)
+	test.receive(1, 1, 0, 1, false, now)
+	test.receive(3, 2, 0, 0, false, now)
+	test.receive(4, 3, 0, 0, true, now)
+	test.receive(5, 4, 0, 0, false, now)
+
+	now = test.exhaustRetries(now)
+
+	if !reflect.DeepEqual(test.decoded, []int{0, 3, 4}) {
+		t.Fatalf("decoded frames %v, want [0 3 4]", test.decoded)
+	}
+
+	feedback, pli := test.lastFeedback(t)
+	if feedback.CkPtFrameId != 4 {
+		t.Fatalf("checkpoint %d, want 4", feedback.CkPtFrameId)
+	}
+	if pli {
+		t.Fatal("requested a keyframe although one was available")
+	}
+
+	// packets for skipped frames are ignored
+	test.receive(2, 1, 1, 1, false, now)
+	if !reflect.DeepEqual(test.decoded, []int{0, 3, 4}) {
+		t.Fatalf("decoded frames %v after late packet", test.decoded)
+	}
+}
+
+func TestStreamRequestsKeyframeWhenRecoveryFails(t *testing.T) {
+	test := newTestStream()
+	now := time.Now()
+
+	test.receive(0, 0, 0, 0, true, now)
+	test.receive(1, 1, 0, 1, false, now)
+	test.receive(3, 2, 0, 0, false, now)
+
+	now = test.exhaustRetries(now)
+
+	_, pli := test.lastFeedback(t)
+	if !pli {
+		t.Fatal("expected a keyframe request")
+	}
+
+	// keyframe requests are rate-limited
+	sent := len(test.sent)
+	test.checkLoss(now.Add(lossCheckInterval))
+	if len(test.sent) != sent {
+		t.Fatal("repeated keyframe request too soon")
+	}
+
+	// non-keyframes are dropped until the keyframe arrives
+	test.receive(4, 3, 0, 0, false, now)
+	test.receive(5, 4, 0, 0, true, now)
+	test.receive(6, 5, 0, 0, false, now)
+	if !reflect.DeepEqual(test.decoded, []int{0, 4, 5}) {
+		t.Fatalf("decoded frames %v, want [0 4 5]", test.decoded)
+	}
+
+	feedback, pli := test.lastFeedback(t)
+	if feedback.CkPtFrameId != 5 || pli {
+		t.Fatalf("unexpected feedback after resync: %+v pli=%t", feedback, pli)
+	}
+}
+
+func TestStreamReceiverReportUsesSenderReportTiming(t *testing.T) {
+	test := newTestStream()
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1234}
+	now := time.Now()
+
+	senderReport := &rtcp.SenderReport{SSRC: 1, NTPTime: 0x1122334455667788, RTPTime: 1000}
+	test.handleRtcpPackets([]rtcp.Packet{senderReport}, addr, now)
+	test.sendFeedback(now.Add(500*time.Millisecond), nil)
+
+	// pion cannot parse the cast feedback that follows, so only decode the
+	// leading receiver report
+	raw := test.sent[len(test.sent)-1]
+	var header rtcp.Header
+	if err := header.Unmarshal(raw); err != nil {
+		t.Fatal(err)
+	}
+	var receiverReport rtcp.ReceiverReport
+	if err := receiverReport.Unmarshal(raw[:(int(header.Length)+1)*4]); err != nil {
+		t.Fatal(err)
+	}
+	if len(receiverReport.Reports) != 1 {
+		t.Fatalf("%d reception reports, want 1", len(receiverReport.Reports))
+	}
+
+	report := receiverReport.Reports[0]
+	if report.LastSenderReport != 0x33445566 {
+		t.Fatalf("last sender report %x, want 33445566", report.LastSenderReport)
+	}
+	if report.Delay != 65536/2 {
+		t.Fatalf("delay %d, want %d", report.Delay, 65536/2)
+	}
+}
+
+func TestStreamHoldsFramesUntilPlayoutTime(t *testing.T) {
+	test := newTestStreamWithDelay(100 * time.Millisecond)
+	now := time.Now()
+
+	// frames are 33ms apart, but arrive in a burst
+	test.receiveWithTimestamp(0, 0, 0, 0, true, 0, now)
+	test.receiveWithTimestamp(1, 1, 0, 0, false, 3000, now.Add(5*time.Millisecond))
+	if len(test.decoded) != 0 {
+		t.Fatalf("decoded frames %v before their playout time", test.decoded)
+	}
+
+	// frames are acknowledged once complete, regardless of playout
+	feedback, _ := test.lastFeedback(t)
+	if feedback.CkPtFrameId != 1 || feedback.TargetPlayoutDelay != 100 {
+		t.Fatalf("unexpected feedback %+v", feedback)
+	}
+
+	test.playFrames(now.Add(100 * time.Millisecond))
+	if !reflect.DeepEqual(test.rendered, []int{0}) {
+		t.Fatalf("rendered frames %v, want [0]", test.rendered)
+	}
+
+	test.playFrames(now.Add(133 * time.Millisecond))
+	if !reflect.DeepEqual(test.rendered, []int{0, 1}) {
+		t.Fatalf("rendered frames %v, want [0 1]", test.rendered)
+	}
+}
+
+func TestStreamDecodesLateFramesWithoutRendering(t *testing.T) {
+	test := newTestStreamWithDelay(100 * time.Millisecond)
+	now := time.Now()
+
+	test.receiveWithTimestamp(0, 0, 0, 0, true, 0, now)
+	test.receiveWithTimestamp(1, 1, 0, 0, false, 3000, now)
+
+	// frames that are already late when playout is checked are not rendered
+	test.receiveWithTimestamp(2, 2, 0, 0, false, 90000, now.Add(900*time.Millisecond))
+	if !reflect.DeepEqual(test.decoded, []int{0, 1}) {
+		t.Fatalf("decoded frames %v, want [0 1]", test.decoded)
+	}
+	if len(test.rendered) != 0 {
+		t.Fatalf("rendered late frames %v", test.rendered)
+	}
+
+	test.playFrames(now.Add(time.Second))
+	if !reflect.DeepEqual(test.rendered, []int{2}) {
+		t.Fatalf("rendered frames %v, want [2]", test.rendered)
+	}
+}
+
+func TestStreamStatsCountPlayout(t *testing.T) {
+	test := newTestStreamWithDelay(100 * time.Millisecond)
+	now := time.Now()
+
+	// frames 0 and 1 are late and frame 2 is rendered. Sequence number 2 is
+	// lost, but the duplicate of sequence number 3 hides the loss.
+	test.receiveWithTimestamp(0, 0, 0, 0, true, 0, now)
+	test.receiveWithTimestamp(1, 1, 0, 0, false, 3000, now)
+	test.receiveWithTimestamp(3, 2, 0, 0, false, 90000, now.Add(900*time.Millisecond))
+	test.receiveWithTimestamp(3, 2, 0, 0, false, 90000, now.Add(900*time.Millisecond))
+	test.playFrames(now.Add(time.Second))
+
+	stats := test.stats(now.Add(time.Second))
+	expected := StreamStats{
+		Bitrate:           stats.Bitrate,
+		BytesReceived:     stats.BytesReceived,
+		FramesDecoded:     3,
+		FramesDropped:     2,
+		FramesRendered:    1,
+		Jitter:            stats.Jitter,
+		PacketsDuplicated: 1,
+		PacketsLost:       0,
+		PacketsReceived:   4,
+		PlayoutDelay:      100 * time.Millisecond,
+		SSRC:              1,
+	}
+	if stats != expected {
+		t.Fatalf("stats %+v, want %+v", stats, expected)
+	}
+	if stats.BytesReceived == 0 || stats.Jitter == 0 {
+		t.Fatalf("expected bytes and jitter to be measured, got %+v", stats)
+	}
+}
+
+func TestStreamStatsCountDecodeErrorsAndSkippedFrames(t *testing.T) {
+	test := newTestStream()
+	test.decodeErr = errors.New("corrupt frame")
+	now := time.Now()
+
+	test.receive(0, 0, 0, 0, true, now)
+	test.decodeErr = nil
+
+	// frames 1 and 2 are lost, and are skipped when keyframe 3 arrives
+	test.waitingForKeyframe = true
+	test.receive(1, 3, 0, 0, true, now)
+
+	stats := test.stats(now)
+	if stats.DecodeErrors != 1 || stats.FramesDecoded != 2 || stats.FramesRendered != 1 || stats.FramesDropped != 3 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestBitrateMeterMeasuresCompleteIntervals(t *testing.T) {
+	var meter bitrateMeter
+	now := time.Now()
+
+	meter.add(1000, now)
+	meter.add(1500, now.Add(500*time.Millisecond))
+	if rate := meter.rate(now.Add(900 * time.Millisecond)); rate != 0 {
+		t.Fatalf("expected no rate before the first interval completes, got %v", rate)
+	}
+
+	meter.add(500, now.Add(time.Second))
+	if rate := meter.rate(now.Add(time.Second)); rate != 20000 {
+		t.Fatalf("expected 20000 bits per second, got %v", rate)
+	}
+
+	if rate := meter.rate(now.Add(3 * time.Second)); rate != 2000 {
+		t.Fatalf("expected 2000 bits per second, got %v", rate)
+	}
+
+	// an interval without data reports zero
+	if rate := meter.rate(now.Add(4 * time.Second)); rate != 0 {
+		t.Fatalf("expected 0 bits per second, got %v", rate)
+	}
+}
+
+func TestStreamUsesSenderReportForPlayout(t *testing.T) {
+	test := newTestStreamWithDelay(100 * time.Millisecond)
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1234}
+	now := time.Now()
+
+	// the sender's clock is 10s behind, and rtp timestamp 9000 was captured
+	// 20ms before the sender report arrived
+	senderReport := &rtcp.SenderReport{
+		SSRC:    1,
+		NTPTime: toNtpTime(now.Add(-10 * time.Second)),
+		RTPTime: 9000 + 1800,
+	}
+	test.handleRtcpPackets([]rtcp.Packet{senderReport}, addr, now)
+
+	test.receiveWithTimestamp(0, 0, 0, 0, true, 9000, now)
+	test.playFrames(now.Add(79 * time.Millisecond))
+	if len(test.decoded) != 0 {
+		t.Fatalf("decoded frames %v before their playout time", test.decoded)
+	}
+
+	test.playFrames(now.Add(80 * time.Millisecond))
+	if !reflect.DeepEqual(test.rendered, []int{0}) {
+		t.Fatalf("rendered frames %v, want [0]", test.rendered)
+	}
+}
+
+func TestStreamFollowsRequestedPlayoutDelay(t *testing.T) {
+	test := newTestStreamWithDelay(100 * time.Millisecond)
+	now := time.Now()
+
+	payload := []byte{0x81, 0, 0, 0, 0, 0, adaptiveLatencyExtType << 2, 2, 0x01, 0x2c, 0xaa}
+	packet := &rtp.Packet{Header: rtp.Header{SSRC: 1}, Payload: payload}
+	test.handleDataPacket(packet, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1234}, now)
+
+	feedback, _ := test.lastFeedback(t)
+	if feedback.TargetPlayoutDelay != 300 {
+		t.Fatalf("playout delay %d, want 300", feedback.TargetPlayoutDelay)
+	}
+
+	test.playFrames(now.Add(299 * time.Millisecond))
+	if len(test.decoded) != 0 {
+		t.Fatal("played frame before the requested playout delay")
+	}
+}
+
+func TestStreamRequestsKeyframeOnDemand(t *testing.T) {
+	test := newTestStream()
+	now := time.Now()
+
+	test.receive(0, 0, 0, 0, true, now)
+	test.requestKeyframe(now)
+
+	_, pli := test.lastFeedback(t)
+	if !pli {
+		t.Fatal("expected a keyframe request")
+	}
+
+	// the request is not repeated, since the stream is not waiting for a
+	// keyframe
+	test.receive(1, 1, 0, 0, false, now)
+	if _, pli := test.lastFeedback(t); pli {
+		t.Fatal("keyframe request repeated")
+	}
+}
+
+func TestClosedStreamIgnoresPackets(t *testing.T) {
+	test := newTestStreamWithDelay(time.Second)
+	now := time.Now()
+
+	test.receive(0, 0, 0, 0, true, now)
+	test.close()
+	if test.hasReadyFrames() {
+		t.Fatal("closed stream kept ready frames")
+	}
+
+	sent := len(test.sent)
+	test.receive(1, 1, 0, 0, false, now)
+	test.playFrames(now.Add(2 * time.Second))
+	test.checkLoss(now.Add(2 * time.Second))
+	test.requestKeyframe(now)
+
+	if len(test.decoded) != 0 {
+		t.Fatalf("closed stream decoded frames %v", test.decoded)
+	}
+	if len(test.sent) != sent {
+		t.Fatal("closed stream sent rtcp")
+	}
+}
@@ -0,0 +1,248 @@
+package session
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// WebM is a subset of Matroska, which is built from EBML elements. Each
+// element is an ID, followed by a variable length size and then its data.
+// Recordings are written as a live stream: the segment and its clusters have
+// an unknown size, and there are no cues. Each frame is written as soon as it
+// arrives, so a recording can be played back up to its last frame even if the
+// receiver exits without closing it.
+
+const (
+	ebmlHeaderId         = 0x1a45dfa3
+	ebmlVersionId        = 0x4286
+	ebmlReadVersionId    = 0x42f7
+	ebmlMaxIdLengthId    = 0x42f2
+	ebmlMaxSizeLengthId  = 0x42f3
+	ebmlDocTypeId        = 0x4282
+	ebmlDocTypeVersionId = 0x4287
+	ebmlDocTypeReadId    = 0x4285
+
+	segmentId           = 0x18538067
+	infoId              = 0x1549a966
+	timecodeScaleId     = 0x2ad7b1
+	muxingAppId         = 0x4d80
+	writingAppId        = 0x5741
+	tracksId            = 0x1654ae6b
+	trackEntryId        = 0xae
+	trackNumberId       = 0xd7
+	trackUidId          = 0x73c5
+	trackTypeId         = 0x83
+	codecId             = 0x86
+	codecPrivateId      = 0x63a2
+	videoId             = 0xe0
+	pixelWidthId        = 0xb0
+	pixelHeightId       = 0xba
+	audioId             = 0xe1
+	samplingFrequencyId = 0xb5
+	channelsId          = 0x9f
+	clusterId           = 0x1f43b675
+	timecodeId          = 0xe7
+	simpleBlockId       = 0xa3
+
+	// ebmlUnknownSize marks an element whose size is not known in advance.
+	ebmlUnknownSize = 0x01ffffffffffffff
+
+	videoTrackNumber = 1
+	audioTrackNumber = 2
+
+	matroskaVideoTrackType = 1
+	matroskaAudioTrackType = 2
+
+	// webmTimecodeScale makes block timecodes count milliseconds.
+	webmTimecodeScale = 1000000
+
+	// maxClusterDuration keeps block timecodes within their signed 16-bit
+	// offset from the cluster timecode.
+	maxClusterDuration = math.MaxInt16
+
+	muxingApp = "go-cast"
+)
+
+func ebmlId(id uint32) []byte {
+	switch {
+	case id > 0xffffff:
+		return []byte{byte(id >> 24), byte(id >> 16), byte(id >> 8), byte(id)}
+	case id > 0xffff:
+		return []byte{byte(id >> 16), byte(id >> 8), byte(id)}
+	case id > 0xff:
+		return []byte{byte(id >> 8), byte(id)}
+	default:
+		return []byte{byte(id)}
+	}
+}
+
+// ebmlSize encodes a size as a variable length integer, using the shortest
+// encoding that does not collide with the reserved all-ones value.
+func ebmlSize(size uint64) []byte {
+	if size == ebmlUnknownSize {
+		return []byte{0x01, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	}
+
+	length := 1
+	for length < 8 && size >= (1<<(7*length))-1 {
+		length++
+	}
+
+	encoded := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		encoded[i] = byte(size)
+		size >>= 8
+	}
+	encoded[0] |= 0x80 >> (length - 1)
+
+	return encoded
+}
+
+func ebmlElement(id uint32, data ...[]byte) []byte {
+	size := 0
+	for _, part := range data {
+		size += len(part)
+	}
+
+	element := append(ebmlId(id), ebmlSize(uint64(size))...)
+	for _, part := range data {
+		element = append(element, part...)
+	}
+
+	return element
+}
+
+func ebmlUint(id uint32, value uint64) []byte {
+	length := 1
+	for length < 8 && value>>(8*length) != 0 {
+		length++
+	}
+
+	data := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		data[i] = byte(value)
+		value >>= 8
+	}
+
+	return ebmlElement(id, data)
+}
+
+func ebmlFloat(id uint32, value float64) []byte {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, math.Float64bits(value))
+	return ebmlElement(id, data)
+}
+
+func ebmlString(id uint32, value string) []byte {
+	return ebmlElement(id, []byte(value))
+}
+
+// opusHead builds the Opus identification header that Matroska stores as the
+// codec private data for Opus tracks.
+func opusHead(channels int, sampleRate uint32) []byte {
+	head := make([]byte, 19)
+	copy(head, "OpusHead")
+	head[8] = 1
+	head[9] = byte(channels)
+	binary.LittleEndian.PutUint32(head[12:], sampleRate)
+	return head
+}
+
+// webmWriter writes a VP8 video track and optional Opus audio track to a WebM
+// file. Clusters are written with an unknown size, so that blocks can be
+// written without buffering them.
+type webmWriter struct {
+	clusterTimecode int64
+	hasCluster      bool
+	output          io.Writer
+}
+
+func newWebmWriter(output io.Writer, width int, height int, audio *AudioTrack) (*webmWriter, error) {
+	header := ebmlElement(ebmlHeaderId,
+		ebmlUint(ebmlVersionId, 1),
+		ebmlUint(ebmlReadVersionId, 1),
+		ebmlUint(ebmlMaxIdLengthId, 4),
+		ebmlUint(ebmlMaxSizeLengthId, 8),
+		ebmlString(ebmlDocTypeId, "webm"),
+		ebmlUint(ebmlDocTypeVersionId, 2),
+		ebmlUint(ebmlDocTypeReadId, 2))
+
+	segment := append(ebmlId(segmentId), ebmlSize(ebmlUnknownSize)...)
+
+	info := ebmlElement(infoId,
+		ebmlUint(timecodeScaleId, webmTimecodeScale),
+		ebmlString(muxingAppId, muxingApp),
+		ebmlString(writingAppId, muxingApp))
+
+	entries := [][]byte{
+		ebmlElement(trackEntryId,
+			ebmlUint(trackNumberId, videoTrackNumber),
+			ebmlUint(trackUidId, videoTrackNumber),
+			ebmlUint(trackTypeId, matroskaVideoTrackType),
+			ebmlString(codecId, "V_VP8"),
+			ebmlElement(videoId,
+				ebmlUint(pixelWidthId, uint64(width)),
+				ebmlUint(pixelHeightId, uint64(height)))),
+	}
+	if audio != nil {
+		entries = append(entries, ebmlElement(trackEntryId,
+			ebmlUint(trackNumberId, audioTrackNumber),
+			ebmlUint(trackUidId, audioTrackNumber),
+			ebmlUint(trackTypeId, matroskaAudioTrackType),
+			ebmlString(codecId, "A_OPUS"),
+			ebmlElement(codecPrivateId, opusHead(audio.Channels, audio.ClockRate)),
+			ebmlElement(audioId,
+				ebmlFloat(samplingFrequencyId, float64(audio.ClockRate)),
+				ebmlUint(channelsId, uint64(audio.Channels)))))
+	}
+	tracks := ebmlElement(tracksId, entries...)
+
+	for _, part := range [][]byte{header, segment, info, tracks} {
+		if _, err := output.Write(part); err != nil {
+			return nil, fmt.Errorf("write webm header: %w", err)
+		}
+	}
+
+	return &webmWriter{output: output}, nil
+}
+
+// writeBlock writes a frame in the current cluster, starting a new cluster at
+// each video keyframe or when the timecode no longer fits in the block.
+func (writer *webmWriter) writeBlock(trackNumber uint8, data []byte, keyframe bool, timecode int64) error {
+	offset := timecode - writer.clusterTimecode
+	startCluster := !writer.hasCluster ||
+		(trackNumber == videoTrackNumber && keyframe) ||
+		offset < math.MinInt16 || offset > maxClusterDuration
+
+	var element []byte
+	if startCluster {
+		writer.clusterTimecode = max(timecode, 0)
+		writer.hasCluster = true
+		offset = timecode - writer.clusterTimecode
+
+		element = append(ebmlId(clusterId), ebmlSize(ebmlUnknownSize)...)
+		element = append(element, ebmlUint(timecodeId, uint64(writer.clusterTimecode))...)
+	}
+
+	block := make([]byte, 4, 4+len(data))
+	block[0] = 0x80 | trackNumber
+	binary.BigEndian.PutUint16(block[1:], uint16(int16(offset)))
+	if keyframe {
+		block[3] = 0x80
+	}
+	block = append(block, data...)
+
+	element = append(element, ebmlElement(simpleBlockId, block)...)
+	if _, err := writer.output.Write(element); err != nil {
+		return fmt.Errorf("write webm block: %w", err)
+	}
+	return nil
+}
+
+// close finishes the recording. Every block has already been written, and
+// elements of unknown size need no end marker, so there is nothing left to do.
+func (writer *webmWriter) close() error {
+	return nil
+}